@@ -8,14 +8,17 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -29,18 +32,27 @@ import (
 
 	"kai-core/diff"
 	"kai-core/merge"
+	"kai/internal/apidiff"
+	"kai/internal/blame"
+	"kai/internal/changedlines"
 	"kai/internal/classify"
+	"kai/internal/codeowners"
 	"kai/internal/dirio"
 	"kai/internal/explain"
+	"kai/internal/export"
 	"kai/internal/filesource"
 	"kai/internal/gitio"
 	"kai/internal/graph"
 	"kai/internal/intent"
+	"kai/internal/junit"
+	"kai/internal/layers"
 	"kai/internal/module"
 	"kai/internal/parse"
+	"kai/internal/query"
 	"kai/internal/ref"
 	"kai/internal/remote"
 	"kai/internal/review"
+	"kai/internal/runner"
 	"kai/internal/snapshot"
 	"kai/internal/status"
 	"kai/internal/util"
@@ -201,6 +213,123 @@ Use this to enable selective CI testing based on which files changed.`,
 	RunE: runAnalyzeCalls,
 }
 
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the codebase against architecture rules",
+}
+
+var checkLayersCmd = &cobra.Command{
+	Use:   "layers",
+	Short: "Check module layering rules and import cycles",
+	Long: `Evaluate the layering rules in .kai/rules/modules.yaml against the
+IMPORTS graph of a snapshot and report offending file pairs.
+
+Rules are declared next to the modules:
+
+  layers:
+    noCycles: true        # no import cycles between modules
+    rules:
+      - from: ui
+        deny: [db]        # ui may not import db
+
+With --base, only violations that are new relative to the base snapshot are
+reported, so CI fails on newly introduced violations only.
+
+Exit codes:
+  0 - No (new) violations
+  1 - Violations found or error
+
+Examples:
+  kai check layers
+  kai check layers --base @snap:prev --snapshot @snap:last
+  kai check layers --json`,
+	SilenceUsage: true, // violations are not usage errors
+	RunE:         runCheckLayers,
+}
+
+var apiCmd = &cobra.Command{
+	Use:   "api",
+	Short: "Inspect the public API of a library",
+}
+
+var apiDiffCmd = &cobra.Command{
+	Use:   "diff <base> [head]",
+	Short: "Report public API changes between two snapshots",
+	Long: `Compare the exported API surface of two snapshots and classify each change
+as breaking, additive or patch, then suggest the next version.
+
+The surface is built from the symbols of each snapshot:
+  Go      - exported identifiers, methods, struct fields and interface methods
+            (internal/ packages and package main are skipped)
+  Python  - names in __all__, or names without a leading underscore
+  Rust    - pub items, fields, enum variants and inherent methods
+  JS/TS   - exported names and members of exported classes
+
+Removed symbols and changed signatures are breaking, as are methods added to
+interfaces or traits and variants added to Rust enums. Other additions are
+additive, and implementation-only changes are patch.
+
+The current version is read from package.json, Cargo.toml or pyproject.toml
+(under --path) in the base snapshot, the last release being compared, or
+given with --current.
+
+Exit codes:
+  0 - No changes at or above the --fail-on level
+  1 - Changes at or above the --fail-on level, or error
+
+Examples:
+  kai api diff @snap:prev @snap:last
+  kai api diff @snap:prev --fail-on breaking
+  kai api diff v1 v2 --path crates/core --current 0.9.3 --json`,
+	Args:         cobra.RangeArgs(1, 2),
+	SilenceUsage: true, // breaking changes are not usage errors
+	RunE:         runAPIDiff,
+}
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Inspect and export the semantic graph",
+}
+
+var graphExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a snapshot's subgraph as DOT, GraphML or JSON Lines",
+	Long: `Export the files, symbols and modules of a snapshot and the edges between
+them for visualization or offline analysis.
+
+DOT output groups files and symbols into one cluster per module, using the
+module rules in .kai/rules/modules.yaml.
+
+Examples:
+  kai graph export --format dot --edges CALLS,IMPORTS | dot -Tsvg > graph.svg
+  kai graph export --snapshot @snap:prev --format graphml -o graph.graphml
+  kai graph export --format jsonl --kinds File --edges IMPORTS`,
+	RunE: runGraphExport,
+}
+
+var queryCmd = &cobra.Command{
+	Use:   "query <query>",
+	Short: "Query the semantic graph with a pipeline language",
+	Long: `Query the semantic graph of a snapshot with a small pipeline language.
+
+A query starts with a source and is refined by stages separated by "|":
+
+  Sources:     files, symbols, modules
+  Traversals:  callers [depth N], callees [depth N], imports [depth N],
+               importers [depth N], tests, files, symbols
+  Filters:     where <cond> [and <cond>...], limit N
+
+Conditions compare a field with =, != or glob-match it with ~ / !~.
+Fields: name, kind, path (alias file), lang, or any payload key.
+
+Examples:
+  kai query 'symbols where kind=function and file~"auth/**" | callers depth 2 | tests'
+  kai query 'files where path=src/db.ts | importers depth 3' --json
+  kai query 'symbols where name=validateSession | tests' --sql`,
+	Args: cobra.ExactArgs(1),
+	RunE: runQuery,
+}
+
 var testCmd = &cobra.Command{
 	Use:   "test",
 	Short: "Test-related commands",
@@ -289,6 +418,31 @@ Examples:
 	RunE: runCIPrint,
 }
 
+var ciCommandCmd = &cobra.Command{
+	Use:   "command",
+	Short: "Print test runner commands for a selection plan",
+	Long: `Translates a plan file into command lines for a test runner, one per
+line, ready to run in a CI step. Arguments are quoted for POSIX shells.
+
+Selected files narrowed to test cases run only those cases where the
+runner supports it. When the plan is full, expanded or shadow, or
+recommends a full run, the command runs the whole suite instead.
+
+Runners:
+  go      - go test per package, with -run for narrowed cases
+  jest    - npx jest --ci --runTestsByPath, with -t for narrowed cases
+  vitest  - npx vitest run, with -t for narrowed cases
+  pytest  - pytest with files and file::case node IDs
+  rspec   - bundle exec rspec with spec files
+  cargo   - cargo test with --lib and --test targets per crate
+
+Examples:
+  kai ci command --plan plan.json --runner go
+  kai ci command --plan plan.json --runner pytest | sh
+  kai ci plan @cs:last --emit jest   # Plan and emit in one step`,
+	RunE: runCICommand,
+}
+
 var ciDetectRuntimeRiskCmd = &cobra.Command{
 	Use:   "detect-runtime-risk",
 	Short: "Analyze test logs for runtime risk signals (tripwire)",
@@ -316,6 +470,11 @@ Tripwire Mode (--tripwire):
 
     kai ci detect-runtime-risk --stderr test.log --tripwire || npm run test:full
 
+Flaky Tests:
+  Failures of tests marked flaky by 'kai ci ingest-results' never trigger the
+  tripwire. A JUnit report attributes each failure to a test file, so flaky
+  tests can only be recognized in JUnit input.
+
 Examples:
   # Analyze Jest output
   kai ci detect-runtime-risk --logs ./jest-results.json
@@ -343,10 +502,34 @@ test selection algorithm.
 
 Examples:
   kai ci record-miss --plan plan.json --evidence ./test-results.json
+  kai ci record-miss --plan plan.json --evidence build/test-results/junit.xml
   kai ci record-miss --plan plan.json --failed "tests/auth.test.js,tests/api.test.js"`,
 	RunE: runCIRecordMiss,
 }
 
+var ciMissesCmd = &cobra.Command{
+	Use:   "misses",
+	Short: "Inspect recorded test selection misses",
+}
+
+var ciMissesReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Summarize the planner's historical precision and recall",
+	Long: `Aggregates the records written by 'kai ci record-miss' to
+.kai/ci-misses.jsonl.
+
+Recall is the share of failing tests that the plan had selected; precision is
+the share of selected tests that failed. Also lists the most missed tests and
+the changed file → test mappings learned from misses, which 'kai ci plan'
+adds to future plans (see the misses section of ci-policy.yaml).
+
+Examples:
+  kai ci misses report
+  kai ci misses report --since 30
+  kai ci misses report --json`,
+	RunE: runCIMissesReport,
+}
+
 var ciExplainDynamicImportsCmd = &cobra.Command{
 	Use:   "explain-dynamic-imports [path]",
 	Short: "Analyze and explain dynamic imports in a file or directory",
@@ -377,6 +560,12 @@ Supported formats:
   - NYC/Istanbul JSON (coverage-final.json)
   - coverage.py JSON
   - JaCoCo XML
+  - Go coverprofile (go test -coverprofile)
+  - LCOV (lcov.info; TN: records name the test)
+  - Cobertura XML
+
+Reports that aggregate a whole suite are recorded under the "aggregate" test.
+Use --test when a report comes from a single test (e.g. a go test -run loop).
 
 The coverage map is stored in .kai/coverage-map.json and used during
 plan generation when coverage.enabled=true in ci-policy.yaml.
@@ -391,11 +580,76 @@ Examples:
   # Ingest JaCoCo XML
   kai ci ingest-coverage --from build/reports/jacoco.xml --format jacoco
 
+  # Ingest per-test Go profiles
+  for t in $(go test -list . ./pkg/auth | grep ^Test); do
+    go test -run "^$t$" -coverpkg=./... -coverprofile=cover.out ./pkg/auth
+    kai ci ingest-coverage --from cover.out --format go --test "pkg/auth/$t"
+  done
+
+  # Ingest LCOV with per-test TN: records
+  kai ci ingest-coverage --from coverage/lcov.info
+
   # Tag with branch and run ID
   kai ci ingest-coverage --from coverage.json --branch main --tag nightly-2025-12-06`,
 	RunE: runCIIngestCoverage,
 }
 
+var ciIngestTimingsCmd = &cobra.Command{
+	Use:   "ingest-timings",
+	Short: "Ingest test durations from JUnit XML for sharding",
+	Long: `Records how long each test file or class took, from JUnit XML reports
+(Gradle, Maven Surefire, jest-junit, pytest --junitxml, rspec_junit_formatter,
+go-junit-report, cargo-nextest). Durations are averaged over recent runs and
+used by 'kai ci plan --shards' to balance shards.
+
+Test cases are grouped by their file attribute, or by classname when the
+report has none; plans map them back to test files.
+
+The timings are stored in .kai/test-timings.json, next to the coverage map.
+
+Examples:
+  kai ci ingest-timings --from junit.xml
+  kai ci ingest-timings --from reports/shard-0.xml --from reports/shard-1.xml`,
+	RunE: runCIIngestTimings,
+}
+
+var ciIngestResultsCmd = &cobra.Command{
+	Use:   "ingest-results",
+	Short: "Record test outcomes from JUnit XML to track flaky tests",
+	Long: `Records whether each test file passed or failed, from JUnit XML reports,
+together with the inputs it ran on: a commit SHA, or the head snapshot of
+--plan. A test that both passed and failed on the same inputs is flaky.
+
+A test is marked flaky once its flake rate reaches flaky.threshold in
+ci-policy.yaml. The flake rate is the share of inputs run more than once on
+which the test both passed and failed. Failures of flaky tests don't trigger
+'kai ci detect-runtime-risk' tripwires, aren't learned as misses, and are
+listed by 'kai ci print --section safety'.
+
+The ledger is stored in .kai/flaky-tests.json.
+
+Examples:
+  kai ci ingest-results --from junit.xml --inputs "$GITHUB_SHA"
+  kai ci ingest-results --from reports/shard-0.xml --from reports/shard-1.xml --plan plan.json`,
+	RunE: runCIIngestResults,
+}
+
+var ciShardCmd = &cobra.Command{
+	Use:   "shard",
+	Short: "Print one shard of a sharded plan",
+	Long: `Prints the test files assigned to one shard of a plan generated with
+'kai ci plan --shards N', one per line. With --runner, prints the runner
+commands for the shard instead (see 'kai ci command').
+
+Shard indexes are 0-based.
+
+Examples:
+  kai ci plan @cs:last --shards 4 --out plan.json
+  kai ci shard --plan plan.json --index $CI_NODE_INDEX
+  kai ci shard --plan plan.json --index 2 --runner jest | sh`,
+	RunE: runCIShard,
+}
+
 var ciIngestContractsCmd = &cobra.Command{
 	Use:   "ingest-contracts",
 	Short: "Register contract schemas and their associated tests",
@@ -482,6 +736,7 @@ var (
 	ciExplain    bool   // Output human-readable explanation
 	ciGitRange   string // BASE..HEAD format for git-based CI plan
 	ciGitRepo    string // Git repo path for --git-range
+	ciEmit       string // Runner to emit commands for (plan --emit, command --runner)
 	ciPlanFile   string
 	ciSection    string
 	// detect-runtime-risk flags
@@ -493,11 +748,23 @@ var (
 	// record-miss flags
 	ciEvidenceFile string
 	ciFailedTests  string
+	// misses report flags
+	ciMissesSinceDays int
+	ciMissesJSON      bool
 	// ingest-coverage flags
 	ciCoverageFrom   string
 	ciCoverageFormat string
 	ciCoverageBranch string
 	ciCoverageTag    string
+	ciCoverageTest   string
+	// ingest-timings flags
+	ciTimingsFrom []string
+	// ingest-results flags
+	ciResultsFrom   []string
+	ciResultsInputs string
+	// sharding flags
+	ciShards     int
+	ciShardIndex int
 	// ingest-contracts flags
 	ciContractType      string
 	ciContractPath      string
@@ -600,7 +867,34 @@ Example:
 var logCmd = &cobra.Command{
 	Use:   "log",
 	Short: "Show chronological log of snapshots and changesets",
-	RunE:  runLog,
+	Long: `Show chronological log of snapshots and changesets.
+
+With --symbol or --file, show the semantic history of a single function or
+file instead: each changeset that touched it, with before/after signatures
+and the detected change types.
+
+Examples:
+  kai log -n 5
+  kai log --symbol validateSession
+  kai log --symbol Auth.login --file src/auth.ts
+  kai log --file src/config.json --json`,
+	RunE: runLog,
+}
+
+var blameCmd = &cobra.Command{
+	Use:   "blame <file>[:symbol]",
+	Short: "Show which changeset last changed each symbol",
+	Long: `Show, for each symbol in a file, the most recent changeset that
+semantically modified it, along with its intent, change categories and author.
+
+Formatting-only changes (no semantic change type) are skipped.
+
+Examples:
+  kai blame src/auth/session.ts                   # Every symbol in the file
+  kai blame src/auth/session.ts:validateSession   # A single symbol
+  kai blame src/auth/session.ts --at @snap:prev   # As of an older snapshot`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBlame,
 }
 
 var statusCmd = &cobra.Command{
@@ -766,10 +1060,32 @@ Unlike line-based merge, this understands code structure and can:
 - Detect API signature conflicts when both sides change function params
 - Classify conflicts semantically (DELETE_vs_MODIFY, CONCURRENT_CREATE, etc.)
 
+On conflict, the merged file is still written, with each conflicting unit
+between markers naming the conflict kind and what each side changed:
+
+  <<<<<<< left BODY_DIVERGED Cart.Total
+  ...left version...
+  =======
+  ...right version...
+  >>>>>>> right
+
+Resolve them by editing the file, or with --resolve, which walks the
+conflicts offering each resolution (keep left, keep right, union, or the
+automatic one where there is one). Then run --continue to check that no
+markers remain.
+
 Examples:
   kai merge base.js left.js right.js --lang js
-  kai merge base.py branch1.py branch2.py --lang py --output merged.py`,
-	Args: cobra.ExactArgs(3),
+  kai merge base.py branch1.py branch2.py --lang py --output merged.py
+  kai merge base.go ours.go theirs.go --output merged.go
+  kai merge base.go ours.go theirs.go --output merged.go --resolve
+  kai merge --continue merged.go`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if mergeContinue {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(3)(cmd, args)
+	},
 	RunE: runMerge,
 }
 
@@ -1285,6 +1601,28 @@ var (
 	reviewExplain    bool
 	reviewBase       string
 
+	checkLayersSnap string
+	checkLayersBase string
+	checkLayersJSON bool
+
+	apiDiffFailOn  string
+	apiDiffJSON    bool
+	apiDiffCurrent string
+	apiDiffPath    string
+
+	graphExportSnap   string
+	graphExportFormat string
+	graphExportKinds  string
+	graphExportEdges  string
+	graphExportOut    string
+
+	queryAt   string
+	queryJSON bool
+	querySQL  bool
+
+	blameAt   string
+	blameJSON bool
+
 	statusDir      string
 	statusAgainst  string
 	statusNameOnly bool
@@ -1292,6 +1630,9 @@ var (
 	statusSemantic bool
 	statusExplain  bool
 	logLimit       int
+	logSymbol      string
+	logFile        string
+	logJSON        bool
 	repoPath      string
 	dirPath       string
 	editText       string
@@ -1354,9 +1695,11 @@ var (
 	fetchExplain   bool
 
 	// Merge flags
-	mergeLang   string
-	mergeOutput string
-	mergeJSON   bool
+	mergeLang     string
+	mergeOutput   string
+	mergeJSON     bool
+	mergeResolve  bool
+	mergeContinue bool
 
 	// Modules flags
 	modulesInfer     bool
@@ -1379,6 +1722,26 @@ func init() {
 	intentRenderCmd.Flags().BoolVar(&regenerateIntent, "regenerate", false, "Force regenerate intent (ignore saved)")
 	dumpCmd.Flags().BoolVar(&jsonFlag, "json", false, "Output as JSON")
 	logCmd.Flags().IntVarP(&logLimit, "limit", "n", 10, "Number of entries to show")
+	logCmd.Flags().StringVar(&logSymbol, "symbol", "", "Show history of a symbol (fully-qualified name)")
+	logCmd.Flags().StringVar(&logFile, "file", "", "Show history of a file (or restrict --symbol to this file)")
+	logCmd.Flags().BoolVar(&logJSON, "json", false, "Output as JSON (with --symbol/--file)")
+	checkLayersCmd.Flags().StringVar(&checkLayersSnap, "snapshot", "@snap:last", "Snapshot to check")
+	checkLayersCmd.Flags().StringVar(&checkLayersBase, "base", "", "Only report violations not present in this snapshot")
+	checkLayersCmd.Flags().BoolVar(&checkLayersJSON, "json", false, "Output as JSON")
+	apiDiffCmd.Flags().StringVar(&apiDiffFailOn, "fail-on", "", "Exit 1 on changes at or above this level: breaking, additive or patch")
+	apiDiffCmd.Flags().BoolVar(&apiDiffJSON, "json", false, "Output as JSON")
+	apiDiffCmd.Flags().StringVar(&apiDiffCurrent, "current", "", "Current version (default: read from the base snapshot's package manifest)")
+	apiDiffCmd.Flags().StringVar(&apiDiffPath, "path", "", "Only consider files under this directory")
+	graphExportCmd.Flags().StringVar(&graphExportSnap, "snapshot", "@snap:last", "Snapshot to export")
+	graphExportCmd.Flags().StringVar(&graphExportFormat, "format", "dot", "Output format: dot, graphml or jsonl")
+	graphExportCmd.Flags().StringVar(&graphExportKinds, "kinds", "", "Comma-separated node kinds to include (default: File,Symbol,Module)")
+	graphExportCmd.Flags().StringVar(&graphExportEdges, "edges", "", "Comma-separated edge types to include (default: CONTAINS,DEFINES_IN,IMPORTS,CALLS,TESTS)")
+	graphExportCmd.Flags().StringVarP(&graphExportOut, "output", "o", "", "Write to file instead of stdout")
+	queryCmd.Flags().StringVar(&queryAt, "snapshot", "@snap:last", "Snapshot to query")
+	queryCmd.Flags().BoolVar(&queryJSON, "json", false, "Output as JSON")
+	queryCmd.Flags().BoolVar(&querySQL, "sql", false, "Print the compiled SQL instead of running it")
+	blameCmd.Flags().StringVar(&blameAt, "at", "@snap:last", "Snapshot whose symbols are blamed")
+	blameCmd.Flags().BoolVar(&blameJSON, "json", false, "Output as JSON")
 	statusCmd.Flags().StringVar(&statusDir, "dir", ".", "Directory to check for changes")
 	statusCmd.Flags().StringVar(&statusAgainst, "against", "", "Baseline ref/selector to compare against (default: @snap:last)")
 	statusCmd.Flags().BoolVar(&statusNameOnly, "name-only", false, "Output just paths with status prefixes (A/M/D)")
@@ -1495,9 +1858,11 @@ func init() {
 	reviewExportCmd.Flags().BoolVar(&reviewExportHTML, "html", false, "Export as HTML")
 
 	// Merge flags
-	mergeCmd.Flags().StringVar(&mergeLang, "lang", "", "Language (js, ts, py) - auto-detected from extension if not specified")
+	mergeCmd.Flags().StringVar(&mergeLang, "lang", "", "Language (js, ts, py, go) - auto-detected from extension if not specified")
 	mergeCmd.Flags().StringVarP(&mergeOutput, "output", "o", "", "Output file path (defaults to stdout)")
 	mergeCmd.Flags().BoolVar(&mergeJSON, "json", false, "Output result as JSON (includes conflicts)")
+	mergeCmd.Flags().BoolVar(&mergeResolve, "resolve", false, "Walk the conflicts interactively and write the resolved file to --output")
+	mergeCmd.Flags().BoolVar(&mergeContinue, "continue", false, "Check that no conflict markers remain in the merged file")
 
 	// Modules init flags
 	modulesInitCmd.Flags().BoolVar(&modulesInfer, "infer", false, "Auto-detect modules from source structure")
@@ -1574,10 +1939,18 @@ func init() {
 	// CI commands
 	ciCmd.AddCommand(ciPlanCmd)
 	ciCmd.AddCommand(ciPrintCmd)
+	ciCmd.AddCommand(ciCommandCmd)
 	ciCmd.AddCommand(ciDetectRuntimeRiskCmd)
 	ciCmd.AddCommand(ciRecordMissCmd)
+	ciCmd.AddCommand(ciMissesCmd)
+	ciMissesCmd.AddCommand(ciMissesReportCmd)
+	ciMissesReportCmd.Flags().IntVar(&ciMissesSinceDays, "since", 0, "Only include records from the last N days")
+	ciMissesReportCmd.Flags().BoolVar(&ciMissesJSON, "json", false, "Output as JSON")
 	ciCmd.AddCommand(ciExplainDynamicImportsCmd)
 	ciCmd.AddCommand(ciIngestCoverageCmd)
+	ciCmd.AddCommand(ciIngestTimingsCmd)
+	ciCmd.AddCommand(ciIngestResultsCmd)
+	ciCmd.AddCommand(ciShardCmd)
 	ciCmd.AddCommand(ciIngestContractsCmd)
 	ciCmd.AddCommand(ciAnnotatePlanCmd)
 	ciCmd.AddCommand(ciValidatePlanCmd)
@@ -1589,24 +1962,39 @@ func init() {
 	ciPlanCmd.Flags().BoolVar(&ciExplain, "explain", false, "Output human-readable explanation table instead of JSON")
 	ciPlanCmd.Flags().StringVar(&ciGitRange, "git-range", "", "Git range BASE..HEAD to create changeset from (e.g., main..feature)")
 	ciPlanCmd.Flags().StringVar(&ciGitRepo, "repo", ".", "Path to Git repository (used with --git-range)")
+	ciPlanCmd.Flags().IntVar(&ciShards, "shards", 0, "Split targets into N shards balanced by ingested test timings")
+	ciPlanCmd.Flags().StringVar(&ciEmit, "emit", "", "Print test commands for a runner instead of the plan: go, jest, vitest, pytest, rspec, cargo")
 	ciPrintCmd.Flags().StringVar(&ciPlanFile, "plan", "plan.json", "Path to plan file")
 	ciPrintCmd.Flags().StringVar(&ciSection, "section", "summary", "Section to display: targets, impact, summary")
+	ciCommandCmd.Flags().StringVar(&ciPlanFile, "plan", "plan.json", "Path to plan file")
+	ciCommandCmd.Flags().StringVar(&ciEmit, "runner", "", "Test runner: go, jest, vitest, pytest, rspec, cargo")
+	ciCommandCmd.MarkFlagRequired("runner")
 	// detect-runtime-risk flags
 	ciDetectRuntimeRiskCmd.Flags().StringVar(&ciLogsFile, "logs", "", "Path to test output JSON (Jest, Mocha, pytest, etc.)")
 	ciDetectRuntimeRiskCmd.Flags().StringVar(&ciStderrFile, "stderr", "", "Path to stderr/text log file")
-	ciDetectRuntimeRiskCmd.Flags().StringVar(&ciLogFormat, "format", "auto", "Log format: auto, jest, mocha, pytest, go, text")
+	ciDetectRuntimeRiskCmd.Flags().StringVar(&ciLogFormat, "format", "auto", "Log format: auto, jest, mocha, pytest, go, junit, text")
 	ciDetectRuntimeRiskCmd.Flags().StringVar(&ciPlanFile, "plan", "", "Path to plan file (for cross-reference)")
 	ciDetectRuntimeRiskCmd.Flags().BoolVar(&ciTripwire, "tripwire", false, "Tripwire mode: exit 75 if rerun needed, 0 otherwise")
-	ciDetectRuntimeRiskCmd.Flags().BoolVar(&ciRerunOnFail, "rerun-on-fail", false, "Treat any test failure as a tripwire trigger")
+	ciDetectRuntimeRiskCmd.Flags().BoolVar(&ciRerunOnFail, "rerun-on-fail", false, "Treat any test failure as a tripwire trigger, except failures of flaky tests")
 	// record-miss flags
 	ciRecordMissCmd.Flags().StringVar(&ciPlanFile, "plan", "", "Path to plan file (required)")
-	ciRecordMissCmd.Flags().StringVar(&ciEvidenceFile, "evidence", "", "Path to test results JSON")
+	ciRecordMissCmd.Flags().StringVar(&ciEvidenceFile, "evidence", "", "Path to test results (Jest, pytest or Go JSON, or JUnit XML)")
 	ciRecordMissCmd.Flags().StringVar(&ciFailedTests, "failed", "", "Comma-separated list of failed test files")
 	// ingest-coverage flags
 	ciIngestCoverageCmd.Flags().StringVar(&ciCoverageFrom, "from", "", "Path to coverage report file(s)")
-	ciIngestCoverageCmd.Flags().StringVar(&ciCoverageFormat, "format", "auto", "Coverage format: auto, nyc, coveragepy, jacoco")
+	ciIngestCoverageCmd.Flags().StringVar(&ciCoverageFormat, "format", "auto", "Coverage format: auto, nyc, coveragepy, jacoco, go, lcov, cobertura")
 	ciIngestCoverageCmd.Flags().StringVar(&ciCoverageBranch, "branch", "", "Branch name for tagging")
 	ciIngestCoverageCmd.Flags().StringVar(&ciCoverageTag, "tag", "", "Tag/identifier for this coverage run")
+	ciIngestTimingsCmd.Flags().StringArrayVar(&ciTimingsFrom, "from", nil, "Path to a JUnit XML report (can be repeated)")
+	ciIngestTimingsCmd.MarkFlagRequired("from")
+	ciIngestResultsCmd.Flags().StringArrayVar(&ciResultsFrom, "from", nil, "Path to a JUnit XML report (can be repeated)")
+	ciIngestResultsCmd.Flags().StringVar(&ciResultsInputs, "inputs", "", "Identifier of the inputs the tests ran on, e.g. a commit SHA (default: the plan's head snapshot)")
+	ciIngestResultsCmd.Flags().StringVar(&ciPlanFile, "plan", "", "Path to plan file (for the head snapshot and test files)")
+	ciIngestResultsCmd.MarkFlagRequired("from")
+	ciShardCmd.Flags().StringVar(&ciPlanFile, "plan", "plan.json", "Path to plan file")
+	ciShardCmd.Flags().IntVar(&ciShardIndex, "index", 0, "Shard to print (0-based)")
+	ciShardCmd.Flags().StringVar(&ciEmit, "runner", "", "Print test commands for this runner instead of file paths")
+	ciIngestCoverageCmd.Flags().StringVar(&ciCoverageTest, "test", "", "Attribute all coverage in the report to this test (per-test runs)")
 	ciIngestCoverageCmd.MarkFlagRequired("from")
 	// ingest-contracts flags
 	ciIngestContractsCmd.Flags().StringVar(&ciContractType, "type", "", "Contract type: openapi, protobuf, graphql")
@@ -1646,8 +2034,10 @@ func init() {
 	reviewCmd.GroupID = groupDiff
 	changesetCmd.GroupID = groupDiff
 	intentCmd.GroupID = groupDiff
+	blameCmd.GroupID = groupDiff
 	rootCmd.AddCommand(diffCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(blameCmd)
 	rootCmd.AddCommand(changesetCmd)
 	rootCmd.AddCommand(intentCmd)
 
@@ -1685,6 +2075,10 @@ func init() {
 	snapshotCmd.GroupID = groupAdvanced
 	snapCmd.GroupID = groupAdvanced
 	analyzeCmd.GroupID = groupAdvanced
+	queryCmd.GroupID = groupAdvanced
+	graphCmd.GroupID = groupAdvanced
+	checkCmd.GroupID = groupCI
+	apiCmd.GroupID = groupCI
 	dumpCmd.GroupID = groupAdvanced
 	listCmd.GroupID = groupAdvanced
 	logCmd.GroupID = groupAdvanced
@@ -1697,6 +2091,13 @@ func init() {
 	rootCmd.AddCommand(snapshotCmd)
 	rootCmd.AddCommand(snapCmd)
 	rootCmd.AddCommand(analyzeCmd)
+	rootCmd.AddCommand(queryCmd)
+	graphCmd.AddCommand(graphExportCmd)
+	rootCmd.AddCommand(graphCmd)
+	checkCmd.AddCommand(checkLayersCmd)
+	rootCmd.AddCommand(checkCmd)
+	apiCmd.AddCommand(apiDiffCmd)
+	rootCmd.AddCommand(apiCmd)
 	rootCmd.AddCommand(dumpCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(logCmd)
@@ -1932,6 +2333,10 @@ kai fetch origin              # Download from server
 | ` + "`" + `CONDITION_CHANGED` + "`" + ` | If/comparison changed | ` + "`" + `if (x > 100)` + "`" + ` → ` + "`" + `if (x > 50)` + "`" + ` |
 | ` + "`" + `CONSTANT_UPDATED` + "`" + ` | Literal value changed | ` + "`" + `TIMEOUT = 3600` + "`" + ` → ` + "`" + `1800` + "`" + ` |
 | ` + "`" + `API_SURFACE_CHANGED` + "`" + ` | Function signature changed | Added parameter to function |
+| ` + "`" + `ERROR_HANDLING_CHANGED` + "`" + ` | try/catch, error check or rescue changed | Added ` + "`" + `if err != nil` + "`" + ` |
+| ` + "`" + `CONCURRENCY_CHANGED` + "`" + ` | Goroutines, locks, async/await or threads changed | Added ` + "`" + `mu.Lock()` + "`" + ` |
+| ` + "`" + `SENSITIVE_API_TOUCHED` + "`" + ` | Crypto, exec, SQL building or auth check touched | Changed ` + "`" + `exec.Command(...)` + "`" + ` |
+| ` + "`" + `DEPENDENCY_UPGRADED` + "`" + ` | Package version changed in a manifest | ` + "`" + `lodash ^4.17.20` + "`" + ` → ` + "`" + `^4.17.21` + "`" + ` |
 | ` + "`" + `FILE_ADDED` + "`" + ` | New file created | Added ` + "`" + `auth/mfa.ts` + "`" + ` |
 | ` + "`" + `FILE_DELETED` + "`" + ` | File removed | Deleted ` + "`" + `deprecated/old.ts` + "`" + ` |
 
@@ -2068,6 +2473,9 @@ kai test affected @snap:prev @snap:last
 | ` + "`" + `dynamic_import` + "`" + ` | High | Dynamic require/import detected |
 | ` + "`" + `no_test_mapping` + "`" + ` | Medium | Changed files have no test coverage |
 | ` + "`" + `cross_module_change` + "`" + ` | Medium | Changes span 3+ modules |
+| ` + "`" + `sensitive_api_change` + "`" + ` | High | Crypto, exec, SQL building or auth checks touched |
+| ` + "`" + `concurrency_change` + "`" + ` | Medium | Goroutines, locks, async/await or threads changed |
+| ` + "`" + `error_handling_change` + "`" + ` | Low | Error handling changed |
 
 ## Working Snapshot Model
 
@@ -2455,11 +2863,14 @@ func categorizeToBucket(category string) ChangeBucket {
 	switch category {
 	// Structural: things were added/removed/moved
 	case "FILE_ADDED", "FILE_DELETED", "FUNCTION_ADDED", "FUNCTION_REMOVED",
-		"JSON_FIELD_ADDED", "JSON_FIELD_REMOVED", "YAML_KEY_ADDED", "YAML_KEY_REMOVED":
+		"JSON_FIELD_ADDED", "JSON_FIELD_REMOVED", "YAML_KEY_ADDED", "YAML_KEY_REMOVED",
+		"DEPENDENCY_ADDED", "DEPENDENCY_REMOVED":
 		return BucketStructural
 	// Behavioral: logic/values changed
 	case "CONDITION_CHANGED", "CONSTANT_UPDATED", "JSON_VALUE_CHANGED",
-		"JSON_ARRAY_CHANGED", "YAML_VALUE_CHANGED", "FILE_CONTENT_CHANGED":
+		"JSON_ARRAY_CHANGED", "YAML_VALUE_CHANGED", "FILE_CONTENT_CHANGED",
+		"ERROR_HANDLING_CHANGED", "CONCURRENCY_CHANGED", "SENSITIVE_API_TOUCHED",
+		"DEPENDENCY_UPGRADED":
 		return BucketBehavioral
 	// API/Contract: interface/contract changed
 	case "API_SURFACE_CHANGED":
@@ -2552,14 +2963,16 @@ func computeCaptureSummary(db *graph.DB, newSnapshotID []byte, matcher *module.M
 				afterContent, _ := db.ReadObject(newDigest)
 
 				if len(beforeContent) > 0 && len(afterContent) > 0 {
-					detector := classify.NewDetector()
+					detector := newChangeDetector()
 					var changes []*classify.ChangeType
 
-					switch lang {
-					case "json":
+					switch {
+					case classify.ManifestEcosystem(path) != "":
+						changes, _ = classify.DetectManifestChanges(path, beforeContent, afterContent)
+					case lang == "json":
 						changes, _ = classify.DetectJSONChanges(path, beforeContent, afterContent)
-					case "ts", "js", "tsx", "jsx", "go", "py":
-						changes, _ = detector.DetectChanges(path, beforeContent, afterContent, "")
+					case classify.IsCodeLang(lang):
+						changes, _ = detector.DetectChanges(path, beforeContent, afterContent, "", lang)
 					default:
 						changes = []*classify.ChangeType{classify.NewFileChange(classify.FileContentChanged, path)}
 					}
@@ -2891,7 +3304,7 @@ func runAnalyzeCalls(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func runTestAffected(cmd *cobra.Command, args []string) error {
+func runCheckLayers(cmd *cobra.Command, args []string) error {
 	db, err := openDB()
 	if err != nil {
 		return err
@@ -2900,146 +3313,423 @@ func runTestAffected(cmd *cobra.Command, args []string) error {
 
 	matcher, err := loadMatcher()
 	if err != nil {
-		return err
+		return fmt.Errorf("loading modules: %w", err)
+	}
+	cfg := matcher.GetLayers()
+	if cfg == nil || (!cfg.NoCycles && len(cfg.Rules) == 0) {
+		return fmt.Errorf("no layering rules configured (add a 'layers' section to %s)", modulesRulesPath)
 	}
 
-	creator := snapshot.NewCreator(db, matcher)
-
-	// Resolve both snapshot IDs
-	baseSnapshotID, err := resolveSnapshotID(db, args[0])
+	headID, err := resolveSnapshotID(db, checkLayersSnap)
 	if err != nil {
-		return fmt.Errorf("resolving base snapshot ID: %w", err)
+		return fmt.Errorf("resolving snapshot: %w", err)
 	}
-
-	headSnapshotID, err := resolveSnapshotID(db, args[1])
+	violations, err := layers.Check(db, headID, matcher, cfg)
 	if err != nil {
-		return fmt.Errorf("resolving head snapshot ID: %w", err)
+		return err
 	}
 
-	// Get files that changed
-	changedFiles, err := getChangedFiles(db, creator, baseSnapshotID, headSnapshotID)
-	if err != nil {
-		return fmt.Errorf("getting changed files: %w", err)
+	if checkLayersBase != "" {
+		baseID, err := resolveSnapshotID(db, checkLayersBase)
+		if err != nil {
+			return fmt.Errorf("resolving base snapshot: %w", err)
+		}
+		baseViolations, err := layers.Check(db, baseID, matcher, cfg)
+		if err != nil {
+			return err
+		}
+		violations = layers.NewViolations(baseViolations, violations)
 	}
 
-	if len(changedFiles) == 0 {
-		fmt.Println("No changed files found")
-		return nil
+	if checkLayersJSON {
+		if violations == nil {
+			violations = []*layers.Violation{}
+		}
+		output, err := json.MarshalIndent(violations, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling JSON: %w", err)
+		}
+		fmt.Println(string(output))
+	} else if len(violations) == 0 {
+		if checkLayersBase != "" {
+			fmt.Println("No new layering violations.")
+		} else {
+			fmt.Println("No layering violations.")
+		}
+	} else {
+		for _, v := range violations {
+			fmt.Printf("  %s\n", v)
+		}
+		fmt.Println()
 	}
 
-	// Build a set of changed file paths
-	changedPaths := make(map[string]bool)
-	for _, f := range changedFiles {
-		changedPaths[f] = true
+	if len(violations) > 0 {
+		if checkLayersBase != "" {
+			return fmt.Errorf("%d new layering violations", len(violations))
+		}
+		return fmt.Errorf("%d layering violations", len(violations))
+	}
+	return nil
+}
+
+func runAPIDiff(cmd *cobra.Command, args []string) error {
+	if apiDiffFailOn != "" && !apidiff.ValidLevel(apiDiffFailOn) {
+		return fmt.Errorf("invalid --fail-on %q (want breaking, additive or patch)", apiDiffFailOn)
 	}
 
-	// Get all files in the snapshot to build the graph context
-	files, err := creator.GetSnapshotFiles(headSnapshotID)
+	db, err := openDB()
 	if err != nil {
-		return fmt.Errorf("getting snapshot files: %w", err)
+		return err
 	}
+	defer db.Close()
 
-	// Build file ID -> path map
-	filePathByID := make(map[string]string)
-	fileIDByPath := make(map[string][]byte)
-	for _, f := range files {
-		path, _ := f.Payload["path"].(string)
-		idHex := util.BytesToHex(f.ID)
-		filePathByID[idHex] = path
-		fileIDByPath[path] = f.ID
+	headRef := "@snap:last"
+	if len(args) > 1 {
+		headRef = args[1]
+	}
+	baseID, err := resolveSnapshotID(db, args[0])
+	if err != nil {
+		return fmt.Errorf("resolving base snapshot: %w", err)
+	}
+	headID, err := resolveSnapshotID(db, headRef)
+	if err != nil {
+		return fmt.Errorf("resolving head snapshot: %w", err)
 	}
 
-	// Find all files that depend on the changed files (reverse call graph)
-	// and all test files that test the changed files
-	affectedTests := make(map[string]bool)
+	creator := snapshot.NewCreator(db, nil)
+	baseFiles, err := apiSnapshotFiles(creator, baseID, apiDiffPath)
+	if err != nil {
+		return fmt.Errorf("reading base snapshot: %w", err)
+	}
+	headFiles, err := apiSnapshotFiles(creator, headID, apiDiffPath)
+	if err != nil {
+		return fmt.Errorf("reading head snapshot: %w", err)
+	}
 
-	// First: direct test files for changed files
-	for changedPath := range changedPaths {
-		// Find TESTS edges pointing to files with this path
-		// Uses path-based lookup to handle content-addressed ID changes
-		testsEdges, err := db.GetEdgesToByPath(changedPath, graph.EdgeTests)
+	report := apidiff.Diff(apidiff.Extract(baseFiles), apidiff.Extract(headFiles))
+	report.Current = apiDiffCurrent
+	if report.Current == "" {
+		report.Current = apidiff.CurrentVersion(baseFiles, apiDiffPath)
+	}
+	report.Next = apidiff.NextVersion(report.Current, report.Level)
+
+	if apiDiffJSON {
+		output, err := json.MarshalIndent(report, "", "  ")
 		if err != nil {
-			continue
-		}
-		for _, e := range testsEdges {
-			srcHex := util.BytesToHex(e.Src)
-			if path, ok := filePathByID[srcHex]; ok {
-				affectedTests[path] = true
-			} else {
-				// Query node directly if not in current snapshot
-				if srcNode, _ := db.GetNode(e.Src); srcNode != nil {
-					if srcPath, ok := srcNode.Payload["path"].(string); ok {
-						affectedTests[srcPath] = true
-					}
-				}
-			}
+			return fmt.Errorf("marshaling JSON: %w", err)
 		}
+		fmt.Println(string(output))
+	} else {
+		fmt.Printf("API diff: %s -> %s\n\n", util.BytesToHex(baseID)[:12], util.BytesToHex(headID)[:12])
+		fmt.Print(report.FormatText())
+	}
 
-		// Also find files that import/call into the changed file
-		importsEdges, err := db.GetEdgesToByPath(changedPath, graph.EdgeImports)
-		if err != nil {
+	if apiDiffFailOn != "" && report.Level != apidiff.LevelNone && apidiff.AtLeast(report.Level, apiDiffFailOn) {
+		return fmt.Errorf("%s API changes (--fail-on %s)", report.Level, apiDiffFailOn)
+	}
+	return nil
+}
+
+// apiSnapshotFiles reads the files of a snapshot that can contribute to its
+// public API, plus the package manifests the current version is read from.
+// With dir set, only files under it are read.
+func apiSnapshotFiles(creator *snapshot.Creator, snapID []byte, dir string) (map[string][]byte, error) {
+	files, err := creator.GetSnapshotFiles(snapID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if dir != "" && dir != "." {
+		prefix = strings.TrimSuffix(dir, "/") + "/"
+	}
+
+	contents := make(map[string][]byte)
+	for _, f := range files {
+		path, _ := f.Payload["path"].(string)
+		digest, _ := f.Payload["digest"].(string)
+		if !strings.HasPrefix(path, prefix) {
 			continue
 		}
-		for _, e := range importsEdges {
-			srcHex := util.BytesToHex(e.Src)
-			if path, ok := filePathByID[srcHex]; ok {
-				// If this importer is a test file, add it
-				if parse.IsTestFile(path) {
-					affectedTests[path] = true
-				}
-			} else {
-				// Query node directly
-				if srcNode, _ := db.GetNode(e.Src); srcNode != nil {
-					if srcPath, ok := srcNode.Payload["path"].(string); ok {
-						if parse.IsTestFile(srcPath) {
-							affectedTests[srcPath] = true
-						}
-					}
-				}
+		switch strings.TrimPrefix(path, prefix) {
+		case "package.json", "Cargo.toml", "pyproject.toml":
+		default:
+			if apidiff.Lang(path) == "" {
+				continue
 			}
 		}
-
-		callsEdges, err := db.GetEdgesToByPath(changedPath, graph.EdgeCalls)
+		content, err := creator.GetFileContent(digest)
 		if err != nil {
 			continue
 		}
-		for _, e := range callsEdges {
-			srcHex := util.BytesToHex(e.Src)
-			if path, ok := filePathByID[srcHex]; ok {
-				if parse.IsTestFile(path) {
-					affectedTests[path] = true
-				}
-			} else {
-				// Query node directly
-				if srcNode, _ := db.GetNode(e.Src); srcNode != nil {
-					if srcPath, ok := srcNode.Payload["path"].(string); ok {
-						if parse.IsTestFile(srcPath) {
-							affectedTests[srcPath] = true
-						}
-					}
-				}
-			}
-		}
+		contents[path] = content
 	}
+	return contents, nil
+}
 
-	// Output results
-	if len(affectedTests) == 0 {
-		fmt.Println("No affected test files found")
-		fmt.Println("(Make sure you've run 'kai analyze calls @snap:last' to build the call graph)")
-		return nil
+func runGraphExport(cmd *cobra.Command, args []string) error {
+	db, err := openDB()
+	if err != nil {
+		return err
 	}
+	defer db.Close()
 
-	// Sort for consistent output
-	var tests []string
-	for t := range affectedTests {
-		tests = append(tests, t)
+	snapID, err := resolveSnapshotID(db, graphExportSnap)
+	if err != nil {
+		return fmt.Errorf("resolving snapshot: %w", err)
 	}
-	sort.Strings(tests)
 
-	fmt.Printf("Affected test files (%d):\n", len(tests))
-	for _, t := range tests {
-		fmt.Println(t)
-	}
+	kinds, err := export.ParseKinds(graphExportKinds)
+	if err != nil {
+		return err
+	}
+	edges, err := export.ParseEdges(graphExportEdges)
+	if err != nil {
+		return err
+	}
+
+	sg, err := export.Collect(db, export.Options{SnapshotID: snapID, Kinds: kinds, Edges: edges})
+	if err != nil {
+		return err
+	}
+
+	var matcher *module.Matcher
+	if export.Format(graphExportFormat) == export.FormatDOT {
+		matcher, err = loadMatcher()
+		if err != nil {
+			return fmt.Errorf("loading modules: %w", err)
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if graphExportOut != "" {
+		f, err := os.Create(graphExportOut)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := export.Write(out, sg, export.Format(graphExportFormat), matcher); err != nil {
+		return err
+	}
+	if graphExportOut != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d nodes and %d edges to %s\n", len(sg.Nodes), len(sg.Edges), graphExportOut)
+	}
+	return nil
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	snapID, err := resolveSnapshotID(db, queryAt)
+	if err != nil {
+		return fmt.Errorf("resolving snapshot: %w", err)
+	}
+
+	q, err := query.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("parsing query: %w", err)
+	}
+	compiled, err := query.Compile(q, snapID)
+	if err != nil {
+		return err
+	}
+
+	if querySQL {
+		fmt.Println(compiled.SQL)
+		return nil
+	}
+
+	rows, err := query.Exec(db, compiled)
+	if err != nil {
+		return err
+	}
+
+	if queryJSON {
+		if rows == nil {
+			rows = []*query.Row{}
+		}
+		output, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling JSON: %w", err)
+		}
+		fmt.Println(string(output))
+		return nil
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No results.")
+		return nil
+	}
+
+	fmt.Printf("%-8s  %-40s  %-12s  %s\n", "KIND", "NAME", "DETAIL", "PATH")
+	for _, r := range rows {
+		name := r.Name
+		if r.Kind == string(graph.KindFile) {
+			name = filepath.Base(r.Path)
+		}
+		fmt.Printf("%-8s  %-40s  %-12s  %s\n", r.Kind, name, r.Detail, r.Path)
+	}
+	fmt.Printf("\n%d results\n", len(rows))
+
+	return nil
+}
+
+func runTestAffected(cmd *cobra.Command, args []string) error {
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	matcher, err := loadMatcher()
+	if err != nil {
+		return err
+	}
+
+	creator := snapshot.NewCreator(db, matcher)
+
+	// Resolve both snapshot IDs
+	baseSnapshotID, err := resolveSnapshotID(db, args[0])
+	if err != nil {
+		return fmt.Errorf("resolving base snapshot ID: %w", err)
+	}
+
+	headSnapshotID, err := resolveSnapshotID(db, args[1])
+	if err != nil {
+		return fmt.Errorf("resolving head snapshot ID: %w", err)
+	}
+
+	// Get files that changed
+	changedFiles, err := getChangedFiles(db, creator, baseSnapshotID, headSnapshotID)
+	if err != nil {
+		return fmt.Errorf("getting changed files: %w", err)
+	}
+
+	if len(changedFiles) == 0 {
+		fmt.Println("No changed files found")
+		return nil
+	}
+
+	// Build a set of changed file paths
+	changedPaths := make(map[string]bool)
+	for _, f := range changedFiles {
+		changedPaths[f] = true
+	}
+
+	// Get all files in the snapshot to build the graph context
+	files, err := creator.GetSnapshotFiles(headSnapshotID)
+	if err != nil {
+		return fmt.Errorf("getting snapshot files: %w", err)
+	}
+
+	// Build file ID -> path map
+	filePathByID := make(map[string]string)
+	fileIDByPath := make(map[string][]byte)
+	for _, f := range files {
+		path, _ := f.Payload["path"].(string)
+		idHex := util.BytesToHex(f.ID)
+		filePathByID[idHex] = path
+		fileIDByPath[path] = f.ID
+	}
+
+	// Find all files that depend on the changed files (reverse call graph)
+	// and all test files that test the changed files
+	affectedTests := make(map[string]bool)
+
+	// First: direct test files for changed files
+	for changedPath := range changedPaths {
+		// Find TESTS edges pointing to files with this path
+		// Uses path-based lookup to handle content-addressed ID changes
+		testsEdges, err := db.GetEdgesToByPath(changedPath, graph.EdgeTests)
+		if err != nil {
+			continue
+		}
+		for _, e := range testsEdges {
+			srcHex := util.BytesToHex(e.Src)
+			if path, ok := filePathByID[srcHex]; ok {
+				affectedTests[path] = true
+			} else {
+				// Query node directly if not in current snapshot
+				if srcNode, _ := db.GetNode(e.Src); srcNode != nil {
+					if srcPath, ok := srcNode.Payload["path"].(string); ok {
+						affectedTests[srcPath] = true
+					}
+				}
+			}
+		}
+
+		// Also find files that import/call into the changed file
+		importsEdges, err := db.GetEdgesToByPath(changedPath, graph.EdgeImports)
+		if err != nil {
+			continue
+		}
+		for _, e := range importsEdges {
+			srcHex := util.BytesToHex(e.Src)
+			if path, ok := filePathByID[srcHex]; ok {
+				// If this importer is a test file, add it
+				if parse.IsTestFile(path) {
+					affectedTests[path] = true
+				}
+			} else {
+				// Query node directly
+				if srcNode, _ := db.GetNode(e.Src); srcNode != nil {
+					if srcPath, ok := srcNode.Payload["path"].(string); ok {
+						if parse.IsTestFile(srcPath) {
+							affectedTests[srcPath] = true
+						}
+					}
+				}
+			}
+		}
+
+		callsEdges, err := db.GetEdgesToByPath(changedPath, graph.EdgeCalls)
+		if err != nil {
+			continue
+		}
+		for _, e := range callsEdges {
+			srcHex := util.BytesToHex(e.Src)
+			if path, ok := filePathByID[srcHex]; ok {
+				if parse.IsTestFile(path) {
+					affectedTests[path] = true
+				}
+			} else {
+				// Query node directly
+				if srcNode, _ := db.GetNode(e.Src); srcNode != nil {
+					if srcPath, ok := srcNode.Payload["path"].(string); ok {
+						if parse.IsTestFile(srcPath) {
+							affectedTests[srcPath] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Output results
+	if len(affectedTests) == 0 {
+		fmt.Println("No affected test files found")
+		fmt.Println("(Make sure you've run 'kai analyze calls @snap:last' to build the call graph)")
+		return nil
+	}
+
+	// Sort for consistent output
+	var tests []string
+	for t := range affectedTests {
+		tests = append(tests, t)
+	}
+	sort.Strings(tests)
+
+	fmt.Printf("Affected test files (%d):\n", len(tests))
+	for _, t := range tests {
+		fmt.Println(t)
+	}
 
 	return nil
 }
@@ -3060,6 +3750,10 @@ type CIPlan struct {
 	DynamicImport *DynamicImportInfo `json:"dynamicImport,omitempty"`       // Dynamic import analysis
 	Coverage      *CoverageInfo      `json:"coverage,omitempty"`            // Coverage-based selection info
 	Contracts     *ContractInfo      `json:"contracts,omitempty"`           // Contract/schema change info
+	Owners        *OwnersInfo        `json:"owners,omitempty"`              // CODEOWNERS ownership of the change
+	Sharding      *CISharding        `json:"sharding,omitempty"`            // Split of targets across parallel jobs
+	Misses        *MissHistoryInfo   `json:"misses,omitempty"`              // Tests added from recorded misses
+	BuildTargets  *CIBuildTargets    `json:"buildTargets,omitempty"`        // Build targets affected by the change
 	Fallback      CIFallback         `json:"fallback"`                      // Fallback/tripwire status
 	Provenance    CIProvenance       `json:"provenance"`                    // Audit trail
 	Prediction    CIPrediction       `json:"prediction,omitempty"`          // For shadow mode comparison
@@ -3180,12 +3874,24 @@ type CIFallback struct {
 
 // CoverageInfo captures coverage-based test selection info
 type CoverageInfo struct {
-	Enabled              bool     `json:"enabled"`
-	LookbackDays         int      `json:"lookbackDays"`
-	FilesWithCoverage    int      `json:"filesWithCoverage"`
-	FilesWithoutCoverage int      `json:"filesWithoutCoverage"`
-	TestsFromCoverage    []string `json:"testsFromCoverage,omitempty"` // Tests selected via coverage
-	CoverageMapAge       string   `json:"coverageMapAge,omitempty"`    // When coverage was last ingested
+	Enabled              bool           `json:"enabled"`
+	LookbackDays         int            `json:"lookbackDays"`
+	FilesWithCoverage    int            `json:"filesWithCoverage"`
+	FilesWithoutCoverage int            `json:"filesWithoutCoverage"`
+	TestsFromCoverage    []string       `json:"testsFromCoverage,omitempty"` // Tests selected via coverage
+	CoverageMapAge       string         `json:"coverageMapAge,omitempty"`    // When coverage was last ingested
+	Files                []CoverageFile `json:"files,omitempty"`             // Per-file selection detail
+	TestsNarrowed        []string       `json:"testsNarrowed,omitempty"`     // Tests covering changed files but none of the changed lines
+}
+
+// CoverageFile records how coverage selected tests for one changed file
+type CoverageFile struct {
+	Path        string   `json:"path"`
+	Granularity string   `json:"granularity"`          // "line" or "file"
+	Reason      string   `json:"reason,omitempty"`     // Why file-level was used
+	BaseRanges  string   `json:"baseRanges,omitempty"` // Changed lines in the base version, e.g. "40-55"
+	HeadRanges  string   `json:"headRanges,omitempty"` // Changed lines in the head version
+	Tests       []string `json:"tests,omitempty"`      // Tests selected for this file
 }
 
 // ContractInfo captures contract/schema change detection
@@ -3196,14 +3902,41 @@ type ContractInfo struct {
 	GeneratedChanged []string            `json:"generatedChanged,omitempty"` // Generated files that changed
 }
 
+// OwnersInfo captures CODEOWNERS-based ownership of the change
+type OwnersInfo struct {
+	Source  string              `json:"source"`            // CODEOWNERS file used
+	Files   map[string][]string `json:"files,omitempty"`   // Changed file -> owners
+	Modules map[string][]string `json:"modules,omitempty"` // Affected module -> owners of its files
+	Tests   map[string][]string `json:"tests,omitempty"`   // Test added by owner widening -> shared owners
+}
+
+// MissHistoryInfo lists the tests selected because they failed after being
+// skipped for changes to the same files before
+type MissHistoryInfo struct {
+	Records  int           `json:"records"`  // Miss records consulted
+	Mappings []MissMapping `json:"mappings"` // Learned mappings for the changed files
+}
+
+// MissMapping is an implicit changed file -> test mapping learned from misses
+type MissMapping struct {
+	File       string  `json:"file"`
+	Test       string  `json:"test"`
+	Weight     float64 `json:"weight"`     // Decayed evidence; see learnMissMappings
+	Misses     int     `json:"misses"`     // Misses behind the mapping
+	LastMissAt string  `json:"lastMissAt"` // ISO8601 timestamp
+	Added      bool    `json:"added"`      // True if only miss history selected the test
+}
+
 // ContractChange represents a changed contract/schema
 type ContractChange struct {
-	Path         string   `json:"path"`
-	Type         string   `json:"type"`                   // openapi, protobuf, graphql
-	Service      string   `json:"service,omitempty"`      // Service/module this schema belongs to
-	DigestBefore string   `json:"digestBefore,omitempty"` // Hash before change
-	DigestAfter  string   `json:"digestAfter,omitempty"`  // Hash after change
-	Tests        []string `json:"tests,omitempty"`        // Tests registered for this schema
+	Path         string          `json:"path"`
+	Type         string          `json:"type"`                   // openapi, protobuf, graphql
+	Service      string          `json:"service,omitempty"`      // Service/module this schema belongs to
+	DigestBefore string          `json:"digestBefore,omitempty"` // Hash before change
+	DigestAfter  string          `json:"digestAfter,omitempty"`  // Hash after change
+	Tests        []string        `json:"tests,omitempty"`        // Tests registered for this schema
+	Changes      []diff.UnitDiff `json:"changes,omitempty"`      // Schema units changed since the base snapshot
+	Breaking     bool            `json:"breaking,omitempty"`     // A change breaks existing clients
 }
 
 // ========== Coverage Parsing Types ==========
@@ -3226,6 +3959,46 @@ type CoverageEntry struct {
 	LinesCovered []int `json:"linesCovered,omitempty"` // Specific lines if available
 }
 
+// ========== Test Timing Types ==========
+
+// TimingsMap stores historical test durations from JUnit reports
+type TimingsMap struct {
+	Version    int                    `json:"version"`
+	IngestedAt string                 `json:"ingestedAt"`
+	Tests      map[string]*TestTiming `json:"tests"` // Report key (test file or classname) -> timing
+}
+
+// TestTiming is the duration of one test file or class across recent runs
+type TestTiming struct {
+	Seconds    float64 `json:"seconds"`    // Moving average over recent runs
+	Runs       int     `json:"runs"`       // Number of reports it appeared in
+	LastSeenAt string  `json:"lastSeenAt"` // ISO8601 timestamp
+}
+
+// ========== Flaky Test Types ==========
+
+// FlakyLedger stores test outcomes by the inputs the tests ran on
+type FlakyLedger struct {
+	Version   int                   `json:"version"`
+	UpdatedAt string                `json:"updatedAt"`
+	Tests     map[string]*FlakyTest `json:"tests"` // Test file -> outcomes
+}
+
+// FlakyTest is one test file's outcomes across recent runs
+type FlakyTest struct {
+	Runs       int           `json:"runs"`       // Reports it appeared in
+	Failures   int           `json:"failures"`   // Reports in which it failed
+	Inputs     []FlakyInputs `json:"inputs"`     // Most recent last
+	LastSeenAt string        `json:"lastSeenAt"` // ISO8601 timestamp
+}
+
+// FlakyInputs counts a test's outcomes on one set of inputs
+type FlakyInputs struct {
+	ID     string `json:"id"` // Commit SHA or snapshot ID
+	Passed int    `json:"passed"`
+	Failed int    `json:"failed"`
+}
+
 // ========== Contract Registry Types ==========
 
 // ContractRegistry stores registered contracts/schemas
@@ -3265,6 +4038,56 @@ type CITargets struct {
 	Full     []string            `json:"full,omitempty"` // All tests (for shadow mode comparison)
 	Tags     map[string][]string `json:"tags,omitempty"`
 	Fallback bool                `json:"fallback"` // If true, runner should fallback to full on failure
+	Cases    []CITestCase        `json:"cases,omitempty"`   // Test cases to run in files narrowed to case level
+	Filters  map[string][]string `json:"filters,omitempty"` // Runner commands by framework (go, pytest, jest)
+}
+
+// CISharding splits the tests to run across parallel CI jobs, balanced by
+// historical duration
+type CISharding struct {
+	Count          int       `json:"count"`
+	TimedFiles     int       `json:"timedFiles"`     // Files with recorded durations
+	DefaultSeconds float64   `json:"defaultSeconds"` // Estimate used for files without history
+	Shards         []CIShard `json:"shards"`
+}
+
+// CIBuildTargets splits the build targets from the policy into those the
+// change affects and those whose builds can be skipped
+type CIBuildTargets struct {
+	All      bool               `json:"all"`              // Every target is affected
+	Reason   string             `json:"reason,omitempty"` // Why every target is affected
+	Modules  []CIAffectedModule `json:"modules"`          // Modules changed or depending on a changed module
+	Affected []CIBuildTarget    `json:"affected"`
+	Skipped  []CIBuildTarget    `json:"skipped"`
+}
+
+// CIAffectedModule is a module whose build targets are affected
+type CIAffectedModule struct {
+	Module string   `json:"module"`
+	Via    []string `json:"via,omitempty"` // Dependency chain from a changed module, when not changed itself
+}
+
+// CIBuildTarget is a Bazel/Buck label, Make target, Docker image or npm
+// workspace of a module
+type CIBuildTarget struct {
+	Kind   string `json:"kind"` // bazel, make, docker, npm
+	Name   string `json:"name"`
+	Module string `json:"module"`
+}
+
+// CIShard is one job's share of the tests
+type CIShard struct {
+	Index            int      `json:"index"` // 0-based
+	Targets          []string `json:"targets"`
+	EstimatedSeconds float64  `json:"estimatedSeconds"`
+}
+
+// CITestCase is a single test case selected because it reaches a changed symbol
+type CITestCase struct {
+	File      string   `json:"file"`
+	Name      string   `json:"name"`      // TestAdd, TestCart::test_add, "cart adds items"
+	Framework string   `json:"framework"` // go, pytest, jest
+	Symbols   []string `json:"symbols"`   // Changed symbols the case reaches
 }
 
 type CIImpact struct {
@@ -3272,6 +4095,16 @@ type CIImpact struct {
 	SymbolsChanged  []CISymbolChange `json:"symbolsChanged,omitempty"`
 	ModulesAffected []string         `json:"modulesAffected,omitempty"`
 	Uncertainty     []string         `json:"uncertainty,omitempty"`
+
+	Dependencies []CIDependencyChange `json:"dependencies,omitempty"`
+}
+
+// CIDependencyChange is a dependency a manifest change added, removed or
+// upgraded, with the source files that import it
+type CIDependencyChange struct {
+	Manifest string `json:"manifest"`
+	classify.DependencyChange
+	Importers []string `json:"importers,omitempty"`
 }
 
 type CISymbolChange struct {
@@ -3294,6 +4127,15 @@ type CISafety struct {
 	PanicSwitch      bool             `json:"panicSwitch"`      // Force full run (env/label override)
 	AutoExpanded     bool             `json:"autoExpanded"`     // Was selection auto-expanded due to risk?
 	ExpansionReasons []string         `json:"expansionReasons,omitempty"`
+	FlakyTests       []FlakyTestInfo  `json:"flakyTests,omitempty"` // Tests to run that are known to be flaky
+}
+
+// FlakyTestInfo describes a flaky test in a plan
+type FlakyTestInfo struct {
+	Test      string  `json:"test"`
+	FlakeRate float64 `json:"flakeRate"` // Share of repeated inputs with both passes and failures
+	Runs      int     `json:"runs"`
+	Failures  int     `json:"failures"`
 }
 
 // StructuralRisk represents a detected risk pattern
@@ -3338,10 +4180,90 @@ type CIPolicyConfig struct {
 
 	// Contracts configures contract/schema change detection
 	Contracts CIPolicyContracts `yaml:"contracts" json:"contracts"`
+
+	// Misses configures learning from recorded selection misses
+	Misses CIPolicyMisses `yaml:"misses" json:"misses"`
+
+	// Flaky configures flaky test tracking
+	Flaky CIPolicyFlaky `yaml:"flaky" json:"flaky"`
+
+	// Targets maps modules to build targets
+	Targets CIPolicyTargets `yaml:"targets" json:"targets"`
+
+	// Sensitive configures which calls count as security-sensitive
+	Sensitive CIPolicySensitive `yaml:"sensitive" json:"sensitive"`
+
+	// Dependencies configures how dependency manifest changes expand plans
+	Dependencies CIPolicyDependencies `yaml:"dependencies" json:"dependencies"`
 }
 
-// CIPolicyDynamicImports configures dynamic import handling
-type CIPolicyDynamicImports struct {
+// CIPolicySensitive adds call patterns reported as SENSITIVE_API_TOUCHED,
+// on top of the built-in crypto, exec and auth patterns
+type CIPolicySensitive struct {
+	// Patterns: callee globs with a kind, e.g. {kind: payments, pattern: "billing.*"}
+	Patterns []classify.SensitivePattern `yaml:"patterns" json:"patterns"`
+}
+
+// CIPolicyDependencies configures what a change to go.mod, package.json,
+// Cargo.toml, requirements or their lockfiles runs
+type CIPolicyDependencies struct {
+	// Scope: "full", "importers" - with importers, a manifest change that only
+	// adds, removes or upgrades dependencies runs the tests of files importing
+	// those packages instead of everything (default full)
+	Scope string `yaml:"scope" json:"scope"`
+}
+
+// CIPolicyTargets maps modules from kai.modules.yaml to build targets, so
+// plans can tell which builds a change affects
+type CIPolicyTargets struct {
+	// UnmatchedFiles: "all", "ignore" - what a changed file outside every
+	// module affects (default all)
+	UnmatchedFiles string `yaml:"unmatchedFiles" json:"unmatchedFiles"`
+	// Ignore: globs for changed files that never affect builds
+	Ignore []string `yaml:"ignore" json:"ignore"`
+	// Modules: build targets per module
+	Modules []CIPolicyModuleTargets `yaml:"modules" json:"modules"`
+}
+
+// CIPolicyModuleTargets lists the build targets of one module
+type CIPolicyModuleTargets struct {
+	Module string   `yaml:"module" json:"module"`
+	Bazel  []string `yaml:"bazel" json:"bazel"`   // Bazel or Buck labels (//services/api:server)
+	Make   []string `yaml:"make" json:"make"`     // Make targets
+	Docker []string `yaml:"docker" json:"docker"` // Docker images
+	NPM    []string `yaml:"npm" json:"npm"`       // npm workspace packages
+	// DependsOn: modules this one builds from without importing them, such as
+	// generated code or shared assets
+	DependsOn []string `yaml:"dependsOn" json:"dependsOn"`
+}
+
+// CIPolicyFlaky configures the flaky test ledger in .kai/flaky-tests.json
+type CIPolicyFlaky struct {
+	// Enabled: ignore failures of flaky tests in tripwires and miss learning
+	// (default true)
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Threshold: flake rate at or above which a test is flaky (default 0.1)
+	Threshold float64 `yaml:"threshold" json:"threshold"`
+	// Window: number of recent inputs kept per test (default 50)
+	Window int `yaml:"window" json:"window"`
+}
+
+// CIPolicyMisses configures learning from .kai/ci-misses.jsonl
+type CIPolicyMisses struct {
+	// Enabled: select tests that failed after being skipped for changes to
+	// the same files before (default true)
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// HalfLifeDays: a miss counts half as much after this many days (default 30)
+	HalfLifeDays int `yaml:"halfLifeDays" json:"halfLifeDays"`
+	// MinWeight: minimum decayed weight for a learned mapping to select a
+	// test (default 0.25)
+	MinWeight float64 `yaml:"minWeight" json:"minWeight"`
+	// RetentionDays: ignore misses older than this (default 90)
+	RetentionDays int `yaml:"retentionDays" json:"retentionDays"`
+}
+
+// CIPolicyDynamicImports configures dynamic import handling
+type CIPolicyDynamicImports struct {
 	// Expansion strategy: nearest_module, package, owners, full_suite
 	Expansion string `yaml:"expansion" json:"expansion"`
 	// OwnersFallback: if module unknown, widen to code owners' suites
@@ -3402,6 +4324,9 @@ type CIPolicyCoverage struct {
 	OnNoCoverage string `yaml:"onNoCoverage" json:"onNoCoverage"`
 	// RetentionDays: prune coverage entries older than this (default 90)
 	RetentionDays int `yaml:"retentionDays" json:"retentionDays"`
+	// LineLevel: only select tests whose covered lines intersect the changed
+	// symbol ranges, when the coverage map has line data (default true)
+	LineLevel bool `yaml:"lineLevel" json:"lineLevel"`
 }
 
 // CIPolicyContracts configures contract/schema change detection
@@ -3473,6 +4398,7 @@ func DefaultCIPolicy() CIPolicyConfig {
 			MinHits:       1,      // Trust mappings with at least 1 hit
 			OnNoCoverage:  "warn", // Warn but don't expand for files without coverage
 			RetentionDays: 90,     // Prune entries older than 90 days
+			LineLevel:     true,   // Intersect covered lines with changed ranges
 		},
 		Contracts: CIPolicyContracts{
 			Enabled:            true,                                       // Contract detection enabled by default
@@ -3481,6 +4407,24 @@ func DefaultCIPolicy() CIPolicyConfig {
 			RetentionRevisions: 50,                                         // Keep last 50 revisions per contract
 			Generated:          []CIPolicyGeneratedMapping{},               // User-defined schema→outputs
 		},
+		Misses: CIPolicyMisses{
+			Enabled:       true, // Learn from recorded misses by default
+			HalfLifeDays:  30,   // A month-old miss counts half
+			MinWeight:     0.25, // One miss in a change of up to 4 files
+			RetentionDays: 90,   // Ignore misses older than 90 days
+		},
+		Flaky: CIPolicyFlaky{
+			Enabled:   true, // Track flaky tests by default
+			Threshold: 0.1,  // Flipped on 1 in 10 repeated inputs
+			Window:    50,   // Keep the last 50 inputs per test
+		},
+		Targets: CIPolicyTargets{
+			UnmatchedFiles: "all",                // Unknown changes rebuild everything
+			Ignore:         []string{"**/*.md"}, // Docs never affect builds
+		},
+		Dependencies: CIPolicyDependencies{
+			Scope: "full", // Any manifest change runs everything
+		},
 	}
 }
 
@@ -3524,18 +4468,22 @@ func loadCIPolicy() (CIPolicyConfig, string, error) {
 
 // Structural risk type constants
 const (
-	RiskConfigChange      = "config_change"       // package.json, tsconfig, etc changed
-	RiskBuildFileChange   = "build_file_change"   // webpack, vite, build configs
-	RiskGlobalChange      = "global_change"       // Global state, env vars, shared constants
-	RiskDynamicImport     = "dynamic_import"      // Dynamic require/import detected
-	RiskReflection        = "reflection"          // Reflection or metaprogramming
-	RiskTestInfra         = "test_infra"          // Test helpers, fixtures, mocks changed
-	RiskNoTestMapping     = "no_test_mapping"     // Changed files have no test coverage
-	RiskCircularDep       = "circular_dependency" // Circular import detected
-	RiskNewFile           = "new_file"            // New file with no test coverage
-	RiskDeletedFile       = "deleted_file"        // File was deleted
-	RiskManyFilesChanged  = "many_files_changed"  // Too many files changed (>threshold)
-	RiskCrossModuleChange = "cross_module_change" // Changes span multiple modules
+	RiskConfigChange      = "config_change"         // package.json, tsconfig, etc changed
+	RiskBuildFileChange   = "build_file_change"     // webpack, vite, build configs
+	RiskGlobalChange      = "global_change"         // Global state, env vars, shared constants
+	RiskDynamicImport     = "dynamic_import"        // Dynamic require/import detected
+	RiskReflection        = "reflection"            // Reflection or metaprogramming
+	RiskTestInfra         = "test_infra"            // Test helpers, fixtures, mocks changed
+	RiskNoTestMapping     = "no_test_mapping"       // Changed files have no test coverage
+	RiskCircularDep       = "circular_dependency"   // Circular import detected
+	RiskNewFile           = "new_file"              // New file with no test coverage
+	RiskDeletedFile       = "deleted_file"          // File was deleted
+	RiskManyFilesChanged  = "many_files_changed"    // Too many files changed (>threshold)
+	RiskCrossModuleChange = "cross_module_change"   // Changes span multiple modules
+	RiskErrorHandling     = "error_handling_change" // try/catch, error checks or rescue changed
+	RiskConcurrency       = "concurrency_change"    // Goroutines, locks, async/await or threads changed
+	RiskSensitiveAPI      = "sensitive_api_change"  // Crypto, exec, SQL building or auth checks touched
+	RiskDependencyChange  = "dependency_change"     // Dependencies added, removed or upgraded
 )
 
 // Config file patterns that affect all tests
@@ -3768,6 +4716,7 @@ func expandForDynamicImports(
 	changedFiles []string,
 	moduleMappings []ModulePathMapping,
 	filesByModule map[string][]string, // map[module name] -> test files in that module
+	owners *codeowners.File, // nil when the repo has no CODEOWNERS
 ) ([]string, *DynamicImportInfo) {
 	info := &DynamicImportInfo{
 		Detected: len(detectedImports) > 0,
@@ -3827,7 +4776,7 @@ func expandForDynamicImports(
 
 	// Union model: try nearest_module first, fall back to owners, then full_suite
 	for _, imp := range importsToExpand {
-		impTests, impStrategy := expandSingleImport(imp, policy, allTestFiles, moduleMappings, filesByModule)
+		impTests, impStrategy := expandSingleImport(imp, policy, allTestFiles, moduleMappings, filesByModule, owners)
 
 		// Track what this import expanded to
 		for j := range info.Files {
@@ -3850,7 +4799,7 @@ func expandForDynamicImports(
 		// Track if we had to escalate
 		if impStrategy == "full_suite" {
 			strategyUsed = "full_suite"
-		} else if impStrategy == "owners" && strategyUsed != "full_suite" {
+		} else if strings.HasPrefix(impStrategy, "owners") && strategyUsed != "full_suite" {
 			strategyUsed = "owners"
 		}
 	}
@@ -3881,6 +4830,7 @@ func expandSingleImport(
 	allTestFiles []string,
 	moduleMappings []ModulePathMapping,
 	filesByModule map[string][]string,
+	owners *codeowners.File,
 ) ([]string, string) {
 	var tests []string
 	fileDir := filepath.Dir(imp.Path)
@@ -3923,9 +4873,18 @@ func expandSingleImport(
 		}
 	}
 
-	// Strategy 3: owners (would parse CODEOWNERS - for now use parent directories)
-	if policy.Expansion == "owners" || policy.OwnersFallback {
-		// Expand to parent directory tests as a proxy for "team ownership"
+	// Strategy 3: owners - tests owned by the same CODEOWNERS entries
+	if (policy.Expansion == "owners" || policy.OwnersFallback) && owners != nil {
+		if fileOwners := owners.Owners(imp.Path); len(fileOwners) > 0 {
+			tests = testsSharingOwners(owners, fileOwners, allTestFiles)
+			if len(tests) > 0 {
+				return tests, "owners: " + strings.Join(fileOwners, ",")
+			}
+		}
+	}
+
+	// Without CODEOWNERS, use parent directories as a proxy for team ownership
+	if (policy.Expansion == "owners" || policy.OwnersFallback) && owners == nil {
 		parentDir := filepath.Dir(fileDir)
 		for _, t := range allTestFiles {
 			testDir := filepath.Dir(t)
@@ -3943,6 +4902,98 @@ func expandSingleImport(
 	return allTestFiles, "full_suite"
 }
 
+// testsSharingOwners returns the test files owned by any of the given owners
+func testsSharingOwners(owners *codeowners.File, fileOwners []string, allTestFiles []string) []string {
+	var tests []string
+	for _, t := range allTestFiles {
+		if len(codeowners.Shared(fileOwners, owners.Owners(t))) > 0 {
+			tests = append(tests, t)
+		}
+	}
+	return tests
+}
+
+// loadCodeOwners finds the CODEOWNERS file in the snapshot being planned.
+// Returns nil when the snapshot has none.
+func loadCodeOwners(readContent func(path string) ([]byte, error)) (*codeowners.File, error) {
+	for _, loc := range codeowners.Locations {
+		content, err := readContent(loc)
+		if err != nil {
+			continue
+		}
+		owners, err := codeowners.Parse(content)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", loc, err)
+		}
+		owners.Path = loc
+		for _, skipped := range owners.Skipped {
+			fmt.Fprintf(os.Stderr, "warning: %s: skipping %s\n", loc, skipped)
+		}
+		return owners, nil
+	}
+	return nil, nil
+}
+
+// buildOwnersInfo attaches owners to the changed files
+func buildOwnersInfo(owners *codeowners.File, changedFiles []string) *OwnersInfo {
+	info := &OwnersInfo{
+		Source:  owners.Path,
+		Files:   make(map[string][]string),
+		Modules: make(map[string][]string),
+		Tests:   make(map[string][]string),
+	}
+	for _, f := range changedFiles {
+		if o := owners.Owners(f); len(o) > 0 {
+			info.Files[f] = o
+		}
+	}
+	return info
+}
+
+// moduleOwners returns the owners of each module: the union of the owners of
+// the module's files
+func moduleOwners(owners *codeowners.File, modules []string, allPaths []string, matcher *module.Matcher) map[string][]string {
+	pathsByModule := make(map[string][]string)
+	for _, p := range allPaths {
+		for _, m := range matcher.MatchPath(p) {
+			pathsByModule[m] = append(pathsByModule[m], p)
+		}
+	}
+	result := make(map[string][]string)
+	for _, mod := range modules {
+		if o := owners.OwnersOf(pathsByModule[mod]); len(o) > 0 {
+			result[mod] = o
+		}
+	}
+	return result
+}
+
+// widenByOwners selects the tests owned by the owners of the changed source
+// files. Returns test -> shared owners.
+func widenByOwners(owners *codeowners.File, changedFiles []string, allTestFiles []string) map[string][]string {
+	changedOwners := make(map[string]bool)
+	for _, f := range changedFiles {
+		if parse.IsTestFile(f) {
+			continue
+		}
+		for _, o := range owners.Owners(f) {
+			changedOwners[o] = true
+		}
+	}
+	result := make(map[string][]string)
+	if len(changedOwners) == 0 {
+		return result
+	}
+	for _, t := range allTestFiles {
+		for _, o := range owners.Owners(t) {
+			if changedOwners[o] {
+				result[t] = append(result[t], o)
+			}
+		}
+	}
+	return result
+}
+
 // estimateBoundedFootprint estimates how many files a bounded pattern matches
 func estimateBoundedFootprint(boundedBy string, allFiles []string) int {
 	// Extract the pattern from the boundedBy string (e.g., "webpackInclude: /\.widget\.js$/")
@@ -4178,6 +5229,275 @@ func detectStructuralRisksWithContent(changedFiles []string, affectedTests map[s
 	return risks
 }
 
+// detectSemanticRisks runs change detection on the changed source files and
+// reports error handling, concurrency and sensitive API changes as risks.
+// Sensitive changes trigger expansion; the others lower confidence only.
+func detectSemanticRisks(changedFiles []string, langByPath map[string]string, readBase, readHead FileContentReader, detector *classify.Detector) []StructuralRisk {
+	var risks []StructuralRisk
+	for _, file := range changedFiles {
+		lang := langByPath[file]
+		if !classify.IsCodeLang(lang) || parse.IsTestFile(file) {
+			continue
+		}
+		before, errBase := readBase(file)
+		after, errHead := readHead(file)
+		if errBase != nil || errHead != nil {
+			continue // Added and removed files have no edits to classify
+		}
+		changes, err := detector.DetectChanges(file, before, after, "", lang)
+		if err != nil {
+			continue
+		}
+
+		for _, ct := range changes {
+			line := 0
+			if len(ct.Evidence.FileRanges) > 0 {
+				line = ct.Evidence.FileRanges[0].Start[0] + 1
+			}
+			switch ct.Category {
+			case classify.ErrorHandlingChanged:
+				risks = append(risks, StructuralRisk{
+					Type:        RiskErrorHandling,
+					Description: fmt.Sprintf("Error handling changed in %s:%d - failure paths are rarely covered by tests", file, line),
+					Severity:    "low",
+					FilePath:    file,
+				})
+			case classify.ConcurrencyChanged:
+				risks = append(risks, StructuralRisk{
+					Type:        RiskConcurrency,
+					Description: fmt.Sprintf("Concurrency changed in %s:%d - races may not show up in tests", file, line),
+					Severity:    "medium",
+					FilePath:    file,
+				})
+			case classify.SensitiveAPITouched:
+				var kinds []string
+				for _, sym := range ct.Evidence.Symbols {
+					if kind, ok := strings.CutPrefix(sym, "sensitive:"); ok {
+						kinds = append(kinds, kind)
+					}
+				}
+				risks = append(risks, StructuralRisk{
+					Type:        RiskSensitiveAPI,
+					Description: fmt.Sprintf("Sensitive API touched in %s:%d (%s)", file, line, strings.Join(kinds, ", ")),
+					Severity:    "high",
+					FilePath:    file,
+					Triggered:   true,
+				})
+			}
+		}
+	}
+	return risks
+}
+
+// dependencySourceExts are the source files that can import each
+// ecosystem's packages.
+var dependencySourceExts = map[string][]string{
+	"go":    {".go"},
+	"npm":   {".js", ".jsx", ".ts", ".tsx", ".mjs", ".cjs"},
+	"cargo": {".rs"},
+	"pypi":  {".py"},
+}
+
+// analyzeDependencyChanges diffs the changed dependency manifests and finds
+// the files below each manifest that import a changed package. scoped holds
+// the manifests whose change the importers' tests cover: only dependencies
+// changed, every upgraded package is imported somewhere (so none is only a
+// transitive dependency), and a lockfile only moves packages a manifest next
+// to it changed. Added and removed manifests are never scoped.
+func analyzeDependencyChanges(changedFiles, allPaths []string, readBase, readHead FileContentReader) ([]CIDependencyChange, map[string]bool) {
+	var changes []CIDependencyChange
+	scoped := make(map[string]bool)
+	var lockfiles []string
+	direct := make(map[string]bool) // dir + ecosystem + name of manifest changes
+
+	contents := make(map[string][]byte)
+	importers := func(manifest, ecosystem, name string) []string {
+		dir := filepath.Dir(manifest)
+		var result []string
+		for _, path := range allPaths {
+			if dir != "." && !strings.HasPrefix(path, dir+"/") {
+				continue
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if !slices.Contains(dependencySourceExts[ecosystem], ext) {
+				continue
+			}
+			content, ok := contents[path]
+			if !ok {
+				content, _ = readHead(path)
+				contents[path] = content
+			}
+			if classify.DependencyImported(ecosystem, name, content) {
+				result = append(result, path)
+			}
+		}
+		return result
+	}
+
+	for _, file := range changedFiles {
+		ecosystem := classify.ManifestEcosystem(file)
+		if ecosystem == "" {
+			continue
+		}
+		before, errBase := readBase(file)
+		after, errHead := readHead(file)
+		if errBase != nil || errHead != nil {
+			continue
+		}
+		beforeManifest, err := classify.ParseManifest(file, before)
+		if err != nil {
+			continue
+		}
+		afterManifest, err := classify.ParseManifest(file, after)
+		if err != nil {
+			continue
+		}
+
+		ok := beforeManifest.Rest == afterManifest.Rest
+		for _, dc := range classify.DiffManifests(beforeManifest, afterManifest) {
+			change := CIDependencyChange{
+				Manifest:         file,
+				DependencyChange: dc,
+				Importers:        importers(file, ecosystem, dc.Name),
+			}
+			if !afterManifest.Lockfile {
+				direct[filepath.Dir(file)+"\x00"+ecosystem+"\x00"+dc.Name] = true
+				if dc.Category == classify.DependencyUpgraded && len(change.Importers) == 0 {
+					ok = false
+				}
+			}
+			changes = append(changes, change)
+		}
+		scoped[file] = ok
+		if afterManifest.Lockfile {
+			lockfiles = append(lockfiles, file)
+		}
+	}
+
+	for _, file := range lockfiles {
+		key := filepath.Dir(file) + "\x00" + classify.ManifestEcosystem(file) + "\x00"
+		for _, c := range changes {
+			if c.Manifest == file && !direct[key+c.Name] {
+				scoped[file] = false
+			}
+		}
+	}
+	for file, ok := range scoped {
+		if !ok {
+			delete(scoped, file)
+		}
+	}
+	return changes, scoped
+}
+
+// scopeDependencyRisks replaces the config change risk of each scoped
+// manifest with a dependency change risk that doesn't trigger a full run.
+// Major upgrades and downgrades are medium severity, others low.
+func scopeDependencyRisks(risks []StructuralRisk, changes []CIDependencyChange, scoped map[string]bool) []StructuralRisk {
+	var result []StructuralRisk
+	for _, r := range risks {
+		if r.Type != RiskConfigChange || !scoped[r.FilePath] {
+			result = append(result, r)
+			continue
+		}
+
+		counts := make(map[string]int)
+		importers := make(map[string]bool)
+		severity := "low"
+		for _, c := range changes {
+			if c.Manifest != r.FilePath {
+				continue
+			}
+			switch {
+			case c.Category == classify.DependencyAdded:
+				counts["added"]++
+			case c.Category == classify.DependencyRemoved:
+				counts["removed"]++
+			case c.Downgrade:
+				counts["downgraded"]++
+				severity = "medium"
+			case c.Delta == "major":
+				counts["major upgrade"]++
+				severity = "medium"
+			default:
+				counts["upgraded"]++
+			}
+			for _, path := range c.Importers {
+				importers[path] = true
+			}
+		}
+		var parts []string
+		for _, kind := range []string{"added", "removed", "upgraded", "major upgrade", "downgraded"} {
+			if counts[kind] > 0 {
+				parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+			}
+		}
+		if len(parts) == 0 {
+			parts = append(parts, "no dependency changes")
+		}
+		result = append(result, StructuralRisk{
+			Type:        RiskDependencyChange,
+			Description: fmt.Sprintf("Dependencies changed in %s: %s - running tests of %d importing files", r.FilePath, strings.Join(parts, ", "), len(importers)),
+			Severity:    severity,
+			FilePath:    r.FilePath,
+		})
+	}
+	return result
+}
+
+// testsReachingFiles returns the test files among paths, and the tests that
+// test or import one of them.
+func testsReachingFiles(db *graph.DB, paths []string, filePathByID map[string]string) []string {
+	tests := make(map[string]bool)
+	for _, path := range paths {
+		if parse.IsTestFile(path) {
+			tests[path] = true
+		}
+		addTestsReachingFile(db, path, filePathByID, tests)
+	}
+	return mapKeysToSortedSlice(tests)
+}
+
+// addTestsReachingFile adds to tests the files that test path, and the test
+// files that import it. Edges are looked up by path, which handles
+// content-addressed ID changes when files are modified.
+func addTestsReachingFile(db *graph.DB, path string, filePathByID map[string]string, tests map[string]bool) {
+	srcPath := func(e *graph.Edge) string {
+		if path, ok := filePathByID[util.BytesToHex(e.Src)]; ok {
+			return path
+		}
+		// Source file might not be in the current snapshot; query the node
+		if node, err := db.GetNode(e.Src); err == nil && node != nil {
+			path, _ := node.Payload["path"].(string)
+			return path
+		}
+		return ""
+	}
+
+	testsEdges, _ := db.GetEdgesToByPath(path, graph.EdgeTests)
+	for _, e := range testsEdges {
+		if p := srcPath(e); p != "" {
+			tests[p] = true
+		}
+	}
+	importsEdges, _ := db.GetEdgesToByPath(path, graph.EdgeImports)
+	for _, e := range importsEdges {
+		if p := srcPath(e); parse.IsTestFile(p) {
+			tests[p] = true
+		}
+	}
+}
+
+// newChangeDetector returns a change detector that also reports the CI
+// policy's sensitive call patterns.
+func newChangeDetector() *classify.Detector {
+	detector := classify.NewDetector()
+	if policy, _, err := loadCIPolicy(); err == nil {
+		detector.AddSensitivePatterns(policy.Sensitive.Patterns...)
+	}
+	return detector
+}
+
 // calculateConfidence returns a confidence score (0.0-1.0) based on the risk signals
 func calculateConfidence(risks []StructuralRisk, testsFound int, changedFiles int) float64 {
 	if changedFiles == 0 {
@@ -4298,6 +5618,15 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 	var creator *snapshot.Creator
 	var cleanupFunc func() // For temp dir cleanup
 
+	if ciEmit != "" {
+		if _, err := runner.Get(ciEmit); err != nil {
+			return err
+		}
+	}
+	if ciShards < 0 {
+		return fmt.Errorf("--shards must be positive")
+	}
+
 	// Handle --git-range mode: create ephemeral DB and snapshots from git
 	if ciGitRange != "" {
 		// Parse BASE..HEAD format
@@ -4562,6 +5891,59 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 			fileIDByPath[path] = f.ID
 		}
 
+		// Create a content reader for dynamic import detection and CODEOWNERS
+		// Map path -> digest for quick lookup
+		fileDigestByPath := make(map[string]string)
+		for _, f := range files {
+			if path, ok := f.Payload["path"].(string); ok {
+				if digest, ok := f.Payload["digest"].(string); ok {
+					fileDigestByPath[path] = digest
+				}
+			}
+		}
+		contentReader := func(path string) ([]byte, error) {
+			digest, ok := fileDigestByPath[path]
+			if !ok {
+				return nil, fmt.Errorf("file not found: %s", path)
+			}
+			return db.ReadObject(digest)
+		}
+
+		// Base contents, read once for the analyses that compare against
+		// the base snapshot
+		var baseReader func(path string) ([]byte, error)
+		if baseSnapshotID != nil {
+			baseFiles, err := creator.GetSnapshotFiles(baseSnapshotID)
+			if err != nil {
+				return fmt.Errorf("getting base snapshot files: %w", err)
+			}
+			baseReader = snapshotContentReader(db, baseFiles)
+		}
+
+		// Load CODEOWNERS for owner-based expansion
+		owners, err := loadCodeOwners(contentReader)
+		if err != nil {
+			return fmt.Errorf("loading CODEOWNERS: %w", err)
+		}
+		if owners != nil {
+			plan.Owners = buildOwnersInfo(owners, changedFiles)
+			analyzersUsed = append(analyzersUsed, "codeowners@1")
+		}
+		ownersExpansion := ciPolicy.DynamicImports.Expansion == "owners" || ciPolicy.DynamicImports.OwnersFallback
+
+		// Find changed symbols and the test cases that reach them
+		symbolChanges, err := computeSymbolChanges(db, creator, baseSnapshotID, headSnapshotID, changedFiles, files)
+		if err != nil {
+			return fmt.Errorf("computing symbol changes: %w", err)
+		}
+		for _, sc := range symbolChanges {
+			plan.Impact.SymbolsChanged = append(plan.Impact.SymbolsChanged, CISymbolChange{FQ: sc.path + ":" + sc.fq, Change: sc.change})
+		}
+		casesByFile, err := testCasesReaching(db, headSnapshotID, symbolChanges, filePathByID)
+		if err != nil {
+			return fmt.Errorf("finding test cases: %w", err)
+		}
+
 		// Try strategies in order: symbols -> imports -> coverage
 		strategies := []string{ciStrategy}
 		if ciStrategy == "auto" {
@@ -4571,52 +5953,24 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 		for _, strat := range strategies {
 			switch strat {
 			case "symbols":
-				// Try symbol-level analysis
+				// Select tests whose cases reach a changed symbol
 				analyzersUsed = append(analyzersUsed, "symbols@1")
-				// For now, fall through to imports
+				for testPath := range casesByFile {
+					affectedTargets[testPath] = true
+				}
+				// Only stop here when every changed source file is reached;
+				// otherwise keep the tests and let later strategies add more
+				if len(affectedTargets) > 0 && symbolsCoverChanges(changedFiles, symbolChanges, casesByFile) {
+					fallbackUsed = "symbols"
+					break
+				}
 				continue
 
 			case "imports":
 				analyzersUsed = append(analyzersUsed, "imports@1")
 				// Use file-level import graph
 				for _, changedPath := range changedFiles {
-					// Find test files that test this file BY PATH
-					// This handles content-addressed ID changes when files are modified
-					testsEdges, _ := db.GetEdgesToByPath(changedPath, graph.EdgeTests)
-					for _, e := range testsEdges {
-						srcHex := util.BytesToHex(e.Src)
-						if path, ok := filePathByID[srcHex]; ok {
-							affectedTargets[path] = true
-						} else {
-							// Source file might not be in current snapshot's filePathByID
-							// Query the node directly to get its path
-							if srcNode, err := db.GetNode(e.Src); err == nil && srcNode != nil {
-								if srcPath, ok := srcNode.Payload["path"].(string); ok {
-									affectedTargets[srcPath] = true
-								}
-							}
-						}
-					}
-
-					// Find files that import this file and are tests (also by path)
-					importsEdges, _ := db.GetEdgesToByPath(changedPath, graph.EdgeImports)
-					for _, e := range importsEdges {
-						srcHex := util.BytesToHex(e.Src)
-						if path, ok := filePathByID[srcHex]; ok {
-							if parse.IsTestFile(path) {
-								affectedTargets[path] = true
-							}
-						} else {
-							// Query node directly
-							if srcNode, err := db.GetNode(e.Src); err == nil && srcNode != nil {
-								if srcPath, ok := srcNode.Payload["path"].(string); ok {
-									if parse.IsTestFile(srcPath) {
-										affectedTargets[srcPath] = true
-									}
-								}
-							}
-						}
-					}
+					addTestsReachingFile(db, changedPath, filePathByID, affectedTargets)
 				}
 
 				if len(affectedTargets) > 0 {
@@ -4644,6 +5998,15 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 				filesWithCoverage := 0
 				filesWithoutCoverage := 0
 				testsFromCoverage := make(map[string]bool)
+				testsNarrowed := make(map[string]bool)
+				var coverageFiles []CoverageFile
+
+				// Base contents are needed to compute changed line ranges
+				lineLevel := ciPolicy.Coverage.LineLevel && baseReader != nil
+				var ranger *changedlines.Analyzer
+				if lineLevel {
+					ranger = changedlines.New()
+				}
 
 				for _, changedPath := range changedFiles {
 					// Skip test files themselves
@@ -4666,17 +6029,59 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 
 					if hasCoverage && len(entries) > 0 {
 						filesWithCoverage++
+						cf := CoverageFile{Path: changedPath, Granularity: "file"}
+
+						// Narrow to tests whose covered lines intersect the change
+						var baseRanges, headRanges []diff.Range
+						switch {
+						case !ciPolicy.Coverage.LineLevel:
+							cf.Reason = "line-level selection disabled"
+						case !lineLevel:
+							cf.Reason = "no base snapshot"
+						case !coverageHasLines(entries):
+							cf.Reason = "no line data"
+						case !changedlines.Parses(changedPath):
+							cf.Reason = "language not parsed"
+						default:
+							before, errBase := baseReader(changedPath)
+							after, errHead := contentReader(changedPath)
+							if errBase != nil || errHead != nil {
+								cf.Reason = "file added or removed"
+								break
+							}
+							baseRanges, headRanges = ranger.Ranges(changedPath, before, after)
+							cf.Granularity = "line"
+							cf.BaseRanges = changedlines.Format(baseRanges)
+							cf.HeadRanges = changedlines.Format(headRanges)
+						}
+
 						for _, entry := range entries {
 							// Filter by MinHits policy
-							if entry.HitCount >= ciPolicy.Coverage.MinHits && entry.TestID != "aggregate" && entry.TestID != "" {
-								testsFromCoverage[entry.TestID] = true
+							if entry.HitCount < ciPolicy.Coverage.MinHits || entry.TestID == "aggregate" || entry.TestID == "" {
+								continue
+							}
+							// Entries without line data fall back to file level
+							if cf.Granularity == "line" && len(entry.LinesCovered) > 0 &&
+								!changedlines.Intersects(baseRanges, entry.LinesCovered) &&
+								!changedlines.Intersects(headRanges, entry.LinesCovered) {
+								testsNarrowed[entry.TestID] = true
+								continue
 							}
+							testsFromCoverage[entry.TestID] = true
+							cf.Tests = append(cf.Tests, entry.TestID)
 						}
+						sort.Strings(cf.Tests)
+						coverageFiles = append(coverageFiles, cf)
 					} else {
 						filesWithoutCoverage++
 					}
 				}
 
+				// A test narrowed out for one file may still cover another
+				for testID := range testsFromCoverage {
+					delete(testsNarrowed, testID)
+				}
+
 				// Add tests from coverage to affected targets
 				for testPath := range testsFromCoverage {
 					affectedTargets[testPath] = true
@@ -4695,6 +6100,8 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 					FilesWithoutCoverage: filesWithoutCoverage,
 					TestsFromCoverage:    mapKeysToSortedSlice(testsFromCoverage),
 					CoverageMapAge:       coverageAge,
+					Files:                coverageFiles,
+					TestsNarrowed:        mapKeysToSortedSlice(testsNarrowed),
 				}
 
 				// If files without coverage, increase uncertainty
@@ -4730,25 +6137,20 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 					changedSet[p] = true
 				}
 
+				// Schemas are read from the head snapshot, falling back to
+				// the working tree
+				readSchema := func(path string) ([]byte, error) {
+					if data, err := contentReader(path); err == nil {
+						return data, nil
+					}
+					return os.ReadFile(path)
+				}
+
 				for _, contract := range contractRegistry.Contracts {
 					// Check if this contract schema was changed
 					if changedSet[contract.Path] {
-						// Read current schema and compute digest
-						currentDigest := ""
-						if data, err := os.ReadFile(contract.Path); err == nil {
-							currentDigest = util.Blake3HashHex(data)
-						}
-
 						// If digest changed from registered, this is a schema change
-						if currentDigest != "" && currentDigest != contract.Digest {
-							change := ContractChange{
-								Path:         contract.Path,
-								Type:         contract.Type,
-								Service:      contract.Service,
-								DigestBefore: contract.Digest,
-								DigestAfter:  currentDigest,
-								Tests:        contract.Tests,
-							}
+						if change, ok := contractSchemaChange(contract, baseReader, readSchema); ok {
 							schemasChanged = append(schemasChanged, change)
 
 							// Add registered tests for this contract
@@ -4788,17 +6190,111 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 							fmt.Sprintf("contract_change:%d_schemas", len(schemasChanged)))
 						analyzersUsed = append(analyzersUsed, "contracts@1")
 					}
+					// Breaking schema changes add further risk
+					breaking := 0
+					for _, change := range schemasChanged {
+						if change.Breaking {
+							breaking++
+						}
+					}
+					if breaking > 0 {
+						plan.Uncertainty.Score += 10
+						plan.Uncertainty.Sources = append(plan.Uncertainty.Sources,
+							fmt.Sprintf("contract_breaking:%d_schemas", breaking))
+					}
 				}
 			}
 		}
 
-		// Update provenance with analyzers used
-		plan.Provenance.Analyzers = analyzersUsed
-
-		plan.Policy.FallbackUsed = fallbackUsed
+		// === MISS HISTORY ===
+		// Select tests that failed after being skipped for changes to the
+		// same files before
+		if ciPolicy.Misses.Enabled {
+			if records := loadMissRecords(); len(records) > 0 {
+				flaky := flakyTests(loadFlakyLedger(), ciPolicy.Flaky)
+				learned := learnMissMappings(records, time.Now(), ciPolicy.Misses, flaky)
+				isTest := make(map[string]bool, len(allTestFiles))
+				for _, t := range allTestFiles {
+					isTest[t] = true
+				}
+				info := &MissHistoryInfo{Records: len(records)}
+				addedByMisses := make(map[string]bool)
+				for _, f := range changedFiles {
+					for _, m := range learned[f] {
+						if m.Weight < ciPolicy.Misses.MinWeight || !isTest[m.Test] {
+							continue
+						}
+						if !affectedTargets[m.Test] {
+							addedByMisses[m.Test] = true
+							affectedTargets[m.Test] = true
+						}
+						info.Mappings = append(info.Mappings, *m)
+					}
+				}
+				for i := range info.Mappings {
+					info.Mappings[i].Added = addedByMisses[info.Mappings[i].Test]
+				}
+				if len(info.Mappings) > 0 {
+					sort.Slice(info.Mappings, func(i, j int) bool {
+						if info.Mappings[i].File != info.Mappings[j].File {
+							return info.Mappings[i].File < info.Mappings[j].File
+						}
+						return info.Mappings[i].Test < info.Mappings[j].Test
+					})
+					plan.Misses = info
+					analyzersUsed = append(analyzersUsed, "misses@1")
+				}
+			}
+		}
 
-		// Convert affected targets to sorted list
-		for t := range affectedTargets {
+		// Report dependency changes. With importers scope, a manifest that
+		// only changes dependencies selects the tests of the files importing
+		// them
+		var dependencyScoped map[string]bool
+		if baseReader != nil {
+			var allPaths []string
+			for path := range fileIDByPath {
+				allPaths = append(allPaths, path)
+			}
+			sort.Strings(allPaths)
+			plan.Impact.Dependencies, dependencyScoped = analyzeDependencyChanges(changedFiles, allPaths, baseReader, contentReader)
+			if ciPolicy.Dependencies.Scope != "importers" {
+				dependencyScoped = nil
+			}
+		}
+		if len(dependencyScoped) > 0 {
+			analyzersUsed = append(analyzersUsed, "dependencies@1")
+			importerSet := make(map[string]bool)
+			for _, c := range plan.Impact.Dependencies {
+				if dependencyScoped[c.Manifest] {
+					for _, path := range c.Importers {
+						importerSet[path] = true
+					}
+				}
+			}
+			var importers []string
+			for path := range importerSet {
+				importers = append(importers, path)
+			}
+			sort.Strings(importers)
+			added := 0
+			for _, t := range testsReachingFiles(db, importers, filePathByID) {
+				if !affectedTargets[t] {
+					affectedTargets[t] = true
+					added++
+				}
+			}
+			plan.ExpansionLog = append(plan.ExpansionLog,
+				fmt.Sprintf("dependencies (importers) → %d importing files, %d tests", len(importers), added))
+		}
+
+		// Update provenance with analyzers used
+		plan.Provenance.Analyzers = analyzersUsed
+
+		plan.Policy.FallbackUsed = fallbackUsed
+
+		// Convert affected targets to sorted list
+		for t := range affectedTargets {
 			plan.Targets.Run = append(plan.Targets.Run, t)
 		}
 		sort.Strings(plan.Targets.Run)
@@ -4811,13 +6307,34 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 			plan.Uncertainty.Score += 30
 			plan.Uncertainty.Sources = append(plan.Uncertainty.Sources, "no_test_mapping:present")
 
+			// Widen to tests owned by the same code owners; the risk policy
+			// still applies on top
+			var ownerTests map[string][]string
+			if owners != nil && ownersExpansion {
+				ownerTests = widenByOwners(owners, changedFiles, allTestFiles)
+			}
+			if len(ownerTests) > 0 {
+				for t, o := range ownerTests {
+					affectedTargets[t] = true
+					plan.Targets.Run = append(plan.Targets.Run, t)
+					plan.Owners.Tests[t] = o
+				}
+				sort.Strings(plan.Targets.Run)
+				plan.Policy.Expanded = true
+				plan.Risk = "low"
+				plan.ExpansionLog = append(plan.ExpansionLog,
+					fmt.Sprintf("no_test_mapping → owners (%s) expanded by %d tests",
+						strings.Join(owners.OwnersOf(changedFiles), ","), len(ownerTests)))
+			}
+
 			// Apply risk policy (original behavior still applies)
 			switch ciRiskPolicy {
 			case "expand":
 				// Add all test files as targets
 				for _, f := range files {
 					path, _ := f.Payload["path"].(string)
-					if parse.IsTestFile(path) {
+					if parse.IsTestFile(path) && !affectedTargets[path] {
+						affectedTargets[path] = true
 						plan.Targets.Run = append(plan.Targets.Run, path)
 					}
 				}
@@ -4856,28 +6373,33 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 
 			// Build path mappings for accurate test matching
 			moduleMappings = buildModulePathMappings(matcher, modulesAffected)
-		}
 
-		// Create a content reader for dynamic import detection
-		// Map path -> digest for quick lookup
-		fileDigestByPath := make(map[string]string)
-		for _, f := range files {
-			if path, ok := f.Payload["path"].(string); ok {
-				if digest, ok := f.Payload["digest"].(string); ok {
-					fileDigestByPath[path] = digest
+			if owners != nil {
+				var allPaths []string
+				for path := range fileIDByPath {
+					allPaths = append(allPaths, path)
 				}
+				plan.Owners.Modules = moduleOwners(owners, modulesAffected, allPaths, matcher)
 			}
 		}
-		contentReader := func(path string) ([]byte, error) {
-			digest, ok := fileDigestByPath[path]
-			if !ok {
-				return nil, fmt.Errorf("file not found: %s", path)
-			}
-			return db.ReadObject(digest)
-		}
 
 		// Detect structural risks (with content analysis for dynamic imports)
 		risks := detectStructuralRisksWithContent(changedFiles, affectedTargets, allTestFiles, modulesAffected, contentReader)
+
+		// Add risks from what the edits do: error handling, concurrency, sensitive APIs
+		if baseReader != nil {
+			langByPath := make(map[string]string)
+			for _, f := range files {
+				path, _ := f.Payload["path"].(string)
+				langByPath[path], _ = f.Payload["lang"].(string)
+			}
+			risks = append(risks, detectSemanticRisks(changedFiles, langByPath, baseReader, contentReader, newChangeDetector())...)
+		}
+
+		// Scoped manifests no longer trigger a full run
+		if len(dependencyScoped) > 0 {
+			risks = scopeDependencyRisks(risks, plan.Impact.Dependencies, dependencyScoped)
+		}
 		plan.Safety.StructuralRisks = risks
 
 		// Detect dynamic imports in detail and perform scoped expansion
@@ -4922,8 +6444,24 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 			changedFiles,
 			moduleMappings,
 			moduleTestMap,
+			owners,
 		)
 
+		// Record which tests were added because of shared ownership
+		if owners != nil && dynamicImportInfo != nil {
+			for _, imp := range dynamicImportInfo.Files {
+				if !strings.HasPrefix(imp.ExpandedTo, "owners") {
+					continue
+				}
+				impOwners := owners.Owners(imp.Path)
+				for _, t := range dynamicExpandedTests {
+					if shared := codeowners.Shared(impOwners, owners.Owners(t)); len(shared) > 0 {
+						plan.Owners.Tests[t] = shared
+					}
+				}
+			}
+		}
+
 		// Add dynamically expanded tests to targets
 		if len(dynamicExpandedTests) > 0 {
 			for _, t := range dynamicExpandedTests {
@@ -5047,6 +6585,12 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 			}
 		}
 
+		// Narrow selected test files to the cases that reach changed symbols
+		if plan.Mode == "selective" && !plan.Policy.Expanded {
+			plan.Targets.Cases = selectTestCases(plan.Targets.Run, changedFiles, casesByFile)
+			plan.Targets.Filters = caseFilters(&plan)
+		}
+
 		// Adjust risk level based on safety analysis
 		if len(risks) > 0 && plan.Risk == "low" {
 			hasHighRisk := false
@@ -5062,6 +6606,40 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Flag tests to run that are known to be flaky
+	running := plan.Targets.Run
+	if runnerTargets(&plan).Full {
+		running = allTestFiles
+	}
+	ledger := loadFlakyLedger()
+	flaky := flakyTests(ledger, ciPolicy.Flaky)
+	for _, t := range running {
+		if flaky[t] {
+			ft := ledger.Tests[t]
+			plan.Safety.FlakyTests = append(plan.Safety.FlakyTests, FlakyTestInfo{
+				Test:      t,
+				FlakeRate: math.Round(flakeRate(ft)*100) / 100,
+				Runs:      ft.Runs,
+				Failures:  ft.Failures,
+			})
+		}
+	}
+
+	// Select the build targets of affected modules
+	if len(ciPolicy.Targets.Modules) > 0 && matcher != nil {
+		deps, err := layers.Dependencies(db, headSnapshotID, matcher)
+		if err != nil {
+			return fmt.Errorf("resolving module dependencies: %w", err)
+		}
+		plan.BuildTargets = selectBuildTargets(ciPolicy.Targets, changedFiles, matcher, deps)
+		plan.Provenance.Analyzers = append(plan.Provenance.Analyzers, "targets@1")
+	}
+
+	// Split the tests to run across parallel jobs
+	if ciShards > 0 {
+		plan.Sharding = shardTargets(running, allTestFiles, loadOrCreateTimingsMap(), ciShards)
+	}
+
 	// Output the plan
 	planJSON, err := json.MarshalIndent(plan, "", "  ")
 	if err != nil {
@@ -5073,7 +6651,17 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 		if err := os.WriteFile(ciOutFile, planJSON, 0644); err != nil {
 			return fmt.Errorf("writing plan file: %w", err)
 		}
-		fmt.Printf("Plan written to %s\n", ciOutFile)
+		if ciEmit != "" {
+			// Keep stdout to the emitted commands
+			fmt.Fprintf(os.Stderr, "Plan written to %s\n", ciOutFile)
+		} else {
+			fmt.Printf("Plan written to %s\n", ciOutFile)
+		}
+	}
+
+	// Print runner commands instead of the plan
+	if ciEmit != "" {
+		return emitRunnerCommands(&plan, ciEmit)
 	}
 
 	// Handle --explain flag for human-readable output
@@ -5108,9 +6696,19 @@ func runCIPlan(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 		fmt.Printf("  Files changed: %d\n", len(plan.Impact.FilesChanged))
 		fmt.Printf("  Targets to run: %d\n", len(plan.Targets.Run))
+		if len(plan.Targets.Cases) > 0 {
+			fmt.Printf("  Test cases: %d (narrowed by changed symbols)\n", len(plan.Targets.Cases))
+		}
 		if len(plan.Targets.Full) > 0 {
 			fmt.Printf("  Full suite size: %d\n", len(plan.Targets.Full))
 		}
+		if plan.BuildTargets != nil {
+			fmt.Printf("  Build targets: %d of %d affected\n", len(plan.BuildTargets.Affected),
+				len(plan.BuildTargets.Affected)+len(plan.BuildTargets.Skipped))
+		}
+		if plan.Sharding != nil {
+			fmt.Printf("  Shards: %d (%d of %d files timed)\n", plan.Sharding.Count, plan.Sharding.TimedFiles, shardedFileCount(plan.Sharding))
+		}
 		if plan.Policy.Expanded || plan.Safety.AutoExpanded {
 			fmt.Printf("  (Expanded for safety)\n")
 		}
@@ -5351,6 +6949,19 @@ func printExplainTable(plan CIPlan) {
 	fmt.Println()
 }
 
+func runCICommand(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(ciPlanFile)
+	if err != nil {
+		return fmt.Errorf("reading plan file: %w", err)
+	}
+
+	var plan CIPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("parsing plan file: %w", err)
+	}
+	return emitRunnerCommands(&plan, ciEmit)
+}
+
 func runCIPrint(cmd *cobra.Command, args []string) error {
 	// Read plan file
 	data, err := os.ReadFile(ciPlanFile)
@@ -5386,6 +6997,45 @@ func runCIPrint(cmd *cobra.Command, args []string) error {
 				fmt.Printf("  %s\n", t)
 			}
 		}
+		if len(plan.Targets.Cases) > 0 {
+			fmt.Println("\nTest cases:")
+			for _, tc := range plan.Targets.Cases {
+				fmt.Printf("  %s :: %s\n", tc.File, tc.Name)
+			}
+			fmt.Println("\nRunner filters:")
+			for _, framework := range []string{parse.FrameworkGo, parse.FrameworkPytest, parse.FrameworkJest} {
+				for _, cmd := range plan.Targets.Filters[framework] {
+					fmt.Printf("  %s\n", cmd)
+				}
+			}
+		}
+		if bt := plan.BuildTargets; bt != nil {
+			fmt.Printf("\nBuild targets (%d affected, %d skipped):\n", len(bt.Affected), len(bt.Skipped))
+			if bt.All {
+				fmt.Printf("  All targets: %s\n", bt.Reason)
+			}
+			via := make(map[string][]string)
+			for _, m := range bt.Modules {
+				via[m.Module] = m.Via
+			}
+			for _, t := range bt.Affected {
+				fmt.Printf("  %s %s (%s", t.Kind, t.Name, t.Module)
+				if len(via[t.Module]) > 0 {
+					fmt.Printf(", via %s", strings.Join(via[t.Module], " → "))
+				}
+				fmt.Println(")")
+			}
+		}
+		if plan.Sharding != nil {
+			fmt.Printf("\nShards (%d of %d files timed, %.1fs assumed otherwise):\n",
+				plan.Sharding.TimedFiles, shardedFileCount(plan.Sharding), plan.Sharding.DefaultSeconds)
+			for _, shard := range plan.Sharding.Shards {
+				fmt.Printf("  [%d] %d files, ~%.1fs\n", shard.Index, len(shard.Targets), shard.EstimatedSeconds)
+				for _, t := range shard.Targets {
+					fmt.Printf("      %s\n", t)
+				}
+			}
+		}
 
 	case "impact":
 		fmt.Println("Impact:")
@@ -5427,6 +7077,12 @@ func runCIPrint(cmd *cobra.Command, args []string) error {
 				fmt.Printf("  [%s] %s%s\n", r.Severity, r.Description, triggered)
 			}
 		}
+		if len(plan.Safety.FlakyTests) > 0 {
+			fmt.Printf("\nFlaky Tests: %d (failures don't trigger tripwires)\n", len(plan.Safety.FlakyTests))
+			for _, f := range plan.Safety.FlakyTests {
+				fmt.Printf("  %s (flake rate %.0f%%, failed %d of %d runs)\n", f.Test, f.FlakeRate*100, f.Failures, f.Runs)
+			}
+		}
 		if len(plan.Safety.ExpansionReasons) > 0 {
 			fmt.Println("\nExpansion Reasons:")
 			for _, reason := range plan.Safety.ExpansionReasons {
@@ -5457,17 +7113,50 @@ func runCIPrint(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		// 2. Symbol-level impact
-		for _, sym := range plan.Impact.SymbolsChanged {
-			for _, t := range plan.Targets.Run {
-				// Check if test imports/depends on this symbol
-				if strings.Contains(sym.FQ, filepath.Dir(t)) {
-					causeMap[t] = append(causeMap[t], fmt.Sprintf("symbol changed: %s (%s)", sym.FQ, sym.Change))
+		// 2. Symbol-level impact: test cases reaching changed symbols
+		for _, tc := range plan.Targets.Cases {
+			causeMap[tc.File] = append(causeMap[tc.File], fmt.Sprintf("case %s reaches %s", tc.Name, strings.Join(tc.Symbols, ", ")))
+		}
+		if len(plan.Targets.Cases) == 0 {
+			for _, sym := range plan.Impact.SymbolsChanged {
+				for _, t := range plan.Targets.Run {
+					// Check if test imports/depends on this symbol
+					if strings.Contains(sym.FQ, filepath.Dir(t)) {
+						causeMap[t] = append(causeMap[t], fmt.Sprintf("symbol changed: %s (%s)", sym.FQ, sym.Change))
+					}
+				}
+			}
+		}
+
+		// 3. Coverage of the changed lines (or files, without line data)
+		if plan.Coverage != nil {
+			for _, cf := range plan.Coverage.Files {
+				for _, t := range cf.Tests {
+					if cf.Granularity == "line" {
+						causeMap[t] = append(causeMap[t], fmt.Sprintf("coverage: covers changed lines of %s (%s)", cf.Path, coverageRangesLabel(cf)))
+					} else {
+						causeMap[t] = append(causeMap[t], fmt.Sprintf("coverage: covers %s (file-level, %s)", cf.Path, cf.Reason))
+					}
 				}
 			}
 		}
 
-		// 3. Expansion log entries
+		// 4. Owner-based widening (shared CODEOWNERS with changed files)
+		if plan.Owners != nil {
+			for t, o := range plan.Owners.Tests {
+				causeMap[t] = append(causeMap[t], fmt.Sprintf("owners: %s also own changed files", strings.Join(o, ", ")))
+			}
+		}
+
+		// 5. Learned from recorded misses
+		if plan.Misses != nil {
+			for _, m := range plan.Misses.Mappings {
+				causeMap[m.Test] = append(causeMap[m.Test], fmt.Sprintf("miss history: failed after being skipped for %s (misses: %d, weight %.2f, last %s)",
+					m.File, m.Misses, m.Weight, m.LastMissAt))
+			}
+		}
+
+		// 6. Expansion log entries
 		for _, log := range plan.ExpansionLog {
 			// Parse expansion log: "reason → tests..."
 			parts := strings.SplitN(log, " → ", 2)
@@ -5482,7 +7171,7 @@ func runCIPrint(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		// 4. Dynamic import causes
+		// 7. Dynamic import causes
 		if plan.DynamicImport != nil && plan.DynamicImport.Detected {
 			for _, imp := range plan.DynamicImport.Files {
 				if imp.ExpandedTo != "" {
@@ -5502,7 +7191,7 @@ func runCIPrint(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		// 5. Structural risks that triggered expansion
+		// 8. Structural risks that triggered expansion
 		for _, r := range plan.Safety.StructuralRisks {
 			if r.Triggered {
 				for _, t := range plan.Targets.Run {
@@ -5514,7 +7203,7 @@ func runCIPrint(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		// 6. Auto-expansion reasons
+		// 9. Auto-expansion reasons
 		if plan.Safety.AutoExpanded {
 			for _, reason := range plan.Safety.ExpansionReasons {
 				for _, t := range plan.Targets.Run {
@@ -5547,6 +7236,24 @@ func runCIPrint(cmd *cobra.Command, args []string) error {
 			}
 		}
 
+		// Show tests left out because they don't reach the changed lines
+		if plan.Coverage != nil && len(plan.Coverage.TestsNarrowed) > 0 {
+			fmt.Println("\nNot selected (covered file, but none of the changed lines)")
+			for _, t := range plan.Coverage.TestsNarrowed {
+				fmt.Printf("  %s\n", t)
+			}
+		}
+
+		// Show ownership of the changed files
+		if plan.Owners != nil && len(plan.Owners.Files) > 0 {
+			fmt.Printf("\nOwners (%s)\n", plan.Owners.Source)
+			for _, f := range plan.Impact.FilesChanged {
+				if o, ok := plan.Owners.Files[f]; ok {
+					fmt.Printf("  %s: %s\n", f, strings.Join(o, ", "))
+				}
+			}
+		}
+
 		// Show if full suite was triggered
 		if plan.Safety.PanicSwitch {
 			fmt.Println("\n  FULL SUITE TRIGGERED (panic switch)")
@@ -5627,6 +7334,9 @@ func runCIPrint(cmd *cobra.Command, args []string) error {
 			if len(plan.Coverage.TestsFromCoverage) > 0 {
 				fmt.Printf("  Tests from coverage:    %d\n", len(plan.Coverage.TestsFromCoverage))
 			}
+			if len(plan.Coverage.TestsNarrowed) > 0 {
+				fmt.Printf("  Narrowed by lines:      %d\n", len(plan.Coverage.TestsNarrowed))
+			}
 		}
 
 		// Contracts info
@@ -5665,6 +7375,7 @@ type RuntimeRiskReport struct {
 	TripwireTriggered bool                `json:"tripwireTriggered"`
 	Risks             []RuntimeRiskSignal `json:"risks"`
 	Recommendation    string              `json:"recommendation"`
+	FlakyIgnored      []string            `json:"flakyIgnored,omitempty"` // Flaky tests whose failures were ignored
 }
 
 // RuntimeRiskSignal represents a single detected runtime risk
@@ -5675,6 +7386,7 @@ type RuntimeRiskSignal struct {
 	File        string `json:"file,omitempty"`
 	Line        int    `json:"line,omitempty"`
 	Evidence    string `json:"evidence,omitempty"`
+	Flaky       bool   `json:"flaky,omitempty"` // From a flaky test; doesn't trigger the tripwire
 }
 
 // Runtime risk signal types
@@ -5740,6 +7452,22 @@ var runtimeRiskPatterns = []struct {
 	{`panic: .*nil pointer`, RuntimeRiskUnexpectedFail, "high", "Go nil pointer panic"},
 	{`FAIL\s+[\w/.]+\s+\[build failed\]`, RuntimeRiskSetupCrash, "critical", "Go build failed"},
 
+	// ===== JVM (Gradle / Maven) =====
+	{`java\.lang\.ClassNotFoundException: ([\w.$]+)`, RuntimeRiskModuleNotFound, "critical", "Java class not found"},
+	{`java\.lang\.NoClassDefFoundError: ([\w/$]+)`, RuntimeRiskModuleNotFound, "critical", "Java class definition missing"},
+	{`java\.lang\.NoSuchMethodError`, RuntimeRiskImportError, "critical", "Java method missing - stale dependency"},
+	{`java\.lang\.ExceptionInInitializerError`, RuntimeRiskSetupCrash, "critical", "Java static initializer failed"},
+	{`Failed to load ApplicationContext`, RuntimeRiskSetupCrash, "critical", "Spring context failed to load"},
+
+	// ===== Ruby / RSpec =====
+	{`LoadError: cannot load such file -- (\S+)`, RuntimeRiskModuleNotFound, "critical", "Ruby file not found"},
+	{`NameError: uninitialized constant ([\w:]+)`, RuntimeRiskImportError, "critical", "Ruby constant missing"},
+	{`An error occurred while loading`, RuntimeRiskSetupCrash, "critical", "RSpec failed to load spec file"},
+
+	// ===== Rust =====
+	{`error\[E0432\]: unresolved import`, RuntimeRiskImportError, "critical", "Rust unresolved import"},
+	{`error: could not compile`, RuntimeRiskSetupCrash, "critical", "Rust compilation failed"},
+
 	// ===== Jest / JavaScript Test Runners =====
 	{`beforeAll.*failed|beforeEach.*failed`, RuntimeRiskSetupCrash, "critical", "Test setup hook failed"},
 	{`afterAll.*failed|afterEach.*failed`, RuntimeRiskSetupCrash, "high", "Test teardown hook failed"},
@@ -5808,8 +7536,36 @@ func runCIDetectRuntimeRisk(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Convert content to string for pattern matching
-	contentStr := string(content)
+	// Failures of known flaky tests don't trip the wire
+	ciPolicy, _, err := loadCIPolicy()
+	if err != nil {
+		return fmt.Errorf("loading CI policy: %w", err)
+	}
+	flaky := flakyTests(loadFlakyLedger(), ciPolicy.Flaky)
+
+	// Scan the whole log, or each failed case of a JUnit report, which
+	// attributes risks to the case's test file
+	sources := []runtimeRiskSource{{text: string(content)}}
+	isJUnit := ciLogFormat == "junit" || (ciLogFormat == "auto" && isJUnitXML(content))
+	if isJUnit {
+		cases, err := junit.Parse(content)
+		if err != nil {
+			return err
+		}
+		sources = junitRiskSources(cases, candidateTestFiles(plan), flaky)
+	}
+	failures := 0 // Failed cases of tests that aren't flaky
+	flakyFailed := make(map[string]bool)
+	for _, src := range sources {
+		if src.flaky {
+			flakyFailed[src.file] = true
+		} else if isJUnit {
+			failures++
+		}
+	}
+	if len(flakyFailed) > 0 {
+		report.FlakyIgnored = mapKeysToSortedSlice(flakyFailed)
+	}
 
 	// Check each pattern
 	for _, p := range runtimeRiskPatterns {
@@ -5818,39 +7574,45 @@ func runCIDetectRuntimeRisk(cmd *cobra.Command, args []string) error {
 			continue
 		}
 
-		matches := re.FindAllStringSubmatch(contentStr, -1)
-		for _, match := range matches {
-			risk := RuntimeRiskSignal{
-				Type:        p.riskType,
-				Severity:    p.severity,
-				Description: p.description,
-			}
+		for _, src := range sources {
+			matches := re.FindAllStringSubmatch(src.text, -1)
+			for _, match := range matches {
+				risk := RuntimeRiskSignal{
+					Type:        p.riskType,
+					Severity:    p.severity,
+					Description: p.description,
+					File:        src.file,
+					Flaky:       src.flaky,
+				}
 
-			// Extract additional context if available
-			if len(match) > 1 {
-				risk.Evidence = match[1]
-			} else {
-				risk.Evidence = match[0]
-			}
+				// Extract additional context if available
+				if len(match) > 1 {
+					risk.Evidence = match[1]
+				} else {
+					risk.Evidence = match[0]
+				}
 
-			// Check if the risk is related to files outside the plan selection
-			if plan != nil && risk.File != "" {
-				inPlan := false
-				for _, t := range plan.Targets.Run {
-					if strings.Contains(t, risk.File) || strings.Contains(risk.File, t) {
-						inPlan = true
-						break
+				// Check if the risk is related to files outside the plan selection
+				if risk.Flaky {
+					risk.Description += " (flaky test, ignored)"
+				} else if plan != nil && risk.File != "" {
+					inPlan := false
+					for _, t := range plan.Targets.Run {
+						if strings.Contains(t, risk.File) || strings.Contains(risk.File, t) {
+							inPlan = true
+							break
+						}
+					}
+					if inPlan {
+						// Risk is in selected files, lower severity
+						risk.Description += " (in selected files)"
+					} else {
+						risk.Description += " (NOT in selected files - possible miss)"
 					}
 				}
-				if inPlan {
-					// Risk is in selected files, lower severity
-					risk.Description += " (in selected files)"
-				} else {
-					risk.Description += " (NOT in selected files - possible miss)"
-				}
-			}
 
-			report.Risks = append(report.Risks, risk)
+				report.Risks = append(report.Risks, risk)
+			}
 		}
 	}
 
@@ -5858,10 +7620,15 @@ func runCIDetectRuntimeRisk(cmd *cobra.Command, args []string) error {
 	report.TotalRisks = len(report.Risks)
 	report.RisksDetected = report.TotalRisks > 0
 
-	// Count by severity
+	// Count by severity, leaving out flaky tests
 	criticalCount := 0
 	highCount := 0
+	counted := 0
 	for _, r := range report.Risks {
+		if r.Flaky {
+			continue
+		}
+		counted++
 		switch r.Severity {
 		case "critical":
 			criticalCount++
@@ -5871,8 +7638,13 @@ func runCIDetectRuntimeRisk(cmd *cobra.Command, args []string) error {
 	}
 
 	// Determine if tripwire should trigger
+	// Text logs show failures only through risk signals; JUnit reports list
+	// every failed case
+	if !isJUnit {
+		failures = counted
+	}
 	tripwireTriggered := criticalCount > 0 || highCount > 0
-	if ciRerunOnFail && report.RisksDetected {
+	if ciRerunOnFail && failures > 0 {
 		tripwireTriggered = true
 	}
 
@@ -5881,10 +7653,12 @@ func runCIDetectRuntimeRisk(cmd *cobra.Command, args []string) error {
 		report.Recommendation = "RERUN: Critical runtime errors detected. Run full test suite."
 	} else if highCount > 0 {
 		report.Recommendation = "RERUN: High severity runtime errors detected. Run full test suite."
-	} else if report.RisksDetected && ciRerunOnFail {
+	} else if failures > 0 && ciRerunOnFail {
 		report.Recommendation = "RERUN: Failures detected with --rerun-on-fail. Run full test suite."
-	} else if report.RisksDetected {
+	} else if counted > 0 {
 		report.Recommendation = "WARNING: Minor runtime issues detected. Monitor for patterns."
+	} else if len(report.FlakyIgnored) > 0 {
+		report.Recommendation = "OK: Only known flaky tests failed."
 	} else {
 		report.Recommendation = "OK: No runtime risk signals detected."
 	}
@@ -5915,6 +7689,9 @@ func runCIDetectRuntimeRisk(cmd *cobra.Command, args []string) error {
 			fmt.Printf("Tripwire:       TRIGGERED (rerun recommended)\n")
 		}
 		fmt.Printf("Recommendation: %s\n", report.Recommendation)
+		if len(report.FlakyIgnored) > 0 {
+			fmt.Printf("Flaky Ignored:  %s\n", strings.Join(report.FlakyIgnored, ", "))
+		}
 
 		if len(report.Risks) > 0 {
 			fmt.Println("\nDetected Risks:")
@@ -5924,6 +7701,9 @@ func runCIDetectRuntimeRisk(cmd *cobra.Command, args []string) error {
 					break
 				}
 				fmt.Printf("  [%s] %s: %s\n", r.Severity, r.Type, r.Description)
+				if r.File != "" {
+					fmt.Printf("         File: %s\n", r.File)
+				}
 				if r.Evidence != "" {
 					evidence := r.Evidence
 					if len(evidence) > 60 {
@@ -5955,6 +7735,7 @@ type MissRecord struct {
 	Timestamp     string   `json:"timestamp"`
 	PlanFile      string   `json:"planFile,omitempty"`
 	PlanProvenance CIProvenance `json:"planProvenance,omitempty"`
+	ChangedFiles  []string `json:"changedFiles,omitempty"` // Files changed in the planned change
 	FailedTests   []string `json:"failedTests"`
 	SelectedTests []string `json:"selectedTests"`
 	MissedTests   []string `json:"missedTests"` // Failed but not selected
@@ -5985,12 +7766,12 @@ func runCIRecordMiss(cmd *cobra.Command, args []string) error {
 			failedTests[i] = strings.TrimSpace(failedTests[i])
 		}
 	} else if ciEvidenceFile != "" {
-		// Try to parse evidence file (Jest/Mocha JSON format)
+		// Try to parse evidence file (Jest/pytest/Go JSON or JUnit XML)
 		evidenceData, err := os.ReadFile(ciEvidenceFile)
 		if err != nil {
 			return fmt.Errorf("reading evidence file: %w", err)
 		}
-		failedTests = extractFailedTestsFromEvidence(evidenceData)
+		failedTests = extractFailedTestsFromEvidence(evidenceData, candidateTestFiles(&plan))
 	} else {
 		return fmt.Errorf("either --failed or --evidence is required")
 	}
@@ -6025,6 +7806,7 @@ func runCIRecordMiss(cmd *cobra.Command, args []string) error {
 		Timestamp:      time.Now().UTC().Format(time.RFC3339),
 		PlanFile:       ciPlanFile,
 		PlanProvenance: plan.Provenance,
+		ChangedFiles:   plan.Impact.FilesChanged,
 		FailedTests:    failedTests,
 		SelectedTests:  plan.Targets.Run,
 		MissedTests:    missedTests,
@@ -6068,10 +7850,33 @@ func runCIRecordMiss(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// extractFailedTestsFromEvidence parses test results to find failed tests
-func extractFailedTestsFromEvidence(data []byte) []string {
+// extractFailedTestsFromEvidence parses test results to find failed tests.
+// JUnit XML cases are mapped to the test files they came from among
+// testFiles.
+func extractFailedTestsFromEvidence(data []byte, testFiles []string) []string {
 	var failedTests []string
 
+	// Try JUnit XML
+	if isJUnitXML(data) {
+		cases, err := junit.Parse(data)
+		if err != nil {
+			return nil
+		}
+		seen := make(map[string]bool)
+		for _, c := range cases {
+			if !c.Failed {
+				continue
+			}
+			for _, f := range junitCaseFiles(c, testFiles) {
+				if !seen[f] {
+					seen[f] = true
+					failedTests = append(failedTests, f)
+				}
+			}
+		}
+		return failedTests
+	}
+
 	// Try Jest format
 	var jestResult struct {
 		TestResults []struct {
@@ -6122,6 +7927,97 @@ func extractFailedTestsFromEvidence(data []byte) []string {
 	return failedTests
 }
 
+// runtimeRiskSource is text scanned for runtime risks, with the test file it
+// came from when known.
+type runtimeRiskSource struct {
+	file  string
+	text  string
+	flaky bool // Every file it came from is a known flaky test
+}
+
+// junitRiskSources returns the message and output of each failed case.
+func junitRiskSources(cases []junit.Case, testFiles []string, flaky map[string]bool) []runtimeRiskSource {
+	var sources []runtimeRiskSource
+	for _, c := range cases {
+		if !c.Failed {
+			continue
+		}
+		files := junitCaseFiles(c, testFiles)
+		allFlaky := true
+		for _, f := range files {
+			allFlaky = allFlaky && flaky[f]
+		}
+		sources = append(sources, runtimeRiskSource{
+			file:  strings.Join(files, ", "),
+			text:  c.Message + "\n" + c.Output,
+			flaky: allFlaky,
+		})
+	}
+	return sources
+}
+
+// junitCaseFiles maps a JUnit case to its test files, or to its file
+// attribute or classname when it matches none.
+func junitCaseFiles(c junit.Case, testFiles []string) []string {
+	if files := junit.Locate(c.Key(), testFiles); len(files) > 0 {
+		return files
+	}
+	return []string{c.Key()}
+}
+
+// isJUnitXML reports whether data looks like a JUnit XML report.
+func isJUnitXML(data []byte) bool {
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	trimmed := strings.TrimSpace(string(head))
+	return strings.HasPrefix(trimmed, "<") &&
+		(strings.Contains(trimmed, "<testsuites") || strings.Contains(trimmed, "<testsuite"))
+}
+
+// candidateTestFiles lists test paths JUnit cases can map back to: those
+// named in the plan, plus test files in the working tree, since a missed
+// test is by definition not among the selected ones.
+func candidateTestFiles(plan *CIPlan) []string {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(paths []string) {
+		for _, p := range paths {
+			if !seen[p] {
+				seen[p] = true
+				files = append(files, p)
+			}
+		}
+	}
+	if plan != nil {
+		add(plan.Targets.Run)
+		add(plan.Targets.Skip)
+		add(plan.Targets.Full)
+	}
+
+	var onDisk []string
+	filepath.WalkDir(".", func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != "." && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" || name == "target") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		path = filepath.ToSlash(path)
+		if parse.IsTestFile(path) || strings.Contains("/"+path, "/src/test/") {
+			onDisk = append(onDisk, path)
+		}
+		return nil
+	})
+	add(onDisk)
+	return files
+}
+
 // appendMissRecord appends a miss record to the CI misses log
 func appendMissRecord(record MissRecord) error {
 	// Find .kai directory
@@ -6147,40 +8043,256 @@ func appendMissRecord(record MissRecord) error {
 	return err
 }
 
-// runCIExplainDynamicImports scans files for dynamic imports and explains their impact
-func runCIExplainDynamicImports(cmd *cobra.Command, args []string) error {
-	// Default to current directory
-	targetPath := "."
-	if len(args) > 0 {
-		targetPath = args[0]
-	}
-
-	// Load CI policy
-	ciPolicy, _, err := loadCIPolicy()
+// loadMissRecords reads .kai/ci-misses.jsonl, skipping malformed lines
+func loadMissRecords() []MissRecord {
+	data, err := os.ReadFile(filepath.Join(".", kaiDir, "ci-misses.jsonl"))
 	if err != nil {
-		return fmt.Errorf("loading CI policy: %w", err)
+		return nil
 	}
 
-	// Find all files to scan
-	var filesToScan []string
-	stat, err := os.Stat(targetPath)
-	if err != nil {
-		return fmt.Errorf("accessing path: %w", err)
+	var records []MissRecord
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var r MissRecord
+		if json.Unmarshal([]byte(line), &r) == nil {
+			records = append(records, r)
+		}
 	}
+	return records
+}
 
-	if stat.IsDir() {
-		// Walk directory
-		err = filepath.Walk(targetPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil // Skip errors
+// learnMissMappings turns recorded misses into changed file -> test mappings.
+// Each miss adds 1/N to the weight of the mapping from each of the N files
+// changed in that plan to the missed test, so misses in small changes count
+// more. A miss counts half as much every HalfLifeDays, and not at all after
+// RetentionDays. Records without changed files cannot be attributed, and
+// flaky tests are left out since their failures say nothing about the change.
+func learnMissMappings(records []MissRecord, now time.Time, policy CIPolicyMisses, flaky map[string]bool) map[string]map[string]*MissMapping {
+	learned := make(map[string]map[string]*MissMapping)
+	for _, r := range records {
+		if len(r.MissedTests) == 0 || len(r.ChangedFiles) == 0 {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, r.Timestamp)
+		if err != nil {
+			continue
+		}
+		ageDays := now.Sub(at).Hours() / 24
+		if policy.RetentionDays > 0 && ageDays > float64(policy.RetentionDays) {
+			continue
+		}
+		decay := 1.0
+		if policy.HalfLifeDays > 0 && ageDays > 0 {
+			decay = math.Pow(0.5, ageDays/float64(policy.HalfLifeDays))
+		}
+		share := decay / float64(len(r.ChangedFiles))
+
+		for _, f := range r.ChangedFiles {
+			if learned[f] == nil {
+				learned[f] = make(map[string]*MissMapping)
 			}
-			if info.IsDir() {
-				// Skip common non-source directories
-				base := filepath.Base(path)
-				if base == "node_modules" || base == ".git" || base == "vendor" || base == "__pycache__" {
-					return filepath.SkipDir
+			for _, t := range r.MissedTests {
+				if flaky[t] {
+					continue
+				}
+				m, ok := learned[f][t]
+				if !ok {
+					m = &MissMapping{File: f, Test: t}
+					learned[f][t] = m
+				}
+				m.Weight += share
+				m.Misses++
+				if r.Timestamp > m.LastMissAt {
+					m.LastMissAt = r.Timestamp
 				}
-				return nil
+			}
+		}
+	}
+	for _, tests := range learned {
+		for _, m := range tests {
+			m.Weight = math.Round(m.Weight*100) / 100
+		}
+	}
+	return learned
+}
+
+// MissReport summarizes the planner's accuracy over recorded runs
+type MissReport struct {
+	Records   int           `json:"records"`
+	Since     string        `json:"since,omitempty"`
+	Selected  int           `json:"selected"`  // Tests selected, summed over records
+	Failed    int           `json:"failed"`    // Tests that failed
+	Caught    int           `json:"caught"`    // Failed and selected
+	Missed    int           `json:"missed"`    // Failed but not selected
+	Recall    float64       `json:"recall"`    // Caught / failed
+	Precision float64       `json:"precision"` // Caught / selected
+	TopMissed []MissCount   `json:"topMissed,omitempty"`
+	Learned   []MissMapping `json:"learned,omitempty"` // Mappings strong enough to select tests
+}
+
+// MissCount is how often a test was missed
+type MissCount struct {
+	Test  string `json:"test"`
+	Count int    `json:"count"`
+}
+
+// buildMissReport aggregates records at or after since (all when zero)
+func buildMissReport(records []MissRecord, since, now time.Time, policy CIPolicyMisses, flaky map[string]bool) MissReport {
+	var report MissReport
+	if !since.IsZero() {
+		report.Since = since.UTC().Format(time.RFC3339)
+	}
+
+	var kept []MissRecord
+	missCounts := make(map[string]int)
+	for _, r := range records {
+		if !since.IsZero() {
+			at, err := time.Parse(time.RFC3339, r.Timestamp)
+			if err != nil || at.Before(since) {
+				continue
+			}
+		}
+		kept = append(kept, r)
+		report.Records++
+		report.Selected += len(r.SelectedTests)
+		report.Failed += len(r.FailedTests)
+		report.Missed += len(r.MissedTests)
+		for _, t := range r.MissedTests {
+			missCounts[t]++
+		}
+	}
+	report.Caught = report.Failed - report.Missed
+	if report.Failed > 0 {
+		report.Recall = float64(report.Caught) / float64(report.Failed)
+	}
+	if report.Selected > 0 {
+		report.Precision = float64(report.Caught) / float64(report.Selected)
+	}
+
+	for t, n := range missCounts {
+		report.TopMissed = append(report.TopMissed, MissCount{Test: t, Count: n})
+	}
+	sort.Slice(report.TopMissed, func(i, j int) bool {
+		if report.TopMissed[i].Count != report.TopMissed[j].Count {
+			return report.TopMissed[i].Count > report.TopMissed[j].Count
+		}
+		return report.TopMissed[i].Test < report.TopMissed[j].Test
+	})
+	if len(report.TopMissed) > 10 {
+		report.TopMissed = report.TopMissed[:10]
+	}
+
+	for _, tests := range learnMissMappings(kept, now, policy, flaky) {
+		for _, m := range tests {
+			if m.Weight >= policy.MinWeight {
+				report.Learned = append(report.Learned, *m)
+			}
+		}
+	}
+	sort.Slice(report.Learned, func(i, j int) bool {
+		if report.Learned[i].Weight != report.Learned[j].Weight {
+			return report.Learned[i].Weight > report.Learned[j].Weight
+		}
+		if report.Learned[i].File != report.Learned[j].File {
+			return report.Learned[i].File < report.Learned[j].File
+		}
+		return report.Learned[i].Test < report.Learned[j].Test
+	})
+	return report
+}
+
+// runCIMissesReport prints the planner's historical precision and recall
+func runCIMissesReport(cmd *cobra.Command, args []string) error {
+	ciPolicy, _, err := loadCIPolicy()
+	if err != nil {
+		return fmt.Errorf("loading CI policy: %w", err)
+	}
+
+	now := time.Now()
+	var since time.Time
+	if ciMissesSinceDays > 0 {
+		since = now.AddDate(0, 0, -ciMissesSinceDays)
+	}
+	flaky := flakyTests(loadFlakyLedger(), ciPolicy.Flaky)
+	report := buildMissReport(loadMissRecords(), since, now, ciPolicy.Misses, flaky)
+
+	if ciMissesJSON {
+		output, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(output))
+		return nil
+	}
+
+	fmt.Println("Test Selection Accuracy")
+	fmt.Println(strings.Repeat("=", 50))
+	if report.Records == 0 {
+		fmt.Println("No miss records. Run 'kai ci record-miss' after test runs to collect them.")
+		return nil
+	}
+	fmt.Printf("Records:    %d", report.Records)
+	if report.Since != "" {
+		fmt.Printf(" (since %s)", report.Since)
+	}
+	fmt.Println()
+	fmt.Printf("Selected:   %d tests\n", report.Selected)
+	fmt.Printf("Failed:     %d tests (%d caught, %d missed)\n", report.Failed, report.Caught, report.Missed)
+	if report.Failed > 0 {
+		fmt.Printf("Recall:     %.1f%% of failing tests were selected\n", report.Recall*100)
+	}
+	if report.Selected > 0 {
+		fmt.Printf("Precision:  %.1f%% of selected tests failed\n", report.Precision*100)
+	}
+
+	if len(report.TopMissed) > 0 {
+		fmt.Println("\nMost missed tests:")
+		for _, m := range report.TopMissed {
+			fmt.Printf("  %3d  %s\n", m.Count, m.Test)
+		}
+	}
+	if len(report.Learned) > 0 {
+		fmt.Println("\nLearned mappings (applied to future plans):")
+		for _, m := range report.Learned {
+			fmt.Printf("  %s → %s (weight %.2f, misses: %d)\n", m.File, m.Test, m.Weight, m.Misses)
+		}
+	}
+	return nil
+}
+
+// runCIExplainDynamicImports scans files for dynamic imports and explains their impact
+func runCIExplainDynamicImports(cmd *cobra.Command, args []string) error {
+	// Default to current directory
+	targetPath := "."
+	if len(args) > 0 {
+		targetPath = args[0]
+	}
+
+	// Load CI policy
+	ciPolicy, _, err := loadCIPolicy()
+	if err != nil {
+		return fmt.Errorf("loading CI policy: %w", err)
+	}
+
+	// Find all files to scan
+	var filesToScan []string
+	stat, err := os.Stat(targetPath)
+	if err != nil {
+		return fmt.Errorf("accessing path: %w", err)
+	}
+
+	if stat.IsDir() {
+		// Walk directory
+		err = filepath.Walk(targetPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil // Skip errors
+			}
+			if info.IsDir() {
+				// Skip common non-source directories
+				base := filepath.Base(path)
+				if base == "node_modules" || base == ".git" || base == "vendor" || base == "__pycache__" {
+					return filepath.SkipDir
+				}
+				return nil
 			}
 			// Only check source files
 			ext := strings.ToLower(filepath.Ext(path))
@@ -6424,6 +8536,12 @@ func createChangesetFromSnapshots(db *graph.DB, baseSnapID, headSnapID []byte, m
 	}
 	defer tx.Rollback()
 
+	// Record the author (system user) so blame can attribute changes
+	author := os.Getenv("USER")
+	if author == "" {
+		author = "unknown"
+	}
+
 	// Create changeset node
 	changeSetPayload := map[string]interface{}{
 		"base":        util.BytesToHex(baseSnapID),
@@ -6431,6 +8549,7 @@ func createChangesetFromSnapshots(db *graph.DB, baseSnapID, headSnapID []byte, m
 		"title":       "",
 		"description": message,
 		"intent":      "",
+		"author":      author,
 		"createdAt":   util.NowMs(),
 	}
 	changeSetID, err := db.InsertNode(tx, graph.KindChangeSet, changeSetPayload)
@@ -6439,7 +8558,7 @@ func createChangesetFromSnapshots(db *graph.DB, baseSnapID, headSnapID []byte, m
 	}
 
 	// Detect change types
-	detector := classify.NewDetector()
+	detector := newChangeDetector()
 
 	// Load symbols for each changed file
 	for i := range changedPaths {
@@ -6479,13 +8598,16 @@ func createChangesetFromSnapshots(db *graph.DB, baseSnapID, headSnapID []byte, m
 			var changes []*classify.ChangeType
 			var err error
 
-			switch lang {
-			case "json":
+			switch {
+			case classify.ManifestEcosystem(path) != "":
+				// Dependencies added, removed and upgraded
+				changes, err = classify.DetectManifestChanges(path, beforeContent, afterContent)
+			case lang == "json":
 				// Use JSON-specific detection
 				changes, err = classify.DetectJSONChanges(path, beforeContent, afterContent)
-			case "ts", "js":
+			case classify.IsCodeLang(lang):
 				// Use tree-sitter based detection
-				changes, err = detector.DetectChanges(path, beforeContent, afterContent, util.BytesToHex(changedFileIDs[i]))
+				changes, err = detector.DetectChanges(path, beforeContent, afterContent, util.BytesToHex(changedFileIDs[i]), lang)
 			default:
 				// Non-parseable files get FILE_CONTENT_CHANGED
 				changes = []*classify.ChangeType{classify.NewFileChange(classify.FileContentChanged, path)}
@@ -7066,6 +9188,10 @@ func runLog(cmd *cobra.Command, args []string) error {
 	}
 	defer db.Close()
 
+	if logSymbol != "" || logFile != "" {
+		return runHistoryLog(db)
+	}
+
 	var entries []logEntry
 
 	// Get all snapshots
@@ -7200,6 +9326,140 @@ func runLog(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// runHistoryLog prints the semantic history of a symbol or file (kai log --symbol/--file).
+func runHistoryLog(db *graph.DB) error {
+	path := ""
+	if logFile != "" {
+		path = filepath.ToSlash(filepath.Clean(logFile))
+	}
+
+	steps, err := blame.History(db, blame.HistoryOptions{
+		Symbol: logSymbol,
+		Path:   path,
+	})
+	if err != nil {
+		return err
+	}
+
+	if logLimit > 0 && len(steps) > logLimit {
+		steps = steps[:logLimit]
+	}
+
+	if logJSON {
+		if steps == nil {
+			steps = []*blame.HistoryStep{}
+		}
+		output, err := json.MarshalIndent(steps, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling JSON: %w", err)
+		}
+		fmt.Println(string(output))
+		return nil
+	}
+
+	if len(steps) == 0 {
+		fmt.Println("No history found.")
+		return nil
+	}
+
+	for i, step := range steps {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		timestamp := "unknown"
+		if step.CreatedAt > 0 {
+			timestamp = time.UnixMilli(step.CreatedAt).Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Printf("[changeset] %s\n", shortID(step.ChangeSetID))
+		fmt.Printf("Date:    %s\n", timestamp)
+		if step.Author != "" {
+			fmt.Printf("Author:  %s\n", step.Author)
+		}
+		if step.Intent != "" {
+			fmt.Printf("Intent:  %s\n", step.Intent)
+		}
+		if len(step.ChangeTypes) > 0 {
+			fmt.Printf("Changes: %s\n", strings.Join(step.ChangeTypes, ", "))
+		}
+		if step.MovedFrom != "" {
+			fmt.Printf("Moved:   from %s\n", step.MovedFrom)
+		}
+
+		sd := &diff.SemanticDiff{Files: step.Files}
+		fmt.Println()
+		fmt.Print(sd.FormatText())
+	}
+
+	return nil
+}
+
+func runBlame(cmd *cobra.Command, args []string) error {
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Split "path:symbol" on the last colon
+	path, symbol := args[0], ""
+	if idx := strings.LastIndex(path, ":"); idx > 0 {
+		path, symbol = path[:idx], path[idx+1:]
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	snapID, err := resolveSnapshotID(db, blameAt)
+	if err != nil {
+		return fmt.Errorf("resolving snapshot: %w", err)
+	}
+
+	entries, err := blame.Compute(db, blame.Options{
+		Path:       path,
+		Symbol:     symbol,
+		SnapshotID: snapID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if blameJSON {
+		output, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling JSON: %w", err)
+		}
+		fmt.Println(string(output))
+		return nil
+	}
+
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s %s (lines %d-%d)\n", e.Kind, e.Symbol, e.StartLine, e.EndLine)
+		if e.ChangeSetID == "" {
+			fmt.Println("  (no recorded semantic change)")
+			continue
+		}
+
+		timestamp := "unknown"
+		if e.CreatedAt > 0 {
+			timestamp = time.UnixMilli(e.CreatedAt).Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("  Changeset: %s\n", shortID(e.ChangeSetID))
+		fmt.Printf("  Date:      %s\n", timestamp)
+		if e.Author != "" {
+			fmt.Printf("  Author:    %s\n", e.Author)
+		}
+		if e.Intent != "" {
+			fmt.Printf("  Intent:    %s\n", e.Intent)
+		}
+		fmt.Printf("  Changes:   %s\n", strings.Join(e.Categories, ", "))
+	}
+
+	return nil
+}
+
 func runStatus(cmd *cobra.Command, args []string) error {
 	// Check if Kai is initialized
 	if _, err := os.Stat(kaiDir); os.IsNotExist(err) {
@@ -7524,6 +9784,22 @@ func runDiff(cmd *cobra.Command, args []string) error {
 			}
 		}
 
+		// A migration means little on its own, so each touched migration
+		// directory is diffed as the schema its files build
+		var migrationDirs []string
+		for i := range sd.Files {
+			if dir := diff.MigrationDir(sd.Files[i].Path); dir != "" {
+				sd.Files[i].Units = nil
+				if !slices.Contains(migrationDirs, dir) {
+					migrationDirs = append(migrationDirs, dir)
+				}
+			}
+		}
+		sort.Strings(migrationDirs)
+		for _, dir := range migrationDirs {
+			sd.Files = append(sd.Files, differ.DiffMigrations(dir, baseContent, headContent))
+		}
+
 		sd.ComputeSummary()
 
 		if diffJSON {
@@ -8114,6 +10390,13 @@ func runIntegrate(cmd *cobra.Command, args []string) error {
 }
 
 func runMerge(cmd *cobra.Command, args []string) error {
+	if mergeContinue {
+		return runMergeContinue(args)
+	}
+	if mergeResolve && mergeOutput == "" {
+		return fmt.Errorf("--resolve needs --output for the resolved file")
+	}
+
 	baseFile := args[0]
 	leftFile := args[1]
 	rightFile := args[2]
@@ -8159,11 +10442,12 @@ func runMerge(cmd *cobra.Command, args []string) error {
 	// Output as JSON if requested
 	if mergeJSON {
 		type jsonConflict struct {
-			Kind      string `json:"kind"`
-			Unit      string `json:"unit"`
-			Message   string `json:"message"`
-			LeftDiff  string `json:"leftDiff,omitempty"`
-			RightDiff string `json:"rightDiff,omitempty"`
+			Kind        string   `json:"kind"`
+			Unit        string   `json:"unit"`
+			Message     string   `json:"message"`
+			LeftDiff    string   `json:"leftDiff,omitempty"`
+			RightDiff   string   `json:"rightDiff,omitempty"`
+			Resolutions []string `json:"resolutions,omitempty"`
 		}
 		type jsonResult struct {
 			Success   bool           `json:"success"`
@@ -8175,15 +10459,19 @@ func runMerge(cmd *cobra.Command, args []string) error {
 			Success: result.Success,
 		}
 		for _, c := range result.Conflicts {
-			jr.Conflicts = append(jr.Conflicts, jsonConflict{
+			jc := jsonConflict{
 				Kind:      string(c.Kind),
 				Unit:      c.UnitKey.String(),
 				Message:   c.Message,
 				LeftDiff:  c.LeftDiff,
 				RightDiff: c.RightDiff,
-			})
+			}
+			for _, r := range c.Resolutions {
+				jc.Resolutions = append(jc.Resolutions, r.Label)
+			}
+			jr.Conflicts = append(jr.Conflicts, jc)
 		}
-		if merged := result.Files["file"]; merged != nil {
+		if merged := result.Files["file"]; merged != nil && result.Success {
 			jr.Merged = string(merged)
 		}
 
@@ -8195,8 +10483,38 @@ func runMerge(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	marked := result.Files["file"]
+	if !result.Success && mergeResolve && marked != nil {
+		// Pick up where an earlier --resolve or hand edit left off
+		if existing, err := os.ReadFile(mergeOutput); err == nil && len(merge.FindConflicts(existing)) > 0 {
+			marked = existing
+		}
+		resolved, remaining := resolveConflicts(marked, result.Conflicts, bufio.NewReader(os.Stdin), os.Stdout)
+		if err := os.WriteFile(mergeOutput, resolved, 0644); err != nil {
+			return fmt.Errorf("writing output file: %w", err)
+		}
+		fmt.Println()
+		if remaining > 0 {
+			fmt.Printf("%d conflict(s) remain in %s\n", remaining, mergeOutput)
+			fmt.Printf("Run 'kai merge --resolve' again, or edit the file and run 'kai merge --continue %s'\n", mergeOutput)
+		} else {
+			fmt.Printf("All conflicts resolved -> %s\n", mergeOutput)
+		}
+		return nil
+	}
+
 	// Text output
 	if !result.Success {
+		if marked != nil {
+			if mergeOutput != "" {
+				if err := os.WriteFile(mergeOutput, marked, 0644); err != nil {
+					return fmt.Errorf("writing output file: %w", err)
+				}
+			} else {
+				fmt.Print(string(marked))
+			}
+		}
+
 		fmt.Fprintf(os.Stderr, "Merge conflicts detected (%d):\n\n", len(result.Conflicts))
 		for _, c := range result.Conflicts {
 			fmt.Fprintf(os.Stderr, "  %s: %s\n", c.Kind, c.Message)
@@ -8209,6 +10527,10 @@ func runMerge(cmd *cobra.Command, args []string) error {
 			}
 			fmt.Fprintln(os.Stderr)
 		}
+		if marked != nil && mergeOutput != "" {
+			fmt.Fprintf(os.Stderr, "Conflict markers written to %s\n", mergeOutput)
+			fmt.Fprintf(os.Stderr, "Resolve with --resolve, or edit the file and run 'kai merge --continue %s'\n", mergeOutput)
+		}
 		return fmt.Errorf("merge has conflicts")
 	}
 
@@ -8230,6 +10552,135 @@ func runMerge(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// resolveConflicts walks the marked blocks in a merged file, asking which
+// resolution to apply to each. It returns the file with the chosen
+// resolutions applied and the number of blocks left unresolved.
+func resolveConflicts(content []byte, conflicts []merge.Conflict, in *bufio.Reader, out io.Writer) ([]byte, int) {
+	byUnit := make(map[string]*merge.Conflict)
+	for i := range conflicts {
+		byUnit[strings.Join(conflicts[i].UnitKey.SymbolPath, ".")] = &conflicts[i]
+	}
+	autoResolution := func(c *merge.Conflict) *merge.Resolution {
+		for i := range c.Resolutions {
+			if c.Resolutions[i].AutoApply {
+				return &c.Resolutions[i]
+			}
+		}
+		return nil
+	}
+
+	blocks := merge.FindConflicts(content)
+	chosen := make(map[int]*merge.Resolution)
+	autoRest := false
+walk:
+	for i, block := range blocks {
+		c := byUnit[block.Unit]
+		if c == nil {
+			continue // Not from this merge; leave it for the user
+		}
+		if autoRest {
+			if r := autoResolution(c); r != nil {
+				chosen[i] = r
+			}
+			continue
+		}
+
+		fmt.Fprintf(out, "\nConflict %d of %d: %s %s (line %d)\n", i+1, len(blocks), c.Kind, block.Unit, block.Line)
+		fmt.Fprintf(out, "  %s\n", c.Message)
+		if c.LeftDiff != "" {
+			fmt.Fprintf(out, "  Left:  %s\n", c.LeftDiff)
+		}
+		if c.RightDiff != "" {
+			fmt.Fprintf(out, "  Right: %s\n", c.RightDiff)
+		}
+		for _, side := range []struct {
+			name string
+			unit *merge.MergeUnit
+		}{{"left", c.Left}, {"right", c.Right}} {
+			fmt.Fprintf(out, "\n  --- %s\n", side.name)
+			if side.unit == nil {
+				fmt.Fprintln(out, "  (deleted)")
+				continue
+			}
+			for _, line := range strings.Split(string(side.unit.Content), "\n") {
+				fmt.Fprintf(out, "  %s\n", line)
+			}
+		}
+		fmt.Fprintln(out)
+		for j, r := range c.Resolutions {
+			auto := ""
+			if r.AutoApply {
+				auto = " (auto)"
+			}
+			fmt.Fprintf(out, "  [%d] %s%s - %s\n", j+1, r.Label, auto, r.Description)
+		}
+		fmt.Fprintln(out, "  [s] Skip  [a] Auto-resolve this and the rest  [q] Quit")
+
+		for {
+			fmt.Fprint(out, "Choice: ")
+			input, err := in.ReadString('\n')
+			input = strings.TrimSpace(strings.ToLower(input))
+			if n, convErr := strconv.Atoi(input); convErr == nil && n >= 1 && n <= len(c.Resolutions) {
+				chosen[i] = &c.Resolutions[n-1]
+				break
+			}
+			switch input {
+			case "s":
+				continue walk
+			case "a":
+				autoRest = true
+				if r := autoResolution(c); r != nil {
+					chosen[i] = r
+				}
+				continue walk
+			case "q":
+				break walk
+			}
+			if err != nil {
+				break walk // End of input
+			}
+		}
+	}
+
+	// Apply from the end so earlier offsets stay valid
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if r := chosen[i]; r != nil {
+			content = merge.ResolveConflict(content, blocks[i], r.Result)
+		}
+	}
+	return content, len(blocks) - len(chosen)
+}
+
+// runMergeContinue checks that a merged file has no conflict markers left.
+func runMergeContinue(args []string) error {
+	path := mergeOutput
+	if len(args) == 1 {
+		path = args[0]
+	}
+	if path == "" {
+		return fmt.Errorf("--continue needs the merged file, as an argument or --output")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading merged file: %w", err)
+	}
+
+	lines := merge.MarkerLines(content)
+	if len(lines) > 0 {
+		fmt.Fprintf(os.Stderr, "Conflict markers remain in %s:\n", path)
+		fileLines := strings.Split(string(content), "\n")
+		for _, n := range lines {
+			fmt.Fprintf(os.Stderr, "  line %d: %s\n", n, strings.TrimSpace(fileLines[n-1]))
+		}
+		return fmt.Errorf("%d unresolved conflict marker(s) in %s", len(lines), path)
+	}
+
+	fmt.Printf("No conflict markers remain in %s\n", path)
+	fmt.Println("Merge complete.")
+	return nil
+}
+
 func runCheckout(cmd *cobra.Command, args []string) error {
 	db, err := openDB()
 	if err != nil {
@@ -11711,14 +14162,24 @@ func runCIIngestCoverage(cmd *cobra.Command, args []string) error {
 		entries, err = parseCoveragePyCoverage(data)
 	case "jacoco":
 		entries, err = parseJaCoCoCoverage(data)
+	case "go":
+		entries, err = parseGoCoverProfile(data, goModulePath("go.mod"))
+	case "lcov":
+		entries, err = parseLCOVCoverage(data)
+	case "cobertura":
+		entries, err = parseCoberturaCoverage(data)
 	default:
-		return fmt.Errorf("unknown coverage format: %s (use --format nyc|coveragepy|jacoco)", format)
+		return fmt.Errorf("unknown coverage format: %s (use --format nyc|coveragepy|jacoco|go|lcov|cobertura)", format)
 	}
 
 	if err != nil {
 		return fmt.Errorf("parsing coverage (%s): %w", format, err)
 	}
 
+	if ciCoverageTest != "" {
+		entries = attributeCoverageToTest(entries, ciCoverageTest)
+	}
+
 	// Normalize paths to repo-relative POSIX format
 	normalizedEntries := make(map[string][]CoverageEntry)
 	for filePath, testEntries := range entries {
@@ -11832,15 +14293,24 @@ func pruneCoverageMap(cm *CoverageMap, retentionDays int) int {
 // detectCoverageFormat auto-detects coverage format from filename and content
 func detectCoverageFormat(path string, data []byte) string {
 	name := filepath.Base(path)
+	content := string(data)
 
 	if strings.Contains(name, "coverage-final") || strings.Contains(name, "nyc") {
 		return "nyc"
 	}
+	if strings.HasPrefix(content, "mode: ") {
+		return "go"
+	}
+	if strings.HasSuffix(name, ".info") || strings.Contains(content, "end_of_record") {
+		return "lcov"
+	}
 	if strings.HasSuffix(name, ".xml") {
+		if strings.Contains(name, "cobertura") || strings.Contains(content, "<coverage") {
+			return "cobertura"
+		}
 		return "jacoco"
 	}
 
-	content := string(data)
 	if strings.Contains(content, "statementMap") {
 		return "nyc"
 	}
@@ -11958,6 +14428,179 @@ func parseJaCoCoCoverage(data []byte) (map[string][]CoverageEntry, error) {
 	return entries, nil
 }
 
+// coverageLines accumulates covered lines per file and test
+type coverageLines map[string]map[string]map[int]bool
+
+func (c coverageLines) add(file, testID string, line int) {
+	if c[file] == nil {
+		c[file] = make(map[string]map[int]bool)
+	}
+	if c[file][testID] == nil {
+		c[file][testID] = make(map[int]bool)
+	}
+	c[file][testID][line] = true
+}
+
+// entries converts the accumulated lines to coverage entries, one per test,
+// with sorted line numbers
+func (c coverageLines) entries() map[string][]CoverageEntry {
+	entries := make(map[string][]CoverageEntry)
+	for file, tests := range c {
+		testIDs := make([]string, 0, len(tests))
+		for testID := range tests {
+			testIDs = append(testIDs, testID)
+		}
+		sort.Strings(testIDs)
+		for _, testID := range testIDs {
+			lines := make([]int, 0, len(tests[testID]))
+			for line := range tests[testID] {
+				lines = append(lines, line)
+			}
+			sort.Ints(lines)
+			entries[file] = append(entries[file], CoverageEntry{TestID: testID, HitCount: 1, LinesCovered: lines})
+		}
+	}
+	return entries
+}
+
+// attributeCoverageToTest collapses all entries of each file into a single
+// entry for testID
+func attributeCoverageToTest(entries map[string][]CoverageEntry, testID string) map[string][]CoverageEntry {
+	lines := make(coverageLines)
+	for file, fileEntries := range entries {
+		for _, e := range fileEntries {
+			for _, line := range e.LinesCovered {
+				lines.add(file, testID, line)
+			}
+		}
+	}
+	return lines.entries()
+}
+
+// goModulePath reads the module path from a go.mod file, or returns "" if
+// there is none
+func goModulePath(goModFile string) string {
+	data, err := os.ReadFile(goModFile)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`)
+		}
+	}
+	return ""
+}
+
+// parseGoCoverProfile parses `go test -coverprofile` output. File names are
+// import paths; the module path is stripped to make them repo-relative.
+// Concatenated profiles (several "mode:" headers) are accepted.
+func parseGoCoverProfile(data []byte, modulePath string) (map[string][]CoverageEntry, error) {
+	// file:startLine.startCol,endLine.endCol numStatements count
+	blockRe := regexp.MustCompile(`^(.+):(\d+)\.\d+,(\d+)\.\d+ \d+ (\d+)$`)
+
+	lines := make(coverageLines)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		m := blockRe.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: invalid profile block %q", i+1, line)
+		}
+		count, _ := strconv.Atoi(m[4])
+		if count == 0 {
+			continue
+		}
+		file := m[1]
+		if modulePath != "" {
+			file = strings.TrimPrefix(file, modulePath+"/")
+		}
+		start, _ := strconv.Atoi(m[2])
+		end, _ := strconv.Atoi(m[3])
+		for l := start; l <= end; l++ {
+			lines.add(file, "aggregate", l)
+		}
+	}
+
+	return lines.entries(), nil
+}
+
+// parseLCOVCoverage parses LCOV tracefiles. Records under a TN: (test name)
+// line are attributed to that test; records without one are aggregate.
+func parseLCOVCoverage(data []byte) (map[string][]CoverageEntry, error) {
+	lines := make(coverageLines)
+	testID := "aggregate"
+	file := ""
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "TN:"):
+			testID = strings.TrimSpace(strings.TrimPrefix(line, "TN:"))
+			if testID == "" {
+				testID = "aggregate"
+			}
+		case strings.HasPrefix(line, "SF:"):
+			file = strings.TrimPrefix(line, "SF:")
+		case strings.HasPrefix(line, "DA:"):
+			if file == "" {
+				return nil, fmt.Errorf("line %d: DA record outside of a source file", i+1)
+			}
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: invalid DA record %q", i+1, line)
+			}
+			lineNum, err1 := strconv.Atoi(fields[0])
+			hits, err2 := strconv.Atoi(fields[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("line %d: invalid DA record %q", i+1, line)
+			}
+			if hits > 0 {
+				lines.add(file, testID, lineNum)
+			}
+		case line == "end_of_record":
+			file = ""
+		}
+	}
+
+	return lines.entries(), nil
+}
+
+// parseCoberturaCoverage parses Cobertura XML. Class file names are relative
+// to the report's <source> roots and are kept as-is.
+func parseCoberturaCoverage(data []byte) (map[string][]CoverageEntry, error) {
+	var report struct {
+		Packages []struct {
+			Classes []struct {
+				Filename string `xml:"filename,attr"`
+				Lines    []struct {
+					Number int `xml:"number,attr"`
+					Hits   int `xml:"hits,attr"`
+				} `xml:"lines>line"`
+			} `xml:"classes>class"`
+		} `xml:"packages>package"`
+	}
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+
+	lines := make(coverageLines)
+	for _, pkg := range report.Packages {
+		for _, class := range pkg.Classes {
+			for _, l := range class.Lines {
+				if l.Hits > 0 {
+					lines.add(class.Filename, "aggregate", l.Number)
+				}
+			}
+		}
+	}
+
+	return lines.entries(), nil
+}
+
 func loadOrCreateCoverageMap() *CoverageMap {
 	data, err := os.ReadFile(coverageMapFile)
 	if err != nil {
@@ -11993,6 +14636,9 @@ func mergeTestEntries(existing, new []CoverageEntry) []CoverageEntry {
 		if ex, ok := entryMap[e.TestID]; ok {
 			ex.HitCount += e.HitCount
 			ex.LastSeenAt = e.LastSeenAt
+			if len(e.LinesCovered) > 0 {
+				ex.LinesCovered = e.LinesCovered // Latest run reflects the current code
+			}
 		} else {
 			entryCopy := e
 			entryMap[e.TestID] = &entryCopy
@@ -12024,6 +14670,830 @@ func mapKeysToSortedSlice(m map[string]bool) []string {
 	return result
 }
 
+// snapshotContentReader returns a function reading file contents by path
+// from the object store, for the given snapshot files.
+func snapshotContentReader(db *graph.DB, files []*graph.Node) func(path string) ([]byte, error) {
+	// Map path -> digest for quick lookup
+	fileDigestByPath := make(map[string]string)
+	for _, f := range files {
+		if path, ok := f.Payload["path"].(string); ok {
+			if digest, ok := f.Payload["digest"].(string); ok {
+				fileDigestByPath[path] = digest
+			}
+		}
+	}
+	return func(path string) ([]byte, error) {
+		digest, ok := fileDigestByPath[path]
+		if !ok {
+			return nil, fmt.Errorf("file not found: %s", path)
+		}
+		return db.ReadObject(digest)
+	}
+}
+
+// symbolChange is a symbol added, modified or removed by a change.
+type symbolChange struct {
+	id     []byte // Symbol node in the head snapshot (base for removed symbols)
+	fq     string
+	path   string
+	change string // added, modified, removed
+}
+
+// computeSymbolChanges returns the symbols of changed source files whose
+// ranges intersect the changed lines. Test files are skipped: their cases
+// are selected directly.
+func computeSymbolChanges(db *graph.DB, creator *snapshot.Creator, baseSnapshotID, headSnapshotID []byte, changedFiles []string, headFiles []*graph.Node) ([]symbolChange, error) {
+	headByPath := make(map[string]*graph.Node)
+	for _, f := range headFiles {
+		path, _ := f.Payload["path"].(string)
+		headByPath[path] = f
+	}
+	baseByPath := make(map[string]*graph.Node)
+	if baseSnapshotID != nil {
+		baseFiles, err := creator.GetSnapshotFiles(baseSnapshotID)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range baseFiles {
+			path, _ := f.Payload["path"].(string)
+			baseByPath[path] = f
+		}
+	}
+
+	readFile := func(f *graph.Node) []byte {
+		digest, _ := f.Payload["digest"].(string)
+		content, err := db.ReadObject(digest)
+		if err != nil {
+			return nil
+		}
+		return content
+	}
+
+	var ranger *changedlines.Analyzer
+	var changes []symbolChange
+	for _, path := range changedFiles {
+		if parse.IsTestFile(path) {
+			continue
+		}
+		head, base := headByPath[path], baseByPath[path]
+
+		var fileNode *graph.Node
+		var snapID []byte
+		change := "modified"
+		switch {
+		case head != nil && base == nil:
+			fileNode, snapID, change = head, headSnapshotID, "added"
+		case head == nil && base != nil:
+			fileNode, snapID, change = base, baseSnapshotID, "removed"
+		case head != nil:
+			fileNode, snapID = head, headSnapshotID
+		default:
+			continue
+		}
+
+		symbols, err := creator.GetSymbolsInFile(fileNode.ID, snapID)
+		if err != nil {
+			return nil, err
+		}
+
+		var headRanges []diff.Range
+		if change == "modified" {
+			if ranger == nil {
+				ranger = changedlines.New()
+			}
+			_, headRanges = ranger.Ranges(path, readFile(base), readFile(head))
+		}
+
+		for _, sym := range symbols {
+			kind, _ := sym.Payload["kind"].(string)
+			if kind == snapshot.SymbolKindTest {
+				continue
+			}
+			if change == "modified" {
+				start, end := blame.SymbolLines(sym)
+				if !rangesOverlap(headRanges, start+1, end+1) {
+					continue
+				}
+			}
+			fq, _ := sym.Payload["fqName"].(string)
+			changes = append(changes, symbolChange{id: sym.ID, fq: fq, path: path, change: change})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].path != changes[j].path {
+			return changes[i].path < changes[j].path
+		}
+		return changes[i].fq < changes[j].fq
+	})
+	return changes, nil
+}
+
+// rangesOverlap reports whether lines start..end (1-based) overlap any range.
+func rangesOverlap(ranges []diff.Range, start, end int) bool {
+	for _, r := range ranges {
+		if start <= r.EndLine && end >= r.StartLine {
+			return true
+		}
+	}
+	return false
+}
+
+// testCasesReaching returns, by test file, the test cases with a CALLS edge
+// to a changed symbol in the head snapshot.
+func testCasesReaching(db *graph.DB, headSnapshotID []byte, changes []symbolChange, filePathByID map[string]string) (map[string][]CITestCase, error) {
+	byID := make(map[string]*CITestCase)
+	for _, sc := range changes {
+		if sc.change == "removed" {
+			continue
+		}
+		edges, err := db.GetEdgesByContextAndDst(headSnapshotID, graph.EdgeCalls, sc.id)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			key := util.BytesToHex(e.Src)
+			tc, ok := byID[key]
+			if !ok {
+				node, err := db.GetNode(e.Src)
+				if err != nil {
+					return nil, err
+				}
+				if node == nil {
+					continue
+				}
+				if kind, _ := node.Payload["kind"].(string); kind != snapshot.SymbolKindTest {
+					continue
+				}
+				fileID, _ := node.Payload["fileId"].(string)
+				name, _ := node.Payload["fqName"].(string)
+				framework, _ := node.Payload["framework"].(string)
+				tc = &CITestCase{File: filePathByID[fileID], Name: name, Framework: framework}
+				byID[key] = tc
+			}
+			tc.Symbols = append(tc.Symbols, sc.fq)
+		}
+	}
+
+	result := make(map[string][]CITestCase)
+	for _, tc := range byID {
+		if tc.File == "" {
+			continue
+		}
+		sort.Strings(tc.Symbols)
+		result[tc.File] = append(result[tc.File], *tc)
+	}
+	for _, cases := range result {
+		sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	}
+	return result, nil
+}
+
+// symbolsCoverChanges reports whether every changed source file has changed
+// symbols, each reached by at least one test case, so symbol-level selection
+// alone is complete. A file with one unreached symbol still needs the
+// imports strategy.
+func symbolsCoverChanges(changedFiles []string, changes []symbolChange, casesByFile map[string][]CITestCase) bool {
+	reached := make(map[string]bool)
+	for _, cases := range casesByFile {
+		for _, tc := range cases {
+			for _, sym := range tc.Symbols {
+				reached[sym] = true
+			}
+		}
+	}
+	covered := make(map[string]bool)
+	for _, sc := range changes {
+		if prev, seen := covered[sc.path]; !seen || prev {
+			covered[sc.path] = reached[sc.fq]
+		}
+	}
+	for _, path := range changedFiles {
+		if !parse.IsTestFile(path) && !covered[path] {
+			return false
+		}
+	}
+	return true
+}
+
+// selectTestCases narrows each selected test file to the cases that reach a
+// changed symbol. Files that changed themselves, or whose cases reach no
+// changed symbol (they were selected through imports, coverage or policy),
+// keep all of their cases.
+func selectTestCases(run, changedFiles []string, casesByFile map[string][]CITestCase) []CITestCase {
+	changed := make(map[string]bool, len(changedFiles))
+	for _, f := range changedFiles {
+		changed[f] = true
+	}
+
+	var narrowed []CITestCase
+	for _, file := range run {
+		if cases := casesByFile[file]; len(cases) > 0 && !changed[file] {
+			narrowed = append(narrowed, cases...)
+		}
+	}
+	return narrowed
+}
+
+// caseFilters renders the runner commands for each framework with narrowed
+// cases, e.g. go test ./cart -run '^(TestTotal)$'.
+func caseFilters(plan *CIPlan) map[string][]string {
+	if len(plan.Targets.Cases) == 0 {
+		return nil
+	}
+	targets := runnerTargets(plan)
+	targets.Full = false // Filters describe the selection, not the fallback
+	filters := make(map[string][]string)
+	for _, tc := range plan.Targets.Cases {
+		if _, done := filters[tc.Framework]; done {
+			continue
+		}
+		adapter, err := runner.Get(tc.Framework)
+		if err != nil {
+			continue
+		}
+		var cmds []string
+		for _, cmd := range adapter.Commands(targets) {
+			cmds = append(cmds, cmd.String())
+		}
+		filters[tc.Framework] = cmds
+	}
+	return filters
+}
+
+// runnerTargets converts a plan's targets for the runner adapters. Full,
+// expanded and shadow plans, and plans recommending a full run, run the
+// whole suite.
+func runnerTargets(plan *CIPlan) runner.Targets {
+	t := runner.Targets{
+		Run:  plan.Targets.Run,
+		Skip: plan.Targets.Skip,
+		Full: plan.Safety.RecommendFull || plan.Mode == "full" || plan.Mode == "expanded" || plan.Mode == "shadow",
+	}
+	for _, tc := range plan.Targets.Cases {
+		t.Cases = append(t.Cases, runner.Case{File: tc.File, Name: tc.Name})
+	}
+	return t
+}
+
+// emitRunnerCommands prints the commands running the plan with the named
+// runner, one per line. Nothing is printed when no selected test belongs to
+// the runner.
+func emitRunnerCommands(plan *CIPlan, name string) error {
+	adapter, err := runner.Get(name)
+	if err != nil {
+		return err
+	}
+	if plan.Mode == "skip" {
+		fmt.Fprintln(os.Stderr, "No changes; no tests to run")
+		return nil
+	}
+	cmds := adapter.Commands(runnerTargets(plan))
+	if len(cmds) == 0 {
+		fmt.Fprintf(os.Stderr, "No selected tests belong to %s\n", name)
+	}
+	for _, cmd := range cmds {
+		fmt.Println(cmd.String())
+	}
+	return nil
+}
+
+// coverageRangesLabel describes the changed ranges of a file, e.g.
+// "lines 40-55" or "base lines 40-52, head lines 40-55".
+func coverageRangesLabel(cf CoverageFile) string {
+	if cf.BaseRanges == cf.HeadRanges {
+		return "lines " + cf.HeadRanges
+	}
+	var parts []string
+	if cf.BaseRanges != "" {
+		parts = append(parts, "base lines "+cf.BaseRanges)
+	}
+	if cf.HeadRanges != "" {
+		parts = append(parts, "head lines "+cf.HeadRanges)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// coverageHasLines reports whether any entry recorded covered lines.
+func coverageHasLines(entries []CoverageEntry) bool {
+	for _, e := range entries {
+		if len(e.LinesCovered) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ========== Build Targets ==========
+
+// selectBuildTargets returns the build targets affected by the changed
+// files. A module is affected when one of its files changed, or when it
+// depends on an affected module, through imports (deps) or the policy's
+// dependsOn. Changed files outside every module affect all targets unless
+// the policy says to ignore them.
+func selectBuildTargets(policy CIPolicyTargets, changedFiles []string, matcher *module.Matcher, deps map[string][]string) *CIBuildTargets {
+	result := &CIBuildTargets{Modules: []CIAffectedModule{}, Affected: []CIBuildTarget{}, Skipped: []CIBuildTarget{}}
+
+	var changed []string
+	for _, f := range changedFiles {
+		ignored := false
+		for _, pattern := range policy.Ignore {
+			if matched, _ := doublestar.Match(pattern, f); matched {
+				ignored = true
+				break
+			}
+		}
+		if ignored {
+			continue
+		}
+		modules := matcher.MatchPath(f)
+		if len(modules) == 0 && policy.UnmatchedFiles != "ignore" && !result.All {
+			result.All = true
+			result.Reason = fmt.Sprintf("%s is outside every module", f)
+		}
+		changed = append(changed, modules...)
+	}
+
+	// Modules depending on each module, for walking from changed modules to
+	// their dependents
+	dependents := make(map[string][]string)
+	for from, tos := range deps {
+		for _, to := range tos {
+			dependents[to] = append(dependents[to], from)
+		}
+	}
+	for _, m := range policy.Modules {
+		for _, to := range m.DependsOn {
+			dependents[to] = append(dependents[to], m.Module)
+		}
+	}
+
+	// Breadth-first, so each module is reached through a shortest chain
+	via := make(map[string][]string)
+	queue := []string{}
+	sort.Strings(changed)
+	for _, m := range changed {
+		if _, ok := via[m]; !ok {
+			via[m] = nil
+			queue = append(queue, m)
+		}
+	}
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		next := append([]string(nil), dependents[m]...)
+		sort.Strings(next)
+		for _, d := range next {
+			if _, ok := via[d]; ok {
+				continue
+			}
+			chain := via[m]
+			if chain == nil {
+				chain = []string{m}
+			}
+			via[d] = append(append([]string(nil), chain...), d)
+			queue = append(queue, d)
+		}
+	}
+
+	modules := make([]string, 0, len(via))
+	for m := range via {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+	for _, m := range modules {
+		result.Modules = append(result.Modules, CIAffectedModule{Module: m, Via: via[m]})
+	}
+	for _, m := range policy.Modules {
+		_, affected := via[m.Module]
+		for _, kt := range []struct {
+			kind  string
+			names []string
+		}{{"bazel", m.Bazel}, {"make", m.Make}, {"docker", m.Docker}, {"npm", m.NPM}} {
+			for _, name := range kt.names {
+				t := CIBuildTarget{Kind: kt.kind, Name: name, Module: m.Module}
+				if affected || result.All {
+					result.Affected = append(result.Affected, t)
+				} else {
+					result.Skipped = append(result.Skipped, t)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// ========== Test Timings ==========
+
+const timingsFile = ".kai/test-timings.json"
+
+// timingWindow is how many recent runs the moving average of a test's
+// duration effectively covers.
+const timingWindow = 10
+
+// runCIIngestTimings records test durations from JUnit XML reports
+func runCIIngestTimings(cmd *cobra.Command, args []string) error {
+	timings := loadOrCreateTimingsMap()
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+
+	cases := 0
+	for _, from := range ciTimingsFrom {
+		data, err := os.ReadFile(from)
+		if err != nil {
+			return fmt.Errorf("reading report: %w", err)
+		}
+		parsed, err := junit.Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", from, err)
+		}
+
+		// Sum the report's cases per file or class; each report is one run
+		seconds := make(map[string]float64)
+		for _, c := range parsed {
+			if c.Skipped {
+				continue
+			}
+			seconds[c.Key()] += c.Seconds
+			cases++
+		}
+		for key, secs := range seconds {
+			t, ok := timings.Tests[key]
+			if !ok {
+				t = &TestTiming{}
+				timings.Tests[key] = t
+			}
+			t.Runs++
+			if t.Runs == 1 {
+				t.Seconds = secs
+			} else {
+				t.Seconds += (secs - t.Seconds) / float64(min(t.Runs, timingWindow))
+			}
+			t.LastSeenAt = timestamp
+		}
+	}
+	timings.IngestedAt = timestamp
+
+	if err := saveTimingsMap(timings); err != nil {
+		return fmt.Errorf("saving timings: %w", err)
+	}
+
+	fmt.Println("Timing Ingestion Complete")
+	fmt.Println(strings.Repeat("-", 40))
+	fmt.Printf("Reports:     %d\n", len(ciTimingsFrom))
+	fmt.Printf("Test cases:  %d\n", cases)
+	fmt.Printf("Tracked:     %d files/classes\n", len(timings.Tests))
+	fmt.Printf("Saved to:    %s\n", timingsFile)
+	return nil
+}
+
+func loadOrCreateTimingsMap() *TimingsMap {
+	data, err := os.ReadFile(timingsFile)
+	if err != nil {
+		return &TimingsMap{Version: 1, Tests: make(map[string]*TestTiming)}
+	}
+
+	var tm TimingsMap
+	if json.Unmarshal(data, &tm) != nil {
+		return &TimingsMap{Version: 1, Tests: make(map[string]*TestTiming)}
+	}
+
+	if tm.Tests == nil {
+		tm.Tests = make(map[string]*TestTiming)
+	}
+	return &tm
+}
+
+func saveTimingsMap(tm *TimingsMap) error {
+	os.MkdirAll(filepath.Dir(timingsFile), 0755)
+	data, err := json.MarshalIndent(tm, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(timingsFile, data, 0644)
+}
+
+// testFileDurations estimates each test file's duration from the timings.
+// A key that maps to several files (a Go package) is split evenly between
+// them, so keys are located against all test files, not just the selection.
+func testFileDurations(timings *TimingsMap, allTestFiles []string) map[string]float64 {
+	keys := make([]string, 0, len(timings.Tests))
+	for key := range timings.Tests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // Fixed summation order keeps estimates deterministic
+
+	durations := make(map[string]float64)
+	for _, key := range keys {
+		files := junit.Locate(key, allTestFiles)
+		for _, f := range files {
+			durations[f] += timings.Tests[key].Seconds / float64(len(files))
+		}
+	}
+	return durations
+}
+
+// shardTargets assigns the files to n shards, longest first, each to the
+// shard with the least estimated time so far (lowest index on ties). Go test
+// files in one package stay together, since go test runs whole packages.
+// Files without history count as the average timed file, or one second when
+// nothing is timed. The same inputs always give the same shards.
+func shardTargets(files, allTestFiles []string, timings *TimingsMap, n int) *CISharding {
+	durations := testFileDurations(timings, allTestFiles)
+
+	sharding := &CISharding{Count: n, DefaultSeconds: 1}
+	var total float64
+	for _, f := range files {
+		if d, ok := durations[f]; ok {
+			sharding.TimedFiles++
+			total += d
+		}
+	}
+	if sharding.TimedFiles > 0 {
+		sharding.DefaultSeconds = total / float64(sharding.TimedFiles)
+	}
+
+	type unit struct {
+		key     string
+		files   []string
+		seconds float64
+	}
+	byKey := make(map[string]*unit)
+	var units []*unit
+	for _, f := range files {
+		key := f
+		if strings.HasSuffix(f, "_test.go") {
+			key = filepath.Dir(f) + "/"
+		}
+		u, ok := byKey[key]
+		if !ok {
+			u = &unit{key: key}
+			byKey[key] = u
+			units = append(units, u)
+		}
+		u.files = append(u.files, f)
+		if d, ok := durations[f]; ok {
+			u.seconds += d
+		} else {
+			u.seconds += sharding.DefaultSeconds
+		}
+	}
+	sort.Slice(units, func(i, j int) bool {
+		if units[i].seconds != units[j].seconds {
+			return units[i].seconds > units[j].seconds
+		}
+		return units[i].key < units[j].key
+	})
+
+	sharding.Shards = make([]CIShard, n)
+	for i := range sharding.Shards {
+		sharding.Shards[i] = CIShard{Index: i, Targets: []string{}}
+	}
+	for _, u := range units {
+		best := 0
+		for i := 1; i < n; i++ {
+			if sharding.Shards[i].EstimatedSeconds < sharding.Shards[best].EstimatedSeconds {
+				best = i
+			}
+		}
+		sharding.Shards[best].Targets = append(sharding.Shards[best].Targets, u.files...)
+		sharding.Shards[best].EstimatedSeconds += u.seconds
+	}
+	for i := range sharding.Shards {
+		sort.Strings(sharding.Shards[i].Targets)
+	}
+	return sharding
+}
+
+func shardedFileCount(sharding *CISharding) int {
+	count := 0
+	for _, shard := range sharding.Shards {
+		count += len(shard.Targets)
+	}
+	return count
+}
+
+// runCIShard prints one shard's targets, or its runner commands
+func runCIShard(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(ciPlanFile)
+	if err != nil {
+		return fmt.Errorf("reading plan file: %w", err)
+	}
+
+	var plan CIPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("parsing plan file: %w", err)
+	}
+	if plan.Sharding == nil {
+		return fmt.Errorf("plan has no shards; generate it with 'kai ci plan --shards N'")
+	}
+	if ciShardIndex < 0 || ciShardIndex >= len(plan.Sharding.Shards) {
+		return fmt.Errorf("shard index %d out of range (plan has %d shards, indexed from 0)", ciShardIndex, len(plan.Sharding.Shards))
+	}
+	shard := plan.Sharding.Shards[ciShardIndex]
+
+	if ciEmit == "" {
+		for _, t := range shard.Targets {
+			fmt.Println(t)
+		}
+		return nil
+	}
+
+	adapter, err := runner.Get(ciEmit)
+	if err != nil {
+		return err
+	}
+	// The shard already holds the full suite when one is recommended, so
+	// run exactly its files
+	targets := runner.Targets{Run: shard.Targets}
+	if !runnerTargets(&plan).Full {
+		for _, tc := range plan.Targets.Cases {
+			targets.Cases = append(targets.Cases, runner.Case{File: tc.File, Name: tc.Name})
+		}
+	}
+	for _, c := range adapter.Commands(targets) {
+		fmt.Println(c.String())
+	}
+	return nil
+}
+
+// ========== Flaky Tests ==========
+
+const flakyLedgerFile = ".kai/flaky-tests.json"
+
+// runCIIngestResults records test outcomes from JUnit XML reports in the
+// flaky test ledger
+func runCIIngestResults(cmd *cobra.Command, args []string) error {
+	ciPolicy, _, err := loadCIPolicy()
+	if err != nil {
+		return fmt.Errorf("loading CI policy: %w", err)
+	}
+
+	var plan *CIPlan
+	if ciPlanFile != "" {
+		planData, err := os.ReadFile(ciPlanFile)
+		if err != nil {
+			return fmt.Errorf("reading plan: %w", err)
+		}
+		var p CIPlan
+		if err := json.Unmarshal(planData, &p); err != nil {
+			return fmt.Errorf("parsing plan: %w", err)
+		}
+		plan = &p
+	}
+	inputs := ciResultsInputs
+	if inputs == "" && plan != nil {
+		inputs = plan.Provenance.Head
+	}
+	if inputs == "" {
+		return fmt.Errorf("--inputs is required unless --plan records a head snapshot")
+	}
+
+	ledger := loadFlakyLedger()
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	testFiles := candidateTestFiles(plan)
+
+	cases := 0
+	for _, from := range ciResultsFrom {
+		data, err := os.ReadFile(from)
+		if err != nil {
+			return fmt.Errorf("reading report: %w", err)
+		}
+		parsed, err := junit.Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", from, err)
+		}
+
+		// Each report is one run; a file fails it if any of its cases failed
+		failed := make(map[string]bool)
+		for _, c := range parsed {
+			if c.Skipped {
+				continue
+			}
+			cases++
+			for _, f := range junitCaseFiles(c, testFiles) {
+				failed[f] = failed[f] || c.Failed
+			}
+		}
+		for _, f := range mapKeysToSortedSlice(failed) {
+			recordTestOutcome(ledger, f, inputs, failed[f], timestamp, ciPolicy.Flaky.Window)
+		}
+	}
+	ledger.UpdatedAt = timestamp
+
+	if err := saveFlakyLedger(ledger); err != nil {
+		return fmt.Errorf("saving flaky test ledger: %w", err)
+	}
+
+	flaky := flakyTests(ledger, ciPolicy.Flaky)
+	fmt.Println("Result Ingestion Complete")
+	fmt.Println(strings.Repeat("-", 40))
+	fmt.Printf("Reports:     %d\n", len(ciResultsFrom))
+	fmt.Printf("Test cases:  %d\n", cases)
+	fmt.Printf("Inputs:      %s\n", inputs)
+	fmt.Printf("Tracked:     %d test files\n", len(ledger.Tests))
+	fmt.Printf("Flaky:       %d\n", len(flaky))
+	for _, t := range mapKeysToSortedSlice(flaky) {
+		fmt.Printf("  %s (flake rate %.0f%%)\n", t, flakeRate(ledger.Tests[t])*100)
+	}
+	fmt.Printf("Saved to:    %s\n", flakyLedgerFile)
+	return nil
+}
+
+// recordTestOutcome adds one run of a test on the given inputs, keeping the
+// most recent window inputs (all when window is 0)
+func recordTestOutcome(ledger *FlakyLedger, test, inputs string, failed bool, timestamp string, window int) {
+	t, ok := ledger.Tests[test]
+	if !ok {
+		t = &FlakyTest{}
+		ledger.Tests[test] = t
+	}
+	t.Runs++
+	if failed {
+		t.Failures++
+	}
+	t.LastSeenAt = timestamp
+
+	idx := -1
+	for i, in := range t.Inputs {
+		if in.ID == inputs {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		t.Inputs = append(t.Inputs, FlakyInputs{ID: inputs})
+		idx = len(t.Inputs) - 1
+	}
+	if failed {
+		t.Inputs[idx].Failed++
+	} else {
+		t.Inputs[idx].Passed++
+	}
+	if window > 0 && len(t.Inputs) > window {
+		t.Inputs = t.Inputs[len(t.Inputs)-window:]
+	}
+}
+
+// flakeRate is the share of inputs a test ran on more than once on which it
+// both passed and failed. Inputs with a single run can't show a flip.
+func flakeRate(t *FlakyTest) float64 {
+	repeated, mixed := 0, 0
+	for _, in := range t.Inputs {
+		if in.Passed+in.Failed < 2 {
+			continue
+		}
+		repeated++
+		if in.Passed > 0 && in.Failed > 0 {
+			mixed++
+		}
+	}
+	if repeated == 0 {
+		return 0
+	}
+	return float64(mixed) / float64(repeated)
+}
+
+// flakyTests returns the tests whose flake rate reaches the policy threshold,
+// or nil when flaky test tracking is disabled
+func flakyTests(ledger *FlakyLedger, policy CIPolicyFlaky) map[string]bool {
+	if !policy.Enabled {
+		return nil
+	}
+	flaky := make(map[string]bool)
+	for test, t := range ledger.Tests {
+		if rate := flakeRate(t); rate > 0 && rate >= policy.Threshold {
+			flaky[test] = true
+		}
+	}
+	return flaky
+}
+
+func loadFlakyLedger() *FlakyLedger {
+	data, err := os.ReadFile(flakyLedgerFile)
+	if err != nil {
+		return &FlakyLedger{Version: 1, Tests: make(map[string]*FlakyTest)}
+	}
+
+	var ledger FlakyLedger
+	if json.Unmarshal(data, &ledger) != nil {
+		return &FlakyLedger{Version: 1, Tests: make(map[string]*FlakyTest)}
+	}
+
+	if ledger.Tests == nil {
+		ledger.Tests = make(map[string]*FlakyTest)
+	}
+	return &ledger
+}
+
+func saveFlakyLedger(ledger *FlakyLedger) error {
+	os.MkdirAll(filepath.Dir(flakyLedgerFile), 0755)
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(flakyLedgerFile, data, 0644)
+}
+
 // ========== Contract Ingestion ==========
 
 const contractsFile = ".kai/contracts.json"
@@ -12143,6 +15613,47 @@ func canonicalizeSchema(data []byte, schemaType string) []byte {
 	return data
 }
 
+// contractSchemaChange compares a contract schema in the head snapshot with
+// its registered digest. The digest is taken over the canonical schema, as
+// ingest-contracts computes it, so comment and formatting edits are not
+// changes. When the base version can be read, the schema is also diffed unit
+// by unit to tell breaking changes apart.
+func contractSchemaChange(contract ContractBinding, readBase, readHead func(path string) ([]byte, error)) (ContractChange, bool) {
+	data, err := readHead(contract.Path)
+	if err != nil {
+		return ContractChange{}, false
+	}
+	digest := util.Blake3HashHex(canonicalizeSchema(data, contract.Type))
+	if digest == contract.Digest {
+		return ContractChange{}, false
+	}
+
+	change := ContractChange{
+		Path:         contract.Path,
+		Type:         contract.Type,
+		Service:      contract.Service,
+		DigestBefore: contract.Digest,
+		DigestAfter:  digest,
+		Tests:        contract.Tests,
+	}
+	if readBase == nil {
+		return change, true
+	}
+	before, err := readBase(contract.Path)
+	if err != nil {
+		return change, true
+	}
+	if fd, err := diff.NewDiffer().DiffFile(contract.Path, before, data); err == nil {
+		change.Changes = fd.Units
+		for _, u := range fd.Units {
+			if u.Breaking {
+				change.Breaking = true
+			}
+		}
+	}
+	return change, true
+}
+
 // canonicalizeYAMLorJSON attempts to parse and re-serialize with sorted keys
 func canonicalizeYAMLorJSON(data []byte) []byte {
 	// Try JSON first
@@ -12183,8 +15694,15 @@ func canonicalizeGraphQL(content string) []byte {
 	return []byte(strings.Join(cleaned, "\n"))
 }
 
-// canonicalizeProtobuf strips Protobuf comments and normalizes whitespace
+// canonicalizeProtobuf renders the schema of a .proto file with
+// declarations sorted, so that reordering, comments and options do not change
+// the digest. Files that do not parse fall back to stripping comments and
+// normalizing whitespace.
 func canonicalizeProtobuf(content string) []byte {
+	if canonical, err := classify.CanonicalProto([]byte(content)); err == nil {
+		return canonical
+	}
+
 	// Strip // comments
 	var result strings.Builder
 	lines := strings.Split(content, "\n")
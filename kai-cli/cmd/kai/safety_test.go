@@ -1,9 +1,23 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"os"
+	"reflect"
 	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"kai-core/merge"
+	"kai/internal/classify"
+	"kai/internal/codeowners"
+	"kai/internal/junit"
+	"kai/internal/module"
+	"kai/internal/util"
 )
 
 // TestDetectStructuralRisks verifies that structural risk detection works correctly
@@ -134,6 +148,137 @@ func TestDetectStructuralRisks(t *testing.T) {
 	}
 }
 
+// TestDetectSemanticRisks verifies that risky edits in source files become
+// structural risks, and that only sensitive ones trigger expansion
+func TestDetectSemanticRisks(t *testing.T) {
+	base := map[string]string{
+		"worker.go": "package w\n\nfunc run() {\n\tstep()\n}\n",
+		"auth.py":   "def login(user):\n    return True\n",
+		"pay.go":    "package w\n\nfunc pay() {\n\tlog(1)\n}\n",
+		"README.md": "# Docs\n",
+	}
+	head := map[string]string{
+		"worker.go": "package w\n\nfunc run() {\n\tgo step()\n\tif err := step(); err != nil {\n\t\tpanic(err)\n\t}\n}\n",
+		"auth.py":   "def login(user):\n    return check_permission(user, 'login')\n",
+		"pay.go":    "package w\n\nfunc pay() {\n\tbilling.Charge(1)\n}\n",
+		"README.md": "# Docs\n\nMore.\n",
+		"new.go":    "package w\n\nfunc init() {\n\tgo run()\n}\n",
+	}
+	reader := func(files map[string]string) FileContentReader {
+		return func(path string) ([]byte, error) {
+			content, ok := files[path]
+			if !ok {
+				return nil, fmt.Errorf("file not found: %s", path)
+			}
+			return []byte(content), nil
+		}
+	}
+	langs := map[string]string{"worker.go": "go", "auth.py": "python", "pay.go": "go", "README.md": "markdown", "new.go": "go"}
+	changed := []string{"worker.go", "auth.py", "pay.go", "README.md", "new.go"}
+
+	detector := classify.NewDetector()
+	detector.AddSensitivePatterns(classify.SensitivePattern{Kind: "payments", Pattern: "billing.*"})
+	risks := detectSemanticRisks(changed, langs, reader(base), reader(head), detector)
+
+	got := make(map[string]StructuralRisk)
+	for _, r := range risks {
+		got[r.Type+" "+r.FilePath] = r
+	}
+	for _, want := range []string{
+		RiskConcurrency + " worker.go",
+		RiskErrorHandling + " worker.go",
+		RiskSensitiveAPI + " auth.py",
+		RiskSensitiveAPI + " pay.go",
+	} {
+		if _, ok := got[want]; !ok {
+			t.Errorf("missing risk %q, got %v", want, risks)
+		}
+	}
+	if len(risks) != 4 {
+		t.Errorf("expected 4 risks (none for docs or added files), got %d: %v", len(risks), risks)
+	}
+
+	if r := got[RiskSensitiveAPI+" pay.go"]; !r.Triggered || r.Severity != "high" || !strings.Contains(r.Description, "payments") {
+		t.Errorf("sensitive risk should be high, triggered and name its kind, got %+v", r)
+	}
+	if r := got[RiskConcurrency+" worker.go"]; r.Triggered || !strings.Contains(r.Description, "worker.go:4") {
+		t.Errorf("concurrency risk should not trigger and should point at line 4, got %+v", r)
+	}
+}
+
+// TestAnalyzeDependencyChanges verifies that manifest changes are diffed,
+// their importers found, and only dependency-only changes scoped
+func TestAnalyzeDependencyChanges(t *testing.T) {
+	base := map[string]string{
+		"go.mod":              "module m\n\nrequire (\n\tgithub.com/a/lib v1.2.0\n\tgithub.com/b/old v0.1.0\n)\n",
+		"go.sum":              "github.com/a/lib v1.2.0 h1:x=\ngithub.com/b/old v0.1.0 h1:y=\n",
+		"web/package.json":    `{"scripts": {"test": "jest"}, "dependencies": {"lodash": "^4.17.20"}}`,
+		"py/requirements.txt": "requests==2.31.0\nurllib3==2.0.0\n",
+	}
+	head := map[string]string{
+		"go.mod":              "module m\n\nrequire (\n\tgithub.com/a/lib v2.0.0\n\tgithub.com/c/new v1.0.0\n)\n",
+		"go.sum":              "github.com/a/lib v2.0.0 h1:z=\ngithub.com/c/new v1.0.0 h1:w=\n",
+		"web/package.json":    `{"scripts": {"test": "vitest"}, "dependencies": {"lodash": "^4.17.21"}}`,
+		"py/requirements.txt": "requests==2.32.0\nurllib3==2.2.0\n",
+		"svc/handler.go":      "package svc\n\nimport \"github.com/a/lib/client\"\n",
+		"svc/other.go":        "package svc\n\nimport \"fmt\"\n",
+		"web/app.js":          "import { debounce } from 'lodash';\n",
+		"py/api.py":           "import requests\n",
+	}
+	reader := func(files map[string]string) FileContentReader {
+		return func(path string) ([]byte, error) {
+			content, ok := files[path]
+			if !ok {
+				return nil, fmt.Errorf("file not found: %s", path)
+			}
+			return []byte(content), nil
+		}
+	}
+	var allPaths []string
+	for path := range head {
+		allPaths = append(allPaths, path)
+	}
+	sort.Strings(allPaths)
+	changed := []string{"go.mod", "go.sum", "web/package.json", "py/requirements.txt", "svc/handler.go"}
+
+	changes, scoped := analyzeDependencyChanges(changed, allPaths, reader(base), reader(head))
+
+	got := make(map[string]CIDependencyChange)
+	for _, c := range changes {
+		got[c.Manifest+" "+c.Name] = c
+	}
+	if c := got["go.mod github.com/a/lib"]; c.Category != classify.DependencyUpgraded || c.Delta != "major" ||
+		!reflect.DeepEqual(c.Importers, []string{"svc/handler.go"}) {
+		t.Errorf("go.mod lib change = %+v, want a major upgrade imported by svc/handler.go", c)
+	}
+	if c := got["go.mod github.com/b/old"]; c.Category != classify.DependencyRemoved {
+		t.Errorf("go.mod old change = %+v, want removed", c)
+	}
+	if c := got["web/package.json lodash"]; !reflect.DeepEqual(c.Importers, []string{"web/app.js"}) {
+		t.Errorf("lodash importers = %v, want web/app.js", c.Importers)
+	}
+
+	// go.mod and go.sum only move imported or added packages; package.json
+	// also changed a script, and urllib3 is only a transitive dependency
+	want := map[string]bool{"go.mod": true, "go.sum": true}
+	if !reflect.DeepEqual(scoped, want) {
+		t.Errorf("scoped = %v, want %v", scoped, want)
+	}
+
+	risks := []StructuralRisk{
+		{Type: RiskConfigChange, FilePath: "go.mod", Severity: "high", Triggered: true},
+		{Type: RiskConfigChange, FilePath: "web/package.json", Severity: "high", Triggered: true},
+	}
+	risks = scopeDependencyRisks(risks, changes, scoped)
+	if r := risks[0]; r.Type != RiskDependencyChange || r.Triggered || r.Severity != "medium" ||
+		!strings.Contains(r.Description, "1 added, 1 removed, 1 major upgrade") {
+		t.Errorf("go.mod risk = %+v, want an untriggered medium dependency change", r)
+	}
+	if r := risks[1]; r.Type != RiskConfigChange || !r.Triggered {
+		t.Errorf("package.json risk = %+v, want the config change kept", r)
+	}
+}
+
 // TestCalculateConfidence verifies confidence scoring
 func TestCalculateConfidence(t *testing.T) {
 	tests := []struct {
@@ -929,6 +1074,7 @@ func TestExtractFailedTestsFromEvidence(t *testing.T) {
 	tests := []struct {
 		name      string
 		evidence  string
+		testFiles []string
 		wantTests []string
 	}{
 		{
@@ -965,11 +1111,30 @@ func TestExtractFailedTestsFromEvidence(t *testing.T) {
 			evidence:  `{}`,
 			wantTests: []string{},
 		},
+		{
+			name: "junit xml mapped to test files",
+			evidence: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="com.shop.CartTest">
+    <testcase classname="com.shop.CartTest" name="addsItems"><failure message="boom"/></testcase>
+    <testcase classname="com.shop.CartTest" name="removesItems"><failure message="boom"/></testcase>
+    <testcase classname="com.shop.OrderTest" name="places"/>
+  </testsuite>
+  <testsuite name="rspec">
+    <testcase classname="spec.checkout_spec" name="checks out" file="./spec/checkout_spec.rb"><error message="x"/></testcase>
+  </testsuite>
+  <testsuite name="nextest">
+    <testcase classname="shop::unknown" name="it_runs"><failure/></testcase>
+  </testsuite>
+</testsuites>`,
+			testFiles: []string{"src/test/java/com/shop/CartTest.java", "src/test/java/com/shop/OrderTest.java", "spec/checkout_spec.rb"},
+			wantTests: []string{"src/test/java/com/shop/CartTest.java", "spec/checkout_spec.rb", "shop::unknown"},
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			failed := extractFailedTestsFromEvidence([]byte(tc.evidence))
+			failed := extractFailedTestsFromEvidence([]byte(tc.evidence), tc.testFiles)
 
 			if len(failed) != len(tc.wantTests) {
 				t.Errorf("extractFailedTestsFromEvidence() got %d tests, want %d", len(failed), len(tc.wantTests))
@@ -1190,6 +1355,7 @@ func TestExpandForDynamicImports(t *testing.T) {
 				[]string{},
 				moduleMappings,
 				moduleTestMap,
+				nil,
 			)
 
 			if len(expandedTests) != tc.wantExpandedCount {
@@ -1226,6 +1392,7 @@ func TestDynamicImportTelemetry(t *testing.T) {
 		[]string{},
 		[]ModulePathMapping{}, // Empty module mappings
 		map[string][]string{},
+		nil,
 	)
 
 	if info.Telemetry.TotalDetected != 4 {
@@ -1428,7 +1595,7 @@ func TestExpansionStrategyDeterminism(t *testing.T) {
 	// Run expansion multiple times
 	results := make([][]string, 10)
 	for i := 0; i < 10; i++ {
-		expanded, _ := expandForDynamicImports(imports, policy, allTests, changedFiles, moduleMappings, moduleTestMap)
+		expanded, _ := expandForDynamicImports(imports, policy, allTests, changedFiles, moduleMappings, moduleTestMap, nil)
 		results[i] = expanded
 	}
 
@@ -1514,6 +1681,114 @@ func TestParseCoveragePyCoverage(t *testing.T) {
 	}
 }
 
+func TestParseGoCoverProfile(t *testing.T) {
+	profile := `mode: set
+github.com/acme/shop/pkg/cart/cart.go:10.2,12.16 2 1
+github.com/acme/shop/pkg/cart/cart.go:20.2,21.10 1 0
+github.com/acme/shop/pkg/tax/tax.go:5.30,7.2 1 1
+mode: set
+github.com/acme/shop/pkg/tax/tax.go:9.1,9.20 1 1
+`
+
+	entries, err := parseGoCoverProfile([]byte(profile), "github.com/acme/shop")
+	if err != nil {
+		t.Fatalf("parseGoCoverProfile failed: %v", err)
+	}
+
+	cart, ok := entries["pkg/cart/cart.go"]
+	if !ok {
+		t.Fatalf("missing pkg/cart/cart.go entry: %v", entries)
+	}
+	if got := cart[0].LinesCovered; len(got) != 3 || got[0] != 10 || got[2] != 12 {
+		t.Errorf("cart.go lines = %v, want [10 11 12]", got)
+	}
+	if got := entries["pkg/tax/tax.go"][0].LinesCovered; len(got) != 4 {
+		t.Errorf("tax.go lines = %v, want 4 lines across both profiles", got)
+	}
+
+	if _, err := parseGoCoverProfile([]byte("mode: set\nnot a block\n"), ""); err == nil {
+		t.Error("expected error for malformed block")
+	}
+}
+
+func TestParseLCOVCoverage(t *testing.T) {
+	lcov := `TN:cart checkout
+SF:src/cart.js
+DA:1,1
+DA:2,0
+DA:3,4
+end_of_record
+TN:
+SF:src/cart.js
+DA:2,1
+end_of_record
+`
+
+	entries, err := parseLCOVCoverage([]byte(lcov))
+	if err != nil {
+		t.Fatalf("parseLCOVCoverage failed: %v", err)
+	}
+
+	cart := entries["src/cart.js"]
+	if len(cart) != 2 {
+		t.Fatalf("got %d entries, want 2 (aggregate and named test)", len(cart))
+	}
+	byTest := map[string][]int{}
+	for _, e := range cart {
+		byTest[e.TestID] = e.LinesCovered
+	}
+	if got := byTest["cart checkout"]; len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("named test lines = %v, want [1 3]", got)
+	}
+	if got := byTest["aggregate"]; len(got) != 1 || got[0] != 2 {
+		t.Errorf("aggregate lines = %v, want [2]", got)
+	}
+}
+
+func TestParseCoberturaCoverage(t *testing.T) {
+	cobertura := `<?xml version="1.0" ?>
+<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">
+<coverage line-rate="0.5" version="1.9">
+	<sources><source>/build/app</source></sources>
+	<packages>
+		<package name="app">
+			<classes>
+				<class name="Cart" filename="app/cart.py">
+					<lines>
+						<line number="1" hits="1"/>
+						<line number="2" hits="0"/>
+					</lines>
+				</class>
+				<class name="CartItem" filename="app/cart.py">
+					<lines><line number="9" hits="3"/></lines>
+				</class>
+			</classes>
+		</package>
+	</packages>
+</coverage>`
+
+	entries, err := parseCoberturaCoverage([]byte(cobertura))
+	if err != nil {
+		t.Fatalf("parseCoberturaCoverage failed: %v", err)
+	}
+	if got := entries["app/cart.py"][0].LinesCovered; len(got) != 2 || got[0] != 1 || got[1] != 9 {
+		t.Errorf("cart.py lines = %v, want [1 9]", got)
+	}
+}
+
+func TestAttributeCoverageToTest(t *testing.T) {
+	entries := map[string][]CoverageEntry{
+		"pkg/a.go": {
+			{TestID: "aggregate", HitCount: 1, LinesCovered: []int{3, 1}},
+			{TestID: "other", HitCount: 1, LinesCovered: []int{2}},
+		},
+	}
+	got := attributeCoverageToTest(entries, "pkg/TestA")
+	if len(got["pkg/a.go"]) != 1 || got["pkg/a.go"][0].TestID != "pkg/TestA" || len(got["pkg/a.go"][0].LinesCovered) != 3 {
+		t.Errorf("attributeCoverageToTest = %+v", got)
+	}
+}
+
 func TestDetectCoverageFormat(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1545,6 +1820,24 @@ func TestDetectCoverageFormat(t *testing.T) {
 			content:  "<jacoco version='1.0'>",
 			want:     "jacoco",
 		},
+		{
+			name:     "go coverprofile from content",
+			filename: "cover.out",
+			content:  "mode: atomic\npkg/a.go:1.1,2.2 1 1\n",
+			want:     "go",
+		},
+		{
+			name:     "lcov from filename",
+			filename: "lcov.info",
+			content:  "TN:\nSF:a.js\n",
+			want:     "lcov",
+		},
+		{
+			name:     "cobertura from content",
+			filename: "coverage.xml",
+			content:  `<?xml version="1.0"?><coverage line-rate="0.9">`,
+			want:     "cobertura",
+		},
 		{
 			name:     "coveragepy from content",
 			filename: "coverage.json",
@@ -1625,6 +1918,44 @@ func TestContractBindingDigest(t *testing.T) {
 	}
 }
 
+func TestContractSchemaChange(t *testing.T) {
+	base := "syntax = \"proto3\";\nmessage User {\n  string id = 1;\n  string email = 2;\n}\n"
+	contract := ContractBinding{
+		Type:   "protobuf",
+		Path:   "api/user.proto",
+		Tests:  []string{"api/user_test.go"},
+		Digest: util.Blake3HashHex(canonicalizeSchema([]byte(base), "protobuf")),
+	}
+	reader := func(content string) func(string) ([]byte, error) {
+		return func(string) ([]byte, error) { return []byte(content), nil }
+	}
+
+	// Comments and field order do not change the canonical digest
+	reformatted := "syntax = \"proto3\";\n// Users\nmessage User { string email = 2; string id = 1; }\n"
+	if _, ok := contractSchemaChange(contract, reader(base), reader(reformatted)); ok {
+		t.Error("expected a formatting-only edit not to be a schema change")
+	}
+
+	// Changing a field's type breaks clients
+	head := strings.Replace(base, "string email = 2", "bytes email = 2", 1)
+	change, ok := contractSchemaChange(contract, reader(base), reader(head))
+	if !ok {
+		t.Fatal("expected a schema change")
+	}
+	if !change.Breaking || len(change.Changes) != 1 || change.Changes[0].Name != "User.email" {
+		t.Errorf("unexpected change %+v", change)
+	}
+	if change.DigestBefore != contract.Digest || change.DigestAfter == contract.Digest {
+		t.Errorf("digests %s -> %s", change.DigestBefore, change.DigestAfter)
+	}
+
+	// Without a base version the change is still reported
+	change, ok = contractSchemaChange(contract, nil, reader(head))
+	if !ok || change.Breaking || change.Changes != nil {
+		t.Errorf("unexpected change without a base %+v", change)
+	}
+}
+
 // computeTestDigest is a test helper for computing digests
 func computeTestDigest(data []byte) string {
 	// Simple checksum for testing - in reality uses Blake3
@@ -1862,7 +2193,7 @@ func TestExpandSingleImportUsesPathPrefixes(t *testing.T) {
 		Line: 42,
 	}
 
-	tests, strategy := expandSingleImport(imp, policy, allTestFiles, moduleMappings, filesByModule)
+	tests, strategy := expandSingleImport(imp, policy, allTestFiles, moduleMappings, filesByModule, nil)
 
 	// Should find the App module and return its tests
 	if len(tests) != 2 {
@@ -1902,7 +2233,7 @@ func TestExpandSingleImportWithModuleNamesWouldFallback(t *testing.T) {
 		Line: 42,
 	}
 
-	tests, strategy := expandSingleImport(imp, policy, allTestFiles, wrongMappings, filesByModule)
+	tests, strategy := expandSingleImport(imp, policy, allTestFiles, wrongMappings, filesByModule, nil)
 
 	// With wrong mappings and no fallback, should go to full_suite
 	if strategy != "full_suite" {
@@ -1913,6 +2244,51 @@ func TestExpandSingleImportWithModuleNamesWouldFallback(t *testing.T) {
 	}
 }
 
+// TestExpandSingleImportWithCodeOwners verifies the owners stage selects tests
+// owned by the same CODEOWNERS entries as the file with the dynamic import
+func TestExpandSingleImportWithCodeOwners(t *testing.T) {
+	owners, err := codeowners.Parse([]byte(`
+*                 @org/everyone
+/src/billing/     @org/payments
+/tests/payments/  @org/payments
+/tests/ui/        @org/frontend
+`))
+	if err != nil {
+		t.Fatalf("parsing CODEOWNERS: %v", err)
+	}
+
+	policy := &CIPolicyDynamicImports{
+		Expansion:      "owners",
+		OwnersFallback: false,
+	}
+
+	allTestFiles := []string{
+		"tests/payments/charge.test.js",
+		"tests/payments/refund.test.js",
+		"tests/ui/cart.test.js",
+	}
+
+	imp := DynamicImportFile{
+		Path: "src/billing/providers.js",
+		Kind: "import(variable)",
+		Line: 7,
+	}
+
+	tests, strategy := expandSingleImport(imp, policy, allTestFiles, nil, nil, owners)
+	if strategy != "owners: @org/payments" {
+		t.Errorf("strategy = %q, want 'owners: @org/payments'", strategy)
+	}
+	if len(tests) != 2 {
+		t.Errorf("expected 2 payments tests, got %d: %v", len(tests), tests)
+	}
+
+	// Changed source files widen to tests with shared owners
+	widened := widenByOwners(owners, []string{"src/billing/providers.js"}, allTestFiles)
+	if len(widened) != 2 || widened["tests/ui/cart.test.js"] != nil {
+		t.Errorf("widenByOwners = %v", widened)
+	}
+}
+
 // TestModulePathMappingIntegration tests the full flow from module names to correct test matching
 func TestModulePathMappingIntegration(t *testing.T) {
 	// This is an integration test that simulates the full flow
@@ -1960,7 +2336,7 @@ func TestModulePathMappingIntegration(t *testing.T) {
 		Line: 10,
 	}
 
-	expandedTests, strategy := expandSingleImport(imp, policy, testFiles, moduleMappings, testMap)
+	expandedTests, strategy := expandSingleImport(imp, policy, testFiles, moduleMappings, testMap, nil)
 
 	// Should expand to Widgets tests only (nearest_module strategy)
 	if strategy != "nearest_module: Widgets" {
@@ -1981,3 +2357,339 @@ func TestModulePathMappingIntegration(t *testing.T) {
 		}
 	}
 }
+
+func TestSymbolsCoverChanges(t *testing.T) {
+	changes := []symbolChange{
+		{fq: "Add", path: "cart/cart.go", change: "modified"},
+		{fq: "parse", path: "cart/parse.go", change: "modified"},
+	}
+	cases := map[string][]CITestCase{
+		"cart/cart_test.go": {{File: "cart/cart_test.go", Name: "TestTotal", Framework: "go", Symbols: []string{"Add"}}},
+	}
+
+	if symbolsCoverChanges([]string{"cart/cart.go", "cart/parse.go"}, changes, cases) {
+		t.Error("cart/parse.go is not reached by any case; symbol selection is incomplete")
+	}
+	if !symbolsCoverChanges([]string{"cart/cart.go", "cart/cart_test.go"}, changes, cases) {
+		t.Error("every changed source file is reached; symbol selection is complete")
+	}
+
+	// One reached symbol does not cover the file's other changed symbols
+	changes = append(changes, symbolChange{fq: "Remove", path: "cart/cart.go", change: "modified"})
+	if symbolsCoverChanges([]string{"cart/cart.go"}, changes, cases) {
+		t.Error("Remove is not reached by any case; cart/cart.go still needs import-based selection")
+	}
+}
+
+func TestTestFileDurations(t *testing.T) {
+	timings := &TimingsMap{Tests: map[string]*TestTiming{
+		"src/cart.test.js":         {Seconds: 4},
+		"example.com/shop/cart":    {Seconds: 6}, // Go package: split over its files
+		"tests.test_api.TestOrder": {Seconds: 1},
+		"tests.test_api.TestUser":  {Seconds: 2},
+	}}
+	all := []string{"src/cart.test.js", "cart/a_test.go", "cart/b_test.go", "tests/test_api.py", "tests/test_new.py"}
+
+	got := testFileDurations(timings, all)
+	want := map[string]float64{"src/cart.test.js": 4, "cart/a_test.go": 3, "cart/b_test.go": 3, "tests/test_api.py": 3}
+	if len(got) != len(want) {
+		t.Fatalf("durations = %v, want %v", got, want)
+	}
+	for f, d := range want {
+		if got[f] != d {
+			t.Errorf("duration[%s] = %v, want %v", f, got[f], d)
+		}
+	}
+}
+
+func TestShardTargets(t *testing.T) {
+	timings := &TimingsMap{Tests: map[string]*TestTiming{
+		"a.test.js":     {Seconds: 10},
+		"b.test.js":     {Seconds: 6},
+		"c.test.js":     {Seconds: 5},
+		"pkg/x_test.go": {Seconds: 2},
+		"pkg/y_test.go": {Seconds: 2},
+		"other_test.py": {Seconds: 1},
+	}}
+	files := []string{"pkg/y_test.go", "c.test.js", "a.test.js", "new.test.js", "b.test.js", "pkg/x_test.go", "other_test.py"}
+
+	sharding := shardTargets(files, files, timings, 3)
+	if sharding.TimedFiles != 6 || sharding.DefaultSeconds != 26.0/6 {
+		t.Errorf("timed = %d, default = %v", sharding.TimedFiles, sharding.DefaultSeconds)
+	}
+
+	var got []string
+	for _, shard := range sharding.Shards {
+		got = append(got, fmt.Sprintf("%d:%s", shard.Index, strings.Join(shard.Targets, ",")))
+	}
+	// a (10) | b (6) | c (5); then new (4.33, untimed), the Go package (4)
+	// and other (1) each go to the lightest shard; the package stays together
+	want := "0:a.test.js 1:b.test.js,pkg/x_test.go,pkg/y_test.go 2:c.test.js,new.test.js,other_test.py"
+	if strings.Join(got, " ") != want {
+		t.Errorf("shards = %s\nwant     %s", strings.Join(got, " "), want)
+	}
+
+	// Input order does not matter
+	reversed := make([]string, len(files))
+	for i, f := range files {
+		reversed[len(files)-1-i] = f
+	}
+	again := shardTargets(reversed, files, timings, 3)
+	for i := range sharding.Shards {
+		if strings.Join(again.Shards[i].Targets, ",") != strings.Join(sharding.Shards[i].Targets, ",") {
+			t.Errorf("shard %d differs with reordered input", i)
+		}
+	}
+
+	// More shards than files leaves empty shards, not missing ones
+	if s := shardTargets([]string{"a.test.js"}, files, timings, 3); len(s.Shards) != 3 || len(s.Shards[2].Targets) != 0 {
+		t.Errorf("shards = %+v", s.Shards)
+	}
+}
+
+func TestJUnitRiskSources(t *testing.T) {
+	cases, err := junit.Parse([]byte(`<testsuite name="gradle">
+  <testcase classname="com.shop.CartTest" name="adds">
+    <error message="java.lang.NoClassDefFoundError: com/shop/Pricing" type="java.lang.NoClassDefFoundError">at com.shop.CartTest.adds</error>
+  </testcase>
+  <testcase classname="com.shop.OrderTest" name="places"><system-out>java.lang.NoClassDefFoundError in a passing test</system-out></testcase>
+</testsuite>`))
+	if err != nil {
+		t.Fatalf("parsing: %v", err)
+	}
+
+	sources := junitRiskSources(cases, []string{"src/test/java/com/shop/CartTest.java"}, nil)
+	if len(sources) != 1 {
+		t.Fatalf("got %d sources, want only the failed case", len(sources))
+	}
+	if sources[0].file != "src/test/java/com/shop/CartTest.java" || sources[0].flaky {
+		t.Errorf("source = %+v", sources[0])
+	}
+	flaky := map[string]bool{"src/test/java/com/shop/CartTest.java": true}
+	if sources := junitRiskSources(cases, []string{"src/test/java/com/shop/CartTest.java"}, flaky); !sources[0].flaky {
+		t.Error("failure of a flaky test should be marked flaky")
+	}
+
+	var found bool
+	for _, p := range runtimeRiskPatterns {
+		if regexp.MustCompile(p.pattern).MatchString(sources[0].text) && p.riskType == RuntimeRiskModuleNotFound && p.severity == "critical" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("NoClassDefFoundError should be a critical module_not_found risk")
+	}
+}
+
+func TestIsJUnitXML(t *testing.T) {
+	for in, want := range map[string]bool{
+		`<?xml version="1.0"?>` + "\n<testsuites>": true,
+		"  <testsuite name=\"x\">":                 true,
+		`{"testResults": []}`:                      false,
+		"FAIL cart_test.go <testsuite>":            false,
+	} {
+		if got := isJUnitXML([]byte(in)); got != want {
+			t.Errorf("isJUnitXML(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestLearnMissMappings(t *testing.T) {
+	now := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	policy := CIPolicyMisses{Enabled: true, HalfLifeDays: 30, MinWeight: 0.25, RetentionDays: 90}
+	records := []MissRecord{
+		// Today, one changed file: full weight
+		{Timestamp: "2026-03-31T00:00:00Z", ChangedFiles: []string{"src/pricing.js"}, MissedTests: []string{"tests/cart.test.js"}},
+		// A half-life ago, two changed files: 0.5 * 1/2 each
+		{Timestamp: "2026-03-01T00:00:00Z", ChangedFiles: []string{"src/pricing.js", "src/tax.js"}, MissedTests: []string{"tests/cart.test.js"}},
+		// Past retention
+		{Timestamp: "2025-12-01T00:00:00Z", ChangedFiles: []string{"src/old.js"}, MissedTests: []string{"tests/old.test.js"}},
+		// No changed files recorded (older record format)
+		{Timestamp: "2026-03-30T00:00:00Z", MissedTests: []string{"tests/api.test.js"}},
+		// No misses
+		{Timestamp: "2026-03-30T00:00:00Z", ChangedFiles: []string{"src/api.js"}},
+	}
+
+	learned := learnMissMappings(records, now, policy, nil)
+	m := learned["src/pricing.js"]["tests/cart.test.js"]
+	if m == nil || m.Weight != 1.25 || m.Misses != 2 || m.LastMissAt != "2026-03-31T00:00:00Z" {
+		t.Errorf("pricing -> cart = %+v", m)
+	}
+	if m := learned["src/tax.js"]["tests/cart.test.js"]; m == nil || m.Weight != 0.25 {
+		t.Errorf("tax -> cart = %+v", m)
+	}
+	if len(learned) != 2 {
+		t.Errorf("learned files = %d, want 2 (expired and unattributable records ignored)", len(learned))
+	}
+
+	learned = learnMissMappings(records, now, policy, map[string]bool{"tests/cart.test.js": true})
+	if m := learned["src/pricing.js"]["tests/cart.test.js"]; m != nil {
+		t.Errorf("flaky test learned: %+v", m)
+	}
+}
+
+func TestBuildMissReport(t *testing.T) {
+	now := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	policy := CIPolicyMisses{Enabled: true, HalfLifeDays: 30, MinWeight: 0.5, RetentionDays: 90}
+	records := []MissRecord{
+		{Timestamp: "2026-03-30T00:00:00Z", ChangedFiles: []string{"a.go"},
+			SelectedTests: []string{"a_test.go", "b_test.go"}, FailedTests: []string{"a_test.go", "c_test.go"}, MissedTests: []string{"c_test.go"}},
+		{Timestamp: "2026-03-20T00:00:00Z", ChangedFiles: []string{"a.go", "b.go", "d.go"},
+			SelectedTests: []string{"a_test.go", "b_test.go"}, FailedTests: []string{"c_test.go"}, MissedTests: []string{"c_test.go"}},
+		{Timestamp: "2026-01-01T00:00:00Z", SelectedTests: []string{"x_test.go"}, FailedTests: []string{"x_test.go"}},
+	}
+
+	report := buildMissReport(records, time.Time{}, now, policy, nil)
+	if report.Records != 3 || report.Selected != 5 || report.Failed != 4 || report.Caught != 2 || report.Missed != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Recall != 0.5 || report.Precision != 0.4 {
+		t.Errorf("recall = %v, precision = %v", report.Recall, report.Precision)
+	}
+	if len(report.TopMissed) != 1 || report.TopMissed[0] != (MissCount{Test: "c_test.go", Count: 2}) {
+		t.Errorf("top missed = %+v", report.TopMissed)
+	}
+	// Only a.go -> c_test.go is strong enough (b.go and d.go got a third of one miss)
+	if len(report.Learned) != 1 || report.Learned[0].File != "a.go" {
+		t.Errorf("learned = %+v", report.Learned)
+	}
+
+	recent := buildMissReport(records, now.AddDate(0, 0, -30), now, policy, nil)
+	if recent.Records != 2 || recent.Recall != 1.0/3 {
+		t.Errorf("recent = %+v", recent)
+	}
+}
+
+func TestFlakyTests(t *testing.T) {
+	ledger := &FlakyLedger{Version: 1, Tests: make(map[string]*FlakyTest)}
+	record := func(test, inputs string, failed ...bool) {
+		for _, f := range failed {
+			recordTestOutcome(ledger, test, inputs, f, "2026-04-01T00:00:00Z", 3)
+		}
+	}
+	// Flipped on one of two inputs that ran twice; the single run can't flip
+	record("tests/flaky.test.js", "c1", true, false)
+	record("tests/flaky.test.js", "c2", false, false)
+	record("tests/flaky.test.js", "c3", true)
+	// Failed consistently on the same inputs: broken, not flaky
+	record("tests/broken.test.js", "c1", true, true)
+	record("tests/broken.test.js", "c2", false)
+
+	ft := ledger.Tests["tests/flaky.test.js"]
+	if ft.Runs != 5 || ft.Failures != 2 || len(ft.Inputs) != 3 {
+		t.Errorf("flaky test = %+v", ft)
+	}
+	if rate := flakeRate(ft); rate != 0.5 {
+		t.Errorf("flake rate = %v, want 0.5", rate)
+	}
+	if rate := flakeRate(ledger.Tests["tests/broken.test.js"]); rate != 0 {
+		t.Errorf("broken flake rate = %v, want 0", rate)
+	}
+
+	policy := CIPolicyFlaky{Enabled: true, Threshold: 0.5, Window: 3}
+	if got := flakyTests(ledger, policy); len(got) != 1 || !got["tests/flaky.test.js"] {
+		t.Errorf("flaky = %v", got)
+	}
+	policy.Threshold = 0.6
+	if got := flakyTests(ledger, policy); len(got) != 0 {
+		t.Errorf("flaky above threshold = %v", got)
+	}
+	if got := flakyTests(ledger, CIPolicyFlaky{Threshold: 0.1}); got != nil {
+		t.Errorf("disabled = %v", got)
+	}
+
+	// The window drops the oldest inputs, and with them the flip
+	record("tests/flaky.test.js", "c4", false)
+	if ft.Inputs[0].ID != "c2" || flakeRate(ft) != 0 {
+		t.Errorf("inputs after window = %+v", ft.Inputs)
+	}
+}
+
+func TestSelectBuildTargets(t *testing.T) {
+	matcher := module.NewMatcher([]module.ModuleRule{
+		{Name: "core", Paths: []string{"core/**"}},
+		{Name: "api", Paths: []string{"services/api/**"}},
+		{Name: "web", Paths: []string{"web/**"}},
+		{Name: "proto", Paths: []string{"proto/**"}},
+		{Name: "tools", Paths: []string{"tools/**"}},
+	})
+	policy := CIPolicyTargets{
+		UnmatchedFiles: "all",
+		Ignore:         []string{"**/*.md"},
+		Modules: []CIPolicyModuleTargets{
+			{Module: "api", Bazel: []string{"//services/api:server"}, Docker: []string{"shop/api"}},
+			{Module: "web", NPM: []string{"@shop/web"}, DependsOn: []string{"proto"}},
+			{Module: "tools", Make: []string{"tools"}},
+		},
+	}
+	deps := map[string][]string{"api": {"core"}, "web": {"api"}} // From imports
+
+	names := func(targets []CIBuildTarget) string {
+		var parts []string
+		for _, bt := range targets {
+			parts = append(parts, bt.Kind+":"+bt.Name)
+		}
+		return strings.Join(parts, ",")
+	}
+
+	// core changed: api imports it, web imports api
+	got := selectBuildTargets(policy, []string{"core/money.go", "core/README.md"}, matcher, deps)
+	if got.All || names(got.Affected) != "bazel://services/api:server,docker:shop/api,npm:@shop/web" || names(got.Skipped) != "make:tools" {
+		t.Errorf("core change: affected %s, skipped %s", names(got.Affected), names(got.Skipped))
+	}
+	if len(got.Modules) != 3 || got.Modules[2].Module != "web" || strings.Join(got.Modules[2].Via, ">") != "core>api>web" {
+		t.Errorf("modules = %+v", got.Modules)
+	}
+
+	// proto has no imports edge to web, only the declared dependency
+	got = selectBuildTargets(policy, []string{"proto/cart.proto"}, matcher, deps)
+	if names(got.Affected) != "npm:@shop/web" {
+		t.Errorf("proto change: affected %s", names(got.Affected))
+	}
+
+	// A file outside every module affects everything, unless ignored
+	got = selectBuildTargets(policy, []string{"Makefile"}, matcher, deps)
+	if !got.All || len(got.Skipped) != 0 || !strings.Contains(got.Reason, "Makefile") {
+		t.Errorf("unmatched change = %+v", got)
+	}
+	policy.UnmatchedFiles = "ignore"
+	got = selectBuildTargets(policy, []string{"Makefile", "docs/guide.md"}, matcher, deps)
+	if got.All || len(got.Affected) != 0 || len(got.Skipped) != 4 {
+		t.Errorf("ignored unmatched change = %+v", got)
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	base := []byte("package a\n\nimport \"strings\"\n\nfunc A() int {\n\treturn 1\n}\n\nfunc B() int {\n\treturn 1\n}\n")
+	left := []byte("package a\n\nimport s \"strings\"\n\nfunc A() int {\n\treturn 2\n}\n\nfunc B() int {\n\treturn 2\n}\n")
+	right := []byte("package a\n\nimport str \"strings\"\n\nfunc A() int {\n\treturn 3\n}\n\nfunc B() int {\n\treturn 3\n}\n")
+
+	result, err := merge.Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("Merge3Way: %v", err)
+	}
+	marked := result.Files["file"]
+	if len(merge.FindConflicts(marked)) != 3 {
+		t.Fatalf("expected 3 marked conflicts:\n%s", marked)
+	}
+
+	// Union the import, keep right for A, skip B
+	resolved, remaining := resolveConflicts(marked, result.Conflicts, bufio.NewReader(strings.NewReader("3\n2\ns\n")), io.Discard)
+	if remaining != 1 {
+		t.Errorf("remaining = %d, want 1", remaining)
+	}
+	got := string(resolved)
+	if !strings.Contains(got, "import s \"strings\"\nimport str \"strings\"\n") || !strings.Contains(got, "return 3\n}\n\n<<<<<<< left STATEMENT_DIVERGED B") {
+		t.Errorf("resolved file:\n%s", got)
+	}
+
+	// Auto-resolve has nothing safe for B, and running out of input stops
+	resolved, remaining = resolveConflicts(resolved, result.Conflicts, bufio.NewReader(strings.NewReader("a\n")), io.Discard)
+	if remaining != 1 || len(merge.MarkerLines(resolved)) != 3 {
+		t.Errorf("expected B to stay marked, remaining = %d:\n%s", remaining, resolved)
+	}
+	resolved, remaining = resolveConflicts(resolved, result.Conflicts, bufio.NewReader(strings.NewReader("")), io.Discard)
+	if remaining != 1 {
+		t.Errorf("remaining after EOF = %d, want 1", remaining)
+	}
+}
@@ -0,0 +1,474 @@
+// Package apidiff compares the public API of a library between two
+// snapshots and classifies each change by the semver bump it calls for.
+package apidiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kai/internal/parse"
+)
+
+// Change levels, from least to most significant.
+const (
+	LevelNone     = "none"
+	LevelPatch    = "patch"
+	LevelAdditive = "additive"
+	LevelBreaking = "breaking"
+)
+
+var levelRank = map[string]int{LevelNone: 0, LevelPatch: 1, LevelAdditive: 2, LevelBreaking: 3}
+
+// ValidLevel reports whether s names a change level other than none.
+func ValidLevel(s string) bool {
+	return levelRank[s] > 0
+}
+
+// AtLeast reports whether level is as significant as min.
+func AtLeast(level, min string) bool {
+	return levelRank[level] >= levelRank[min]
+}
+
+// Symbol is one element of a library's public API.
+type Symbol struct {
+	Package   string `json:"package"` // Go package directory, or module path for other languages
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Signature string `json:"signature"`
+	File      string `json:"file"`
+	Lang      string `json:"lang"`
+	Body      string `json:"-"`
+}
+
+// Key identifies a symbol across snapshots.
+func (s *Symbol) Key() string {
+	return s.Package + "." + s.Name
+}
+
+// Surface is the public API of a snapshot.
+type Surface struct {
+	Symbols map[string]*Symbol // By Key
+	Files   map[string]string  // Source file -> content, for files that count
+}
+
+// Change is a difference in the public API between two snapshots.
+type Change struct {
+	Level   string `json:"level"`
+	Action  string `json:"action"` // added, removed or changed
+	Package string `json:"package"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Before  string `json:"before,omitempty"` // Signature in the base
+	After   string `json:"after,omitempty"`  // Signature in the head
+	Reason  string `json:"reason"`
+}
+
+// String formats a change for display.
+func (c Change) String() string {
+	name := c.Package + "." + c.Name
+	reason := ""
+	if c.Reason != c.Action {
+		reason = " (" + c.Reason + ")"
+	}
+	switch {
+	case c.Action == "added":
+		return fmt.Sprintf("+ %s: %s%s", name, c.After, reason)
+	case c.Action == "removed":
+		return fmt.Sprintf("- %s: %s%s", name, c.Before, reason)
+	case c.Before != c.After:
+		return fmt.Sprintf("~ %s: %s -> %s%s", name, c.Before, c.After, reason)
+	default:
+		return fmt.Sprintf("~ %s%s", name, reason)
+	}
+}
+
+// Report is the API difference between two snapshots.
+type Report struct {
+	Level        string   `json:"level"`
+	Changes      []Change `json:"changes"`
+	FilesChanged int      `json:"filesChanged"` // Source files in scope that changed
+	Current      string   `json:"current,omitempty"`
+	Next         string   `json:"next,omitempty"` // Suggested next version
+}
+
+// Lang returns the language of a file that can contribute to a library's
+// public API, or "" for tests, examples, vendored code, Go internal
+// packages and other files.
+func Lang(filePath string) string {
+	if parse.IsTestFile(filePath) {
+		return ""
+	}
+	for _, dir := range strings.Split(path.Dir(filePath), "/") {
+		switch dir {
+		case "test", "tests", "__tests__", "testdata", "examples", "example", "benches", "vendor", "node_modules":
+			return ""
+		}
+	}
+
+	switch path.Ext(filePath) {
+	case ".go":
+		for _, dir := range strings.Split(path.Dir(filePath), "/") {
+			if dir == "internal" {
+				return ""
+			}
+		}
+		return "go"
+	case ".py":
+		return "py"
+	case ".rs":
+		return "rust"
+	case ".js", ".mjs", ".cjs", ".jsx":
+		return "js"
+	case ".ts", ".tsx":
+		if strings.HasSuffix(filePath, ".d.ts") {
+			return ""
+		}
+		return "ts"
+	default:
+		return ""
+	}
+}
+
+var goMainPackage = regexp.MustCompile(`(?m)^package\s+main\b`)
+
+// Extract builds the public API surface of a set of files. Files that Lang
+// rejects are ignored, as are Go main packages and private Python modules.
+func Extract(files map[string][]byte) *Surface {
+	parser := parse.NewParser()
+	s := &Surface{
+		Symbols: make(map[string]*Symbol),
+		Files:   make(map[string]string),
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		lang := Lang(p)
+		content := files[p]
+		if lang == "" || lang == "go" && goMainPackage.Match(content) {
+			continue
+		}
+		pkg := packageOf(p, lang)
+		if lang == "py" && strings.HasPrefix(path.Base(pkg), "_") {
+			continue
+		}
+		s.Files[p] = string(content)
+
+		symbols, err := parser.ExtractAPI(content, lang)
+		if err != nil {
+			continue
+		}
+		for _, sym := range symbols {
+			api := &Symbol{
+				Package:   pkg,
+				Name:      sym.Name,
+				Kind:      sym.Kind,
+				Signature: sym.Signature,
+				File:      p,
+				Lang:      lang,
+				Body:      rangeText(content, sym.Range),
+			}
+			s.Symbols[api.Key()] = api
+		}
+	}
+
+	return s
+}
+
+// packageOf returns the name under which code imports a file's symbols: the
+// directory of a Go file, and the module path of others ("pkg/util" for
+// pkg/util.py or pkg/util/__init__.py).
+func packageOf(filePath, lang string) string {
+	if lang == "go" {
+		return path.Dir(filePath)
+	}
+	module := strings.TrimSuffix(filePath, path.Ext(filePath))
+	switch path.Base(module) {
+	case "__init__", "index", "lib", "mod":
+		return path.Dir(module)
+	}
+	return module
+}
+
+// rangeText returns the text of a symbol's range.
+func rangeText(content []byte, r parse.Range) string {
+	lines := strings.Split(string(content), "\n")
+	if r.Start[0] >= len(lines) || r.End[0] >= len(lines) {
+		return ""
+	}
+	if r.Start[0] == r.End[0] {
+		line := lines[r.Start[0]]
+		return line[min(r.Start[1], len(line)):min(r.End[1], len(line))]
+	}
+	text := lines[r.Start[0]][min(r.Start[1], len(lines[r.Start[0]])):]
+	for i := r.Start[0] + 1; i < r.End[0]; i++ {
+		text += "\n" + lines[i]
+	}
+	last := lines[r.End[0]]
+	return text + "\n" + last[:min(r.End[1], len(last))]
+}
+
+// Diff compares two API surfaces. Removed symbols and changed signatures
+// are breaking; added symbols are additive, except interface methods and
+// Rust enum variants, which break implementers and exhaustive matches.
+// Implementation changes behind an unchanged signature are patches, and so
+// is any other change to a source file.
+func Diff(base, head *Surface) *Report {
+	r := &Report{Level: LevelNone, Changes: []Change{}}
+
+	for key, after := range head.Symbols {
+		before, ok := base.Symbols[key]
+		if !ok {
+			c := Change{Level: LevelAdditive, Action: "added", After: after.Signature, Reason: "added"}
+			switch after.Kind {
+			case "interface_method":
+				c.Level, c.Reason = LevelBreaking, "implementations must add it"
+			case "variant":
+				c.Level, c.Reason = LevelBreaking, "exhaustive matches must handle it"
+			}
+			r.add(c, after)
+			continue
+		}
+
+		c := Change{Action: "changed", Before: before.Signature, After: after.Signature}
+		switch {
+		case before.Kind != after.Kind:
+			c.Level, c.Reason = LevelBreaking, fmt.Sprintf("%s became %s", before.Kind, after.Kind)
+		case before.Signature != after.Signature:
+			c.Level, c.Reason = LevelBreaking, "signature changed"
+			if optionalParamsAdded(before, after) {
+				c.Level, c.Reason = LevelAdditive, "optional parameters added"
+			}
+		case before.Body != after.Body && hasBody(after.Kind):
+			c.Level, c.Reason = LevelPatch, "implementation changed"
+		default:
+			continue
+		}
+		r.add(c, after)
+	}
+
+	for key, before := range base.Symbols {
+		if _, ok := head.Symbols[key]; !ok {
+			r.add(Change{Level: LevelBreaking, Action: "removed", Before: before.Signature, Reason: "removed"}, before)
+		}
+	}
+
+	for p, content := range head.Files {
+		if base.Files[p] != content {
+			r.FilesChanged++
+		}
+	}
+	for p := range base.Files {
+		if _, ok := head.Files[p]; !ok {
+			r.FilesChanged++
+		}
+	}
+	if r.FilesChanged > 0 && r.Level == LevelNone {
+		r.Level = LevelPatch
+	}
+
+	sort.Slice(r.Changes, func(i, j int) bool {
+		a, b := r.Changes[i], r.Changes[j]
+		if a.Level != b.Level {
+			return levelRank[a.Level] > levelRank[b.Level]
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Name < b.Name
+	})
+	return r
+}
+
+func (r *Report) add(c Change, sym *Symbol) {
+	c.Package, c.Name, c.Kind = sym.Package, sym.Name, sym.Kind
+	r.Changes = append(r.Changes, c)
+	if levelRank[c.Level] > levelRank[r.Level] {
+		r.Level = c.Level
+	}
+}
+
+// hasBody reports whether a change in a symbol's text, with its signature
+// unchanged, is an implementation change. A type's text changes with its
+// members, which are compared on their own.
+func hasBody(kind string) bool {
+	switch kind {
+	case "function", "method", "variable":
+		return true
+	}
+	return false
+}
+
+// optionalParamsAdded reports whether a Python or JS function only gained
+// parameters with defaults (or *args/**kwargs/rest parameters) after its
+// existing ones, which existing calls still satisfy.
+func optionalParamsAdded(before, after *Symbol) bool {
+	switch after.Lang {
+	case "py", "js", "ts":
+	default:
+		return false
+	}
+	bp, bok := params(before.Signature)
+	ap, aok := params(after.Signature)
+	if !bok || !aok || len(ap) <= len(bp) || strings.Split(before.Signature, "(")[0] != strings.Split(after.Signature, "(")[0] {
+		return false
+	}
+	for i, p := range bp {
+		if ap[i] != p {
+			return false
+		}
+	}
+	for _, p := range ap[len(bp):] {
+		if !strings.Contains(p, "=") && !strings.HasPrefix(p, "*") && !strings.HasPrefix(p, "...") {
+			return false
+		}
+	}
+	return true
+}
+
+// params splits the parameter list of a signature at top-level commas.
+func params(signature string) ([]string, bool) {
+	start := strings.Index(signature, "(")
+	end := strings.LastIndex(signature, ")")
+	if start < 0 || end < start {
+		return nil, false
+	}
+
+	var out []string
+	depth, last := 0, start+1
+	for i := start + 1; i < end; i++ {
+		switch signature[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, strings.TrimSpace(signature[last:i]))
+				last = i + 1
+			}
+		}
+	}
+	if p := strings.TrimSpace(signature[last:end]); p != "" {
+		out = append(out, p)
+	}
+	return out, true
+}
+
+var versionRe = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)`)
+
+// NextVersion returns the version that follows current for a change level,
+// or "" if current is not a semver version. Below 1.0.0 breaking changes
+// bump the minor version and everything else the patch version, as Cargo
+// and npm treat 0.x releases.
+func NextVersion(current, level string) string {
+	m := versionRe.FindStringSubmatch(current)
+	if m == nil {
+		return ""
+	}
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	patch, _ := strconv.Atoi(m[4])
+
+	switch {
+	case level == LevelNone:
+		return current
+	case major == 0 && level == LevelBreaking:
+		minor, patch = minor+1, 0
+	case major == 0:
+		patch++
+	case level == LevelBreaking:
+		major, minor, patch = major+1, 0, 0
+	case level == LevelAdditive:
+		minor, patch = minor+1, 0
+	default:
+		patch++
+	}
+	return fmt.Sprintf("%s%d.%d.%d", m[1], major, minor, patch)
+}
+
+var manifestVersionRe = regexp.MustCompile(`(?m)^version\s*=\s*["']([^"']+)["']`)
+
+// CurrentVersion reads the version a library declares in its package.json,
+// Cargo.toml or pyproject.toml under dir ("" for the root).
+func CurrentVersion(files map[string][]byte, dir string) string {
+	prefix := ""
+	if dir != "" && dir != "." {
+		prefix = strings.TrimSuffix(dir, "/") + "/"
+	}
+
+	if content, ok := files[prefix+"package.json"]; ok {
+		var pkg struct {
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(content, &pkg) == nil && pkg.Version != "" {
+			return pkg.Version
+		}
+	}
+	for _, name := range []string{"Cargo.toml", "pyproject.toml"} {
+		if m := manifestVersionRe.FindSubmatch(files[prefix+name]); m != nil {
+			return string(m[1])
+		}
+	}
+	return ""
+}
+
+// FormatText formats a report grouped by level.
+func (r *Report) FormatText() string {
+	var sb strings.Builder
+
+	for _, level := range []string{LevelBreaking, LevelAdditive, LevelPatch} {
+		var lines []string
+		for _, c := range r.Changes {
+			if c.Level == level {
+				lines = append(lines, "  "+c.String())
+			}
+		}
+		if len(lines) > 0 {
+			sb.WriteString(strings.ToUpper(level[:1]) + level[1:] + ":\n")
+			sb.WriteString(strings.Join(lines, "\n") + "\n\n")
+		}
+	}
+	if len(r.Changes) == 0 {
+		if r.FilesChanged > 0 {
+			sb.WriteString(fmt.Sprintf("No public API changes (%d source files changed).\n\n", r.FilesChanged))
+		} else {
+			sb.WriteString("No public API changes.\n\n")
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, c := range r.Changes {
+		counts[c.Level]++
+	}
+	sb.WriteString(fmt.Sprintf("Level: %s (%d breaking, %d additive, %d patch)\n",
+		r.Level, counts[LevelBreaking], counts[LevelAdditive], counts[LevelPatch]))
+	switch {
+	case r.Next != "":
+		sb.WriteString(fmt.Sprintf("Suggested version: %s (from %s)\n", r.Next, r.Current))
+	case r.Level != LevelNone:
+		sb.WriteString(fmt.Sprintf("Suggested bump: %s\n", bumpName(r.Level)))
+	}
+	return sb.String()
+}
+
+// bumpName names the version part a change level bumps.
+func bumpName(level string) string {
+	switch level {
+	case LevelBreaking:
+		return "major"
+	case LevelAdditive:
+		return "minor"
+	default:
+		return "patch"
+	}
+}
@@ -0,0 +1,251 @@
+package apidiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func changesByName(r *Report) map[string]Change {
+	out := make(map[string]Change)
+	for _, c := range r.Changes {
+		out[c.Package+"."+c.Name] = c
+	}
+	return out
+}
+
+func TestLang(t *testing.T) {
+	tests := map[string]string{
+		"store/store.go":          "go",
+		"store/store_test.go":     "",
+		"internal/util/util.go":   "",
+		"cmd/kai/internal/x/x.go": "",
+		"pkg/client.py":           "py",
+		"tests/test_client.py":    "",
+		"src/lib.rs":              "rust",
+		"benches/bench.rs":        "",
+		"src/index.ts":            "ts",
+		"src/types.d.ts":          "",
+		"src/index.test.ts":       "",
+		"node_modules/x/index.js": "",
+		"examples/basic/main.go":  "",
+		"README.md":               "",
+	}
+	for path, want := range tests {
+		if got := Lang(path); got != want {
+			t.Errorf("Lang(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestDiff_Go(t *testing.T) {
+	base := Extract(map[string][]byte{
+		"store/store.go": []byte(`package store
+
+type Store struct {
+	Path string
+}
+
+type Backend interface {
+	Get(key string) ([]byte, error)
+}
+
+func Open(path string) (*Store, error) {
+	return &Store{Path: path}, nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+func (s *Store) Flush() error {
+	return nil
+}
+
+func helper() {}
+`),
+		"internal/cache/cache.go": []byte(`package cache
+
+func New() {}
+`),
+		"cmd/tool/main.go": []byte(`package main
+
+func Run() {}
+`),
+	})
+	head := Extract(map[string][]byte{
+		"store/store.go": []byte(`package store
+
+type Store struct {
+	Path string
+	Size int
+}
+
+type Backend interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+}
+
+func Open(path string, readOnly bool) (*Store, error) {
+	return &Store{Path: path}, nil
+}
+
+func (s *Store) Close() error {
+	s.Path = ""
+	return nil
+}
+
+func helper() { println() }
+`),
+		"cmd/tool/main.go": []byte(`package main
+
+func Run(verbose bool) {}
+`),
+	})
+
+	r := Diff(base, head)
+	if r.Level != LevelBreaking {
+		t.Errorf("Level = %q, want breaking", r.Level)
+	}
+
+	changes := changesByName(r)
+	want := map[string]string{
+		"store.Open":        LevelBreaking,
+		"store.Store.Flush": LevelBreaking,
+		"store.Backend.Put": LevelBreaking,
+		"store.Store.Size":  LevelAdditive,
+		"store.Store.Close": LevelPatch,
+	}
+	for name, level := range want {
+		c, ok := changes[name]
+		if !ok {
+			t.Errorf("missing change for %s", name)
+			continue
+		}
+		if c.Level != level {
+			t.Errorf("%s: level = %q (%s), want %q", name, c.Level, c.Reason, level)
+		}
+	}
+	if len(r.Changes) != len(want) {
+		t.Errorf("got %d changes, want %d: %+v", len(r.Changes), len(want), r.Changes)
+	}
+	if r.Changes[0].Level != LevelBreaking || r.Changes[len(r.Changes)-1].Level != LevelPatch {
+		t.Errorf("changes not ordered by level: %+v", r.Changes)
+	}
+}
+
+func TestDiff_PythonOptionalParams(t *testing.T) {
+	base := Extract(map[string][]byte{
+		"client/__init__.py": []byte("def connect(host, port):\n    pass\n"),
+		"client/_impl.py":    []byte("def run():\n    pass\n"),
+	})
+	head := Extract(map[string][]byte{
+		"client/__init__.py": []byte("def connect(host, port, timeout=30):\n    pass\n\ndef close():\n    pass\n"),
+		"client/_impl.py":    []byte("def run(fast):\n    pass\n"),
+	})
+
+	r := Diff(base, head)
+	if r.Level != LevelAdditive {
+		t.Errorf("Level = %q, want additive: %+v", r.Level, r.Changes)
+	}
+	changes := changesByName(r)
+	if c := changes["client.connect"]; c.Level != LevelAdditive || c.Reason != "optional parameters added" {
+		t.Errorf("client.connect = %+v", c)
+	}
+	if c := changes["client.close"]; c.Action != "added" {
+		t.Errorf("client.close = %+v", c)
+	}
+}
+
+func TestDiff_RustVariant(t *testing.T) {
+	base := Extract(map[string][]byte{
+		"src/lib.rs": []byte("pub enum Mode { Fast }\n"),
+	})
+	head := Extract(map[string][]byte{
+		"src/lib.rs": []byte("pub enum Mode { Fast, Slow }\n"),
+	})
+
+	r := Diff(base, head)
+	if r.Level != LevelBreaking {
+		t.Errorf("Level = %q, want breaking: %+v", r.Level, r.Changes)
+	}
+	if c := changesByName(r)["src.Mode::Slow"]; c.Action != "added" || c.Level != LevelBreaking {
+		t.Errorf("src.Mode::Slow = %+v", c)
+	}
+}
+
+func TestDiff_PrivateChangeIsPatch(t *testing.T) {
+	base := Extract(map[string][]byte{
+		"util/util.go": []byte("package util\n\nfunc helper() int { return 1 }\n"),
+	})
+	head := Extract(map[string][]byte{
+		"util/util.go": []byte("package util\n\nfunc helper() int { return 2 }\n"),
+	})
+
+	r := Diff(base, head)
+	if r.Level != LevelPatch || len(r.Changes) != 0 || r.FilesChanged != 1 {
+		t.Errorf("got level %q, %d changes, %d files", r.Level, len(r.Changes), r.FilesChanged)
+	}
+
+	if r := Diff(base, base); r.Level != LevelNone {
+		t.Errorf("identical surfaces: level = %q", r.Level)
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	tests := []struct {
+		current, level, want string
+	}{
+		{"1.4.2", LevelBreaking, "2.0.0"},
+		{"v1.4.2", LevelAdditive, "v1.5.0"},
+		{"1.4.2", LevelPatch, "1.4.3"},
+		{"1.4.2", LevelNone, "1.4.2"},
+		{"0.3.1", LevelBreaking, "0.4.0"},
+		{"0.3.1", LevelAdditive, "0.3.2"},
+		{"1.0.0-rc.1", LevelPatch, "1.0.1"},
+		{"latest", LevelPatch, ""},
+	}
+	for _, tt := range tests {
+		if got := NextVersion(tt.current, tt.level); got != tt.want {
+			t.Errorf("NextVersion(%q, %q) = %q, want %q", tt.current, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestCurrentVersion(t *testing.T) {
+	files := map[string][]byte{
+		"package.json":           []byte(`{"name": "app", "version": "2.1.0"}`),
+		"crates/core/Cargo.toml": []byte("[package]\nname = \"core\"\nversion = \"0.9.3\"\n"),
+	}
+	if got := CurrentVersion(files, ""); got != "2.1.0" {
+		t.Errorf("root version = %q", got)
+	}
+	if got := CurrentVersion(files, "crates/core"); got != "0.9.3" {
+		t.Errorf("crate version = %q", got)
+	}
+	if got := CurrentVersion(files, "docs"); got != "" {
+		t.Errorf("docs version = %q", got)
+	}
+}
+
+func TestReport_FormatText(t *testing.T) {
+	r := &Report{
+		Level: LevelBreaking,
+		Changes: []Change{
+			{Level: LevelBreaking, Action: "removed", Package: "store", Name: "Open", Before: "func Open()", Reason: "removed"},
+			{Level: LevelAdditive, Action: "added", Package: "store", Name: "Close", After: "func Close()", Reason: "added"},
+		},
+		Current: "1.2.0",
+		Next:    "2.0.0",
+	}
+	out := r.FormatText()
+	for _, want := range []string{
+		"Breaking:\n  - store.Open: func Open()\n",
+		"Additive:\n  + store.Close: func Close()\n",
+		"Level: breaking (1 breaking, 1 additive, 0 patch)",
+		"Suggested version: 2.0.0 (from 1.2.0)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Package blame attributes symbols to the changesets that last changed them
+// and traces how a symbol or file evolved across changesets.
+package blame
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"kai/internal/classify"
+	"kai/internal/graph"
+	"kai/internal/intent"
+	"kai/internal/snapshot"
+	"kai/internal/util"
+)
+
+// Entry is the blame result for a single symbol.
+type Entry struct {
+	Symbol      string   `json:"symbol"`
+	Kind        string   `json:"kind"`
+	StartLine   int      `json:"startLine"` // 1-based
+	EndLine     int      `json:"endLine"`   // 1-based
+	ChangeSetID string   `json:"changeset,omitempty"`
+	Intent      string   `json:"intent,omitempty"`
+	Categories  []string `json:"categories,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	CreatedAt   int64    `json:"createdAt,omitempty"`
+}
+
+// Options configures a blame computation.
+type Options struct {
+	Path       string // File path relative to the repo root
+	Symbol     string // Optional symbol name filter (fqName or its last segment)
+	SnapshotID []byte // Snapshot whose symbols are blamed
+}
+
+// Compute returns one entry per symbol in the file, attributed to the most
+// recent changeset that semantically modified it. Symbols that no recorded
+// changeset touched are returned with an empty ChangeSetID.
+func Compute(db *graph.DB, opts Options) ([]*Entry, error) {
+	fileNode, err := snapshot.GetFileByPath(db, opts.SnapshotID, opts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("finding file: %w", err)
+	}
+	if fileNode == nil {
+		return nil, fmt.Errorf("file not found in snapshot: %s", opts.Path)
+	}
+
+	creator := snapshot.NewCreator(db, nil)
+	symbols, err := creator.GetSymbolsInFile(fileNode.ID, opts.SnapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("getting symbols: %w", err)
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("no symbols found for %s (run 'kai analyze symbols' first)", opts.Path)
+	}
+
+	var entries []*Entry
+	pending := make(map[string][]*Entry) // symbol name -> entries awaiting attribution
+	for _, sym := range symbols {
+		name, _ := sym.Payload["fqName"].(string)
+		if name == "" || !MatchSymbol(name, opts.Symbol) {
+			continue
+		}
+		kind, _ := sym.Payload["kind"].(string)
+		start, end := SymbolLines(sym)
+		e := &Entry{Symbol: name, Kind: kind, StartLine: start + 1, EndLine: end + 1}
+		entries = append(entries, e)
+		pending[name] = append(pending[name], e)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("symbol %q not found in %s", opts.Symbol, opts.Path)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].StartLine < entries[j].StartLine
+	})
+
+	changesets, err := db.GetNodesByKind(graph.KindChangeSet)
+	if err != nil {
+		return nil, fmt.Errorf("getting changesets: %w", err)
+	}
+	sort.Slice(changesets, func(i, j int) bool {
+		return createdAt(changesets[i]) > createdAt(changesets[j])
+	})
+
+	gen := intent.NewGenerator(db)
+	for _, cs := range changesets {
+		if len(pending) == 0 {
+			break
+		}
+
+		touched, err := TouchedSymbols(db, cs, opts.Path)
+		if err != nil {
+			return nil, err
+		}
+		if len(touched) == 0 {
+			continue
+		}
+
+		csIntent := changeSetIntent(gen, cs)
+		author, _ := cs.Payload["author"].(string)
+		for name, categories := range touched {
+			waiting, ok := pending[name]
+			if !ok {
+				continue
+			}
+			for _, e := range waiting {
+				e.ChangeSetID = util.BytesToHex(cs.ID)
+				e.Intent = csIntent
+				e.Categories = categories
+				e.Author = author
+				e.CreatedAt = createdAt(cs)
+			}
+			delete(pending, name)
+		}
+	}
+
+	return entries, nil
+}
+
+// TouchedSymbols returns the names of symbols in path that a changeset
+// semantically modified, mapped to the sorted change categories that hit them.
+// Pure content changes (FILE_CONTENT_CHANGED) never count as a modification, so
+// formatting-only changesets are skipped.
+func TouchedSymbols(db *graph.DB, cs *graph.Node, path string) (map[string][]string, error) {
+	gen := intent.NewGenerator(db)
+	changeTypes, err := gen.GetChangeTypesForChangeSet(cs.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting change types: %w", err)
+	}
+
+	var relevant []*classify.ChangeType
+	for _, ct := range changeTypes {
+		if ct.Category == classify.FileContentChanged {
+			continue
+		}
+		for _, fr := range ct.Evidence.FileRanges {
+			if fr.Path == path {
+				relevant = append(relevant, ct)
+				break
+			}
+		}
+	}
+	if len(relevant) == 0 {
+		return nil, nil
+	}
+
+	headSymbols, err := changeSetFileSymbols(db, cs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]string, len(headSymbols))
+	for _, sym := range headSymbols {
+		name, _ := sym.Payload["fqName"].(string)
+		byID[util.BytesToHex(sym.ID)] = name
+	}
+
+	seen := make(map[string]map[string]bool)
+	mark := func(name string, category classify.ChangeCategory) {
+		if name == "" {
+			return
+		}
+		if seen[name] == nil {
+			seen[name] = make(map[string]bool)
+		}
+		seen[name][string(category)] = true
+	}
+
+	for _, ct := range relevant {
+		for _, s := range ct.Evidence.Symbols {
+			if strings.HasPrefix(s, "name:") {
+				mark(strings.TrimPrefix(s, "name:"), ct.Category)
+			} else if name, ok := byID[s]; ok {
+				mark(name, ct.Category)
+			}
+		}
+
+		for _, fr := range ct.Evidence.FileRanges {
+			if fr.Path != path {
+				continue
+			}
+			for _, sym := range headSymbols {
+				name, _ := sym.Payload["fqName"].(string)
+				// A whole-file range (added files) covers every symbol
+				if ct.Category == classify.FileAdded || overlaps(fr, sym) {
+					mark(name, ct.Category)
+				}
+			}
+		}
+	}
+
+	result := make(map[string][]string, len(seen))
+	for name, cats := range seen {
+		var list []string
+		for c := range cats {
+			list = append(list, c)
+		}
+		sort.Strings(list)
+		result[name] = list
+	}
+	return result, nil
+}
+
+// MatchSymbol reports whether a symbol's fqName matches a user-supplied filter.
+// An empty filter matches everything; otherwise the filter must equal the full
+// name or its last dotted segment.
+func MatchSymbol(fqName, filter string) bool {
+	if filter == "" || fqName == filter {
+		return true
+	}
+	if idx := strings.LastIndex(fqName, "."); idx >= 0 {
+		return fqName[idx+1:] == filter
+	}
+	return false
+}
+
+// SymbolLines returns the 0-based start and end lines of a symbol node.
+func SymbolLines(sym *graph.Node) (int, int) {
+	r, ok := sym.Payload["range"].(map[string]interface{})
+	if !ok {
+		return 0, 0
+	}
+	return rangeLine(r["start"]), rangeLine(r["end"])
+}
+
+func rangeLine(v interface{}) int {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) != 2 {
+		return 0
+	}
+	line, _ := arr[0].(float64)
+	return int(line)
+}
+
+// changeSetFileSymbols returns the symbols of path as of the changeset's head snapshot.
+func changeSetFileSymbols(db *graph.DB, cs *graph.Node, path string) ([]*graph.Node, error) {
+	headHex, _ := cs.Payload["head"].(string)
+	if headHex == "" {
+		return nil, nil
+	}
+	headID, err := util.HexToBytes(headHex)
+	if err != nil {
+		return nil, nil
+	}
+
+	fileNode, err := snapshot.GetFileByPath(db, headID, path)
+	if err != nil {
+		return nil, fmt.Errorf("finding file in changeset head: %w", err)
+	}
+	if fileNode == nil {
+		return nil, nil
+	}
+
+	return snapshot.NewCreator(db, nil).GetSymbolsInFile(fileNode.ID, headID)
+}
+
+func overlaps(fr classify.FileRange, sym *graph.Node) bool {
+	start, end := SymbolLines(sym)
+	return fr.Start[0] <= end && start <= fr.End[0]
+}
+
+func changeSetIntent(gen *intent.Generator, cs *graph.Node) string {
+	if text, err := gen.GetChangeSetIntent(cs.ID); err == nil && text != "" {
+		return text
+	}
+	if text, _ := cs.Payload["intent"].(string); text != "" {
+		return text
+	}
+	text, _ := cs.Payload["description"].(string)
+	return text
+}
+
+func createdAt(n *graph.Node) int64 {
+	if ts, ok := n.Payload["createdAt"].(float64); ok {
+		return int64(ts)
+	}
+	return n.CreatedAt
+}
@@ -0,0 +1,254 @@
+package blame
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kai/internal/classify"
+	"kai/internal/graph"
+	"kai/internal/util"
+)
+
+func setupTestDB(t *testing.T) (*graph.DB, func()) {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "kai-blame-test-*")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+
+	dbPath := filepath.Join(tmpDir, "test.db")
+	objPath := filepath.Join(tmpDir, "objects")
+	if err := os.MkdirAll(objPath, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("creating objects dir: %v", err)
+	}
+
+	db, err := graph.Open(dbPath, objPath)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("opening database: %v", err)
+	}
+
+	schema := `
+CREATE TABLE IF NOT EXISTS nodes (id BLOB PRIMARY KEY, kind TEXT NOT NULL, payload TEXT NOT NULL, created_at INTEGER NOT NULL);
+CREATE TABLE IF NOT EXISTS edges (src BLOB NOT NULL, type TEXT NOT NULL, dst BLOB NOT NULL, at BLOB, created_at INTEGER NOT NULL, PRIMARY KEY (src, type, dst, at));
+`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		os.RemoveAll(tmpDir)
+		t.Fatalf("applying schema: %v", err)
+	}
+
+	cleanup := func() {
+		db.Close()
+		os.RemoveAll(tmpDir)
+	}
+
+	return db, cleanup
+}
+
+// testSymbol describes a symbol to insert: name and 0-based line span.
+type testSymbol struct {
+	name       string
+	start, end int
+}
+
+// createSnapshot inserts a snapshot holding one file with the given symbols.
+func createSnapshot(t *testing.T, db *graph.DB, path, digest string, symbols []testSymbol) ([]byte, map[string][]byte) {
+	t.Helper()
+
+	fileID, err := db.InsertNodeDirect(graph.KindFile, map[string]interface{}{
+		"path": path, "lang": "ts", "digest": digest,
+	})
+	if err != nil {
+		t.Fatalf("inserting file: %v", err)
+	}
+	snapID, err := db.InsertNodeDirect(graph.KindSnapshot, map[string]interface{}{
+		"sourceRef": digest, "createdAt": util.NowMs(),
+	})
+	if err != nil {
+		t.Fatalf("inserting snapshot: %v", err)
+	}
+	if err := db.InsertEdgeDirect(snapID, graph.EdgeHasFile, fileID, nil); err != nil {
+		t.Fatalf("inserting HAS_FILE: %v", err)
+	}
+
+	ids := make(map[string][]byte)
+	for _, s := range symbols {
+		symID, err := db.InsertNodeDirect(graph.KindSymbol, map[string]interface{}{
+			"fqName": s.name,
+			"kind":   "function",
+			"fileId": util.BytesToHex(fileID),
+			"range":  map[string]interface{}{"start": []int{s.start, 0}, "end": []int{s.end, 1}},
+		})
+		if err != nil {
+			t.Fatalf("inserting symbol: %v", err)
+		}
+		if err := db.InsertEdgeDirect(symID, graph.EdgeDefinesIn, fileID, snapID); err != nil {
+			t.Fatalf("inserting DEFINES_IN: %v", err)
+		}
+		ids[s.name] = symID
+	}
+	return snapID, ids
+}
+
+func createChangeSet(t *testing.T, db *graph.DB, base, head []byte, createdAt int64, author, intent string, changes []*classify.ChangeType) []byte {
+	t.Helper()
+
+	csID, err := db.InsertNodeDirect(graph.KindChangeSet, map[string]interface{}{
+		"base":      util.BytesToHex(base),
+		"head":      util.BytesToHex(head),
+		"intent":    intent,
+		"author":    author,
+		"createdAt": createdAt,
+	})
+	if err != nil {
+		t.Fatalf("inserting changeset: %v", err)
+	}
+	// Like kai changeset create, link the head files that were added or
+	// modified
+	baseDigests := make(map[string]string)
+	for _, f := range snapshotFiles(t, db, base) {
+		path, _ := f.Payload["path"].(string)
+		baseDigests[path], _ = f.Payload["digest"].(string)
+	}
+	for _, f := range snapshotFiles(t, db, head) {
+		path, _ := f.Payload["path"].(string)
+		if d, ok := baseDigests[path]; ok && d == f.Payload["digest"] {
+			continue
+		}
+		if err := db.InsertEdgeDirect(csID, graph.EdgeModifies, f.ID, nil); err != nil {
+			t.Fatalf("inserting MODIFIES: %v", err)
+		}
+	}
+	for _, ct := range changes {
+		ctID, err := db.InsertNodeDirect(graph.KindChangeType, classify.GetCategoryPayload(ct))
+		if err != nil {
+			t.Fatalf("inserting change type: %v", err)
+		}
+		if err := db.InsertEdgeDirect(csID, graph.EdgeHas, ctID, nil); err != nil {
+			t.Fatalf("inserting HAS: %v", err)
+		}
+	}
+	return csID
+}
+
+func snapshotFiles(t *testing.T, db *graph.DB, snapID []byte) []*graph.Node {
+	t.Helper()
+	edges, err := db.GetEdges(snapID, graph.EdgeHasFile)
+	if err != nil {
+		t.Fatalf("getting snapshot files: %v", err)
+	}
+	var files []*graph.Node
+	for _, e := range edges {
+		node, err := db.GetNode(e.Dst)
+		if err != nil || node == nil {
+			t.Fatalf("getting file node: %v", err)
+		}
+		files = append(files, node)
+	}
+	return files
+}
+
+func rangeChange(cat classify.ChangeCategory, path string, line int) *classify.ChangeType {
+	return &classify.ChangeType{
+		Category: cat,
+		Evidence: classify.Evidence{
+			FileRanges: []classify.FileRange{{Path: path, Start: [2]int{line, 0}, End: [2]int{line, 10}}},
+		},
+	}
+}
+
+func TestCompute_AttributesLatestSemanticChange(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	path := "src/auth.ts"
+	syms := []testSymbol{{"validateSession", 0, 5}, {"login", 10, 20}}
+
+	snap1, _ := createSnapshot(t, db, path, "d1", syms)
+	snap2, _ := createSnapshot(t, db, path, "d2", syms)
+	snap3, _ := createSnapshot(t, db, path, "d3", syms)
+
+	older := createChangeSet(t, db, snap1, snap2, 1000, "alice", "Modify auth validateSession",
+		[]*classify.ChangeType{rangeChange(classify.ConditionChanged, path, 2)})
+	// Newer changeset only reformatted the file and touched login
+	createChangeSet(t, db, snap2, snap3, 2000, "bob", "Update login",
+		[]*classify.ChangeType{
+			classify.NewFileChange(classify.FileContentChanged, path),
+			rangeChange(classify.ConstantUpdated, path, 12),
+		})
+
+	entries, err := Compute(db, Options{Path: path, SnapshotID: snap3})
+	if err != nil {
+		t.Fatalf("Compute: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	validate := entries[0]
+	if validate.Symbol != "validateSession" {
+		t.Fatalf("expected entries sorted by line, got %s first", validate.Symbol)
+	}
+	if validate.ChangeSetID != util.BytesToHex(older) {
+		t.Errorf("validateSession should be blamed on the older changeset")
+	}
+	if validate.Author != "alice" || validate.Intent != "Modify auth validateSession" {
+		t.Errorf("unexpected author/intent: %q / %q", validate.Author, validate.Intent)
+	}
+	if len(validate.Categories) != 1 || validate.Categories[0] != string(classify.ConditionChanged) {
+		t.Errorf("unexpected categories: %v", validate.Categories)
+	}
+
+	login := entries[1]
+	if login.Author != "bob" || login.Categories[0] != string(classify.ConstantUpdated) {
+		t.Errorf("login should be blamed on bob's constant update, got %+v", login)
+	}
+	if login.StartLine != 11 {
+		t.Errorf("expected 1-based start line 11, got %d", login.StartLine)
+	}
+}
+
+func TestCompute_SymbolFilterAndUnchanged(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	path := "src/util.ts"
+	snap, _ := createSnapshot(t, db, path, "d1", []testSymbol{{"Helpers.format", 0, 3}, {"parse", 5, 9}})
+
+	entries, err := Compute(db, Options{Path: path, Symbol: "format", SnapshotID: snap})
+	if err != nil {
+		t.Fatalf("Compute: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Symbol != "Helpers.format" {
+		t.Fatalf("expected only Helpers.format, got %+v", entries)
+	}
+	if entries[0].ChangeSetID != "" {
+		t.Errorf("expected no attribution without changesets")
+	}
+
+	if _, err := Compute(db, Options{Path: path, Symbol: "missing", SnapshotID: snap}); err == nil {
+		t.Error("expected error for unknown symbol")
+	}
+}
+
+func TestMatchSymbol(t *testing.T) {
+	tests := []struct {
+		fqName, filter string
+		want           bool
+	}{
+		{"validateSession", "", true},
+		{"validateSession", "validateSession", true},
+		{"Auth.validateSession", "validateSession", true},
+		{"Auth.validateSession", "Auth.validateSession", true},
+		{"validateSessionX", "validateSession", false},
+	}
+	for _, tt := range tests {
+		if got := MatchSymbol(tt.fqName, tt.filter); got != tt.want {
+			t.Errorf("MatchSymbol(%q, %q) = %v, want %v", tt.fqName, tt.filter, got, tt.want)
+		}
+	}
+}
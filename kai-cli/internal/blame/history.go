@@ -0,0 +1,316 @@
+package blame
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"kai-core/diff"
+	"kai/internal/graph"
+	"kai/internal/intent"
+	"kai/internal/snapshot"
+	"kai/internal/util"
+)
+
+// HistoryOptions configures a symbol or file history traversal.
+type HistoryOptions struct {
+	Symbol string // fqName (or last segment) to follow; empty for whole-file history
+	Path   string // Restrict to a single file; required when Symbol is empty
+}
+
+// HistoryStep is one changeset in the evolution of a symbol or file.
+type HistoryStep struct {
+	ChangeSetID string          `json:"changeset"`
+	CreatedAt   int64           `json:"createdAt"`
+	Author      string          `json:"author,omitempty"`
+	Intent      string          `json:"intent,omitempty"`
+	Files       []diff.FileDiff `json:"files"`
+	ChangeTypes []string        `json:"changeTypes,omitempty"`
+	MovedFrom   string          `json:"movedFrom,omitempty"` // previous path when the symbol moved files
+}
+
+// History walks all changesets from newest to oldest and returns the steps
+// that touched the requested symbol or file. A changeset counts when the
+// semantic diff of a file reports the symbol (signature, addition, removal) or
+// when one of its ChangeTypes maps onto it (e.g. a constant tweak in the body).
+func History(db *graph.DB, opts HistoryOptions) ([]*HistoryStep, error) {
+	if opts.Symbol == "" && opts.Path == "" {
+		return nil, fmt.Errorf("either a symbol or a file path is required")
+	}
+
+	changesets, err := db.GetNodesByKind(graph.KindChangeSet)
+	if err != nil {
+		return nil, fmt.Errorf("getting changesets: %w", err)
+	}
+	sort.Slice(changesets, func(i, j int) bool {
+		return createdAt(changesets[i]) > createdAt(changesets[j])
+	})
+
+	gen := intent.NewGenerator(db)
+	differ := diff.NewDiffer()
+
+	var steps []*HistoryStep
+	for _, cs := range changesets {
+		files, err := changedFileContents(db, cs, opts.Path)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		step := &HistoryStep{
+			ChangeSetID: util.BytesToHex(cs.ID),
+			CreatedAt:   createdAt(cs),
+			Intent:      changeSetIntent(gen, cs),
+		}
+		step.Author, _ = cs.Payload["author"].(string)
+
+		categories := make(map[string]bool)
+		addedIn, removedFrom := "", ""
+
+		paths := make([]string, 0, len(files))
+		for p := range files {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			versions := files[path]
+			fd, err := differ.DiffFile(path, versions[0], versions[1])
+			if err != nil || fd == nil {
+				continue
+			}
+
+			touched, err := TouchedSymbols(db, cs, path)
+			if err != nil {
+				return nil, err
+			}
+
+			if opts.Symbol == "" {
+				step.Files = append(step.Files, *fd)
+				for _, cats := range touched {
+					for _, c := range cats {
+						categories[c] = true
+					}
+				}
+				continue
+			}
+
+			var units []diff.UnitDiff
+			for _, u := range fd.Units {
+				if !MatchSymbol(u.Name, opts.Symbol) {
+					continue
+				}
+				units = append(units, u)
+				switch u.Action {
+				case diff.ActionAdded:
+					addedIn = path
+				case diff.ActionRemoved:
+					removedFrom = path
+				}
+			}
+
+			for name, cats := range touched {
+				if !MatchSymbol(name, opts.Symbol) {
+					continue
+				}
+				for _, c := range cats {
+					categories[c] = true
+				}
+				if len(units) == 0 {
+					// Body-only change: the signature is unchanged, so report it as-is
+					units = append(units, bodyChangeUnit(db, cs, path, name, cats))
+				}
+			}
+
+			if len(units) > 0 {
+				fd.Units = units
+				step.Files = append(step.Files, *fd)
+			}
+		}
+
+		if len(step.Files) == 0 {
+			continue
+		}
+		if addedIn != "" && removedFrom != "" && addedIn != removedFrom {
+			step.MovedFrom = removedFrom
+		}
+		for c := range categories {
+			step.ChangeTypes = append(step.ChangeTypes, c)
+		}
+		sort.Strings(step.ChangeTypes)
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// changedFileContents returns before/after contents for the files a
+// changeset modifies whose digest differs between its base and head
+// snapshots. Files are found through the changeset's MODIFIES edges, which
+// only cover files in head, plus the base files head no longer has, so only
+// the touched files are read. When path is set, only that file is considered.
+func changedFileContents(db *graph.DB, cs *graph.Node, path string) (map[string][2][]byte, error) {
+	baseID := payloadID(cs, "base")
+	headID := payloadID(cs, "head")
+	if headID == nil {
+		return nil, nil
+	}
+
+	paths := []string{path}
+	if path == "" {
+		var err error
+		paths, err = modifiedPaths(db, cs)
+		if err != nil {
+			return nil, err
+		}
+		removed, err := removedPaths(db, baseID, headID)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, removed...)
+	}
+
+	result := make(map[string][2][]byte)
+	for _, p := range paths {
+		base, err := fileByPath(db, baseID, p)
+		if err != nil {
+			return nil, err
+		}
+		head, err := fileByPath(db, headID, p)
+		if err != nil {
+			return nil, err
+		}
+		if head == nil && base == nil || head != nil && base != nil && digest(base) == digest(head) {
+			continue
+		}
+		result[p] = [2][]byte{readContent(db, base), readContent(db, head)}
+	}
+	return result, nil
+}
+
+// modifiedPaths returns the paths of the files a changeset's MODIFIES edges
+// point to.
+func modifiedPaths(db *graph.DB, cs *graph.Node) ([]string, error) {
+	edges, err := db.GetEdges(cs.ID, graph.EdgeModifies)
+	if err != nil {
+		return nil, fmt.Errorf("getting modified files: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, e := range edges {
+		node, err := db.GetNode(e.Dst)
+		if err != nil {
+			return nil, err
+		}
+		if node == nil || node.Kind != graph.KindFile {
+			continue
+		}
+		if p, _ := node.Payload["path"].(string); p != "" && !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+// removedPaths returns the paths of the files in the base snapshot that the
+// head snapshot doesn't have.
+func removedPaths(db *graph.DB, baseID, headID []byte) ([]string, error) {
+	if baseID == nil {
+		return nil, nil
+	}
+	creator := snapshot.NewCreator(db, nil)
+	baseFiles, err := creator.GetSnapshotFiles(baseID)
+	if err != nil {
+		return nil, fmt.Errorf("getting snapshot files: %w", err)
+	}
+	headFiles, err := creator.GetSnapshotFiles(headID)
+	if err != nil {
+		return nil, fmt.Errorf("getting snapshot files: %w", err)
+	}
+
+	inHead := make(map[string]bool, len(headFiles))
+	for _, f := range headFiles {
+		if p, _ := f.Payload["path"].(string); p != "" {
+			inHead[p] = true
+		}
+	}
+	var paths []string
+	for _, f := range baseFiles {
+		if p, _ := f.Payload["path"].(string); p != "" && !inHead[p] {
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+// bodyChangeUnit builds a UnitDiff for a symbol whose body changed without a
+// signature change, using the symbol as recorded in the changeset's head.
+func bodyChangeUnit(db *graph.DB, cs *graph.Node, path, name string, categories []string) diff.UnitDiff {
+	u := diff.UnitDiff{
+		Kind:       diff.KindFunction,
+		Name:       name,
+		Action:     diff.ActionModified,
+		ChangeType: strings.Join(categories, ","),
+	}
+
+	symbols, _ := changeSetFileSymbols(db, cs, path)
+	for _, sym := range symbols {
+		if fq, _ := sym.Payload["fqName"].(string); fq != name {
+			continue
+		}
+		if kind, _ := sym.Payload["kind"].(string); kind != "" {
+			u.Kind = diff.UnitKind(kind)
+		}
+		sig, _ := sym.Payload["signature"].(string)
+		u.BeforeSig, u.AfterSig = sig, sig
+		start, end := SymbolLines(sym)
+		u.Range = &diff.Range{StartLine: start + 1, EndLine: end + 1}
+		break
+	}
+	return u
+}
+
+func payloadID(n *graph.Node, key string) []byte {
+	hex, _ := n.Payload[key].(string)
+	if hex == "" {
+		return nil
+	}
+	id, err := util.HexToBytes(hex)
+	if err != nil {
+		return nil
+	}
+	return id
+}
+
+// fileByPath returns the file at path in a snapshot, or nil when the
+// snapshot is nil or has no such file.
+func fileByPath(db *graph.DB, snapID []byte, path string) (*graph.Node, error) {
+	if snapID == nil {
+		return nil, nil
+	}
+	node, err := snapshot.GetFileByPath(db, snapID, path)
+	if err != nil {
+		return nil, fmt.Errorf("finding file: %w", err)
+	}
+	return node, nil
+}
+
+func digest(n *graph.Node) string {
+	d, _ := n.Payload["digest"].(string)
+	return d
+}
+
+func readContent(db *graph.DB, n *graph.Node) []byte {
+	if n == nil {
+		return nil
+	}
+	content, err := db.ReadObject(digest(n))
+	if err != nil {
+		return nil
+	}
+	return content
+}
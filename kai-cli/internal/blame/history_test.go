@@ -0,0 +1,176 @@
+package blame
+
+import (
+	"testing"
+
+	"kai-core/diff"
+	"kai/internal/classify"
+	"kai/internal/graph"
+	"kai/internal/util"
+)
+
+// createContentSnapshot inserts a snapshot whose files carry real content.
+func createContentSnapshot(t *testing.T, db *graph.DB, files map[string]string) []byte {
+	t.Helper()
+
+	var fileIDs [][]byte
+	var refs []string
+	for path, content := range files {
+		digest, err := db.WriteObject([]byte(content))
+		if err != nil {
+			t.Fatalf("writing object: %v", err)
+		}
+		fileID, err := db.InsertNodeDirect(graph.KindFile, map[string]interface{}{
+			"path": path, "lang": "js", "digest": digest,
+		})
+		if err != nil {
+			t.Fatalf("inserting file: %v", err)
+		}
+		fileIDs = append(fileIDs, fileID)
+		refs = append(refs, digest)
+	}
+
+	snapID, err := db.InsertNodeDirect(graph.KindSnapshot, map[string]interface{}{
+		"sourceRef": refs, "createdAt": util.NowMs(),
+	})
+	if err != nil {
+		t.Fatalf("inserting snapshot: %v", err)
+	}
+	for _, id := range fileIDs {
+		if err := db.InsertEdgeDirect(snapID, graph.EdgeHasFile, id, nil); err != nil {
+			t.Fatalf("inserting HAS_FILE: %v", err)
+		}
+	}
+	return snapID
+}
+
+func TestHistory_SignatureChangesAndMoves(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	v1 := createContentSnapshot(t, db, map[string]string{
+		"src/auth.js": "function validateSession(token) {\n  return token != null;\n}\n",
+	})
+	v2 := createContentSnapshot(t, db, map[string]string{
+		"src/auth.js": "function validateSession(token, opts) {\n  return token != null;\n}\n",
+	})
+	v3 := createContentSnapshot(t, db, map[string]string{
+		"src/auth.js":    "function other() {}\n",
+		"src/session.js": "function validateSession(token, opts) {\n  return token != null;\n}\n",
+	})
+
+	createChangeSet(t, db, v1, v2, 1000, "alice", "Add opts to validateSession", nil)
+	moved := createChangeSet(t, db, v2, v3, 2000, "bob", "Move session helpers", nil)
+
+	steps, err := History(db, HistoryOptions{Symbol: "validateSession"})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(steps))
+	}
+
+	// Newest first
+	if steps[0].ChangeSetID != util.BytesToHex(moved) {
+		t.Errorf("expected move changeset first")
+	}
+	if steps[0].MovedFrom != "src/auth.js" {
+		t.Errorf("expected move from src/auth.js, got %q", steps[0].MovedFrom)
+	}
+
+	sig := steps[1]
+	if len(sig.Files) != 1 || len(sig.Files[0].Units) != 1 {
+		t.Fatalf("expected a single unit in signature step, got %+v", sig.Files)
+	}
+	u := sig.Files[0].Units[0]
+	if u.Action != diff.ActionModified || u.BeforeSig == u.AfterSig {
+		t.Errorf("expected signature change, got %+v", u)
+	}
+	if sig.Author != "alice" {
+		t.Errorf("expected author alice, got %q", sig.Author)
+	}
+}
+
+func TestHistory_DeletedFiles(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	fn := "function validateSession(token) {\n  return token != null;\n}\n"
+	v1 := createContentSnapshot(t, db, map[string]string{"src/auth.js": fn})
+	v2 := createContentSnapshot(t, db, map[string]string{"src/session.js": fn})
+	v3 := createContentSnapshot(t, db, map[string]string{"src/index.js": "function main() {}\n"})
+
+	createChangeSet(t, db, v1, v2, 1000, "alice", "Rename auth.js to session.js", nil)
+	createChangeSet(t, db, v2, v3, 2000, "bob", "Drop sessions", nil)
+
+	steps, err := History(db, HistoryOptions{Symbol: "validateSession"})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(steps))
+	}
+
+	// Newest first: the removal with its file, then the rename as a move
+	removal := steps[0]
+	if len(removal.Files) != 1 || removal.Files[0].Path != "src/session.js" ||
+		len(removal.Files[0].Units) != 1 || removal.Files[0].Units[0].Action != diff.ActionRemoved {
+		t.Errorf("expected validateSession removed from src/session.js, got %+v", removal.Files)
+	}
+	if steps[1].MovedFrom != "src/auth.js" {
+		t.Errorf("expected move from src/auth.js, got %q", steps[1].MovedFrom)
+	}
+}
+
+func TestHistory_BodyChangeFromChangeTypes(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	path := "src/limits.js"
+	v1 := createContentSnapshot(t, db, map[string]string{path: "function limit() {\n  return 10;\n}\n"})
+	v2 := createContentSnapshot(t, db, map[string]string{path: "function limit() {\n  return 20;\n}\n"})
+
+	// Record the symbol in the head snapshot so the change type maps onto it
+	fileNode, err := db.GetEdges(v2, graph.EdgeHasFile)
+	if err != nil || len(fileNode) != 1 {
+		t.Fatalf("getting head file: %v", err)
+	}
+	symID, err := db.InsertNodeDirect(graph.KindSymbol, map[string]interface{}{
+		"fqName":    "limit",
+		"kind":      "function",
+		"signature": "function limit()",
+		"range":     map[string]interface{}{"start": []int{0, 0}, "end": []int{2, 1}},
+	})
+	if err != nil {
+		t.Fatalf("inserting symbol: %v", err)
+	}
+	if err := db.InsertEdgeDirect(symID, graph.EdgeDefinesIn, fileNode[0].Dst, v2); err != nil {
+		t.Fatalf("inserting DEFINES_IN: %v", err)
+	}
+
+	createChangeSet(t, db, v1, v2, 1000, "carol", "Raise limit",
+		[]*classify.ChangeType{rangeChange(classify.ConstantUpdated, path, 1)})
+
+	steps, err := History(db, HistoryOptions{Symbol: "limit", Path: path})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(steps) != 1 {
+		t.Fatalf("expected 1 step, got %d", len(steps))
+	}
+	if len(steps[0].ChangeTypes) != 1 || steps[0].ChangeTypes[0] != string(classify.ConstantUpdated) {
+		t.Errorf("unexpected change types: %v", steps[0].ChangeTypes)
+	}
+	u := steps[0].Files[0].Units[0]
+	if u.AfterSig != "function limit()" || u.Range == nil || u.Range.StartLine != 1 {
+		t.Errorf("unexpected body-change unit: %+v", u)
+	}
+
+	fileSteps, err := History(db, HistoryOptions{Path: path})
+	if err != nil {
+		t.Fatalf("History(file): %v", err)
+	}
+	if len(fileSteps) != 1 || fileSteps[0].Files[0].Path != path {
+		t.Errorf("expected one file-history step, got %+v", fileSteps)
+	}
+}
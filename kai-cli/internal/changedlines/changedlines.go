@@ -0,0 +1,249 @@
+// Package changedlines computes the line ranges touched by a change to a file,
+// widened to the enclosing symbols, for line-level coverage test selection.
+package changedlines
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"kai-core/diff"
+	"kai/internal/parse"
+)
+
+// Analyzer computes changed ranges, reusing one parser across files.
+type Analyzer struct {
+	parser *parse.Parser
+}
+
+// New creates an Analyzer.
+func New() *Analyzer {
+	return &Analyzer{parser: parse.NewParser()}
+}
+
+// Ranges returns the 1-based, inclusive line ranges changed between before and
+// after, in base and head coordinates respectively. Each changed line is
+// widened to the innermost function or class enclosing it, so a test covering any line of
+// a changed function intersects the range. A pure insertion or deletion marks
+// the symbol enclosing the gap on the other side, if any. A change outside
+// every function, or to a file that can't be parsed, widens to the whole file.
+func (a *Analyzer) Ranges(path string, before, after []byte) (base, head []diff.Range) {
+	c := changedLines(string(before), string(after))
+
+	lang := langOf(path)
+	return a.widen(c.base, c.baseGaps, before, lang), a.widen(c.head, c.headGaps, after, lang)
+}
+
+// changes holds changed line numbers on each side, plus gaps: positions where
+// lines were only inserted (base) or only deleted (head). Gap g lies between
+// lines g-1 and g.
+type changes struct {
+	base, head         map[int]bool
+	baseGaps, headGaps []int
+}
+
+func changedLines(before, after string) changes {
+	c := changes{base: make(map[int]bool), head: make(map[int]bool)}
+
+	dmp := diffmatchpatch.New()
+	chars1, chars2, lineArray := dmp.DiffLinesToChars(before, after)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), lineArray)
+
+	oldLine, newLine := 1, 1
+	for i, d := range diffs {
+		n := countLines(d.Text)
+		// A delete next to an insert is a replacement; both sides have lines
+		replaced := (i > 0 && diffs[i-1].Type != diffmatchpatch.DiffEqual) ||
+			(i+1 < len(diffs) && diffs[i+1].Type != diffmatchpatch.DiffEqual)
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			oldLine += n
+			newLine += n
+		case diffmatchpatch.DiffDelete:
+			for j := 0; j < n; j++ {
+				c.base[oldLine+j] = true
+			}
+			if !replaced {
+				c.headGaps = append(c.headGaps, newLine)
+			}
+			oldLine += n
+		case diffmatchpatch.DiffInsert:
+			for j := 0; j < n; j++ {
+				c.head[newLine+j] = true
+			}
+			if !replaced {
+				c.baseGaps = append(c.baseGaps, oldLine)
+			}
+			newLine += n
+		}
+	}
+	return c
+}
+
+// Parses reports whether changes to the file at path can be widened to the
+// symbols enclosing them. Other files widen to the whole file.
+func Parses(path string) bool {
+	return langOf(path) != ""
+}
+
+// widen maps changed lines to ranges, replacing each line by the innermost
+// symbol that encloses it, adds the symbols enclosing each gap, then merges
+// overlapping ranges.
+func (a *Analyzer) widen(lines map[int]bool, gaps []int, content []byte, lang string) []diff.Range {
+	if len(lines) == 0 && len(gaps) == 0 {
+		return nil
+	}
+
+	var symbols []*parse.Symbol
+	parsed := false
+	if lang != "" && len(content) > 0 {
+		if file, err := a.parser.Parse(content, lang); err == nil {
+			symbols, parsed = file.Symbols, true
+		}
+	}
+
+	// A change outside every function - to a top-level const, var or type -
+	// sits on lines coverage tools attribute to no test, so narrowing to it
+	// would drop every covering test. Fall back to the whole file, as for a
+	// file that can't be parsed.
+	whole := []diff.Range{{StartLine: 1, EndLine: max(countLines(string(content)), 1)}}
+	if !parsed {
+		if len(content) == 0 {
+			return nil
+		}
+		return whole
+	}
+	text := strings.Split(string(content), "\n")
+	for line := range lines {
+		blank := line > len(text) || strings.TrimSpace(text[line-1]) == ""
+		if !blank && !inFunction(symbols, line) {
+			return whole
+		}
+	}
+
+	// innermost returns the smallest function or class range containing
+	// first..last. Variables are skipped so locals don't narrow the range.
+	innermost := func(first, last int) (diff.Range, bool) {
+		var r diff.Range
+		best := -1
+		for _, sym := range symbols {
+			if sym.Kind != "function" && sym.Kind != "class" {
+				continue
+			}
+			// Symbol ranges are 0-based
+			start, end := sym.Range.Start[0]+1, sym.Range.End[0]+1
+			if first < start || last > end {
+				continue
+			}
+			if best < 0 || end-start < best {
+				best = end - start
+				r = diff.Range{StartLine: start, EndLine: end}
+			}
+		}
+		return r, best >= 0
+	}
+
+	var ranges []diff.Range
+	for line := range lines {
+		if r, ok := innermost(line, line); ok {
+			ranges = append(ranges, r)
+		} else {
+			ranges = append(ranges, diff.Range{StartLine: line, EndLine: line})
+		}
+	}
+	for _, gap := range gaps {
+		if r, ok := innermost(gap-1, gap); ok {
+			ranges = append(ranges, r)
+		}
+	}
+	return merge(ranges)
+}
+
+// inFunction reports whether a 1-based line lies within a function or
+// method symbol.
+func inFunction(symbols []*parse.Symbol, line int) bool {
+	for _, sym := range symbols {
+		if sym.Kind == "function" && line >= sym.Range.Start[0]+1 && line <= sym.Range.End[0]+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// merge sorts ranges and joins overlapping or adjacent ones.
+func merge(ranges []diff.Range) []diff.Range {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].StartLine < ranges[j].StartLine
+	})
+	var result []diff.Range
+	for _, r := range ranges {
+		if n := len(result); n > 0 && r.StartLine <= result[n-1].EndLine+1 {
+			if r.EndLine > result[n-1].EndLine {
+				result[n-1].EndLine = r.EndLine
+			}
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// Intersects reports whether any of the lines falls within the ranges.
+func Intersects(ranges []diff.Range, lines []int) bool {
+	for _, line := range lines {
+		for _, r := range ranges {
+			if line >= r.StartLine && line <= r.EndLine {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Format renders ranges compactly, e.g. "40-55, 80".
+func Format(ranges []diff.Range) string {
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r.StartLine == r.EndLine {
+			parts = append(parts, fmt.Sprintf("%d", r.StartLine))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r.StartLine, r.EndLine))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func countLines(text string) int {
+	if text == "" {
+		return 0
+	}
+	n := strings.Count(text, "\n")
+	if !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
+
+// langOf returns the parser language for a path, or "" when symbols cannot be
+// extracted and changes widen to the whole file.
+func langOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".js", ".jsx", ".mjs", ".cjs":
+		return "js"
+	case ".ts", ".tsx":
+		return "ts"
+	case ".py":
+		return "py"
+	case ".go":
+		return "go"
+	case ".rb":
+		return "rb"
+	case ".rs":
+		return "rs"
+	default:
+		return ""
+	}
+}
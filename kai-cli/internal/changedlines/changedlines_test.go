@@ -0,0 +1,114 @@
+package changedlines
+
+import (
+	"testing"
+
+	"kai-core/diff"
+)
+
+const before = `function add(a, b) {
+  return a + b;
+}
+
+function mul(a, b) {
+  const r = a * b;
+  return r;
+}
+
+const VERSION = 1;
+`
+
+func TestRanges_WidensToSymbol(t *testing.T) {
+	after := `function add(a, b) {
+  return a + b;
+}
+
+function mul(a, b) {
+  const r = a * b * 1;
+  return r;
+}
+
+const VERSION = 1;
+`
+	base, head := New().Ranges("src/math.js", []byte(before), []byte(after))
+
+	// Only mul (lines 5-8) changed; add must not be included
+	want := "5-8"
+	if got := Format(base); got != want {
+		t.Errorf("base ranges = %q, want %q", got, want)
+	}
+	if got := Format(head); got != want {
+		t.Errorf("head ranges = %q, want %q", got, want)
+	}
+	if Intersects(base, []int{1, 2, 3}) {
+		t.Error("tests covering only add should not intersect")
+	}
+	if !Intersects(base, []int{2, 7}) {
+		t.Error("tests covering mul should intersect")
+	}
+}
+
+func TestRanges_Insertion(t *testing.T) {
+	after := `function add(a, b) {
+  return a + b;
+}
+
+function sub(a, b) {
+  return a - b;
+}
+
+function mul(a, b) {
+  const r = a * b;
+  return r;
+}
+
+const VERSION = 1;
+`
+	base, head := New().Ranges("src/math.js", []byte(before), []byte(after))
+
+	if got := Format(head); got != "5-8" {
+		t.Errorf("head ranges = %q, want the new function 5-8", got)
+	}
+	// The insertion point sits between add and mul in the base version
+	if Intersects(base, []int{2}) || Intersects(base, []int{6}) {
+		t.Errorf("insertion should not widen to neighbouring functions, got %s", Format(base))
+	}
+}
+
+func TestRanges_TopLevelChangeWidensToFile(t *testing.T) {
+	before := "package calc\n\nconst Rate = 2\n\nfunc Apply(x int) int {\n\treturn x * Rate\n}\n"
+	after := "package calc\n\nconst Rate = 3\n\nfunc Apply(x int) int {\n\treturn x * Rate\n}\n"
+	base, head := New().Ranges("calc/calc.go", []byte(before), []byte(after))
+
+	// Coverage never attributes the const line to a test; Apply's lines must match
+	if got := Format(base); got != "1-7" {
+		t.Errorf("base ranges = %q, want the whole file 1-7", got)
+	}
+	if !Intersects(head, []int{6}) {
+		t.Errorf("tests covering Apply should intersect, got %s", Format(head))
+	}
+}
+
+func TestRanges_UnknownLanguage(t *testing.T) {
+	// An import or field change in a Java file can't be placed in a method;
+	// tests covering any line of the file must still intersect
+	before := "import a.B;\n\nclass C {\n  int x() { return 1; }\n}\n"
+	after := "import a.D;\n\nclass C {\n  int x() { return 1; }\n}\n"
+	base, head := New().Ranges("src/C.java", []byte(before), []byte(after))
+	if got := Format(base); got != "1-5" {
+		t.Errorf("base ranges = %q, want the whole file 1-5", got)
+	}
+	if got := Format(head); got != "1-5" {
+		t.Errorf("head ranges = %q, want the whole file 1-5", got)
+	}
+	if Parses("src/C.java") || !Parses("calc/calc.go") {
+		t.Errorf("Parses: want false for Java, true for Go")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	got := merge([]diff.Range{{StartLine: 10, EndLine: 12}, {StartLine: 1, EndLine: 3}, {StartLine: 4, EndLine: 4}, {StartLine: 11, EndLine: 20}})
+	if Format(got) != "1-4, 10-20" {
+		t.Errorf("merge = %s", Format(got))
+	}
+}
@@ -13,6 +13,10 @@ type Evidence = detect.Evidence
 type ChangeType = detect.ChangeType
 type JSONSymbol = detect.JSONSymbol
 type YAMLSymbol = detect.YAMLSymbol
+type SensitivePattern = detect.SensitivePattern
+type Dependency = detect.Dependency
+type DependencyChange = detect.DependencyChange
+type Manifest = detect.Manifest
 
 // Detector wraps kai-core/detect.Detector to use local graph.Node type
 type Detector struct {
@@ -35,6 +39,11 @@ func (d *Detector) DetectChanges(path string, beforeContent, afterContent []byte
 	return d.inner.DetectChanges(path, beforeContent, afterContent, fileID, lang...)
 }
 
+// AddSensitivePatterns adds call patterns to report as SENSITIVE_API_TOUCHED.
+func (d *Detector) AddSensitivePatterns(patterns ...SensitivePattern) {
+	d.inner.AddSensitivePatterns(patterns...)
+}
+
 // DetectFileChange creates a FILE_CONTENT_CHANGED for non-parseable files.
 func (d *Detector) DetectFileChange(path string, lang string) *ChangeType {
 	return d.inner.DetectFileChange(path, lang)
@@ -42,21 +51,27 @@ func (d *Detector) DetectFileChange(path string, lang string) *ChangeType {
 
 // Re-export constants from kai-core/detect
 const (
-	ConditionChanged   = detect.ConditionChanged
-	ConstantUpdated    = detect.ConstantUpdated
-	APISurfaceChanged  = detect.APISurfaceChanged
-	FunctionAdded      = detect.FunctionAdded
-	FunctionRemoved    = detect.FunctionRemoved
-	FileContentChanged = detect.FileContentChanged
-	FileAdded          = detect.FileAdded
-	FileDeleted        = detect.FileDeleted
-	JSONFieldAdded     = detect.JSONFieldAdded
-	JSONFieldRemoved   = detect.JSONFieldRemoved
-	JSONValueChanged   = detect.JSONValueChanged
-	JSONArrayChanged   = detect.JSONArrayChanged
-	YAMLKeyAdded       = detect.YAMLKeyAdded
-	YAMLKeyRemoved     = detect.YAMLKeyRemoved
-	YAMLValueChanged   = detect.YAMLValueChanged
+	ConditionChanged     = detect.ConditionChanged
+	ConstantUpdated      = detect.ConstantUpdated
+	APISurfaceChanged    = detect.APISurfaceChanged
+	FunctionAdded        = detect.FunctionAdded
+	FunctionRemoved      = detect.FunctionRemoved
+	ErrorHandlingChanged = detect.ErrorHandlingChanged
+	ConcurrencyChanged   = detect.ConcurrencyChanged
+	SensitiveAPITouched  = detect.SensitiveAPITouched
+	FileContentChanged   = detect.FileContentChanged
+	FileAdded            = detect.FileAdded
+	FileDeleted          = detect.FileDeleted
+	JSONFieldAdded       = detect.JSONFieldAdded
+	JSONFieldRemoved     = detect.JSONFieldRemoved
+	JSONValueChanged     = detect.JSONValueChanged
+	JSONArrayChanged     = detect.JSONArrayChanged
+	YAMLKeyAdded         = detect.YAMLKeyAdded
+	YAMLKeyRemoved       = detect.YAMLKeyRemoved
+	YAMLValueChanged     = detect.YAMLValueChanged
+	DependencyAdded      = detect.DependencyAdded
+	DependencyRemoved    = detect.DependencyRemoved
+	DependencyUpgraded   = detect.DependencyUpgraded
 )
 
 // Re-export functions from kai-core/detect
@@ -64,6 +79,7 @@ var (
 	GetCategoryPayload = detect.GetCategoryPayload
 	NewFileChange      = detect.NewFileChange
 	IsParseable        = detect.IsParseable
+	IsCodeLang         = detect.IsCodeLang
 	ExtractJSONSymbols = detect.ExtractJSONSymbols
 	DetectJSONChanges  = detect.DetectJSONChanges
 	FormatJSONPath     = detect.FormatJSONPath
@@ -72,4 +88,13 @@ var (
 	ExtractYAMLSymbols = detect.ExtractYAMLSymbols
 	DetectYAMLChanges  = detect.DetectYAMLChanges
 	FormatYAMLPath     = detect.FormatYAMLPath
+
+	ManifestEcosystem     = detect.ManifestEcosystem
+	ParseManifest         = detect.ParseManifest
+	DiffManifests         = detect.DiffManifests
+	DetectManifestChanges = detect.DetectManifestChanges
+	SemverDelta           = detect.SemverDelta
+	DependencyImported    = detect.DependencyImported
+
+	CanonicalProto = detect.CanonicalProto
 )
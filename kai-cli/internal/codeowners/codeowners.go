@@ -0,0 +1,206 @@
+// Package codeowners parses CODEOWNERS files and resolves the owners of a
+// path using GitHub semantics: patterns follow gitignore rules and the last
+// matching rule wins.
+package codeowners
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Locations lists where GitHub looks for a CODEOWNERS file, in order.
+var Locations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Rule is a single CODEOWNERS line.
+type Rule struct {
+	Pattern string   `json:"pattern"`
+	Owners  []string `json:"owners"` // Empty when the pattern explicitly has no owners
+	Line    int      `json:"line"`
+
+	glob     string // doublestar pattern for the path itself
+	dirGlob  string // doublestar pattern for everything under a matched directory
+	dirsOnly bool   // pattern had a trailing slash
+}
+
+// File is a parsed CODEOWNERS file.
+type File struct {
+	Path    string
+	Rules   []*Rule
+	Skipped []string // lines that couldn't be used, e.g. "line 3: negated patterns are not supported: !docs/"
+}
+
+// Parse parses CODEOWNERS content. Like GitHub, it skips lines it can't use,
+// such as negated patterns; each is recorded in Skipped with its line number.
+func Parse(data []byte) (*File, error) {
+	f := &File{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := splitFields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rule := &Rule{Pattern: fields[0], Line: lineNo}
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "#") {
+				break // trailing comment
+			}
+			rule.Owners = append(rule.Owners, owner)
+		}
+		if err := rule.compile(); err != nil {
+			f.Skipped = append(f.Skipped, fmt.Sprintf("line %d: %v", lineNo, err))
+			continue
+		}
+		f.Rules = append(f.Rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading CODEOWNERS: %w", err)
+	}
+	return f, nil
+}
+
+// splitFields splits a line on whitespace, honouring backslash-escaped spaces
+// and "\#" in patterns.
+func splitFields(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ' ' || r == '\t':
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+// compile translates the gitignore-style pattern into doublestar globs.
+func (r *Rule) compile() error {
+	p := r.Pattern
+	if strings.HasPrefix(p, "!") {
+		return fmt.Errorf("negated patterns are not supported: %s", p)
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirsOnly = true
+		p = strings.TrimSuffix(p, "/")
+	}
+
+	// A leading or inner slash anchors the pattern to the repository root;
+	// otherwise it matches at any depth.
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if p == "" || (p == "*" && !anchored) {
+		// "*" (or "/") owns everything
+		r.glob, r.dirGlob = "**", "**"
+		return nil
+	}
+	if !anchored && !strings.HasPrefix(p, "**") {
+		p = "**/" + p
+	}
+	if !doublestar.ValidatePattern(p) {
+		return fmt.Errorf("invalid pattern: %s", r.Pattern)
+	}
+	r.glob = p
+
+	// A pattern matching a directory owns everything beneath it, except that
+	// "dir/*" only covers direct children (GitHub deviates from gitignore here).
+	if !strings.HasSuffix(p, "/*") {
+		r.dirGlob = p + "/**"
+	}
+	return nil
+}
+
+// Matches reports whether the rule applies to a repository-relative path.
+func (r *Rule) Matches(path string) bool {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	if !r.dirsOnly {
+		if ok, _ := doublestar.Match(r.glob, path); ok {
+			return true
+		}
+	}
+	if r.dirGlob != "" {
+		if ok, _ := doublestar.Match(r.dirGlob, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the rule that determines ownership of path (the last
+// matching one), or nil when no rule matches.
+func (f *File) Match(path string) *Rule {
+	if f == nil {
+		return nil
+	}
+	for i := len(f.Rules) - 1; i >= 0; i-- {
+		if f.Rules[i].Matches(path) {
+			return f.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Owners returns the owners of path, or nil when it is unowned.
+func (f *File) Owners(path string) []string {
+	if rule := f.Match(path); rule != nil {
+		return rule.Owners
+	}
+	return nil
+}
+
+// OwnersOf returns the sorted union of owners of the given paths.
+func (f *File) OwnersOf(paths []string) []string {
+	set := make(map[string]bool)
+	for _, p := range paths {
+		for _, o := range f.Owners(p) {
+			set[o] = true
+		}
+	}
+	owners := make([]string, 0, len(set))
+	for o := range set {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// Shared returns the owners present in both lists.
+func Shared(a, b []string) []string {
+	set := make(map[string]bool, len(a))
+	for _, o := range a {
+		set[o] = true
+	}
+	var shared []string
+	for _, o := range b {
+		if set[o] {
+			shared = append(shared, o)
+			delete(set, o)
+		}
+	}
+	return shared
+}
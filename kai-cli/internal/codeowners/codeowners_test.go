@@ -0,0 +1,79 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+)
+
+const sample = `# Default owners
+*                 @org/everyone
+
+*.js              @js-owner  # inline comment
+**/logs           @logs-team
+/build/logs/      @doctocat
+docs/*            docs@example.com
+apps/             @octocat
+/scripts/         @doctocat @octocat
+/apps/github
+\#notes.md        @notes
+`
+
+func TestOwners(t *testing.T) {
+	f, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"README.md", "@org/everyone"},
+		{"src/app.js", "@js-owner"},
+		{"build/logs/out.txt", "@doctocat"},
+		{"docs/getting-started.md", "docs@example.com"},
+		{"docs/build-app/troubleshooting.md", "@org/everyone"}, // docs/* is not recursive
+		{"apps/web/index.ts", "@octocat"},
+		{"lib/apps/util.ts", "@octocat"}, // unanchored directory matches at any depth
+		{"scripts/deploy.sh", "@doctocat @octocat"},
+		{"deeply/nested/logs/a.log", "@logs-team"},
+		{"apps/github/readme.md", ""}, // explicit rule without owners
+		{"#notes.md", "@notes"},
+	}
+
+	for _, tt := range tests {
+		got := strings.Join(f.Owners(tt.path), " ")
+		if got != tt.want {
+			t.Errorf("Owners(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMatch_LastRuleWins(t *testing.T) {
+	f, err := Parse([]byte("src/ @a\nsrc/api/ @b\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if rule := f.Match("src/api/handler.go"); rule == nil || rule.Line != 2 {
+		t.Errorf("expected line 2 to win, got %+v", rule)
+	}
+	if got := f.OwnersOf([]string{"src/api/handler.go", "src/db.go", "README.md"}); strings.Join(got, ",") != "@a,@b" {
+		t.Errorf("OwnersOf = %v", got)
+	}
+	if got := Shared([]string{"@a", "@b"}, []string{"@b", "@c"}); len(got) != 1 || got[0] != "@b" {
+		t.Errorf("Shared = %v", got)
+	}
+}
+
+func TestParse_SkipsInvalidLines(t *testing.T) {
+	f, err := Parse([]byte("* @root\n!src/ @a\ndocs/ @docs\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(f.Rules) != 2 || f.Owners("docs/a.md")[0] != "@docs" {
+		t.Errorf("expected the valid rules to be kept, got %+v", f.Rules)
+	}
+	if len(f.Skipped) != 1 || !strings.HasPrefix(f.Skipped[0], "line 2: negated patterns") {
+		t.Errorf("Skipped = %v", f.Skipped)
+	}
+}
@@ -0,0 +1,277 @@
+// Package export dumps a snapshot-scoped subgraph of the semantic graph in
+// formats suitable for visualization (GraphViz DOT, GraphML) and offline
+// analysis (JSON Lines).
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kai/internal/graph"
+	"kai/internal/util"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	FormatDOT     Format = "dot"
+	FormatGraphML Format = "graphml"
+	FormatJSONL   Format = "jsonl"
+)
+
+// DefaultKinds are the node kinds exported when none are requested.
+var DefaultKinds = []graph.NodeKind{graph.KindFile, graph.KindSymbol, graph.KindModule}
+
+// DefaultEdges are the edge types exported when none are requested.
+var DefaultEdges = []graph.EdgeType{
+	graph.EdgeContains,
+	graph.EdgeDefinesIn,
+	graph.EdgeImports,
+	graph.EdgeCalls,
+	graph.EdgeTests,
+}
+
+// Options selects the subgraph to export.
+type Options struct {
+	SnapshotID []byte
+	Kinds      []graph.NodeKind // Node kinds to include (default: DefaultKinds)
+	Edges      []graph.EdgeType // Edge types to include (default: DefaultEdges)
+}
+
+// Subgraph is a snapshot-scoped slice of the graph. Nodes are sorted by kind
+// and label, edges by type, source and destination.
+type Subgraph struct {
+	SnapshotID []byte
+	Nodes      []*graph.Node
+	Edges      []*graph.Edge
+
+	// FileOf maps a symbol ID (hex) to the path of the file defining it.
+	FileOf map[string]string
+}
+
+// Collect gathers the files, symbols and modules of a snapshot and the edges
+// between them. Only edges whose endpoints are both exported are kept.
+func Collect(db *graph.DB, opts Options) (*Subgraph, error) {
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = DefaultKinds
+	}
+	edgeTypes := opts.Edges
+	if len(edgeTypes) == 0 {
+		edgeTypes = DefaultEdges
+	}
+	wantKind := make(map[graph.NodeKind]bool)
+	for _, k := range kinds {
+		wantKind[k] = true
+	}
+
+	sg := &Subgraph{SnapshotID: opts.SnapshotID, FileOf: make(map[string]string)}
+	nodes := make(map[string]*graph.Node)
+	addNode := func(id []byte) error {
+		key := util.BytesToHex(id)
+		if _, ok := nodes[key]; ok {
+			return nil
+		}
+		node, err := db.GetNode(id)
+		if err != nil {
+			return fmt.Errorf("getting node: %w", err)
+		}
+		if node != nil && wantKind[node.Kind] {
+			nodes[key] = node
+		}
+		return nil
+	}
+
+	// Files belong to the snapshot directly
+	hasFile, err := db.GetEdges(opts.SnapshotID, graph.EdgeHasFile)
+	if err != nil {
+		return nil, fmt.Errorf("getting snapshot files: %w", err)
+	}
+	filePaths := make(map[string]string)
+	for _, e := range hasFile {
+		file, err := db.GetNode(e.Dst)
+		if err != nil {
+			return nil, fmt.Errorf("getting file: %w", err)
+		}
+		if file == nil {
+			continue
+		}
+		key := util.BytesToHex(file.ID)
+		filePaths[key], _ = file.Payload["path"].(string)
+		if wantKind[graph.KindFile] {
+			nodes[key] = file
+		}
+	}
+	if wantKind[graph.KindSnapshot] {
+		if err := addNode(opts.SnapshotID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Symbols and modules are attached to files in the snapshot's context
+	definesIn, err := db.GetEdgesByContext(opts.SnapshotID, graph.EdgeDefinesIn)
+	if err != nil {
+		return nil, fmt.Errorf("getting symbols: %w", err)
+	}
+	for _, e := range definesIn {
+		sg.FileOf[util.BytesToHex(e.Src)] = filePaths[util.BytesToHex(e.Dst)]
+		if wantKind[graph.KindSymbol] {
+			if err := addNode(e.Src); err != nil {
+				return nil, err
+			}
+		}
+	}
+	contains, err := db.GetEdgesByContext(opts.SnapshotID, graph.EdgeContains)
+	if err != nil {
+		return nil, fmt.Errorf("getting modules: %w", err)
+	}
+	if wantKind[graph.KindModule] {
+		for _, e := range contains {
+			if err := addNode(e.Src); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range edgeTypes {
+		edges, err := snapshotEdges(db, opts.SnapshotID, t, hasFile, definesIn, contains)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			src, dst := util.BytesToHex(e.Src), util.BytesToHex(e.Dst)
+			if nodes[src] == nil || nodes[dst] == nil {
+				continue
+			}
+			// Collapse parallel edges (e.g. several call sites between two files)
+			key := src + string(e.Type) + dst
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			sg.Edges = append(sg.Edges, e)
+		}
+	}
+
+	for _, n := range nodes {
+		sg.Nodes = append(sg.Nodes, n)
+	}
+	sort.Slice(sg.Nodes, func(i, j int) bool {
+		a, b := sg.Nodes[i], sg.Nodes[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if la, lb := Label(a), Label(b); la != lb {
+			return la < lb
+		}
+		return bytes.Compare(a.ID, b.ID) < 0
+	})
+	sort.Slice(sg.Edges, func(i, j int) bool {
+		a, b := sg.Edges[i], sg.Edges[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if c := bytes.Compare(a.Src, b.Src); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(a.Dst, b.Dst) < 0
+	})
+
+	return sg, nil
+}
+
+// snapshotEdges returns the edges of one type that belong to a snapshot.
+// Most analysis edges carry the snapshot as their context; CALLS edges carry
+// the call-site node instead, so they are found through the snapshot's files.
+func snapshotEdges(db *graph.DB, snapID []byte, t graph.EdgeType, hasFile, definesIn, contains []*graph.Edge) ([]*graph.Edge, error) {
+	switch t {
+	case graph.EdgeHasFile:
+		return hasFile, nil
+	case graph.EdgeDefinesIn:
+		return definesIn, nil
+	case graph.EdgeContains:
+		return contains, nil
+	case graph.EdgeCalls:
+		var result []*graph.Edge
+		for _, f := range hasFile {
+			edges, err := db.GetEdges(f.Dst, graph.EdgeCalls)
+			if err != nil {
+				return nil, fmt.Errorf("getting calls: %w", err)
+			}
+			result = append(result, edges...)
+		}
+		return result, nil
+	default:
+		edges, err := db.GetEdgesByContext(snapID, t)
+		if err != nil {
+			return nil, fmt.Errorf("getting %s edges: %w", t, err)
+		}
+		return edges, nil
+	}
+}
+
+// Label returns a human-readable name for a node.
+func Label(n *graph.Node) string {
+	switch n.Kind {
+	case graph.KindFile:
+		path, _ := n.Payload["path"].(string)
+		return path
+	case graph.KindSymbol:
+		name, _ := n.Payload["fqName"].(string)
+		return name
+	case graph.KindSnapshot:
+		return "snapshot " + util.BytesToHex(n.ID)[:12]
+	}
+	if name, ok := n.Payload["name"].(string); ok {
+		return name
+	}
+	return util.BytesToHex(n.ID)[:12]
+}
+
+// ParseKinds parses a comma-separated list of node kinds (e.g. "File,Symbol").
+func ParseKinds(s string) ([]graph.NodeKind, error) {
+	valid := map[string]graph.NodeKind{}
+	for _, k := range []graph.NodeKind{graph.KindFile, graph.KindSymbol, graph.KindModule, graph.KindSnapshot} {
+		valid[strings.ToLower(string(k))] = k
+	}
+	var kinds []graph.NodeKind
+	for _, part := range splitList(s) {
+		k, ok := valid[strings.ToLower(part)]
+		if !ok {
+			return nil, fmt.Errorf("unknown node kind %q (expected File, Symbol, Module or Snapshot)", part)
+		}
+		kinds = append(kinds, k)
+	}
+	return kinds, nil
+}
+
+// ParseEdges parses a comma-separated list of edge types (e.g. "CALLS,IMPORTS").
+func ParseEdges(s string) ([]graph.EdgeType, error) {
+	valid := map[string]graph.EdgeType{}
+	for _, t := range append([]graph.EdgeType{graph.EdgeHasFile}, DefaultEdges...) {
+		valid[string(t)] = t
+	}
+	var types []graph.EdgeType
+	for _, part := range splitList(s) {
+		t, ok := valid[strings.ToUpper(part)]
+		if !ok {
+			return nil, fmt.Errorf("unknown edge type %q", part)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,243 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kai/internal/graph"
+	"kai/internal/module"
+	"kai/internal/util"
+)
+
+func setupTestDB(t *testing.T) (*graph.DB, func()) {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "kai-export-test-*")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+
+	dbPath := filepath.Join(tmpDir, "test.db")
+	objPath := filepath.Join(tmpDir, "objects")
+	if err := os.MkdirAll(objPath, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("creating objects dir: %v", err)
+	}
+
+	db, err := graph.Open(dbPath, objPath)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("opening database: %v", err)
+	}
+
+	schema := `
+CREATE TABLE IF NOT EXISTS nodes (id BLOB PRIMARY KEY, kind TEXT NOT NULL, payload TEXT NOT NULL, created_at INTEGER NOT NULL);
+CREATE TABLE IF NOT EXISTS edges (src BLOB NOT NULL, type TEXT NOT NULL, dst BLOB NOT NULL, at BLOB, created_at INTEGER NOT NULL, PRIMARY KEY (src, type, dst, at));
+`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		os.RemoveAll(tmpDir)
+		t.Fatalf("applying schema: %v", err)
+	}
+
+	cleanup := func() {
+		db.Close()
+		os.RemoveAll(tmpDir)
+	}
+
+	return db, cleanup
+}
+
+// fixture builds a snapshot with an auth module (session.ts), an api file
+// calling into it twice, and a test file. An unrelated snapshot's edges are
+// added to make sure they are not exported.
+func fixture(t *testing.T, db *graph.DB) []byte {
+	t.Helper()
+
+	mustNode := func(kind graph.NodeKind, payload map[string]interface{}) []byte {
+		id, err := db.InsertNodeDirect(kind, payload)
+		if err != nil {
+			t.Fatalf("inserting node: %v", err)
+		}
+		return id
+	}
+	mustEdge := func(src []byte, typ graph.EdgeType, dst, at []byte) {
+		if err := db.InsertEdgeDirect(src, typ, dst, at); err != nil {
+			t.Fatalf("inserting edge: %v", err)
+		}
+	}
+
+	snap := mustNode(graph.KindSnapshot, map[string]interface{}{"sourceRef": "fixture"})
+	other := mustNode(graph.KindSnapshot, map[string]interface{}{"sourceRef": "other"})
+
+	files := map[string][]byte{}
+	for _, p := range []string{"src/auth/session.ts", "src/api/handler.ts", "tests/api.test.ts"} {
+		files[p] = mustNode(graph.KindFile, map[string]interface{}{"path": p, "lang": "ts", "digest": p})
+		mustEdge(snap, graph.EdgeHasFile, files[p], nil)
+	}
+
+	mod := mustNode(graph.KindModule, map[string]interface{}{"name": "Auth", "patterns": []string{"src/auth/**"}})
+	mustEdge(mod, graph.EdgeContains, files["src/auth/session.ts"], snap)
+
+	sym := mustNode(graph.KindSymbol, map[string]interface{}{"fqName": "validateSession", "kind": "function"})
+	mustEdge(sym, graph.EdgeDefinesIn, files["src/auth/session.ts"], snap)
+
+	for line := 1; line <= 2; line++ {
+		call := mustNode(graph.KindSymbol, map[string]interface{}{
+			"calleeName": "validateSession", "callerFile": "src/api/handler.ts", "calleeFile": "src/auth/session.ts", "line": line,
+		})
+		mustEdge(files["src/api/handler.ts"], graph.EdgeCalls, files["src/auth/session.ts"], call)
+	}
+	mustEdge(files["src/api/handler.ts"], graph.EdgeImports, files["src/auth/session.ts"], snap)
+	mustEdge(files["tests/api.test.ts"], graph.EdgeTests, files["src/api/handler.ts"], snap)
+
+	// Belongs to another snapshot
+	mustEdge(files["tests/api.test.ts"], graph.EdgeImports, files["src/auth/session.ts"], other)
+
+	return snap
+}
+
+func edgeSummary(sg *Subgraph) []string {
+	var out []string
+	for _, e := range sg.Edges {
+		out = append(out, string(e.Type))
+	}
+	return out
+}
+
+func TestCollect(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	snap := fixture(t, db)
+
+	sg, err := Collect(db, Options{SnapshotID: snap})
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+
+	if len(sg.Nodes) != 5 {
+		t.Errorf("expected 5 nodes (3 files, 1 symbol, 1 module), got %d", len(sg.Nodes))
+	}
+	// Two call sites collapse into one CALLS edge; the other snapshot's import is excluded
+	want := "CALLS,CONTAINS,DEFINES_IN,IMPORTS,TESTS"
+	if got := strings.Join(edgeSummary(sg), ","); got != want {
+		t.Errorf("edges = %s, want %s", got, want)
+	}
+	if got := sg.FileOf[util.BytesToHex(sg.Nodes[len(sg.Nodes)-1].ID)]; got != "src/auth/session.ts" {
+		t.Errorf("expected symbol file to be recorded, got %q", got)
+	}
+}
+
+func TestCollect_Filters(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	snap := fixture(t, db)
+
+	kinds, err := ParseKinds("file")
+	if err != nil {
+		t.Fatalf("ParseKinds: %v", err)
+	}
+	edges, err := ParseEdges("calls, imports")
+	if err != nil {
+		t.Fatalf("ParseEdges: %v", err)
+	}
+
+	sg, err := Collect(db, Options{SnapshotID: snap, Kinds: kinds, Edges: edges})
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(sg.Nodes) != 3 {
+		t.Errorf("expected 3 file nodes, got %d", len(sg.Nodes))
+	}
+	if got := strings.Join(edgeSummary(sg), ","); got != "CALLS,IMPORTS" {
+		t.Errorf("edges = %s, want CALLS,IMPORTS", got)
+	}
+
+	if _, err := ParseKinds("File,Widget"); err == nil {
+		t.Error("expected error for unknown kind")
+	}
+	if _, err := ParseEdges("FOLLOWS"); err == nil {
+		t.Error("expected error for unknown edge type")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	snap := fixture(t, db)
+
+	sg, err := Collect(db, Options{SnapshotID: snap})
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+
+	t.Run("dot", func(t *testing.T) {
+		matcher := module.NewMatcher([]module.ModuleRule{
+			{Name: "Auth", Paths: []string{"src/auth/**"}},
+			{Name: "API", Paths: []string{"src/api/**"}},
+		})
+		var buf bytes.Buffer
+		if err := Write(&buf, sg, FormatDOT, matcher); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		out := buf.String()
+		for _, want := range []string{
+			"digraph kai {",
+			`label="API";`,
+			`label="Auth";`,
+			`[label="function validateSession", shape=ellipse];`,
+			`[label="CALLS"];`,
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("DOT output missing %q:\n%s", want, out)
+			}
+		}
+		if strings.Count(out, "subgraph cluster_") != 2 {
+			t.Errorf("expected 2 clusters:\n%s", out)
+		}
+	})
+
+	t.Run("graphml", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Write(&buf, sg, FormatGraphML, nil); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		var doc graphML
+		if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("invalid GraphML: %v", err)
+		}
+		if len(doc.Graph.Nodes) != len(sg.Nodes) || len(doc.Graph.Edges) != len(sg.Edges) {
+			t.Errorf("GraphML has %d nodes/%d edges, want %d/%d",
+				len(doc.Graph.Nodes), len(doc.Graph.Edges), len(sg.Nodes), len(sg.Edges))
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Write(&buf, sg, FormatJSONL, nil); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		counts := map[string]int{}
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var rec jsonlRecord
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+			}
+			counts[rec.Type]++
+		}
+		if counts["node"] != len(sg.Nodes) || counts["edge"] != len(sg.Edges) {
+			t.Errorf("unexpected record counts: %v", counts)
+		}
+	})
+
+	if err := Write(&bytes.Buffer{}, sg, Format("svg"), nil); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,254 @@
+package export
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kai/internal/graph"
+	"kai/internal/module"
+	"kai/internal/util"
+)
+
+// Write renders a subgraph in the given format. The matcher is used to
+// cluster DOT output by module and may be nil.
+func Write(w io.Writer, sg *Subgraph, format Format, matcher *module.Matcher) error {
+	switch format {
+	case FormatDOT:
+		return WriteDOT(w, sg, matcher)
+	case FormatGraphML:
+		return WriteGraphML(w, sg)
+	case FormatJSONL:
+		return WriteJSONL(w, sg)
+	default:
+		return fmt.Errorf("unknown format %q (expected dot, graphml or jsonl)", format)
+	}
+}
+
+// modulePath returns the path used to place a node in a module cluster.
+func (sg *Subgraph) modulePath(n *graph.Node) string {
+	switch n.Kind {
+	case graph.KindFile:
+		path, _ := n.Payload["path"].(string)
+		return path
+	case graph.KindSymbol:
+		return sg.FileOf[util.BytesToHex(n.ID)]
+	}
+	return ""
+}
+
+// WriteDOT renders the subgraph as a GraphViz digraph. Files and symbols are
+// grouped into one cluster per module; nodes outside any module stay at the
+// top level.
+func WriteDOT(w io.Writer, sg *Subgraph, matcher *module.Matcher) error {
+	clusters := make(map[string][]*graph.Node)
+	var loose []*graph.Node
+	for _, n := range sg.Nodes {
+		mod := ""
+		if n.Kind == graph.KindModule {
+			mod, _ = n.Payload["name"].(string)
+		} else if matcher != nil {
+			if mods := matcher.MatchPath(sg.modulePath(n)); len(mods) > 0 {
+				mod = mods[0]
+			}
+		}
+		if mod == "" {
+			loose = append(loose, n)
+		} else {
+			clusters[mod] = append(clusters[mod], n)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("digraph kai {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [fontname=\"Helvetica\", fontsize=10];\n")
+	sb.WriteString("  edge [fontname=\"Helvetica\", fontsize=8];\n")
+
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		fmt.Fprintf(&sb, "\n  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&sb, "    label=%s;\n", strconv.Quote(name))
+		sb.WriteString("    style=rounded;\n")
+		for _, n := range clusters[name] {
+			sb.WriteString("    " + dotNode(n) + "\n")
+		}
+		sb.WriteString("  }\n")
+	}
+
+	if len(loose) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, n := range loose {
+		sb.WriteString("  " + dotNode(n) + "\n")
+	}
+
+	if len(sg.Edges) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, e := range sg.Edges {
+		fmt.Fprintf(&sb, "  %s -> %s [label=%s%s];\n", dotID(e.Src), dotID(e.Dst), strconv.Quote(string(e.Type)), dotEdgeStyle(e.Type))
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func dotID(id []byte) string {
+	return strconv.Quote(util.BytesToHex(id)[:16])
+}
+
+func dotNode(n *graph.Node) string {
+	shape := "ellipse"
+	label := Label(n)
+	switch n.Kind {
+	case graph.KindFile:
+		shape = "box"
+	case graph.KindModule:
+		shape = "folder"
+	case graph.KindSnapshot:
+		shape = "cylinder"
+	case graph.KindSymbol:
+		if kind, ok := n.Payload["kind"].(string); ok {
+			label = kind + " " + label
+		}
+	}
+	return fmt.Sprintf("%s [label=%s, shape=%s];", dotID(n.ID), strconv.Quote(label), shape)
+}
+
+func dotEdgeStyle(t graph.EdgeType) string {
+	switch t {
+	case graph.EdgeDefinesIn, graph.EdgeContains, graph.EdgeHasFile:
+		return ", style=dotted"
+	case graph.EdgeTests:
+		return ", style=dashed"
+	}
+	return ""
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML renders the subgraph as GraphML with kind, label and path
+// attributes on nodes and a type attribute on edges.
+func WriteGraphML(w io.Writer, sg *Subgraph) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "kind", For: "node", AttrName: "kind", AttrType: "string"},
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "path", For: "node", AttrName: "path", AttrType: "string"},
+			{ID: "type", For: "edge", AttrName: "type", AttrType: "string"},
+		},
+		Graph: graphMLGraph{ID: util.BytesToHex(sg.SnapshotID), EdgeDefault: "directed"},
+	}
+
+	for _, n := range sg.Nodes {
+		node := graphMLNode{
+			ID: util.BytesToHex(n.ID),
+			Data: []graphMLData{
+				{Key: "kind", Value: string(n.Kind)},
+				{Key: "label", Value: Label(n)},
+			},
+		}
+		if path := sg.modulePath(n); path != "" {
+			node.Data = append(node.Data, graphMLData{Key: "path", Value: path})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range sg.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: util.BytesToHex(e.Src),
+			Target: util.BytesToHex(e.Dst),
+			Data:   []graphMLData{{Key: "type", Value: string(e.Type)}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encoding GraphML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// jsonlRecord is one line of JSON Lines output: either a node or an edge.
+type jsonlRecord struct {
+	Type    string                 `json:"type"` // "node" or "edge"
+	ID      string                 `json:"id,omitempty"`
+	Kind    string                 `json:"kind,omitempty"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	Src     string                 `json:"src,omitempty"`
+	Edge    string                 `json:"edge,omitempty"`
+	Dst     string                 `json:"dst,omitempty"`
+	At      string                 `json:"at,omitempty"`
+}
+
+// WriteJSONL renders the subgraph as JSON Lines: all nodes first, then all
+// edges, one record per line.
+func WriteJSONL(w io.Writer, sg *Subgraph) error {
+	enc := json.NewEncoder(w)
+	for _, n := range sg.Nodes {
+		rec := jsonlRecord{Type: "node", ID: util.BytesToHex(n.ID), Kind: string(n.Kind), Payload: n.Payload}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("encoding node: %w", err)
+		}
+	}
+	for _, e := range sg.Edges {
+		rec := jsonlRecord{Type: "edge", Src: util.BytesToHex(e.Src), Edge: string(e.Type), Dst: util.BytesToHex(e.Dst)}
+		if len(e.At) > 0 {
+			rec.At = util.BytesToHex(e.At)
+		}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("encoding edge: %w", err)
+		}
+	}
+	return nil
+}
@@ -90,6 +90,10 @@ func determineVerb(changeTypes []*classify.ChangeType) string {
 	hasAPI := false
 	hasCondition := false
 	hasConstant := false
+	hasSensitive := false
+	hasConcurrency := false
+	hasErrorHandling := false
+	hasDependency := false
 	hasJSONField := false
 	hasJSONValue := false
 	hasYAMLKey := false
@@ -108,6 +112,14 @@ func determineVerb(changeTypes []*classify.ChangeType) string {
 			hasCondition = true
 		case classify.ConstantUpdated:
 			hasConstant = true
+		case classify.SensitiveAPITouched:
+			hasSensitive = true
+		case classify.ConcurrencyChanged:
+			hasConcurrency = true
+		case classify.ErrorHandlingChanged:
+			hasErrorHandling = true
+		case classify.DependencyAdded, classify.DependencyRemoved, classify.DependencyUpgraded:
+			hasDependency = true
 		case classify.JSONFieldAdded, classify.JSONFieldRemoved:
 			hasJSONField = true
 		case classify.JSONValueChanged, classify.JSONArrayChanged:
@@ -136,15 +148,19 @@ func determineVerb(changeTypes []*classify.ChangeType) string {
 		return "Remove"
 	}
 	// Semantic code changes
-	if hasAPI {
+	if hasAPI || hasSensitive {
 		return "Update"
 	}
-	if hasCondition {
+	if hasCondition || hasConcurrency || hasErrorHandling {
 		return "Modify"
 	}
 	if hasConstant {
 		return "Update"
 	}
+	// Dependency manifest changes
+	if hasDependency {
+		return "Update"
+	}
 	// JSON changes
 	if hasJSONField {
 		return "Update"
@@ -0,0 +1,195 @@
+// Package junit parses JUnit XML test reports, as written by Gradle, Maven
+// Surefire, jest-junit, pytest --junitxml, rspec_junit_formatter,
+// go-junit-report and cargo-nextest, and maps test cases back to test files.
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Case is a single <testcase> from a report.
+type Case struct {
+	Name      string
+	Classname string
+	File      string  // file attribute, when the reporter writes one
+	Suite     string  // Name of the enclosing <testsuite>
+	Seconds   float64 // time attribute
+	Failed    bool    // Has a <failure> or <error>
+	Skipped   bool
+	Message   string // Failure or error message, else its text
+	Output    string // <system-out> and <system-err> of the case
+}
+
+type xmlSuite struct {
+	XMLName xml.Name
+	Name    string     `xml:"name,attr"`
+	File    string     `xml:"file,attr"`
+	Cases   []xmlCase  `xml:"testcase"`
+	Suites  []xmlSuite `xml:"testsuite"` // Nested suites
+}
+
+type xmlCase struct {
+	Name      string      `xml:"name,attr"`
+	Classname string      `xml:"classname,attr"`
+	File      string      `xml:"file,attr"`
+	Time      string      `xml:"time,attr"`
+	Failures  []xmlResult `xml:"failure"`
+	Errors    []xmlResult `xml:"error"`
+	Skipped   *xmlResult  `xml:"skipped"`
+	SystemOut string      `xml:"system-out"`
+	SystemErr string      `xml:"system-err"`
+}
+
+type xmlResult struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Parse reads a report whose root is <testsuites> or a single <testsuite>.
+func Parse(data []byte) ([]Case, error) {
+	// <testsuites> decodes as a suite without cases, holding the suites
+	var root xmlSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parsing JUnit XML: %w", err)
+	}
+	if root.XMLName.Local != "testsuites" && root.XMLName.Local != "testsuite" {
+		return nil, fmt.Errorf("parsing JUnit XML: unexpected root element <%s>", root.XMLName.Local)
+	}
+
+	var cases []Case
+	var walk func(s xmlSuite)
+	walk = func(s xmlSuite) {
+		for _, c := range s.Cases {
+			tc := Case{
+				Name:      c.Name,
+				Classname: c.Classname,
+				File:      c.File,
+				Suite:     s.Name,
+				Skipped:   c.Skipped != nil,
+				Output:    strings.TrimSpace(c.SystemOut + "\n" + c.SystemErr),
+			}
+			if tc.File == "" {
+				tc.File = s.File
+			}
+			fmt.Sscanf(strings.ReplaceAll(c.Time, ",", ""), "%g", &tc.Seconds)
+			for _, r := range append(c.Failures, c.Errors...) {
+				tc.Failed = true
+				if tc.Message == "" {
+					tc.Message = strings.TrimSpace(r.Message)
+				}
+				if tc.Message == "" {
+					tc.Message = strings.TrimSpace(r.Text)
+				}
+				if text := strings.TrimSpace(r.Text); text != "" {
+					tc.Output = strings.TrimSpace(text + "\n" + tc.Output)
+				}
+			}
+			cases = append(cases, tc)
+		}
+		for _, nested := range s.Suites {
+			walk(nested)
+		}
+	}
+	walk(root)
+	return cases, nil
+}
+
+// Key identifies the test file a case came from as precisely as the report
+// allows: its file attribute, else its classname, else its suite name.
+func (c Case) Key() string {
+	if c.File != "" {
+		return cleanPath(c.File)
+	}
+	if c.Classname != "" {
+		return c.Classname
+	}
+	return c.Suite
+}
+
+// Locate returns the files that a key from Key refers to. Paths match by
+// suffix, so absolute paths in reports resolve to repo-relative files.
+// Classnames are matched as dotted module or class paths (Python, Java,
+// Kotlin); as cargo-nextest binary IDs, where "crate::name" is the
+// integration test tests/name.rs and a bare crate name is its unit tests; and
+// as Go import paths, which name a package directory and so match every test
+// file in it.
+func Locate(key string, files []string) []string {
+	if key == "" {
+		return nil
+	}
+
+	if strings.Contains(path.Base(key), ".") && !strings.Contains(key, "::") {
+		// Looks like a file path; try it literally first
+		var found []string
+		for _, f := range files {
+			if f == key || strings.HasSuffix(key, "/"+f) || strings.HasSuffix(f, "/"+key) {
+				found = append(found, f)
+			}
+		}
+		if len(found) > 0 {
+			return found
+		}
+	}
+
+	if crate, target, ok := strings.Cut(key, "::"); ok || !strings.ContainsAny(key, "./") {
+		// cargo-nextest binary ID
+		var found []string
+		for _, f := range files {
+			if !strings.HasSuffix(f, ".rs") {
+				continue
+			}
+			inTests := strings.HasPrefix(f, "tests/") || strings.Contains(f, "/tests/")
+			switch {
+			case ok && (strings.HasSuffix("/"+f, "/tests/"+target+".rs") || strings.HasSuffix("/"+f, "/tests/"+target+"/main.rs")):
+				found = append(found, f)
+			case !ok && crate != "" && !inTests:
+				found = append(found, f)
+			}
+		}
+		if len(found) > 0 || ok {
+			return found
+		}
+	}
+
+	if strings.Contains(key, "/") {
+		// Go import path: match the package directory
+		var found []string
+		for _, f := range files {
+			if !strings.HasSuffix(f, "_test.go") {
+				continue
+			}
+			dir := path.Dir(f)
+			if key == dir || strings.HasSuffix(key, "/"+dir) {
+				found = append(found, f)
+			}
+		}
+		return found
+	}
+
+	// Module path: tests.test_cart.TestCart -> tests/test_cart, dropping
+	// trailing class and method segments until a file matches
+	segments := strings.FieldsFunc(key, func(r rune) bool { return r == '.' || r == ':' })
+	for n := len(segments); n > 0; n-- {
+		want := strings.Join(segments[:n], "/")
+		var found []string
+		for _, f := range files {
+			stem := strings.TrimSuffix(f, path.Ext(f))
+			if stem == want || strings.HasSuffix(stem, "/"+want) {
+				found = append(found, f)
+			}
+		}
+		if len(found) > 0 {
+			return found
+		}
+	}
+	return nil
+}
+
+func cleanPath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	p = path.Clean(p)
+	return strings.TrimPrefix(p, "./")
+}
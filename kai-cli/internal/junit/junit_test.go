@@ -0,0 +1,88 @@
+package junit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	report := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="jest tests">
+    <testcase classname="cart adds" name="cart adds" file="src/cart.test.js" time="0.25"/>
+    <testcase classname="cart removes" name="cart removes" file="src/cart.test.js" time="1,000.5">
+      <failure message="expected 1 to be 2">Error: expected 1 to be 2
+    at src/cart.test.js:12</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="outer" file="./spec/cart_spec.rb">
+    <testsuite name="inner">
+      <testcase classname="spec.cart_spec" name="totals" time="2">
+        <skipped/>
+      </testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`)
+
+	cases, err := Parse(report)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(cases) != 3 {
+		t.Fatalf("got %d cases, want 3", len(cases))
+	}
+	if c := cases[0]; c.Seconds != 0.25 || c.Failed || c.Key() != "src/cart.test.js" {
+		t.Errorf("case 0 = %+v", c)
+	}
+	if c := cases[1]; !c.Failed || c.Message != "expected 1 to be 2" || c.Seconds != 1000.5 || !strings.Contains(c.Output, "cart.test.js:12") {
+		t.Errorf("case 1 = %+v", c)
+	}
+	if c := cases[2]; !c.Skipped || c.Suite != "inner" || c.Key() != "spec.cart_spec" {
+		t.Errorf("case 2 = %+v", c)
+	}
+
+	single, err := Parse([]byte(`<testsuite name="pytest"><testcase classname="tests.test_cart.TestCart" name="test_add" time="0.1"><error message="boom"/></testcase></testsuite>`))
+	if err != nil {
+		t.Fatalf("Parse single suite: %v", err)
+	}
+	if len(single) != 1 || !single[0].Failed || single[0].Message != "boom" {
+		t.Errorf("single = %+v", single)
+	}
+
+	if _, err := Parse([]byte(`<coverage/>`)); err == nil {
+		t.Error("expected an error for a non-JUnit root")
+	}
+}
+
+func TestLocate(t *testing.T) {
+	files := []string{
+		"src/cart.test.js",
+		"tests/test_cart.py",
+		"src/test/java/com/shop/CartTest.java",
+		"cart/cart_test.go",
+		"cart/total_test.go",
+		"spec/cart_spec.rb",
+		"tests/checkout.rs",
+		"src/lib.rs",
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"src/cart.test.js", "src/cart.test.js"},
+		{"/home/ci/build/src/cart.test.js", "src/cart.test.js"},
+		{"spec/cart_spec.rb", "spec/cart_spec.rb"},
+		{"tests.test_cart.TestCart", "tests/test_cart.py"},
+		{"tests.test_cart", "tests/test_cart.py"},
+		{"com.shop.CartTest", "src/test/java/com/shop/CartTest.java"},
+		{"example.com/shop/cart", "cart/cart_test.go,cart/total_test.go"},
+		{"shop::checkout", "tests/checkout.rs"},
+		{"shop", "src/lib.rs"},
+		{"com.shop.Missing", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(Locate(tt.key, files), ","); got != tt.want {
+			t.Errorf("Locate(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
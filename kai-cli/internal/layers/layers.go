@@ -0,0 +1,300 @@
+// Package layers checks module architecture rules (forbidden imports and
+// import cycles between modules) against a snapshot's IMPORTS graph.
+package layers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"kai/internal/graph"
+	"kai/internal/module"
+	"kai/internal/util"
+)
+
+// Violation kinds.
+const (
+	RuleDeny  = "deny"
+	RuleCycle = "cycle"
+)
+
+// Violation is a single offending import between two files.
+type Violation struct {
+	Rule       string   `json:"rule"` // RuleDeny or RuleCycle
+	FromModule string   `json:"fromModule"`
+	ToModule   string   `json:"toModule"`
+	FromFile   string   `json:"fromFile"`
+	ToFile     string   `json:"toFile"`
+	Cycle      []string `json:"cycle,omitempty"` // Modules forming the cycle (cycle violations only)
+}
+
+// Key identifies a violation independently of the snapshot it was found in.
+func (v *Violation) Key() string {
+	return strings.Join([]string{v.Rule, v.FromModule, v.ToModule, v.FromFile, v.ToFile}, "|")
+}
+
+// String formats a violation for display.
+func (v *Violation) String() string {
+	if v.Rule == RuleCycle {
+		return fmt.Sprintf("cycle %s: %s -> %s (%s -> %s)",
+			strings.Join(v.Cycle, " <-> "), v.FromModule, v.ToModule, v.FromFile, v.ToFile)
+	}
+	return fmt.Sprintf("%s may not import %s: %s -> %s", v.FromModule, v.ToModule, v.FromFile, v.ToFile)
+}
+
+// Dependencies returns the modules each module imports in the snapshot,
+// sorted. Modules without imports of other modules are omitted.
+func Dependencies(db *graph.DB, snapshotID []byte, matcher *module.Matcher) (map[string][]string, error) {
+	imports, err := moduleImports(db, snapshotID, matcher)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := make(map[string][]string)
+	for _, imp := range imports {
+		if !contains(deps[imp.fromModule], imp.toModule) {
+			deps[imp.fromModule] = append(deps[imp.fromModule], imp.toModule)
+		}
+	}
+	for _, tos := range deps {
+		sort.Strings(tos)
+	}
+	return deps, nil
+}
+
+// moduleImport is an import between files in two different modules.
+type moduleImport struct {
+	fromModule, toModule string
+	fromFile, toFile     string
+}
+
+// Check evaluates the layering rules against the IMPORTS edges of a snapshot.
+// Violations are sorted by rule, modules and files.
+func Check(db *graph.DB, snapshotID []byte, matcher *module.Matcher, cfg *module.LayersConfig) ([]*Violation, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	imports, err := moduleImports(db, snapshotID, matcher)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []*Violation
+
+	denied := make(map[string]map[string]bool)
+	for _, rule := range cfg.Rules {
+		if denied[rule.From] == nil {
+			denied[rule.From] = make(map[string]bool)
+		}
+		for _, to := range rule.Deny {
+			denied[rule.From][to] = true
+		}
+	}
+	for _, imp := range imports {
+		if denied[imp.fromModule][imp.toModule] {
+			violations = append(violations, &Violation{
+				Rule:       RuleDeny,
+				FromModule: imp.fromModule,
+				ToModule:   imp.toModule,
+				FromFile:   imp.fromFile,
+				ToFile:     imp.toFile,
+			})
+		}
+	}
+
+	if cfg.NoCycles {
+		violations = append(violations, cycleViolations(imports)...)
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].Key() < violations[j].Key()
+	})
+	return violations, nil
+}
+
+// NewViolations returns the violations in head that are not present in base.
+func NewViolations(base, head []*Violation) []*Violation {
+	existing := make(map[string]bool)
+	for _, v := range base {
+		existing[v.Key()] = true
+	}
+	var result []*Violation
+	for _, v := range head {
+		if !existing[v.Key()] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// moduleImports resolves the snapshot's file-level imports to imports between
+// modules. A file matched by several modules contributes an import for each.
+func moduleImports(db *graph.DB, snapshotID []byte, matcher *module.Matcher) ([]moduleImport, error) {
+	edges, err := db.GetEdgesByContext(snapshotID, graph.EdgeImports)
+	if err != nil {
+		return nil, fmt.Errorf("getting imports: %w", err)
+	}
+
+	paths := make(map[string]string)
+	pathOf := func(id []byte) (string, error) {
+		key := util.BytesToHex(id)
+		if p, ok := paths[key]; ok {
+			return p, nil
+		}
+		node, err := db.GetNode(id)
+		if err != nil {
+			return "", fmt.Errorf("getting file: %w", err)
+		}
+		p := ""
+		if node != nil {
+			p, _ = node.Payload["path"].(string)
+		}
+		paths[key] = p
+		return p, nil
+	}
+
+	var result []moduleImport
+	for _, e := range edges {
+		from, err := pathOf(e.Src)
+		if err != nil {
+			return nil, err
+		}
+		to, err := pathOf(e.Dst)
+		if err != nil {
+			return nil, err
+		}
+		if from == "" || to == "" {
+			continue
+		}
+		for _, fm := range matcher.MatchPath(from) {
+			for _, tm := range matcher.MatchPath(to) {
+				if fm != tm {
+					result = append(result, moduleImport{fm, tm, from, to})
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// cycleViolations finds strongly connected components of the module import
+// graph and reports every import between modules of the same component.
+func cycleViolations(imports []moduleImport) []*Violation {
+	adj := make(map[string]map[string]bool)
+	for _, imp := range imports {
+		if adj[imp.fromModule] == nil {
+			adj[imp.fromModule] = make(map[string]bool)
+		}
+		adj[imp.fromModule][imp.toModule] = true
+	}
+
+	component := make(map[string][]string)
+	for _, scc := range stronglyConnected(adj) {
+		if len(scc) < 2 {
+			continue
+		}
+		sort.Strings(scc)
+		for _, m := range scc {
+			component[m] = scc
+		}
+	}
+
+	var result []*Violation
+	for _, imp := range imports {
+		scc := component[imp.fromModule]
+		if scc == nil || !contains(scc, imp.toModule) {
+			continue
+		}
+		result = append(result, &Violation{
+			Rule:       RuleCycle,
+			FromModule: imp.fromModule,
+			ToModule:   imp.toModule,
+			FromFile:   imp.fromFile,
+			ToFile:     imp.toFile,
+			Cycle:      scc,
+		})
+	}
+	return result
+}
+
+// stronglyConnected returns the strongly connected components of a directed
+// graph using Tarjan's algorithm. Nodes are visited in sorted order so the
+// result is deterministic.
+func stronglyConnected(adj map[string]map[string]bool) [][]string {
+	var nodes []string
+	seen := make(map[string]bool)
+	for from, tos := range adj {
+		if !seen[from] {
+			seen[from] = true
+			nodes = append(nodes, from)
+		}
+		for to := range tos {
+			if !seen[to] {
+				seen[to] = true
+				nodes = append(nodes, to)
+			}
+		}
+	}
+	sort.Strings(nodes)
+
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var result [][]string
+	next := 0
+
+	var visit func(v string)
+	visit = func(v string) {
+		index[v] = next
+		lowlink[v] = next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		var succ []string
+		for w := range adj[v] {
+			succ = append(succ, w)
+		}
+		sort.Strings(succ)
+		for _, w := range succ {
+			if _, ok := index[w]; !ok {
+				visit(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], index[w])
+			}
+		}
+
+		if lowlink[v] == index[v] {
+			var scc []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			result = append(result, scc)
+		}
+	}
+
+	for _, v := range nodes {
+		if _, ok := index[v]; !ok {
+			visit(v)
+		}
+	}
+	return result
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package layers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kai/internal/graph"
+	"kai/internal/module"
+)
+
+func setupTestDB(t *testing.T) (*graph.DB, func()) {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "kai-layers-test-*")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+
+	dbPath := filepath.Join(tmpDir, "test.db")
+	objPath := filepath.Join(tmpDir, "objects")
+	if err := os.MkdirAll(objPath, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("creating objects dir: %v", err)
+	}
+
+	db, err := graph.Open(dbPath, objPath)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("opening database: %v", err)
+	}
+
+	schema := `
+CREATE TABLE IF NOT EXISTS nodes (id BLOB PRIMARY KEY, kind TEXT NOT NULL, payload TEXT NOT NULL, created_at INTEGER NOT NULL);
+CREATE TABLE IF NOT EXISTS edges (src BLOB NOT NULL, type TEXT NOT NULL, dst BLOB NOT NULL, at BLOB, created_at INTEGER NOT NULL, PRIMARY KEY (src, type, dst, at));
+`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		os.RemoveAll(tmpDir)
+		t.Fatalf("applying schema: %v", err)
+	}
+
+	cleanup := func() {
+		db.Close()
+		os.RemoveAll(tmpDir)
+	}
+
+	return db, cleanup
+}
+
+// createSnapshot inserts a snapshot with an IMPORTS edge for each [from, to] pair.
+func createSnapshot(t *testing.T, db *graph.DB, name string, imports [][2]string) []byte {
+	t.Helper()
+
+	snap, err := db.InsertNodeDirect(graph.KindSnapshot, map[string]interface{}{"sourceRef": name})
+	if err != nil {
+		t.Fatalf("inserting snapshot: %v", err)
+	}
+	fileID := func(path string) []byte {
+		id, err := db.InsertNodeDirect(graph.KindFile, map[string]interface{}{"path": path, "lang": "ts", "digest": path})
+		if err != nil {
+			t.Fatalf("inserting file: %v", err)
+		}
+		if err := db.InsertEdgeDirect(snap, graph.EdgeHasFile, id, nil); err != nil {
+			t.Fatalf("inserting HAS_FILE: %v", err)
+		}
+		return id
+	}
+	for _, imp := range imports {
+		if err := db.InsertEdgeDirect(fileID(imp[0]), graph.EdgeImports, fileID(imp[1]), snap); err != nil {
+			t.Fatalf("inserting IMPORTS: %v", err)
+		}
+	}
+	return snap
+}
+
+func testMatcher() *module.Matcher {
+	return module.NewMatcher([]module.ModuleRule{
+		{Name: "ui", Paths: []string{"src/ui/**"}},
+		{Name: "api", Paths: []string{"src/api/**"}},
+		{Name: "db", Paths: []string{"src/db/**"}},
+	})
+}
+
+func TestCheck_Deny(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	snap := createSnapshot(t, db, "head", [][2]string{
+		{"src/ui/page.ts", "src/api/client.ts"},
+		{"src/ui/page.ts", "src/db/conn.ts"},
+		{"src/api/client.ts", "src/db/conn.ts"},
+		{"src/ui/page.ts", "src/ui/button.ts"},
+	})
+
+	cfg := &module.LayersConfig{Rules: []module.LayerRule{{From: "ui", Deny: []string{"db"}}}}
+	violations, err := Check(db, snap, testMatcher(), cfg)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(violations) != 1 {
+		t.Fatalf("expected 1 violation, got %d: %v", len(violations), violations)
+	}
+	v := violations[0]
+	if v.Rule != RuleDeny || v.FromFile != "src/ui/page.ts" || v.ToFile != "src/db/conn.ts" {
+		t.Errorf("unexpected violation: %+v", v)
+	}
+}
+
+func TestCheck_Cycles(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	snap := createSnapshot(t, db, "head", [][2]string{
+		{"src/ui/page.ts", "src/api/client.ts"},
+		{"src/api/client.ts", "src/db/conn.ts"},
+		{"src/db/conn.ts", "src/ui/format.ts"},
+		{"src/ui/page.ts", "src/ui/format.ts"},
+		{"tools/gen.ts", "src/ui/page.ts"}, // outside any module
+	})
+
+	violations, err := Check(db, snap, testMatcher(), &module.LayersConfig{NoCycles: true})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(violations) != 3 {
+		t.Fatalf("expected 3 cycle violations, got %d: %v", len(violations), violations)
+	}
+	for _, v := range violations {
+		if v.Rule != RuleCycle || len(v.Cycle) != 3 {
+			t.Errorf("unexpected violation: %+v", v)
+		}
+	}
+
+	// Without the back edge there is no cycle
+	acyclic := createSnapshot(t, db, "acyclic", [][2]string{
+		{"src/ui/page.ts", "src/api/client.ts"},
+		{"src/api/client.ts", "src/db/conn.ts"},
+	})
+	violations, err = Check(db, acyclic, testMatcher(), &module.LayersConfig{NoCycles: true})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("expected no violations, got %v", violations)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	snap := createSnapshot(t, db, "head", [][2]string{
+		{"src/ui/page.ts", "src/db/conn.ts"},
+		{"src/ui/page.ts", "src/api/client.ts"},
+		{"src/ui/form.ts", "src/api/client.ts"},
+		{"src/api/client.ts", "src/api/types.ts"}, // within a module
+		{"tools/gen.ts", "src/db/conn.ts"},        // outside any module
+	})
+
+	deps, err := Dependencies(db, snap, testMatcher())
+	if err != nil {
+		t.Fatalf("Dependencies: %v", err)
+	}
+	if len(deps) != 1 || strings.Join(deps["ui"], ",") != "api,db" {
+		t.Errorf("deps = %v, want ui -> api, db", deps)
+	}
+}
+
+func TestNewViolations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	cfg := &module.LayersConfig{Rules: []module.LayerRule{{From: "ui", Deny: []string{"db"}}}}
+	base := createSnapshot(t, db, "base", [][2]string{
+		{"src/ui/legacy.ts", "src/db/conn.ts"},
+	})
+	head := createSnapshot(t, db, "head", [][2]string{
+		{"src/ui/legacy.ts", "src/db/conn.ts"},
+		{"src/ui/page.ts", "src/db/conn.ts"},
+	})
+
+	baseViolations, err := Check(db, base, testMatcher(), cfg)
+	if err != nil {
+		t.Fatalf("Check(base): %v", err)
+	}
+	headViolations, err := Check(db, head, testMatcher(), cfg)
+	if err != nil {
+		t.Fatalf("Check(head): %v", err)
+	}
+
+	added := NewViolations(baseViolations, headViolations)
+	if len(added) != 1 || added[0].FromFile != "src/ui/page.ts" {
+		t.Errorf("expected only the new page.ts violation, got %v", added)
+	}
+}
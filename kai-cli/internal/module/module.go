@@ -8,6 +8,8 @@ import (
 // Re-export types from kai-core/modulematch
 type ModuleRule = modulematch.ModuleRule
 type ModulesConfig = modulematch.ModulesConfig
+type LayerRule = modulematch.LayerRule
+type LayersConfig = modulematch.LayersConfig
 type Matcher = modulematch.Matcher
 
 // Re-export functions from kai-core/modulematch
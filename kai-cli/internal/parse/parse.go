@@ -16,6 +16,15 @@ type CallSite = coreparse.CallSite
 type Import = coreparse.Import
 type ParsedCalls = coreparse.ParsedCalls
 
+// Test case extraction
+type TestCase = coreparse.TestCase
+
+const (
+	FrameworkGo     = coreparse.FrameworkGo
+	FrameworkPytest = coreparse.FrameworkPytest
+	FrameworkJest   = coreparse.FrameworkJest
+)
+
 // Re-export functions from kai-core/parse
 var (
 	NewParser          = coreparse.NewParser
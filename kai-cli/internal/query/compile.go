@@ -0,0 +1,256 @@
+package query
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"modernc.org/sqlite"
+)
+
+func init() {
+	// kai_glob(pattern, value) gives "~" conditions doublestar semantics
+	// ("*" stays within a path segment, "**" crosses segments), which SQLite's
+	// built-in GLOB cannot express. Registered functions are available on every
+	// connection opened afterwards, i.e. all graph.DB connections.
+	sqlite.MustRegisterDeterministicScalarFunction("kai_glob", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		pattern, _ := args[0].(string)
+		value, _ := args[1].(string)
+		if value == "" {
+			return int64(0), nil
+		}
+		ok, err := doublestar.Match(pattern, value)
+		if err != nil || !ok {
+			return int64(0), nil
+		}
+		return int64(1), nil
+	})
+}
+
+// Compiled is a query ready to run against the graph database.
+type Compiled struct {
+	SQL  string
+	Args []interface{}
+}
+
+// pathExpr resolves the file path of a node: files carry it in their payload,
+// symbols through their DEFINES_IN edge in the snapshot.
+const pathExpr = `COALESCE(json_extract(n.payload, '$.path'), (SELECT json_extract(f.payload, '$.path') FROM edges d JOIN nodes f ON f.id = d.dst WHERE d.src = n.id AND d.type = 'DEFINES_IN' AND d.at = ? LIMIT 1))`
+
+// nameExpr is the display name of a node regardless of kind.
+const nameExpr = `COALESCE(json_extract(n.payload, '$.fqName'), json_extract(n.payload, '$.name'), json_extract(n.payload, '$.path'))`
+
+type compiler struct {
+	snapshot []byte
+	ctes     []string
+	args     []interface{}
+}
+
+// Compile turns a parsed query into one SQL statement scoped to a snapshot.
+// The statement returns (id, kind, payload, path) rows.
+func Compile(q *Query, snapshotID []byte) (*Compiled, error) {
+	if len(q.Stages) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	c := &compiler{snapshot: snapshotID}
+	prev := ""
+	for i, stage := range q.Stages {
+		name := fmt.Sprintf("s%d", i)
+		if err := c.stage(name, prev, stage); err != nil {
+			return nil, err
+		}
+		prev = name
+	}
+
+	var sb strings.Builder
+	sb.WriteString("WITH RECURSIVE\n")
+	sb.WriteString(strings.Join(c.ctes, ",\n"))
+	sb.WriteString("\nSELECT n.id, n.kind, n.payload, " + pathExpr + " AS path\n")
+	sb.WriteString("FROM nodes n WHERE n.id IN (SELECT id FROM " + prev + ")\n")
+	sb.WriteString("ORDER BY n.kind, path, " + nameExpr)
+	c.args = append(c.args, c.snapshot)
+
+	return &Compiled{SQL: sb.String(), Args: c.args}, nil
+}
+
+// add appends a CTE, binding args in textual order.
+func (c *compiler) add(name, body string, args ...interface{}) {
+	c.ctes = append(c.ctes, name+"(id) AS (\n"+body+"\n)")
+	c.args = append(c.args, args...)
+}
+
+// addRecursive appends a recursive (id, depth) CTE.
+func (c *compiler) addRecursive(name, body string, args ...interface{}) {
+	c.ctes = append(c.ctes, name+"(id, depth) AS (\n"+body+"\n)")
+	c.args = append(c.args, args...)
+}
+
+func (c *compiler) stage(name, prev string, s Stage) error {
+	raw := name + "_raw"
+	if len(s.Where) == 0 {
+		raw = name
+	}
+
+	switch {
+	case prev == "":
+		c.source(raw, s.Op)
+	case s.Op == OpWhere:
+		c.add(raw, "SELECT id FROM "+prev)
+	case s.Op == OpLimit:
+		c.add(raw, "SELECT n.id FROM nodes n WHERE n.id IN (SELECT id FROM "+prev+") ORDER BY "+pathExpr+", "+nameExpr+" LIMIT ?", c.snapshot, s.Limit)
+	default:
+		if err := c.traverse(raw, prev, s); err != nil {
+			return err
+		}
+	}
+
+	if len(s.Where) == 0 {
+		return nil
+	}
+
+	var conds []string
+	var condArgs []interface{}
+	for _, cond := range s.Where {
+		expr, args := c.cond(cond)
+		conds = append(conds, expr)
+		condArgs = append(condArgs, args...)
+	}
+	c.add(name, "SELECT n.id FROM nodes n WHERE n.id IN (SELECT id FROM "+raw+") AND "+strings.Join(conds, " AND "), condArgs...)
+	return nil
+}
+
+func (c *compiler) source(name string, op Op) {
+	switch op {
+	case OpFiles:
+		c.add(name, "SELECT dst FROM edges WHERE src = ? AND type = 'HAS_FILE'", c.snapshot)
+	case OpSymbols:
+		c.add(name, "SELECT src FROM edges WHERE at = ? AND type = 'DEFINES_IN'", c.snapshot)
+	case OpModules:
+		c.add(name, "SELECT DISTINCT src FROM edges WHERE at = ? AND type = 'CONTAINS'", c.snapshot)
+	}
+}
+
+// files adds a CTE with the files of prev: File nodes pass through and
+// symbols resolve to the file they are defined in.
+func (c *compiler) files(name, prev string) {
+	c.add(name, `SELECT p.id FROM `+prev+` p JOIN nodes n ON n.id = p.id WHERE n.kind = 'File'
+UNION
+SELECT d.dst FROM edges d JOIN `+prev+` p ON d.src = p.id WHERE d.type = 'DEFINES_IN' AND d.at = ?`, c.snapshot)
+}
+
+func (c *compiler) traverse(name, prev string, s Stage) error {
+	inFiles := name + "_files"
+
+	switch s.Op {
+	case OpFiles:
+		c.files(name, prev)
+
+	case OpSymbols:
+		c.add(name, `SELECT p.id FROM `+prev+` p JOIN nodes n ON n.id = p.id WHERE n.kind = 'Symbol'
+UNION
+SELECT d.src FROM edges d JOIN `+prev+` p ON d.dst = p.id WHERE d.type = 'DEFINES_IN' AND d.at = ?`, c.snapshot)
+
+	case OpCallers:
+		// CALLS edges link caller file -> callee file, with the call site
+		// (calleeName, calleeFile) recorded on the node referenced by "at".
+		// Symbols are matched by name against call sites in their file.
+		seed := name + "_seed"
+		c.add(seed, `SELECT e.src FROM edges e
+JOIN nodes cs ON cs.id = e.at
+JOIN `+prev+` p
+JOIN nodes s ON s.id = p.id AND s.kind = 'Symbol'
+JOIN edges d ON d.src = s.id AND d.type = 'DEFINES_IN' AND d.at = ?
+JOIN nodes f ON f.id = d.dst
+WHERE e.type = 'CALLS'
+AND json_extract(cs.payload, '$.calleeFile') = json_extract(f.payload, '$.path')
+AND (json_extract(s.payload, '$.fqName') = json_extract(cs.payload, '$.calleeName')
+  OR json_extract(s.payload, '$.fqName') LIKE '%.' || json_extract(cs.payload, '$.calleeName'))
+UNION
+SELECT e.src FROM edges e JOIN `+prev+` p ON e.dst = p.id WHERE e.type = 'CALLS'`, c.snapshot)
+		c.reach(name, seed, "CALLS", false, s.Depth, nil, true)
+
+	case OpCallees:
+		c.files(inFiles, prev)
+		c.reach(name, inFiles, "CALLS", true, s.Depth, nil, false)
+
+	case OpImports:
+		c.files(inFiles, prev)
+		c.reach(name, inFiles, "IMPORTS", true, s.Depth, c.snapshot, false)
+
+	case OpImporters:
+		c.files(inFiles, prev)
+		c.reach(name, inFiles, "IMPORTS", false, s.Depth, c.snapshot, false)
+
+	case OpTests:
+		// Test files covering the input, plus input files that are tests themselves
+		c.files(inFiles, prev)
+		c.add(name, `SELECT e.src FROM edges e JOIN `+inFiles+` p ON e.dst = p.id WHERE e.type = 'TESTS' AND e.at = ?
+UNION
+SELECT p.id FROM `+inFiles+` p WHERE EXISTS (SELECT 1 FROM edges t WHERE t.src = p.id AND t.type = 'TESTS' AND t.at = ?)`, c.snapshot, c.snapshot)
+
+	default:
+		return fmt.Errorf("unsupported stage %q", s.Op)
+	}
+	return nil
+}
+
+// reach adds a CTE with every node reachable from seed within depth hops of
+// edgeType. forward follows src -> dst; otherwise dst -> src. When seedIsHop
+// is set the seed is already the first hop (callers) and is part of the
+// result; otherwise the seed is the input set and is excluded.
+func (c *compiler) reach(name, seed, edgeType string, forward bool, depth int, at []byte, seedIsHop bool) {
+	from, to := "dst", "src"
+	if forward {
+		from, to = "src", "dst"
+	}
+	atCond := ""
+	var args []interface{}
+	if at != nil {
+		atCond = " AND e.at = ?"
+		args = append(args, at)
+	}
+	args = append(args, depth)
+
+	start, minDepth := "0", "0"
+	if seedIsHop {
+		start = "1"
+		minDepth = "-1"
+	}
+
+	walk := name + "_walk"
+	c.addRecursive(walk, `SELECT id, `+start+` FROM `+seed+`
+UNION
+SELECT e.`+to+`, w.depth + 1 FROM edges e JOIN `+walk+` w ON e.`+from+` = w.id WHERE e.type = '`+edgeType+`'`+atCond+` AND w.depth < ?`, args...)
+	c.add(name, "SELECT DISTINCT id FROM "+walk+" WHERE depth > "+minDepth)
+}
+
+// cond compiles a where condition to a SQL expression over the node alias n.
+func (c *compiler) cond(cond Cond) (string, []interface{}) {
+	var expr string
+	var args []interface{}
+	switch cond.Field {
+	case "name":
+		expr = nameExpr
+	case "file", "path":
+		expr = pathExpr
+		args = append(args, c.snapshot)
+	case "kind":
+		// Symbol kind (function, class, ...) or node kind (File, Module)
+		expr = "COALESCE(json_extract(n.payload, '$.kind'), n.kind)"
+	default:
+		expr = "json_extract(n.payload, '$." + cond.Field + "')"
+	}
+
+	switch cond.Op {
+	case "~":
+		return "kai_glob(?, " + expr + ") = 1", append([]interface{}{cond.Value}, args...)
+	case "!~":
+		return "kai_glob(?, " + expr + ") = 0", append([]interface{}{cond.Value}, args...)
+	case "!=":
+		return "COALESCE(" + expr + ", '') != ?", append(args, cond.Value)
+	default:
+		return expr + " = ?", append(args, cond.Value)
+	}
+}
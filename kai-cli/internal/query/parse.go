@@ -0,0 +1,317 @@
+// Package query implements a small pipeline language over the semantic graph.
+//
+// A query is a sequence of stages separated by "|". The first stage selects a
+// node set from a snapshot and later stages traverse or filter it:
+//
+//	symbols where kind=function and file~"auth/**" | callers depth 2 | tests
+//
+// Queries are compiled to a single SQLite statement over the nodes and edges
+// tables (see Compile).
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Op names a pipeline stage.
+type Op string
+
+const (
+	// Sources (first stage only)
+	OpFiles   Op = "files"
+	OpSymbols Op = "symbols"
+	OpModules Op = "modules"
+
+	// Traversals
+	OpCallers   Op = "callers"
+	OpCallees   Op = "callees"
+	OpImports   Op = "imports"
+	OpImporters Op = "importers"
+	OpTests     Op = "tests"
+
+	// Filters
+	OpWhere Op = "where"
+	OpLimit Op = "limit"
+)
+
+// Cond is a single field comparison in a where clause.
+type Cond struct {
+	Field string `json:"field"`
+	Op    string `json:"op"` // "=", "!=", "~" (glob), "!~"
+	Value string `json:"value"`
+}
+
+// Stage is one step of a query pipeline.
+type Stage struct {
+	Op    Op     `json:"op"`
+	Depth int    `json:"depth,omitempty"` // traversal depth (callers, callees, imports, importers)
+	Limit int    `json:"limit,omitempty"` // for limit stages
+	Where []Cond `json:"where,omitempty"` // conditions applied to the stage output
+}
+
+// Query is a parsed pipeline.
+type Query struct {
+	Stages []Stage `json:"stages"`
+}
+
+var sourceOps = map[Op]bool{OpFiles: true, OpSymbols: true, OpModules: true}
+
+var traversalOps = map[Op]bool{
+	OpCallers: true, OpCallees: true, OpImports: true, OpImporters: true,
+	OpTests: true, OpFiles: true, OpSymbols: true,
+}
+
+// depthOps are traversals that accept "depth N".
+var depthOps = map[Op]bool{OpCallers: true, OpCallees: true, OpImports: true, OpImporters: true}
+
+// Parse parses a query string.
+func Parse(input string) (*Query, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	q := &Query{}
+	for {
+		stage, err := p.parseStage(len(q.Stages) == 0)
+		if err != nil {
+			return nil, err
+		}
+		q.Stages = append(q.Stages, stage)
+
+		if p.done() {
+			break
+		}
+		if tok := p.next(); tok.kind != tokPipe {
+			return nil, fmt.Errorf("expected '|' at position %d, got %q", tok.pos, tok.text)
+		}
+	}
+
+	return q, nil
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokNumber
+	tokPipe
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '|':
+			tokens = append(tokens, token{tokPipe, "|", i})
+			i++
+		case r == '=' || r == '~':
+			tokens = append(tokens, token{tokOp, string(r), i})
+			i++
+		case r == '!':
+			if i+1 < len(runes) && (runes[i+1] == '=' || runes[i+1] == '~') {
+				tokens = append(tokens, token{tokOp, string(runes[i : i+2]), i})
+				i += 2
+			} else {
+				return nil, fmt.Errorf("unexpected '!' at position %d", i)
+			}
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++ // closing quote
+			tokens = append(tokens, token{tokString, sb.String(), start})
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, string(runes[start:i]), start})
+		case isIdentRune(r, true):
+			start := i
+			for i < len(runes) && isIdentRune(runes[i], false) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[start:i]), start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+	return tokens, nil
+}
+
+// isIdentRune reports whether r may appear in a bare word. Bare values may
+// contain dots, slashes and dashes so that paths and qualified names need no
+// quoting (e.g. name=Auth.login, path=src/app.ts).
+func isIdentRune(r rune, first bool) bool {
+	if unicode.IsLetter(r) || r == '_' {
+		return true
+	}
+	if first {
+		return false
+	}
+	return unicode.IsDigit(r) || r == '.' || r == '/' || r == '-' || r == '*'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: -1, pos: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *parser) peekKeyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, word)
+}
+
+func (p *parser) parseStage(first bool) (Stage, error) {
+	tok := p.next()
+	if tok.kind != tokIdent {
+		return Stage{}, fmt.Errorf("expected stage name, got %q", tok.text)
+	}
+	stage := Stage{Op: Op(strings.ToLower(tok.text))}
+
+	switch {
+	case stage.Op == OpWhere:
+		if first {
+			return Stage{}, fmt.Errorf("query must start with a source (files, symbols, modules)")
+		}
+		conds, err := p.parseConds()
+		if err != nil {
+			return Stage{}, err
+		}
+		stage.Where = conds
+		return stage, nil
+
+	case stage.Op == OpLimit:
+		if first {
+			return Stage{}, fmt.Errorf("query must start with a source (files, symbols, modules)")
+		}
+		n, err := p.parseNumber("limit")
+		if err != nil {
+			return Stage{}, err
+		}
+		stage.Limit = n
+		return stage, nil
+
+	case first && !sourceOps[stage.Op]:
+		return Stage{}, fmt.Errorf("query must start with a source (files, symbols, modules), got %q", tok.text)
+
+	case !first && !traversalOps[stage.Op]:
+		return Stage{}, fmt.Errorf("unknown stage %q", tok.text)
+	}
+
+	if p.peekKeyword("depth") {
+		if !depthOps[stage.Op] {
+			return Stage{}, fmt.Errorf("stage %q does not accept depth", stage.Op)
+		}
+		p.next()
+		n, err := p.parseNumber("depth")
+		if err != nil {
+			return Stage{}, err
+		}
+		stage.Depth = n
+	} else if depthOps[stage.Op] {
+		stage.Depth = 1
+	}
+
+	if p.peekKeyword("where") {
+		p.next()
+		conds, err := p.parseConds()
+		if err != nil {
+			return Stage{}, err
+		}
+		stage.Where = conds
+	}
+
+	return stage, nil
+}
+
+func (p *parser) parseNumber(what string) (int, error) {
+	tok := p.next()
+	if tok.kind != tokNumber {
+		return 0, fmt.Errorf("expected number after %s, got %q", what, tok.text)
+	}
+	n, err := strconv.Atoi(tok.text)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", what, tok.text)
+	}
+	return n, nil
+}
+
+func (p *parser) parseConds() ([]Cond, error) {
+	var conds []Cond
+	for {
+		field := p.next()
+		if field.kind != tokIdent || !isFieldName(field.text) {
+			return nil, fmt.Errorf("expected field name, got %q", field.text)
+		}
+		op := p.next()
+		if op.kind != tokOp {
+			return nil, fmt.Errorf("expected =, !=, ~ or !~ after %q", field.text)
+		}
+		value := p.next()
+		if value.kind != tokIdent && value.kind != tokString && value.kind != tokNumber {
+			return nil, fmt.Errorf("expected value after %s%s", field.text, op.text)
+		}
+		conds = append(conds, Cond{Field: strings.ToLower(field.text), Op: op.text, Value: value.text})
+
+		if !p.peekKeyword("and") {
+			return conds, nil
+		}
+		p.next()
+	}
+}
+
+// isFieldName restricts fields to plain identifiers, since unknown fields are
+// compiled into a JSON path expression.
+func isFieldName(s string) bool {
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,226 @@
+package query
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"kai/internal/graph"
+	"kai/internal/util"
+)
+
+func setupTestDB(t *testing.T) (*graph.DB, func()) {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "kai-query-test-*")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+
+	dbPath := filepath.Join(tmpDir, "test.db")
+	objPath := filepath.Join(tmpDir, "objects")
+	if err := os.MkdirAll(objPath, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("creating objects dir: %v", err)
+	}
+
+	db, err := graph.Open(dbPath, objPath)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("opening database: %v", err)
+	}
+
+	schema := `
+CREATE TABLE IF NOT EXISTS nodes (id BLOB PRIMARY KEY, kind TEXT NOT NULL, payload TEXT NOT NULL, created_at INTEGER NOT NULL);
+CREATE TABLE IF NOT EXISTS edges (src BLOB NOT NULL, type TEXT NOT NULL, dst BLOB NOT NULL, at BLOB, created_at INTEGER NOT NULL, PRIMARY KEY (src, type, dst, at));
+`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		os.RemoveAll(tmpDir)
+		t.Fatalf("applying schema: %v", err)
+	}
+
+	cleanup := func() {
+		db.Close()
+		os.RemoveAll(tmpDir)
+	}
+
+	return db, cleanup
+}
+
+// fixture builds a snapshot:
+//
+//	src/auth/session.ts  defines validateSession (function), Session (class)
+//	src/api/handler.ts   imports session.ts, calls validateSession
+//	src/app.ts           imports handler.ts, calls handler
+//	tests/api.test.ts    imports handler.ts (TESTS handler.ts, session.ts)
+func fixture(t *testing.T, db *graph.DB) []byte {
+	t.Helper()
+
+	mustNode := func(kind graph.NodeKind, payload map[string]interface{}) []byte {
+		id, err := db.InsertNodeDirect(kind, payload)
+		if err != nil {
+			t.Fatalf("inserting node: %v", err)
+		}
+		return id
+	}
+	mustEdge := func(src []byte, typ graph.EdgeType, dst, at []byte) {
+		if err := db.InsertEdgeDirect(src, typ, dst, at); err != nil {
+			t.Fatalf("inserting edge: %v", err)
+		}
+	}
+
+	snap := mustNode(graph.KindSnapshot, map[string]interface{}{"sourceRef": "fixture"})
+	files := map[string][]byte{}
+	for _, p := range []string{"src/auth/session.ts", "src/api/handler.ts", "src/app.ts", "tests/api.test.ts"} {
+		files[p] = mustNode(graph.KindFile, map[string]interface{}{"path": p, "lang": "ts", "digest": p})
+		mustEdge(snap, graph.EdgeHasFile, files[p], nil)
+	}
+
+	sym := func(name, kind, path string) {
+		id := mustNode(graph.KindSymbol, map[string]interface{}{
+			"fqName": name, "kind": kind, "fileId": util.BytesToHex(files[path]),
+		})
+		mustEdge(id, graph.EdgeDefinesIn, files[path], snap)
+	}
+	sym("validateSession", "function", "src/auth/session.ts")
+	sym("Session", "class", "src/auth/session.ts")
+	sym("handler", "function", "src/api/handler.ts")
+
+	call := func(from, to, name string) {
+		callID := mustNode(graph.KindSymbol, map[string]interface{}{
+			"calleeName": name, "callerFile": from, "calleeFile": to, "line": 1,
+		})
+		mustEdge(files[from], graph.EdgeCalls, files[to], callID)
+	}
+	call("src/api/handler.ts", "src/auth/session.ts", "validateSession")
+	call("src/app.ts", "src/api/handler.ts", "handler")
+
+	mustEdge(files["src/api/handler.ts"], graph.EdgeImports, files["src/auth/session.ts"], snap)
+	mustEdge(files["src/app.ts"], graph.EdgeImports, files["src/api/handler.ts"], snap)
+	mustEdge(files["tests/api.test.ts"], graph.EdgeImports, files["src/api/handler.ts"], snap)
+	mustEdge(files["tests/api.test.ts"], graph.EdgeTests, files["src/api/handler.ts"], snap)
+	mustEdge(files["tests/api.test.ts"], graph.EdgeTests, files["src/auth/session.ts"], snap)
+
+	return snap
+}
+
+func names(rows []*Row) []string {
+	var out []string
+	for _, r := range rows {
+		out = append(out, r.Name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`symbols where kind=function and file~"auth/**" | callers depth 2 | tests`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(q.Stages) != 3 {
+		t.Fatalf("expected 3 stages, got %d", len(q.Stages))
+	}
+	if q.Stages[0].Op != OpSymbols || len(q.Stages[0].Where) != 2 {
+		t.Errorf("unexpected first stage: %+v", q.Stages[0])
+	}
+	if c := q.Stages[0].Where[1]; c.Field != "file" || c.Op != "~" || c.Value != "auth/**" {
+		t.Errorf("unexpected condition: %+v", c)
+	}
+	if q.Stages[1].Op != OpCallers || q.Stages[1].Depth != 2 {
+		t.Errorf("unexpected callers stage: %+v", q.Stages[1])
+	}
+	if q.Stages[2].Op != OpTests {
+		t.Errorf("unexpected last stage: %+v", q.Stages[2])
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	bad := []string{
+		"",
+		"callers",                          // must start with a source
+		"symbols where",                    // missing condition
+		"symbols where kind",               // missing operator
+		"symbols | tests depth 2",          // tests takes no depth
+		"symbols | frobnicate",             // unknown stage
+		`symbols where name="unterminated`, // bad string
+		"symbols | limit 0",
+	}
+	for _, input := range bad {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) expected error", input)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	snap := fixture(t, db)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`files`, []string{"src/api/handler.ts", "src/app.ts", "src/auth/session.ts", "tests/api.test.ts"}},
+		{`files where path~"src/*.ts"`, []string{"src/app.ts"}},
+		{`files where path~"src/**"`, []string{"src/api/handler.ts", "src/app.ts", "src/auth/session.ts"}},
+		{`symbols where kind=function`, []string{"handler", "validateSession"}},
+		{`symbols where kind!=function`, []string{"Session"}},
+		{`symbols where kind=function and file~"src/auth/**"`, []string{"validateSession"}},
+		{`symbols where name=validateSession | callers`, []string{"src/api/handler.ts"}},
+		{`symbols where name=validateSession | callers depth 2`, []string{"src/api/handler.ts", "src/app.ts"}},
+		{`symbols where name=Session | callers`, nil},
+		{`files where path=src/app.ts | callees depth 2`, []string{"src/api/handler.ts", "src/auth/session.ts"}},
+		{`files where path=src/auth/session.ts | importers depth 3`, []string{"src/api/handler.ts", "src/app.ts", "tests/api.test.ts"}},
+		{`files where path=src/app.ts | imports`, []string{"src/api/handler.ts"}},
+		{`symbols where name=validateSession | tests`, []string{"tests/api.test.ts"}},
+		{`symbols where name=validateSession | callers depth 2 | tests`, []string{"tests/api.test.ts"}},
+		{`files where path=src/auth/session.ts | symbols`, []string{"Session", "validateSession"}},
+		{`symbols | files | where path~"src/api/**"`, []string{"src/api/handler.ts"}},
+		{`files | limit 2`, []string{"src/api/handler.ts", "src/app.ts"}},
+	}
+
+	for _, tt := range tests {
+		rows, err := Run(db, snap, tt.query)
+		if err != nil {
+			t.Errorf("Run(%q): %v", tt.query, err)
+			continue
+		}
+		if got := names(rows); !equal(got, tt.want) {
+			t.Errorf("Run(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRun_RowDetails(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	snap := fixture(t, db)
+
+	rows, err := Run(db, snap, `symbols where name=validateSession`)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	r := rows[0]
+	if r.Kind != "Symbol" || r.Path != "src/auth/session.ts" || r.Detail != "function" {
+		t.Errorf("unexpected row: %+v", r)
+	}
+}
@@ -0,0 +1,74 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"kai/internal/graph"
+	"kai/internal/util"
+)
+
+// Row is one node in a query result.
+type Row struct {
+	ID      string                 `json:"id"`
+	Kind    string                 `json:"kind"`
+	Name    string                 `json:"name"`
+	Path    string                 `json:"path,omitempty"`
+	Detail  string                 `json:"detail,omitempty"` // symbol kind or file language
+	Payload map[string]interface{} `json:"payload,omitempty"`
+}
+
+// Run parses, compiles and executes a query against a snapshot.
+func Run(db *graph.DB, snapshotID []byte, input string) ([]*Row, error) {
+	q, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := Compile(q, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return Exec(db, compiled)
+}
+
+// Exec executes a compiled query.
+func Exec(db *graph.DB, compiled *Compiled) ([]*Row, error) {
+	rows, err := db.Query(compiled.SQL, compiled.Args...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*Row
+	for rows.Next() {
+		var id []byte
+		var kind, payloadJSON string
+		var path *string
+		if err := rows.Scan(&id, &kind, &payloadJSON, &path); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(payloadJSON), &payload); err != nil {
+			return nil, fmt.Errorf("unmarshaling payload: %w", err)
+		}
+
+		row := &Row{ID: util.BytesToHex(id), Kind: kind, Payload: payload}
+		if path != nil {
+			row.Path = *path
+		}
+		switch graph.NodeKind(kind) {
+		case graph.KindSymbol:
+			row.Name, _ = payload["fqName"].(string)
+			row.Detail, _ = payload["kind"].(string)
+		case graph.KindFile:
+			row.Name = row.Path
+			row.Detail, _ = payload["lang"].(string)
+		default:
+			row.Name, _ = payload["name"].(string)
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
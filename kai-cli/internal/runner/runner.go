@@ -0,0 +1,437 @@
+// Package runner translates a CI plan's test selection into command lines for
+// common test runners. Kai never runs tests itself; adapters only produce the
+// commands a CI job would otherwise have to assemble by hand.
+package runner
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Targets is the part of a CI plan an adapter needs.
+type Targets struct {
+	Run   []string // Test files to run
+	Skip  []string // Test files not selected; never emitted, even if also in Run
+	Cases []Case   // Files listed here run only these cases
+	Full  bool     // Run the whole suite instead of Run
+}
+
+// Case is a single test case within a selected file.
+type Case struct {
+	File string
+	Name string
+}
+
+// Command is a command line as separate, unquoted arguments.
+type Command []string
+
+// String renders the command for a POSIX shell, quoting arguments as needed.
+func (c Command) String() string {
+	parts := make([]string, len(c))
+	for i, arg := range c {
+		parts[i] = Quote(arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+
+// Quote returns s unchanged when the shell would read it literally, otherwise
+// wrapped in single quotes.
+func Quote(s string) string {
+	if safeArg.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Adapter produces the commands for one test runner.
+type Adapter interface {
+	// Name is the runner name accepted by Get.
+	Name() string
+	// Matches reports whether the runner executes the test file.
+	Matches(file string) bool
+	// Commands returns the commands running the selection, or nil when no
+	// selected file belongs to the runner.
+	Commands(t Targets) []Command
+}
+
+var adapters = map[string]Adapter{
+	"go":     goAdapter{},
+	"jest":   jestAdapter{},
+	"vitest": vitestAdapter{},
+	"pytest": pytestAdapter{},
+	"rspec":  rspecAdapter{},
+	"cargo":  cargoAdapter{},
+}
+
+// Get returns the adapter for a runner name.
+func Get(name string) (Adapter, error) {
+	if a, ok := adapters[name]; ok {
+		return a, nil
+	}
+	return nil, fmt.Errorf("unknown runner %q (available: %s)", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the supported runner names, sorted.
+func Names() []string {
+	names := make([]string, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selection is the part of Targets that belongs to one adapter: files to run
+// whole, and case names for files narrowed to cases, in Run order.
+type selection struct {
+	files []string
+	cases map[string][]string
+}
+
+func (s selection) empty() bool {
+	return len(s.files) == 0 && len(s.cases) == 0
+}
+
+// narrowed returns the files restricted to cases, in Run order.
+func (s selection) narrowed(run []string) []string {
+	var files []string
+	for _, f := range run {
+		if _, ok := s.cases[f]; ok {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func selectFor(a Adapter, t Targets) selection {
+	skip := make(map[string]bool, len(t.Skip))
+	for _, f := range t.Skip {
+		skip[f] = true
+	}
+	cases := make(map[string][]string)
+	for _, c := range t.Cases {
+		cases[c.File] = append(cases[c.File], c.Name)
+	}
+
+	sel := selection{cases: make(map[string][]string)}
+	seen := make(map[string]bool)
+	for _, f := range t.Run {
+		if skip[f] || seen[f] || !a.Matches(f) {
+			continue
+		}
+		seen[f] = true
+		if names, ok := cases[f]; ok {
+			sel.cases[f] = names
+		} else {
+			sel.files = append(sel.files, f)
+		}
+	}
+	return sel
+}
+
+// namePattern returns an anchored regular expression matching exactly the
+// given test names.
+func namePattern(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = regexp.QuoteMeta(n)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// goAdapter runs `go test` per package. Go selects tests by package, so a
+// package runs whole unless every selected file in it is narrowed to cases.
+type goAdapter struct{}
+
+func (goAdapter) Name() string { return "go" }
+
+func (goAdapter) Matches(file string) bool { return strings.HasSuffix(file, "_test.go") }
+
+func (a goAdapter) Commands(t Targets) []Command {
+	if t.Full {
+		return []Command{{"go", "test", "./..."}}
+	}
+	sel := selectFor(a, t)
+	if sel.empty() {
+		return nil
+	}
+
+	whole := make(map[string]bool)
+	for _, f := range sel.files {
+		whole[goPackage(f)] = true
+	}
+	names := make(map[string][]string)
+	for f, cs := range sel.cases {
+		if pkg := goPackage(f); !whole[pkg] {
+			names[pkg] = append(names[pkg], cs...)
+		}
+	}
+
+	var cmds []Command
+	if len(whole) > 0 {
+		cmd := Command{"go", "test"}
+		cmd = append(cmd, sortedKeys(whole)...)
+		cmds = append(cmds, cmd)
+	}
+	pkgs := make([]string, 0, len(names))
+	for pkg := range names {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		cmds = append(cmds, Command{"go", "test", pkg, "-run", namePattern(dedupe(names[pkg]))})
+	}
+	return cmds
+}
+
+func goPackage(file string) string {
+	dir := path.Dir(file)
+	if dir == "." {
+		return "."
+	}
+	return "./" + dir
+}
+
+// jestAdapter runs jest with exact file paths. Narrowed files each get their
+// own invocation because -t applies to every file in a run.
+type jestAdapter struct{}
+
+func (jestAdapter) Name() string { return "jest" }
+
+func (jestAdapter) Matches(file string) bool { return isJSTest(file) }
+
+func (a jestAdapter) Commands(t Targets) []Command {
+	base := Command{"npx", "jest", "--ci"}
+	if t.Full {
+		return []Command{base}
+	}
+	sel := selectFor(a, t)
+	return jsCommands(sel, t.Run, func(files []string, pattern string) Command {
+		cmd := append(append(Command{}, base...), "--runTestsByPath")
+		cmd = append(cmd, files...)
+		if pattern != "" {
+			cmd = append(cmd, "-t", pattern)
+		}
+		return cmd
+	})
+}
+
+// vitestAdapter runs vitest once (no watch mode) with file filters.
+type vitestAdapter struct{}
+
+func (vitestAdapter) Name() string { return "vitest" }
+
+func (vitestAdapter) Matches(file string) bool { return isJSTest(file) }
+
+func (a vitestAdapter) Commands(t Targets) []Command {
+	base := Command{"npx", "vitest", "run"}
+	if t.Full {
+		return []Command{base}
+	}
+	sel := selectFor(a, t)
+	return jsCommands(sel, t.Run, func(files []string, pattern string) Command {
+		cmd := append(append(Command{}, base...), files...)
+		if pattern != "" {
+			cmd = append(cmd, "-t", pattern)
+		}
+		return cmd
+	})
+}
+
+func jsCommands(sel selection, run []string, build func(files []string, pattern string) Command) []Command {
+	if sel.empty() {
+		return nil
+	}
+	var cmds []Command
+	if len(sel.files) > 0 {
+		cmds = append(cmds, build(sel.files, ""))
+	}
+	for _, f := range sel.narrowed(run) {
+		cmds = append(cmds, build([]string{f}, namePattern(dedupe(sel.cases[f]))))
+	}
+	return cmds
+}
+
+func isJSTest(file string) bool {
+	switch path.Ext(file) {
+	case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".mts", ".cts":
+		return true
+	}
+	return false
+}
+
+// pytestAdapter runs pytest with files and node IDs (file::case).
+type pytestAdapter struct{}
+
+func (pytestAdapter) Name() string { return "pytest" }
+
+func (pytestAdapter) Matches(file string) bool { return strings.HasSuffix(file, ".py") }
+
+func (a pytestAdapter) Commands(t Targets) []Command {
+	if t.Full {
+		return []Command{{"pytest"}}
+	}
+	sel := selectFor(a, t)
+	if sel.empty() {
+		return nil
+	}
+	cmd := Command{"pytest"}
+	for _, f := range t.Run {
+		if names, ok := sel.cases[f]; ok {
+			for _, name := range dedupe(names) {
+				cmd = append(cmd, f+"::"+name)
+			}
+		} else if contains(sel.files, f) {
+			cmd = append(cmd, f)
+		}
+	}
+	return []Command{cmd}
+}
+
+// rspecAdapter runs rspec through bundler with spec files. Case names from the
+// plan are not RSpec examples, so narrowed files run whole.
+type rspecAdapter struct{}
+
+func (rspecAdapter) Name() string { return "rspec" }
+
+func (rspecAdapter) Matches(file string) bool { return strings.HasSuffix(file, "_spec.rb") }
+
+func (a rspecAdapter) Commands(t Targets) []Command {
+	base := Command{"bundle", "exec", "rspec"}
+	if t.Full {
+		return []Command{base}
+	}
+	sel := selectFor(a, t)
+	if sel.empty() {
+		return nil
+	}
+	cmd := append(Command{}, base...)
+	for _, f := range t.Run {
+		if _, ok := sel.cases[f]; ok || contains(sel.files, f) {
+			cmd = append(cmd, f)
+		}
+	}
+	return []Command{cmd}
+}
+
+// cargoAdapter runs cargo test per crate. Files in a crate's tests/ directory
+// are integration test targets (--test <name>); any other file holds unit
+// tests and selects the crate's library tests (--lib).
+type cargoAdapter struct{}
+
+func (cargoAdapter) Name() string { return "cargo" }
+
+func (cargoAdapter) Matches(file string) bool { return strings.HasSuffix(file, ".rs") }
+
+func (a cargoAdapter) Commands(t Targets) []Command {
+	if t.Full {
+		return []Command{{"cargo", "test"}}
+	}
+	sel := selectFor(a, t)
+	if sel.empty() {
+		return nil
+	}
+
+	type crate struct {
+		lib   bool
+		tests map[string]bool
+	}
+	crates := make(map[string]*crate)
+	add := func(f string) {
+		root, target := cargoTarget(f)
+		c, ok := crates[root]
+		if !ok {
+			c = &crate{tests: make(map[string]bool)}
+			crates[root] = c
+		}
+		if target == "" {
+			c.lib = true
+		} else {
+			c.tests[target] = true
+		}
+	}
+	for _, f := range sel.files {
+		add(f)
+	}
+	for f := range sel.cases {
+		add(f)
+	}
+
+	roots := make([]string, 0, len(crates))
+	for root := range crates {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+	var cmds []Command
+	for _, root := range roots {
+		c := crates[root]
+		cmd := Command{"cargo", "test"}
+		if root != "" {
+			cmd = append(cmd, "--manifest-path", root+"/Cargo.toml")
+		}
+		if c.lib {
+			cmd = append(cmd, "--lib")
+		}
+		for _, name := range sortedKeys(c.tests) {
+			cmd = append(cmd, "--test", name)
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// cargoTarget returns the crate root of a Rust file and, for files directly in
+// the crate's tests/ directory, the integration test target name.
+func cargoTarget(file string) (root, target string) {
+	segments := strings.Split(file, "/")
+	for i := len(segments) - 2; i >= 0; i-- {
+		switch segments[i] {
+		case "tests":
+			root = strings.Join(segments[:i], "/")
+			if i == len(segments)-2 {
+				target = strings.TrimSuffix(segments[i+1], ".rs")
+			} else {
+				target = segments[i+1] // tests/<name>/main.rs
+			}
+			return root, target
+		case "src":
+			return strings.Join(segments[:i], "/"), ""
+		}
+	}
+	return "", ""
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, runner string, targets Targets) string {
+	t.Helper()
+	a, err := Get(runner)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", runner, err)
+	}
+	var lines []string
+	for _, cmd := range a.Commands(targets) {
+		lines = append(lines, cmd.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"./cart", "./cart"},
+		{"tests/test_cart.py::TestCart::test_add", "tests/test_cart.py::TestCart::test_add"},
+		{"^(TestAdd)$", "'^(TestAdd)$'"},
+		{"it's ok", `'it'\''s ok'`},
+		{"", "''"},
+	}
+	for _, tt := range tests {
+		if got := Quote(tt.in); got != tt.want {
+			t.Errorf("Quote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGoCommands(t *testing.T) {
+	targets := Targets{
+		Run: []string{"cart/cart_test.go", "api/api_test.go", "util/util_test.go", "util/more_test.go", "web/app.test.js"},
+		Cases: []Case{
+			{File: "cart/cart_test.go", Name: "TestTotal"},
+			{File: "cart/cart_test.go", Name: "TestAdd"},
+			{File: "util/util_test.go", Name: "TestTrim"},
+		},
+	}
+	// util runs whole: more_test.go was selected without cases
+	want := "go test ./api ./util\ngo test ./cart -run '^(TestTotal|TestAdd)$'"
+	if got := render(t, "go", targets); got != want {
+		t.Errorf("commands =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := render(t, "go", Targets{Run: []string{"web/app.test.js"}}); got != "" {
+		t.Errorf("no Go tests selected, got %q", got)
+	}
+	if got := render(t, "go", Targets{Run: []string{"cart/cart_test.go"}, Full: true}); got != "go test ./..." {
+		t.Errorf("full = %q", got)
+	}
+}
+
+func TestJSCommands(t *testing.T) {
+	targets := Targets{
+		Run:   []string{"src/a.test.js", "src/b.test.ts", "src/c.test.js"},
+		Skip:  []string{"src/c.test.js"},
+		Cases: []Case{{File: "src/b.test.ts", Name: "cart adds (twice)"}},
+	}
+	want := "npx jest --ci --runTestsByPath src/a.test.js\n" +
+		"npx jest --ci --runTestsByPath src/b.test.ts -t '^(cart adds \\(twice\\))$'"
+	if got := render(t, "jest", targets); got != want {
+		t.Errorf("jest =\n%s\nwant\n%s", got, want)
+	}
+	want = "npx vitest run src/a.test.js\n" +
+		"npx vitest run src/b.test.ts -t '^(cart adds \\(twice\\))$'"
+	if got := render(t, "vitest", targets); got != want {
+		t.Errorf("vitest =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPytestAndRSpecCommands(t *testing.T) {
+	targets := Targets{
+		Run:   []string{"tests/test_cart.py", "tests/test_api.py", "spec/cart_spec.rb"},
+		Cases: []Case{{File: "tests/test_cart.py", Name: "TestCart::test_add"}},
+	}
+	if got, want := render(t, "pytest", targets), "pytest tests/test_cart.py::TestCart::test_add tests/test_api.py"; got != want {
+		t.Errorf("pytest = %q, want %q", got, want)
+	}
+	if got, want := render(t, "rspec", targets), "bundle exec rspec spec/cart_spec.rb"; got != want {
+		t.Errorf("rspec = %q, want %q", got, want)
+	}
+	targets.Full = true
+	if got := render(t, "pytest", targets); got != "pytest" {
+		t.Errorf("full = %q", got)
+	}
+}
+
+func TestCargoCommands(t *testing.T) {
+	targets := Targets{
+		Run: []string{"tests/cart.rs", "tests/api/main.rs", "src/lib.rs", "crates/db/tests/query.rs", "crates/db/src/pool.rs"},
+	}
+	want := "cargo test --lib --test api --test cart\n" +
+		"cargo test --manifest-path crates/db/Cargo.toml --lib --test query"
+	if got := render(t, "cargo", targets); got != want {
+		t.Errorf("cargo =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGetUnknown(t *testing.T) {
+	if _, err := Get("maven"); err == nil || !strings.Contains(err.Error(), "cargo, go, jest, pytest, rspec, vitest") {
+		t.Errorf("Get(maven) error = %v", err)
+	}
+}
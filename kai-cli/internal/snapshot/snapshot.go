@@ -239,6 +239,8 @@ func (c *Creator) AnalyzeSymbols(snapshotID []byte, progress ProgressFunc) error
 
 // AnalyzeCalls extracts function calls and imports from all files in a snapshot.
 // This builds a call graph: Symbol --CALLS--> Symbol, File --IMPORTS--> File.
+// Test cases in test files become symbols of kind "test" with CALLS edges to
+// the production symbols they reach.
 func (c *Creator) AnalyzeCalls(snapshotID []byte, progress ProgressFunc) error {
 	// Get all files in the snapshot
 	edges, err := c.db.GetEdges(snapshotID, graph.EdgeHasFile)
@@ -256,6 +258,7 @@ func (c *Creator) AnalyzeCalls(snapshotID []byte, progress ProgressFunc) error {
 		content  []byte
 		isTest   bool
 		exported []string // exported symbols
+		calls    []*parse.CallSite
 	}
 	files := make([]*fileInfo, 0, len(edges))
 	filesByPath := make(map[string]*fileInfo)
@@ -273,7 +276,7 @@ func (c *Creator) AnalyzeCalls(snapshotID []byte, progress ProgressFunc) error {
 		lang, _ := fileNode.Payload["lang"].(string)
 
 		// Only process supported languages
-		if lang != "js" && lang != "ts" && lang != "jsx" && lang != "tsx" && lang != "go" && lang != "py" {
+		if lang != "js" && lang != "ts" && lang != "jsx" && lang != "tsx" && lang != "go" && lang != "py" && lang != "python" {
 			continue
 		}
 
@@ -320,6 +323,7 @@ func (c *Creator) AnalyzeCalls(snapshotID []byte, progress ProgressFunc) error {
 		}
 
 		fi.exported = parsed.Exports
+		fi.calls = parsed.Calls
 
 		// Build import graph and collect resolved imports
 		var imports []string
@@ -348,6 +352,24 @@ func (c *Creator) AnalyzeCalls(snapshotID []byte, progress ProgressFunc) error {
 		importGraph[fi.path] = imports
 	}
 
+	// Resolve test cases to the production symbols they reach. This reads
+	// symbols, so it runs before the write transaction.
+	caseFiles := make([]*caseFile, 0, len(files))
+	for _, fi := range files {
+		caseFiles = append(caseFiles, &caseFile{
+			id:      fi.id,
+			path:    fi.path,
+			lang:    fi.lang,
+			content: fi.content,
+			isTest:  fi.isTest,
+			calls:   fi.calls,
+		})
+	}
+	testLinks, err := c.resolveTestCases(snapshotID, parser, caseFiles, importGraph)
+	if err != nil {
+		return err
+	}
+
 	// Third pass: store edges in database
 	tx, err := c.db.BeginTx()
 	if err != nil {
@@ -401,6 +423,11 @@ func (c *Creator) AnalyzeCalls(snapshotID []byte, progress ProgressFunc) error {
 		}
 	}
 
+	// Store test cases with CALLS edges to the symbols they reach
+	if err := c.storeTestCases(tx, snapshotID, testLinks); err != nil {
+		return err
+	}
+
 	// Third pass: create CALLS edges between symbols
 	// This requires matching call names to exported symbols
 	// For now, create edges based on import/export matching
@@ -0,0 +1,244 @@
+package snapshot
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"kai/internal/graph"
+	"kai/internal/parse"
+	"kai/internal/util"
+)
+
+// SymbolKindTest is the symbol kind of test case nodes created by AnalyzeCalls.
+const SymbolKindTest = "test"
+
+// maxReachDepth bounds how many calls deep a test case is followed into
+// helpers and production code.
+const maxReachDepth = 5
+
+// caseFile is a parsed file considered when linking test cases.
+type caseFile struct {
+	id      []byte
+	path    string
+	lang    string
+	content []byte
+	isTest  bool
+	calls   []*parse.CallSite
+}
+
+// funcSymbol is a function symbol with the calls made from its body.
+type funcSymbol struct {
+	id         []byte
+	name       string // Last segment of fqName ("Cart.add" -> "add")
+	file       *caseFile
+	start, end int // 0-based lines
+	calls      []string
+}
+
+// testLink is a test case and the production symbols it reaches.
+type testLink struct {
+	fileID  []byte
+	payload map[string]interface{}
+	reaches [][]byte
+}
+
+// resolveTestCases extracts test cases from test files and follows the calls
+// in each case body through helpers and production functions. Calls are
+// resolved by name: first in the calling file, then in the files it imports
+// (transitively) and its directory, then anywhere in the snapshot when the
+// name is defined in a single file.
+func (c *Creator) resolveTestCases(snapshotID []byte, parser *parse.Parser, files []*caseFile, importGraph map[string][]string) ([]*testLink, error) {
+	byFile := make(map[string][]*funcSymbol)
+	byName := make(map[string][]*funcSymbol)
+	for _, f := range files {
+		nodes, err := c.GetSymbolsInFile(f.id, snapshotID)
+		if err != nil {
+			return nil, fmt.Errorf("getting symbols for %s: %w", f.path, err)
+		}
+		for _, n := range nodes {
+			if kind, _ := n.Payload["kind"].(string); kind != "function" {
+				continue
+			}
+			fqName, _ := n.Payload["fqName"].(string)
+			start, end := symbolLines(n)
+			sym := &funcSymbol{id: n.ID, name: lastSegment(fqName), file: f, start: start, end: end}
+			byFile[f.path] = append(byFile[f.path], sym)
+			byName[sym.name] = append(byName[sym.name], sym)
+		}
+		for _, call := range f.calls {
+			line := call.Range.Start[0]
+			if sym := innermostSymbol(byFile[f.path], line, line); sym != nil {
+				sym.calls = append(sym.calls, call.CalleeName)
+			}
+		}
+	}
+
+	deps := make(map[string]map[string]bool)
+	depsOf := func(path string) map[string]bool {
+		if d, ok := deps[path]; ok {
+			return d
+		}
+		d := map[string]bool{path: true}
+		queue := []string{path}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, imported := range importGraph[current] {
+				if !d[imported] {
+					d[imported] = true
+					queue = append(queue, imported)
+				}
+			}
+		}
+		deps[path] = d
+		return d
+	}
+
+	resolve := func(from *caseFile, name string) []*funcSymbol {
+		var local, near, global []*funcSymbol
+		for _, sym := range byName[name] {
+			if sym.file.lang != from.lang {
+				continue
+			}
+			switch {
+			case sym.file == from:
+				local = append(local, sym)
+			case depsOf(from.path)[sym.file.path] || filepath.Dir(sym.file.path) == filepath.Dir(from.path):
+				near = append(near, sym)
+			default:
+				global = append(global, sym)
+			}
+		}
+		if len(local) > 0 {
+			return local
+		}
+		if len(near) > 0 {
+			return near
+		}
+		if len(global) == 0 {
+			return nil
+		}
+		for _, sym := range global[1:] {
+			if sym.file != global[0].file {
+				return nil // Ambiguous
+			}
+		}
+		return global
+	}
+
+	var links []*testLink
+	for _, f := range files {
+		if !f.isTest {
+			continue
+		}
+		cases, err := parser.ExtractTestCases(f.content, f.lang)
+		if err != nil {
+			continue
+		}
+		fileIDHex := util.BytesToHex(f.id)
+		for _, tc := range cases {
+			link := &testLink{
+				fileID: f.id,
+				payload: map[string]interface{}{
+					"fqName":    tc.Name,
+					"kind":      SymbolKindTest,
+					"framework": tc.Framework,
+					"fileId":    fileIDHex,
+					"range":     map[string]interface{}{"start": tc.Range.Start, "end": tc.Range.End},
+				},
+			}
+
+			// Breadth-first over the call graph, starting from the case body
+			var frontier []string
+			for _, call := range f.calls {
+				if line := call.Range.Start[0]; line >= tc.Range.Start[0] && line <= tc.Range.End[0] {
+					frontier = append(frontier, call.CalleeName)
+				}
+			}
+			from := make([]*caseFile, len(frontier))
+			for i := range from {
+				from[i] = f
+			}
+			seen := make(map[*funcSymbol]bool)
+			for depth := 0; depth < maxReachDepth && len(frontier) > 0; depth++ {
+				var nextNames []string
+				var nextFrom []*caseFile
+				for i, name := range frontier {
+					for _, sym := range resolve(from[i], name) {
+						if seen[sym] || (sym.file == f && sym.start <= tc.Range.Start[0] && sym.end >= tc.Range.End[0]) {
+							continue // Already visited, or the test function itself
+						}
+						seen[sym] = true
+						if !sym.file.isTest {
+							link.reaches = append(link.reaches, sym.id)
+						}
+						for _, callee := range sym.calls {
+							nextNames = append(nextNames, callee)
+							nextFrom = append(nextFrom, sym.file)
+						}
+					}
+				}
+				frontier, from = nextNames, nextFrom
+			}
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// storeTestCases inserts a symbol node for each test case, defined in its
+// test file, with a CALLS edge to every production symbol it reaches.
+func (c *Creator) storeTestCases(tx *sql.Tx, snapshotID []byte, links []*testLink) error {
+	for _, link := range links {
+		caseID, err := c.db.InsertNode(tx, graph.KindSymbol, link.payload)
+		if err != nil {
+			return fmt.Errorf("inserting test case: %w", err)
+		}
+		if err := c.db.InsertEdge(tx, caseID, graph.EdgeDefinesIn, link.fileID, snapshotID); err != nil {
+			return fmt.Errorf("inserting DEFINES_IN edge: %w", err)
+		}
+		for _, symID := range link.reaches {
+			if err := c.db.InsertEdge(tx, caseID, graph.EdgeCalls, symID, snapshotID); err != nil {
+				return fmt.Errorf("inserting CALLS edge: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// innermostSymbol returns the smallest symbol spanning lines first..last.
+func innermostSymbol(symbols []*funcSymbol, first, last int) *funcSymbol {
+	var best *funcSymbol
+	for _, sym := range symbols {
+		if sym.start <= first && sym.end >= last && (best == nil || sym.end-sym.start < best.end-best.start) {
+			best = sym
+		}
+	}
+	return best
+}
+
+// symbolLines returns the 0-based start and end lines of a symbol node.
+func symbolLines(n *graph.Node) (int, int) {
+	r, ok := n.Payload["range"].(map[string]interface{})
+	if !ok {
+		return 0, 0
+	}
+	line := func(v interface{}) int {
+		if arr, ok := v.([]interface{}); ok && len(arr) == 2 {
+			if f, ok := arr[0].(float64); ok {
+				return int(f)
+			}
+		}
+		return 0
+	}
+	return line(r["start"]), line(r["end"])
+}
+
+func lastSegment(fqName string) string {
+	if idx := strings.LastIndex(fqName, "."); idx >= 0 {
+		return fqName[idx+1:]
+	}
+	return fqName
+}
@@ -0,0 +1,201 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"kai/internal/graph"
+	"kai/internal/module"
+)
+
+func setupTestDB(t *testing.T) (*graph.DB, func()) {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "kai-snapshot-test-*")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+
+	dbPath := filepath.Join(tmpDir, "test.db")
+	objPath := filepath.Join(tmpDir, "objects")
+	if err := os.MkdirAll(objPath, 0755); err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("creating objects dir: %v", err)
+	}
+
+	db, err := graph.Open(dbPath, objPath)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatalf("opening database: %v", err)
+	}
+
+	schema := `
+CREATE TABLE IF NOT EXISTS nodes (id BLOB PRIMARY KEY, kind TEXT NOT NULL, payload TEXT NOT NULL, created_at INTEGER NOT NULL);
+CREATE TABLE IF NOT EXISTS edges (src BLOB NOT NULL, type TEXT NOT NULL, dst BLOB NOT NULL, at BLOB, created_at INTEGER NOT NULL, PRIMARY KEY (src, type, dst, at));
+`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		os.RemoveAll(tmpDir)
+		t.Fatalf("applying schema: %v", err)
+	}
+
+	cleanup := func() {
+		db.Close()
+		os.RemoveAll(tmpDir)
+	}
+
+	return db, cleanup
+}
+
+// createSnapshot stores the files as a snapshot and runs symbol and call analysis.
+func createSnapshot(t *testing.T, db *graph.DB, files map[string]string) []byte {
+	t.Helper()
+
+	snap, err := db.InsertNodeDirect(graph.KindSnapshot, map[string]interface{}{"sourceRef": "test"})
+	if err != nil {
+		t.Fatalf("inserting snapshot: %v", err)
+	}
+	for path, content := range files {
+		digest, err := db.WriteObject([]byte(content))
+		if err != nil {
+			t.Fatalf("writing object: %v", err)
+		}
+		lang := map[string]string{".go": "go", ".py": "python", ".js": "js"}[filepath.Ext(path)]
+		id, err := db.InsertNodeDirect(graph.KindFile, map[string]interface{}{"path": path, "lang": lang, "digest": digest})
+		if err != nil {
+			t.Fatalf("inserting file: %v", err)
+		}
+		if err := db.InsertEdgeDirect(snap, graph.EdgeHasFile, id, nil); err != nil {
+			t.Fatalf("inserting HAS_FILE: %v", err)
+		}
+	}
+
+	creator := NewCreator(db, module.NewMatcher(nil))
+	if err := creator.AnalyzeSymbols(snap, nil); err != nil {
+		t.Fatalf("AnalyzeSymbols: %v", err)
+	}
+	if err := creator.AnalyzeCalls(snap, nil); err != nil {
+		t.Fatalf("AnalyzeCalls: %v", err)
+	}
+	return snap
+}
+
+// reachedBy returns "case -> symbols" lines for every test case in the snapshot.
+func reachedBy(t *testing.T, db *graph.DB, snap []byte) []string {
+	t.Helper()
+
+	edges, err := db.GetEdgesByContext(snap, graph.EdgeDefinesIn)
+	if err != nil {
+		t.Fatalf("getting DEFINES_IN: %v", err)
+	}
+	var result []string
+	for _, e := range edges {
+		node, err := db.GetNode(e.Src)
+		if err != nil || node == nil {
+			t.Fatalf("getting node: %v", err)
+		}
+		if kind, _ := node.Payload["kind"].(string); kind != SymbolKindTest {
+			continue
+		}
+		calls, err := db.GetEdges(e.Src, graph.EdgeCalls)
+		if err != nil {
+			t.Fatalf("getting CALLS: %v", err)
+		}
+		var names []string
+		for _, c := range calls {
+			sym, err := db.GetNode(c.Dst)
+			if err != nil || sym == nil {
+				t.Fatalf("getting symbol: %v", err)
+			}
+			names = append(names, sym.Payload["fqName"].(string))
+		}
+		sort.Strings(names)
+		result = append(result, node.Payload["fqName"].(string)+" -> "+strings.Join(names, ","))
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestAnalyzeCalls_LinksGoTestCases(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	snap := createSnapshot(t, db, map[string]string{
+		"cart/cart.go": `package cart
+
+func Add(a, b int) int {
+	return a + b
+}
+
+func Total(items []int) int {
+	t := 0
+	for _, i := range items {
+		t = Add(t, i)
+	}
+	return t
+}
+
+func Mul(a, b int) int {
+	return a * b
+}
+`,
+		"cart/cart_test.go": `package cart
+
+import "testing"
+
+func TestTotal(t *testing.T) {
+	if Total([]int{1, 2}) != 3 {
+		t.Fatal("bad")
+	}
+}
+
+func TestMul(t *testing.T) {
+	check(t, Mul(2, 3), 6)
+}
+
+func check(t *testing.T, got, want int) {}
+`,
+	})
+
+	got := strings.Join(reachedBy(t, db, snap), "; ")
+	// Total reaches Add transitively; helpers in the test file are not linked
+	want := "TestMul -> Mul; TestTotal -> Add,Total"
+	if got != want {
+		t.Errorf("test cases = %q, want %q", got, want)
+	}
+}
+
+func TestAnalyzeCalls_LinksPytestAndJestCases(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	snap := createSnapshot(t, db, map[string]string{
+		"app/calc.py": "def add(a, b):\n    return a + b\n\ndef mul(a, b):\n    return a * b\n",
+		"tests/test_calc.py": `from app.calc import add, mul
+
+def test_add():
+    assert add(1, 2) == 3
+
+class TestMul:
+    def test_mul(self):
+        assert mul(2, 3) == 6
+`,
+		"src/math.js": "function add(a, b) {\n  return a + b;\n}\nmodule.exports = { add };\n",
+		"src/math.test.js": `const { add } = require('./math');
+describe('math', () => {
+  it('adds', () => {
+    expect(add(1, 2)).toBe(3);
+  });
+});
+`,
+	})
+
+	got := strings.Join(reachedBy(t, db, snap), "; ")
+	want := "TestMul::test_mul -> mul; math adds -> add; test_add -> add"
+	if got != want {
+		t.Errorf("test cases = %q, want %q", got, want)
+	}
+}
@@ -38,10 +38,12 @@ func AnalyzeSemantic(db *graph.DB, statusResult *Result, opts SemanticOptions) (
 		return nil, fmt.Errorf("getting snapshot files: %w", err)
 	}
 
-	// Build map of baseline file content
+	// Build map of baseline file content and language
 	baselineContent := make(map[string][]byte)
+	baselineLang := make(map[string]string)
 	for _, f := range snapshotFiles {
 		path, _ := f.Payload["path"].(string)
+		baselineLang[path], _ = f.Payload["lang"].(string)
 		// Load content from object store
 		digest, ok := f.Payload["digest"].(string)
 		if ok && digest != "" {
@@ -71,6 +73,12 @@ func AnalyzeSemantic(db *graph.DB, statusResult *Result, opts SemanticOptions) (
 			continue
 		}
 
+		// Only code gets semantic detection
+		lang := baselineLang[path]
+		if lang != "" && !classify.IsCodeLang(lang) {
+			continue
+		}
+
 		// Read current content
 		fullPath := filepath.Join(dir, path)
 		afterContent, err := os.ReadFile(fullPath)
@@ -79,7 +87,7 @@ func AnalyzeSemantic(db *graph.DB, statusResult *Result, opts SemanticOptions) (
 		}
 
 		// Detect changes
-		changes, err := detector.DetectChanges(path, beforeContent, afterContent, "")
+		changes, err := detector.DetectChanges(path, beforeContent, afterContent, "", lang)
 		if err != nil {
 			continue
 		}
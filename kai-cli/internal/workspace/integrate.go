@@ -3,7 +3,10 @@ package workspace
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
+	"kai-core/merge"
 	"kai/internal/graph"
 	"kai/internal/util"
 )
@@ -110,18 +113,47 @@ func (m *Manager) Integrate(nameOrID string, targetSnapshotID []byte) (*Integrat
 		}
 	}
 
-	// Check for conflicts: files modified in both
+	// Get file nodes from head and target to reuse
+	headFileNodes, err := m.getSnapshotFileNodes(ws.HeadSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	targetFileNodes, err := m.getSnapshotFileNodes(targetSnapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for conflicts: files modified in both. Files in languages with
+	// merge units are merged symbol by symbol, and only conflict when both
+	// sides changed the same symbol.
 	var conflicts []Conflict
+	unitMerged := make(map[string]string) // path -> merged digest
 	for path := range wsModified {
-		if targetModified[path] {
-			conflicts = append(conflicts, Conflict{
-				Path:        path,
-				Description: "File modified in both workspace and target",
-				BaseDigest:  baseFiles[path],
-				HeadDigest:  headFiles[path],
-				NewDigest:   targetFiles[path],
-			})
+		if !targetModified[path] {
+			continue
+		}
+		description := "File modified in both workspace and target"
+		if node := headFileNodes[path]; node != nil && baseFiles[path] != "" && headFiles[path] != "" && targetFiles[path] != "" {
+			lang, _ := node.Payload["lang"].(string)
+			if unitMergeLangs[lang] {
+				digest, unitConflicts, err := m.mergeUnits(lang, baseFiles[path], headFiles[path], targetFiles[path])
+				if err != nil {
+					return nil, fmt.Errorf("merging %s: %w", path, err)
+				}
+				if len(unitConflicts) == 0 {
+					unitMerged[path] = digest
+					continue
+				}
+				description = "Symbols modified in both workspace and target: " + strings.Join(unitConflicts, ", ")
+			}
 		}
+		conflicts = append(conflicts, Conflict{
+			Path:        path,
+			Description: description,
+			BaseDigest:  baseFiles[path],
+			HeadDigest:  headFiles[path],
+			NewDigest:   targetFiles[path],
+		})
 	}
 
 	if len(conflicts) > 0 {
@@ -144,6 +176,10 @@ func (m *Manager) Integrate(nameOrID string, targetSnapshotID []byte) (*Integrat
 		}
 	}
 
+	for path, digest := range unitMerged {
+		mergedFiles[path] = digest
+	}
+
 	// Apply workspace deletions
 	for path := range baseFiles {
 		if _, existsInHead := headFiles[path]; !existsInHead {
@@ -172,26 +208,26 @@ func (m *Manager) Integrate(nameOrID string, targetSnapshotID []byte) (*Integrat
 		return nil, fmt.Errorf("inserting merged snapshot: %w", err)
 	}
 
-	// Get file nodes from head and target to reuse
-	headFileNodes, err := m.getSnapshotFileNodes(ws.HeadSnapshot)
-	if err != nil {
-		return nil, err
-	}
-	targetFileNodes, err := m.getSnapshotFileNodes(targetSnapshotID)
-	if err != nil {
-		return nil, err
-	}
-
 	// Create HAS_FILE edges for merged snapshot
 	for path := range mergedFiles {
-		var fileNode *graph.Node
-		if wsModified[path] {
-			fileNode = headFileNodes[path]
-		} else {
-			fileNode = targetFileNodes[path]
+		var fileID []byte
+		if digest, ok := unitMerged[path]; ok {
+			lang, _ := headFileNodes[path].Payload["lang"].(string)
+			fileID, err = m.db.InsertNode(tx, graph.KindFile, map[string]interface{}{
+				"path":   path,
+				"lang":   lang,
+				"digest": digest,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("inserting merged file: %w", err)
+			}
+		} else if node := headFileNodes[path]; wsModified[path] && node != nil {
+			fileID = node.ID
+		} else if node := targetFileNodes[path]; !wsModified[path] && node != nil {
+			fileID = node.ID
 		}
-		if fileNode != nil {
-			if err := m.db.InsertEdge(tx, mergedSnapID, graph.EdgeHasFile, fileNode.ID, nil); err != nil {
+		if fileID != nil {
+			if err := m.db.InsertEdge(tx, mergedSnapID, graph.EdgeHasFile, fileID, nil); err != nil {
 				return nil, fmt.Errorf("inserting HAS_FILE edge: %w", err)
 			}
 		}
@@ -208,6 +244,49 @@ func (m *Manager) Integrate(nameOrID string, targetSnapshotID []byte) (*Integrat
 	}, nil
 }
 
+// unitMergeLangs are the languages whose files are merged symbol by symbol
+// when both sides modified them.
+var unitMergeLangs = map[string]bool{
+	"go": true,
+}
+
+// mergeUnits 3-way merges a file's contents by merge unit. It returns the
+// digest of the merged content, or the units both sides changed in
+// incompatible ways.
+func (m *Manager) mergeUnits(lang, baseDigest, headDigest, targetDigest string) (string, []string, error) {
+	base, err := m.db.ReadObject(baseDigest)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading base: %w", err)
+	}
+	head, err := m.db.ReadObject(headDigest)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading head: %w", err)
+	}
+	target, err := m.db.ReadObject(targetDigest)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading target: %w", err)
+	}
+
+	result, err := merge.Merge3Way(base, head, target, lang)
+	if err != nil {
+		return "", nil, err
+	}
+	if !result.Success {
+		var units []string
+		for _, c := range result.Conflicts {
+			units = append(units, fmt.Sprintf("%s (%s)", strings.Join(c.UnitKey.SymbolPath, "."), c.Kind))
+		}
+		sort.Strings(units)
+		return "", units, nil
+	}
+
+	digest, err := m.db.WriteObject(result.Files["file"])
+	if err != nil {
+		return "", nil, fmt.Errorf("writing merged object: %w", err)
+	}
+	return digest, nil, nil
+}
+
 // getSnapshotFileMap returns a map of path -> digest for a snapshot.
 func (m *Manager) getSnapshotFileMap(snapshotID []byte) (map[string]string, error) {
 	edges, err := m.db.GetEdges(snapshotID, graph.EdgeHasFile)
@@ -3,6 +3,7 @@ package workspace
 
 import (
 	"fmt"
+	"os"
 
 	"kai/internal/classify"
 	"kai/internal/filesource"
@@ -133,6 +134,12 @@ func (m *Manager) Stage(nameOrID string, source filesource.FileSource, matcher *
 	}
 	defer tx.Rollback()
 
+	// Record the author (system user) so blame can attribute changes
+	author := os.Getenv("USER")
+	if author == "" {
+		author = "unknown"
+	}
+
 	// Create changeset node
 	changeSetPayload := map[string]interface{}{
 		"base":        util.BytesToHex(ws.HeadSnapshot),
@@ -140,6 +147,7 @@ func (m *Manager) Stage(nameOrID string, source filesource.FileSource, matcher *
 		"title":       "",
 		"description": message,
 		"intent":      "",
+		"author":      author,
 		"workspaceId": util.BytesToHex(ws.ID),
 		"createdAt":   util.NowMs(),
 	}
@@ -193,19 +201,19 @@ func (m *Manager) Stage(nameOrID string, source filesource.FileSource, matcher *
 			var changes []*classify.ChangeType
 			var err error
 
-			switch lang {
-			case "json":
+			switch {
+			case classify.ManifestEcosystem(path) != "":
+				// Dependencies added, removed and upgraded
+				changes, err = classify.DetectManifestChanges(path, beforeContent, afterContent)
+			case lang == "json":
 				// Use JSON-specific detection
 				changes, err = classify.DetectJSONChanges(path, beforeContent, afterContent)
-			case "yaml":
+			case lang == "yaml":
 				// Use YAML-specific detection
 				changes, err = classify.DetectYAMLChanges(path, beforeContent, afterContent)
-			case "ts", "js":
-				// Use tree-sitter based detection for JavaScript/TypeScript
+			case classify.IsCodeLang(lang):
+				// Use tree-sitter based detection with the language's detector table
 				changes, err = detector.DetectChanges(path, beforeContent, afterContent, util.BytesToHex(changedFileIDs[i]), lang)
-			case "py":
-				// Use tree-sitter based detection for Python
-				changes, err = detector.DetectChanges(path, beforeContent, afterContent, util.BytesToHex(changedFileIDs[i]), "py")
 			default:
 				// Non-parseable files get FILE_CONTENT_CHANGED
 				changes = []*classify.ChangeType{classify.NewFileChange(classify.FileContentChanged, path)}
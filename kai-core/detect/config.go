@@ -0,0 +1,273 @@
+package detect
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config change categories for TOML, INI and .env files.
+const (
+	ConfigKeyAdded     ChangeCategory = "CONFIG_KEY_ADDED"
+	ConfigKeyRemoved   ChangeCategory = "CONFIG_KEY_REMOVED"
+	ConfigValueChanged ChangeCategory = "CONFIG_VALUE_CHANGED"
+)
+
+// ConfigChange is a key added, removed or given a new value in a config file.
+type ConfigChange struct {
+	Category ChangeCategory
+	Key      string // Dotted path, e.g. "database.port" or "bin[1].name"
+	Before   string
+	After    string
+}
+
+// ConfigFormat returns the format of a config file - "toml", "ini" or
+// "env" - or "" for other files.
+func ConfigFormat(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(base, ".toml"):
+		return "toml"
+	case strings.HasSuffix(base, ".ini"), strings.HasSuffix(base, ".cfg"), base == ".editorconfig":
+		return "ini"
+	case base == ".env", strings.HasPrefix(base, ".env."), strings.HasSuffix(base, ".env"):
+		return "env"
+	default:
+		return ""
+	}
+}
+
+// ParseConfig reads a TOML, INI or .env file into nested maps. INI sections
+// become tables; .env files are flat.
+func ParseConfig(path string, content []byte) (map[string]interface{}, error) {
+	switch ConfigFormat(path) {
+	case "toml":
+		return parseTOML(content)
+	case "ini":
+		return parseINI(content)
+	case "env":
+		return parseEnv(content)
+	default:
+		return nil, fmt.Errorf("not a config file: %s", path)
+	}
+}
+
+// DiffConfigs compares two parsed config files key by key. Nested tables are
+// flattened to dotted keys, so a new table reports each of its keys.
+func DiffConfigs(before, after map[string]interface{}) []ConfigChange {
+	beforeKeys := make(map[string]string)
+	afterKeys := make(map[string]string)
+	flattenConfig("", before, beforeKeys)
+	flattenConfig("", after, afterKeys)
+
+	keys := make([]string, 0, len(beforeKeys)+len(afterKeys))
+	for k := range beforeKeys {
+		keys = append(keys, k)
+	}
+	for k := range afterKeys {
+		if _, ok := beforeKeys[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []ConfigChange
+	for _, k := range keys {
+		b, inBefore := beforeKeys[k]
+		a, inAfter := afterKeys[k]
+		switch {
+		case !inBefore:
+			changes = append(changes, ConfigChange{Category: ConfigKeyAdded, Key: k, After: a})
+		case !inAfter:
+			changes = append(changes, ConfigChange{Category: ConfigKeyRemoved, Key: k, Before: b})
+		case a != b:
+			changes = append(changes, ConfigChange{Category: ConfigValueChanged, Key: k, Before: b, After: a})
+		}
+	}
+	return changes
+}
+
+// flattenConfig maps each leaf of a config tree to its dotted key. Arrays
+// of tables are indexed; other arrays are leaves.
+func flattenConfig(prefix string, v interface{}, out map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			out[prefix] = "{}"
+		}
+		for k, child := range v {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flattenConfig(key, child, out)
+		}
+	case []interface{}:
+		tables := len(v) > 0
+		for _, item := range v {
+			if _, ok := item.(map[string]interface{}); !ok {
+				tables = false
+			}
+		}
+		if !tables {
+			out[prefix] = configValue(v)
+			return
+		}
+		for i, item := range v {
+			flattenConfig(fmt.Sprintf("%s[%d]", prefix, i), item, out)
+		}
+	default:
+		out[prefix] = configValue(v)
+	}
+}
+
+// configValue renders a config value for display and comparison.
+func configValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			if s, ok := item.(string); ok {
+				parts[i] = strconv.Quote(s)
+			} else {
+				parts[i] = configValue(item)
+			}
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + " = " + configValue(v[k])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseINI reads an INI file. Keys before the first section are top-level,
+// [section "sub"] headers nest as section.sub, and indented lines continue
+// the previous value.
+func parseINI(content []byte) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	current := root
+	lastKey := ""
+
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == ';' || trimmed[0] == '#' {
+			continue
+		}
+
+		// Continuation of a multi-line value
+		if lastKey != "" && (line[0] == ' ' || line[0] == '\t') {
+			current[lastKey] = current[lastKey].(string) + "\n" + trimmed
+			continue
+		}
+
+		if trimmed[0] == '[' {
+			end := strings.Index(trimmed, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unclosed section header", i+1)
+			}
+			var keys []string
+			for _, part := range strings.Fields(trimmed[1:end]) {
+				keys = append(keys, strings.Trim(part, `"`))
+			}
+			table, err := tomlTable(root, keys)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			current, lastKey = table, ""
+			continue
+		}
+
+		sep := strings.IndexAny(trimmed, "=:")
+		if sep < 0 {
+			// A bare key, as in my.cnf's skip-networking
+			current[trimmed], lastKey = "", trimmed
+			continue
+		}
+		key := strings.TrimSpace(trimmed[:sep])
+		value := strings.TrimSpace(trimmed[sep+1:])
+		if len(value) >= 2 && (value[0] == '"' && value[len(value)-1] == '"' || value[0] == '\'' && value[len(value)-1] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		current[key], lastKey = value, key
+	}
+
+	return root, nil
+}
+
+// parseEnv reads a .env file of KEY=value lines. Values may be quoted;
+// double-quoted values may span lines and take \n escapes, and unquoted
+// values end at a " #" comment.
+func parseEnv(content []byte) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	lines := strings.Split(string(content), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(strings.TrimRight(lines[i], "\r"))
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", i+1)
+		}
+		key := strings.TrimSpace(line[:eq])
+		value := strings.TrimSpace(line[eq+1:])
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			start := i
+			for !closesQuote(value[1:], '"') {
+				if i+1 == len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated quoted value", start+1)
+				}
+				i++
+				value += "\n" + strings.TrimRight(lines[i], "\r")
+			}
+			value = value[1:strings.LastIndex(value, `"`)]
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value)
+		case strings.HasPrefix(value, "'"):
+			end := strings.LastIndex(value, "'")
+			if end == 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", i+1)
+			}
+			value = value[1:end]
+		default:
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = strings.TrimSpace(value[:idx])
+			}
+		}
+		vars[key] = value
+	}
+
+	return vars, nil
+}
+
+// closesQuote reports whether s contains an unescaped quote.
+func closesQuote(s string, quote byte) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigFormat(t *testing.T) {
+	tests := map[string]string{
+		"config/settings.toml": "toml",
+		"setup.cfg":            "ini",
+		"php.ini":              "ini",
+		".editorconfig":        "ini",
+		".env":                 "env",
+		"deploy/.env.staging":  "env",
+		"prod.env":             "env",
+		"config.yaml":          "",
+		"environment.go":       "",
+	}
+	for path, want := range tests {
+		if got := ConfigFormat(path); got != want {
+			t.Errorf("ConfigFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestDiffConfigs_TOML(t *testing.T) {
+	before := `title = "app"
+
+[database]
+host = "localhost"
+port = 5432
+
+[[bin]]
+name = "server"
+
+[[bin]]
+name = "worker"
+`
+	after := `title = "app"
+
+[database]
+host = "db.internal"
+port = 5432
+pool = { min = 1, max = 10 }
+
+[[bin]]
+name = "server"
+
+[[bin]]
+name = "cron"
+
+[features]
+flags = ["a", "b"]
+`
+
+	got := diffConfigStrings(t, "Config.toml", before, after)
+	want := []ConfigChange{
+		{Category: ConfigValueChanged, Key: "bin[1].name", Before: "worker", After: "cron"},
+		{Category: ConfigValueChanged, Key: "database.host", Before: "localhost", After: "db.internal"},
+		{Category: ConfigKeyAdded, Key: "database.pool.max", After: "10"},
+		{Category: ConfigKeyAdded, Key: "database.pool.min", After: "1"},
+		{Category: ConfigKeyAdded, Key: "features.flags", After: `["a", "b"]`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffConfigs() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDiffConfigs_INI(t *testing.T) {
+	before := `; global settings
+name = app
+
+[server]
+port = 8080
+hosts = a
+  b
+
+[remote "origin"]
+url = git@example.com:a.git
+`
+	after := `name = app
+
+[server]
+port: 9090
+hosts = a
+  b
+  c
+skip-networking
+
+[remote "origin"]
+url = "git@example.com:b.git"
+`
+
+	got := diffConfigStrings(t, "setup.cfg", before, after)
+	want := []ConfigChange{
+		{Category: ConfigValueChanged, Key: "remote.origin.url", Before: "git@example.com:a.git", After: "git@example.com:b.git"},
+		{Category: ConfigValueChanged, Key: "server.hosts", Before: "a\nb", After: "a\nb\nc"},
+		{Category: ConfigValueChanged, Key: "server.port", Before: "8080", After: "9090"},
+		{Category: ConfigKeyAdded, Key: "server.skip-networking"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffConfigs() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDiffConfigs_Env(t *testing.T) {
+	before := `# local settings
+export DATABASE_URL=postgres://localhost/app
+API_KEY='abc # not a comment'
+LOG_LEVEL=info # default
+`
+	after := `DATABASE_URL="postgres://localhost/app"
+LOG_LEVEL=debug
+CERT="-----BEGIN-----
+xyz
+-----END-----"
+GREETING="say \"hi\"\n"
+`
+
+	got := diffConfigStrings(t, ".env", before, after)
+	want := []ConfigChange{
+		{Category: ConfigKeyRemoved, Key: "API_KEY", Before: "abc # not a comment"},
+		{Category: ConfigKeyAdded, Key: "CERT", After: "-----BEGIN-----\nxyz\n-----END-----"},
+		{Category: ConfigKeyAdded, Key: "GREETING", After: "say \"hi\"\n"},
+		{Category: ConfigValueChanged, Key: "LOG_LEVEL", Before: "info", After: "debug"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffConfigs() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if _, err := ParseConfig(".env", []byte("KEY=\"unterminated\n")); err == nil {
+		t.Error("expected an error for an unterminated quoted value")
+	}
+}
+
+func diffConfigStrings(t *testing.T, path, before, after string) []ConfigChange {
+	t.Helper()
+	b, err := ParseConfig(path, []byte(before))
+	if err != nil {
+		t.Fatalf("ParseConfig(before): %v", err)
+	}
+	a, err := ParseConfig(path, []byte(after))
+	if err != nil {
+		t.Fatalf("ParseConfig(after): %v", err)
+	}
+	return DiffConfigs(b, a)
+}
@@ -15,13 +15,18 @@ import (
 type ChangeCategory string
 
 const (
-	// Code-level semantic changes (JS/TS)
+	// Code-level semantic changes (JS/TS, Go, Python, Ruby, Rust)
 	ConditionChanged  ChangeCategory = "CONDITION_CHANGED"
 	ConstantUpdated   ChangeCategory = "CONSTANT_UPDATED"
 	APISurfaceChanged ChangeCategory = "API_SURFACE_CHANGED"
 	FunctionAdded     ChangeCategory = "FUNCTION_ADDED"
 	FunctionRemoved   ChangeCategory = "FUNCTION_REMOVED"
 
+	// Code-level changes that need a closer review
+	ErrorHandlingChanged ChangeCategory = "ERROR_HANDLING_CHANGED" // try/catch, if err != nil, rescue
+	ConcurrencyChanged   ChangeCategory = "CONCURRENCY_CHANGED"    // goroutines, locks, async/await, threads
+	SensitiveAPITouched  ChangeCategory = "SENSITIVE_API_TOUCHED"  // crypto, exec, SQL building, auth checks
+
 	// File-level changes (fallback for non-parsed files)
 	FileContentChanged ChangeCategory = "FILE_CONTENT_CHANGED"
 	FileAdded          ChangeCategory = "FILE_ADDED"
@@ -60,15 +65,17 @@ type ChangeType struct {
 
 // Detector detects change types between two versions of a file.
 type Detector struct {
-	parser  *parse.Parser
-	symbols map[string][]*graph.Node // fileID -> symbols
+	parser    *parse.Parser
+	symbols   map[string][]*graph.Node // fileID -> symbols
+	sensitive []SensitivePattern
 }
 
 // NewDetector creates a new change detector.
 func NewDetector() *Detector {
 	return &Detector{
-		parser:  parse.NewParser(),
-		symbols: make(map[string][]*graph.Node),
+		parser:    parse.NewParser(),
+		symbols:   make(map[string][]*graph.Node),
+		sensitive: append([]SensitivePattern{}, DefaultSensitivePatterns...),
 	}
 }
 
@@ -79,6 +86,8 @@ func (d *Detector) SetSymbols(fileID string, symbols []*graph.Node) {
 
 // DetectChanges detects all change types between two versions of a file.
 // The lang parameter specifies the language for proper parsing (e.g., "py", "js", "ts").
+// It also picks the detector table: which nodes are functions, conditions and
+// literals, and which names are exported.
 func (d *Detector) DetectChanges(path string, beforeContent, afterContent []byte, fileID string, lang ...string) ([]*ChangeType, error) {
 	// Default to JavaScript for backward compatibility
 	parseLang := "js"
@@ -96,34 +105,39 @@ func (d *Detector) DetectChanges(path string, beforeContent, afterContent []byte
 		return nil, fmt.Errorf("parsing after: %w", err)
 	}
 
+	spec := specFor(parseLang)
 	var changes []*ChangeType
 
 	// Detect function additions/removals (most important for intent)
-	funcChanges := d.detectFunctionChanges(path, beforeParsed, afterParsed, beforeContent, afterContent, fileID)
+	funcChanges := d.detectFunctionChanges(path, beforeParsed, afterParsed, beforeContent, afterContent, fileID, spec)
 	changes = append(changes, funcChanges...)
 
 	// Detect condition changes
-	condChanges := d.detectConditionChanges(path, beforeParsed, afterParsed, beforeContent, afterContent, fileID)
+	condChanges := d.detectConditionChanges(path, beforeParsed, afterParsed, beforeContent, afterContent, fileID, spec)
 	changes = append(changes, condChanges...)
 
 	// Detect constant updates
-	constChanges := d.detectConstantUpdates(path, beforeParsed, afterParsed, beforeContent, afterContent, fileID)
+	constChanges := d.detectConstantUpdates(path, beforeParsed, afterParsed, beforeContent, afterContent, fileID, spec)
 	changes = append(changes, constChanges...)
 
 	// Detect API surface changes
-	apiChanges := d.detectAPISurfaceChanges(path, beforeParsed, afterParsed, beforeContent, afterContent, fileID)
+	apiChanges := d.detectAPISurfaceChanges(path, beforeParsed, afterParsed, beforeContent, afterContent, fileID, spec)
 	changes = append(changes, apiChanges...)
 
+	// Detect error handling, concurrency and sensitive API changes
+	signalChanges := d.detectSignalChanges(path, beforeParsed, afterParsed, beforeContent, afterContent, fileID, spec)
+	changes = append(changes, signalChanges...)
+
 	return changes, nil
 }
 
 // detectFunctionChanges detects added or removed functions.
-func (d *Detector) detectFunctionChanges(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string) []*ChangeType {
+func (d *Detector) detectFunctionChanges(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string, spec *langSpec) []*ChangeType {
 	var changes []*ChangeType
 
 	// Get all function declarations from both versions
-	beforeFuncs := getAllFunctions(before, beforeContent)
-	afterFuncs := getAllFunctions(after, afterContent)
+	beforeFuncs := getAllFunctions(before, beforeContent, spec)
+	afterFuncs := getAllFunctions(after, afterContent, spec)
 
 	// Check for added functions
 	for name, afterFunc := range afterFuncs {
@@ -176,9 +190,19 @@ type funcInfo struct {
 }
 
 // getAllFunctions extracts all function declarations from a parsed file.
-func getAllFunctions(parsed *parse.ParsedFile, content []byte) map[string]*funcInfo {
+func getAllFunctions(parsed *parse.ParsedFile, content []byte, spec *langSpec) map[string]*funcInfo {
 	funcs := make(map[string]*funcInfo)
 
+	if spec != jsSpec {
+		for _, node := range findNodes(parsed.Tree.RootNode(), spec.functions...) {
+			name := spec.functionName(node, content)
+			if name != "" {
+				funcs[name] = &funcInfo{name: name, node: node}
+			}
+		}
+		return funcs
+	}
+
 	// Function declarations: function foo() {}
 	for _, node := range parsed.FindNodesOfType("function_declaration") {
 		name := getFunctionName(node, content)
@@ -265,11 +289,11 @@ func getVariableFunctionName(node *sitter.Node, content []byte) (string, *sitter
 }
 
 // detectConditionChanges detects changes in binary/logical/relational expressions.
-func (d *Detector) detectConditionChanges(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string) []*ChangeType {
+func (d *Detector) detectConditionChanges(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string, spec *langSpec) []*ChangeType {
 	var changes []*ChangeType
 
 	// Node types that represent conditions
-	conditionTypes := []string{"binary_expression", "logical_expression", "relational_expression"}
+	conditionTypes := spec.conditions
 
 	beforeNodes := make(map[string][]*sitter.Node)
 	afterNodes := make(map[string][]*sitter.Node)
@@ -296,7 +320,7 @@ func (d *Detector) detectConditionChanges(path string, before, after *parse.Pars
 					// Compare the expressions
 					if beforeText != afterText {
 						// Check if operator or boundary changed
-						if hasOperatorOrBoundaryChange(beforeNode, afterNode, beforeContent, afterContent) {
+						if hasOperatorOrBoundaryChange(beforeNode, afterNode, beforeContent, afterContent, spec) {
 							change := &ChangeType{
 								Category: ConditionChanged,
 								Evidence: Evidence{
@@ -320,10 +344,10 @@ func (d *Detector) detectConditionChanges(path string, before, after *parse.Pars
 }
 
 // detectConstantUpdates detects changes in literal values.
-func (d *Detector) detectConstantUpdates(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string) []*ChangeType {
+func (d *Detector) detectConstantUpdates(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string, spec *langSpec) []*ChangeType {
 	var changes []*ChangeType
 
-	literalTypes := []string{"number", "string"}
+	literalTypes := spec.literals()
 
 	for _, nodeType := range literalTypes {
 		beforeNodes := before.FindNodesOfType(nodeType)
@@ -364,53 +388,62 @@ func (d *Detector) detectConstantUpdates(path string, before, after *parse.Parse
 }
 
 // detectAPISurfaceChanges detects changes in function signatures or exports.
-func (d *Detector) detectAPISurfaceChanges(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string) []*ChangeType {
+func (d *Detector) detectAPISurfaceChanges(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string, spec *langSpec) []*ChangeType {
 	var changes []*ChangeType
 
 	// Check function declarations
-	funcChanges := d.compareFunctions(path, before, after, beforeContent, afterContent, fileID)
+	funcChanges := d.compareFunctions(path, before, after, beforeContent, afterContent, fileID, spec)
 	changes = append(changes, funcChanges...)
 
 	// Check export statements
-	exportChanges := d.compareExports(path, before, after, beforeContent, afterContent, fileID)
+	exportChanges := d.compareExports(path, before, after, beforeContent, afterContent, fileID, spec)
 	changes = append(changes, exportChanges...)
 
 	return changes
 }
 
-func (d *Detector) compareFunctions(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string) []*ChangeType {
+func (d *Detector) compareFunctions(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string, spec *langSpec) []*ChangeType {
 	var changes []*ChangeType
 
-	beforeFuncs := before.FindNodesOfType("function_declaration")
-	afterFuncs := after.FindNodesOfType("function_declaration")
-
-	// Also check arrow functions and method definitions
-	beforeFuncs = append(beforeFuncs, before.FindNodesOfType("method_definition")...)
-	afterFuncs = append(afterFuncs, after.FindNodesOfType("method_definition")...)
-
 	// Build a map of function names to nodes
 	beforeByName := make(map[string]*sitter.Node)
 	afterByName := make(map[string]*sitter.Node)
 
-	for _, node := range beforeFuncs {
-		name := getFunctionName(node, beforeContent)
-		if name != "" {
-			beforeByName[name] = node
+	if spec == jsSpec {
+		beforeFuncs := before.FindNodesOfType("function_declaration")
+		afterFuncs := after.FindNodesOfType("function_declaration")
+
+		// Also check arrow functions and method definitions
+		beforeFuncs = append(beforeFuncs, before.FindNodesOfType("method_definition")...)
+		afterFuncs = append(afterFuncs, after.FindNodesOfType("method_definition")...)
+
+		for _, node := range beforeFuncs {
+			name := getFunctionName(node, beforeContent)
+			if name != "" {
+				beforeByName[name] = node
+			}
 		}
-	}
 
-	for _, node := range afterFuncs {
-		name := getFunctionName(node, afterContent)
-		if name != "" {
-			afterByName[name] = node
+		for _, node := range afterFuncs {
+			name := getFunctionName(node, afterContent)
+			if name != "" {
+				afterByName[name] = node
+			}
+		}
+	} else {
+		for name, fn := range getAllFunctions(before, beforeContent, spec) {
+			beforeByName[name] = fn.node
+		}
+		for name, fn := range getAllFunctions(after, afterContent, spec) {
+			afterByName[name] = fn.node
 		}
 	}
 
 	// Compare functions with same name
 	for name, beforeFunc := range beforeByName {
 		if afterFunc, ok := afterByName[name]; ok {
-			beforeParams := getFunctionParams(beforeFunc, beforeContent)
-			afterParams := getFunctionParams(afterFunc, afterContent)
+			beforeParams := spec.functionSignature(beforeFunc, beforeContent)
+			afterParams := spec.functionSignature(afterFunc, afterContent)
 
 			if beforeParams != afterParams {
 				afterRange := parse.GetNodeRange(afterFunc)
@@ -433,49 +466,37 @@ func (d *Detector) compareFunctions(path string, before, after *parse.ParsedFile
 	return changes
 }
 
-func (d *Detector) compareExports(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string) []*ChangeType {
+func (d *Detector) compareExports(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string, spec *langSpec) []*ChangeType {
 	var changes []*ChangeType
 
-	beforeExports := before.FindNodesOfType("export_statement")
-	afterExports := after.FindNodesOfType("export_statement")
-
 	// Get exported identifiers
-	beforeSet := make(map[string]bool)
-	afterSet := make(map[string]bool)
-
-	for _, node := range beforeExports {
-		ids := getExportedIdentifiers(node, beforeContent)
-		for _, id := range ids {
-			beforeSet[id] = true
-		}
-	}
-
-	for _, node := range afterExports {
-		ids := getExportedIdentifiers(node, afterContent)
-		for _, id := range ids {
-			afterSet[id] = true
-		}
-	}
+	beforeSet := spec.exports(before.Tree.RootNode(), beforeContent)
+	afterSet := spec.exports(after.Tree.RootNode(), afterContent)
 
 	// Check for differences
 	hasDiff := false
 	for id := range beforeSet {
-		if !afterSet[id] {
+		if _, ok := afterSet[id]; !ok {
 			hasDiff = true
 			break
 		}
 	}
 	if !hasDiff {
 		for id := range afterSet {
-			if !beforeSet[id] {
+			if _, ok := beforeSet[id]; !ok {
 				hasDiff = true
 				break
 			}
 		}
 	}
 
-	if hasDiff && len(afterExports) > 0 {
-		afterRange := parse.GetNodeRange(afterExports[0])
+	if hasDiff {
+		// Point at the first added export, else at the file
+		evidence := exportEvidence(beforeSet, afterSet)
+		if evidence == nil {
+			evidence = after.Tree.RootNode()
+		}
+		afterRange := parse.GetNodeRange(evidence)
 		change := &ChangeType{
 			Category: APISurfaceChanged,
 			Evidence: Evidence{
@@ -525,7 +546,7 @@ func (d *Detector) findOverlappingSymbols(fileID string, r parse.Range) []string
 	return result
 }
 
-func hasOperatorOrBoundaryChange(before, after *sitter.Node, beforeContent, afterContent []byte) bool {
+func hasOperatorOrBoundaryChange(before, after *sitter.Node, beforeContent, afterContent []byte, spec *langSpec) bool {
 	// Check if operator differs
 	beforeOp := findOperator(before, beforeContent)
 	afterOp := findOperator(after, afterContent)
@@ -534,8 +555,8 @@ func hasOperatorOrBoundaryChange(before, after *sitter.Node, beforeContent, afte
 	}
 
 	// Check if numeric literals in the expression differ
-	beforeNums := findNumbers(before, beforeContent)
-	afterNums := findNumbers(after, afterContent)
+	beforeNums := findNumbers(before, beforeContent, spec.numbers)
+	afterNums := findNumbers(after, afterContent, spec.numbers)
 	if !equalStringSlices(beforeNums, afterNums) {
 		return true
 	}
@@ -547,20 +568,21 @@ func findOperator(node *sitter.Node, content []byte) string {
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
 		switch child.Type() {
-		case ">", "<", ">=", "<=", "==", "===", "!=", "!==", "&&", "||", "+", "-", "*", "/":
+		case ">", "<", ">=", "<=", "==", "===", "!=", "!==", "&&", "||", "+", "-", "*", "/",
+			"and", "or", "not", "in", "not in", "is", "is not", "<=>", "%", "**", "//":
 			return child.Type()
 		}
 		// Check the actual content for operator-like nodes
 		childContent := parse.GetNodeContent(child, content)
 		switch childContent {
-		case ">", "<", ">=", "<=", "==", "===", "!=", "!==", "&&", "||":
+		case ">", "<", ">=", "<=", "==", "===", "!=", "!==", "&&", "||", "and", "or":
 			return childContent
 		}
 	}
 	return ""
 }
 
-func findNumbers(node *sitter.Node, content []byte) []string {
+func findNumbers(node *sitter.Node, content []byte, numberTypes []string) []string {
 	var nums []string
 	iter := sitter.NewIterator(node, sitter.DFSMode)
 	for {
@@ -568,7 +590,7 @@ func findNumbers(node *sitter.Node, content []byte) []string {
 		if err != nil || n == nil {
 			break
 		}
-		if n.Type() == "number" {
+		if containsString(numberTypes, n.Type()) {
 			nums = append(nums, parse.GetNodeContent(n, content))
 		}
 	}
@@ -610,6 +632,15 @@ func getExportedIdentifiers(node *sitter.Node, content []byte) []string {
 	return ids
 }
 
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 func abs(x int) int {
 	if x < 0 {
 		return -x
@@ -0,0 +1,515 @@
+package detect
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HCL change categories for Terraform and other HCL files.
+const (
+	HCLBlockAdded       ChangeCategory = "HCL_BLOCK_ADDED"
+	HCLBlockRemoved     ChangeCategory = "HCL_BLOCK_REMOVED"
+	HCLAttributeAdded   ChangeCategory = "HCL_ATTRIBUTE_ADDED"
+	HCLAttributeRemoved ChangeCategory = "HCL_ATTRIBUTE_REMOVED"
+	HCLAttributeChanged ChangeCategory = "HCL_ATTRIBUTE_CHANGED"
+)
+
+// HCLBlock is a top-level block with the attributes of its body and nested
+// blocks flattened to dotted paths, e.g. "versioning.enabled". Nested blocks
+// of a type that repeats are indexed: "ingress[1].from_port".
+type HCLBlock struct {
+	Address    string // Terraform address, e.g. "aws_s3_bucket.logs" or "var.region"
+	Type       string // Block type: resource, variable, module, ...
+	Labels     []string
+	Attributes map[string]string // Path -> expression text
+}
+
+// HCLChange is a block added or removed, or an attribute of a block added,
+// removed or changed. Top-level attributes, as in .tfvars files, have an
+// empty Address.
+type HCLChange struct {
+	Category  ChangeCategory
+	Address   string
+	Attribute string // Empty for block changes
+	Before    string
+	After     string
+}
+
+// IsHCL reports whether a file is HCL: Terraform, tfvars or plain .hcl.
+func IsHCL(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tf", ".tfvars", ".hcl":
+		return true
+	}
+	return false
+}
+
+// ParseHCL reads the top-level blocks of an HCL file. Attributes outside any
+// block are collected in a block with an empty address. Expressions are kept
+// as text with whitespace and comments normalized away.
+func ParseHCL(content []byte) ([]*HCLBlock, error) {
+	p := &hclParser{s: string(content), line: 1}
+	body, err := p.body(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*HCLBlock
+	seen := make(map[string]int)
+	add := func(b *HCLBlock) {
+		// Repeated addresses, e.g. two unaliased providers, are numbered
+		if n := seen[b.Address]; n > 0 {
+			b.Address = fmt.Sprintf("%s[%d]", b.Address, n)
+		}
+		seen[b.Address]++
+		blocks = append(blocks, b)
+	}
+
+	if len(body.attrs) > 0 {
+		top := &HCLBlock{Attributes: make(map[string]string)}
+		for _, a := range body.attrs {
+			top.Attributes[a.name] = a.value
+		}
+		add(top)
+	}
+
+	var locals *HCLBlock
+	for _, nb := range body.blocks {
+		b := &HCLBlock{Type: nb.typ, Labels: nb.labels, Attributes: make(map[string]string)}
+		flattenHCL("", nb.body, b.Attributes)
+
+		switch {
+		case nb.typ == "locals":
+			// All locals blocks share one namespace
+			if locals == nil {
+				locals = &HCLBlock{Address: "locals", Type: "locals", Attributes: make(map[string]string)}
+				add(locals)
+			}
+			for k, v := range b.Attributes {
+				locals.Attributes[k] = v
+			}
+			continue
+		case nb.typ == "resource" && len(nb.labels) == 2:
+			b.Address = nb.labels[0] + "." + nb.labels[1]
+		case nb.typ == "data" && len(nb.labels) == 2:
+			b.Address = "data." + nb.labels[0] + "." + nb.labels[1]
+		case nb.typ == "variable" && len(nb.labels) == 1:
+			b.Address = "var." + nb.labels[0]
+		case nb.typ == "provider" && len(nb.labels) == 1:
+			b.Address = "provider." + nb.labels[0]
+			if alias, ok := b.Attributes["alias"]; ok {
+				b.Address += "." + strings.Trim(alias, `"`)
+			}
+		default:
+			b.Address = strings.Join(append([]string{nb.typ}, nb.labels...), ".")
+		}
+		add(b)
+	}
+
+	return blocks, nil
+}
+
+// flattenHCL adds the attributes of a body and its nested blocks to out.
+func flattenHCL(prefix string, body *hclBody, out map[string]string) {
+	for _, a := range body.attrs {
+		out[prefix+a.name] = a.value
+	}
+
+	counts := make(map[string]int)
+	for _, nb := range body.blocks {
+		counts[nestedHCLName(nb)]++
+	}
+	index := make(map[string]int)
+	for _, nb := range body.blocks {
+		name := nestedHCLName(nb)
+		if counts[name] > 1 {
+			name = fmt.Sprintf("%s[%d]", name, index[name])
+			index[nestedHCLName(nb)]++
+		}
+		if len(nb.body.attrs) == 0 && len(nb.body.blocks) == 0 {
+			out[prefix+name] = "{}"
+			continue
+		}
+		flattenHCL(prefix+name+".", nb.body, out)
+	}
+}
+
+func nestedHCLName(nb *hclBlockNode) string {
+	return strings.Join(append([]string{nb.typ}, nb.labels...), ".")
+}
+
+// DiffHCL compares the blocks of two HCL files by address, then the
+// attributes of blocks present in both.
+func DiffHCL(before, after []*HCLBlock) []HCLChange {
+	beforeByAddr := make(map[string]*HCLBlock)
+	for _, b := range before {
+		beforeByAddr[b.Address] = b
+	}
+	afterByAddr := make(map[string]*HCLBlock)
+	for _, b := range after {
+		afterByAddr[b.Address] = b
+	}
+
+	var changes []HCLChange
+	for _, b := range after {
+		old, ok := beforeByAddr[b.Address]
+		if !ok {
+			if b.Address == "" {
+				old = &HCLBlock{}
+			} else {
+				changes = append(changes, HCLChange{Category: HCLBlockAdded, Address: b.Address})
+				continue
+			}
+		}
+		for _, attr := range sortedAttributeKeys(old.Attributes, b.Attributes) {
+			bv, inBefore := old.Attributes[attr]
+			av, inAfter := b.Attributes[attr]
+			switch {
+			case !inBefore:
+				changes = append(changes, HCLChange{Category: HCLAttributeAdded, Address: b.Address, Attribute: attr, After: av})
+			case !inAfter:
+				changes = append(changes, HCLChange{Category: HCLAttributeRemoved, Address: b.Address, Attribute: attr, Before: bv})
+			case bv != av:
+				changes = append(changes, HCLChange{Category: HCLAttributeChanged, Address: b.Address, Attribute: attr, Before: bv, After: av})
+			}
+		}
+	}
+	for _, b := range before {
+		if _, ok := afterByAddr[b.Address]; ok {
+			continue
+		}
+		if b.Address == "" {
+			for _, attr := range sortedAttributeKeys(b.Attributes, nil) {
+				changes = append(changes, HCLChange{Category: HCLAttributeRemoved, Attribute: attr, Before: b.Attributes[attr]})
+			}
+			continue
+		}
+		changes = append(changes, HCLChange{Category: HCLBlockRemoved, Address: b.Address})
+	}
+
+	return changes
+}
+
+func sortedAttributeKeys(a, b map[string]string) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type hclBody struct {
+	attrs  []hclAttr
+	blocks []*hclBlockNode
+}
+
+type hclAttr struct {
+	name  string
+	value string
+}
+
+type hclBlockNode struct {
+	typ    string
+	labels []string
+	body   *hclBody
+}
+
+type hclParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (p *hclParser) eof() bool  { return p.pos >= len(p.s) }
+func (p *hclParser) peek() byte { return p.s[p.pos] }
+
+func (p *hclParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skipBlank skips whitespace and comments.
+func (p *hclParser) skipBlank() {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '#' || strings.HasPrefix(p.s[p.pos:], "//"):
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.s[p.pos:], "/*"):
+			end := strings.Index(p.s[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.s)
+				return
+			}
+			p.line += strings.Count(p.s[p.pos:p.pos+2+end], "\n")
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+// body reads attributes and blocks until EOF, or the closing brace when
+// nested is set.
+func (p *hclParser) body(nested bool) (*hclBody, error) {
+	body := &hclBody{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			if nested {
+				return nil, p.errorf("unclosed block")
+			}
+			return body, nil
+		}
+		if p.peek() == '}' {
+			if !nested {
+				return nil, p.errorf("unexpected }")
+			}
+			p.pos++
+			return body, nil
+		}
+
+		name := p.ident()
+		if name == "" {
+			return nil, p.errorf("unexpected %q", p.peek())
+		}
+		for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+			p.pos++
+		}
+
+		if !p.eof() && p.peek() == '=' && !strings.HasPrefix(p.s[p.pos:], "==") {
+			p.pos++
+			value, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			body.attrs = append(body.attrs, hclAttr{name: name, value: value})
+			continue
+		}
+
+		block := &hclBlockNode{typ: name}
+		for {
+			p.skipBlank()
+			if p.eof() {
+				return nil, p.errorf("expected { after %s", name)
+			}
+			if p.peek() == '{' {
+				p.pos++
+				break
+			}
+			if p.peek() == '"' {
+				label, err := p.str()
+				if err != nil {
+					return nil, err
+				}
+				block.labels = append(block.labels, label)
+				continue
+			}
+			label := p.ident()
+			if label == "" {
+				return nil, p.errorf("unexpected %q in block header", p.peek())
+			}
+			block.labels = append(block.labels, label)
+		}
+		inner, err := p.body(true)
+		if err != nil {
+			return nil, err
+		}
+		block.body = inner
+		body.blocks = append(body.blocks, block)
+	}
+}
+
+func (p *hclParser) ident() string {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+// str reads a quoted string and returns its unquoted text.
+func (p *hclParser) str() (string, error) {
+	start := p.pos
+	if err := p.skipString(); err != nil {
+		return "", err
+	}
+	text := p.s[start:p.pos]
+	if s, err := strconv.Unquote(text); err == nil {
+		return s, nil
+	}
+	return text[1 : len(text)-1], nil
+}
+
+// skipString moves past a quoted template, including ${...} and %{...}
+// sequences that may hold quotes of their own.
+func (p *hclParser) skipString() error {
+	p.pos++
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == '\\':
+			p.pos += 2
+		case c == '"':
+			p.pos++
+			return nil
+		case c == '\n':
+			return p.errorf("unterminated string")
+		case (c == '$' || c == '%') && strings.HasPrefix(p.s[p.pos+1:], "{"):
+			p.pos += 2
+			depth := 1
+			for !p.eof() && depth > 0 {
+				switch p.peek() {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				case '"':
+					if err := p.skipString(); err != nil {
+						return err
+					}
+					continue
+				}
+				p.pos++
+			}
+		default:
+			p.pos++
+		}
+	}
+	return p.errorf("unterminated string")
+}
+
+// expr reads an attribute's expression to the end of its line, following
+// brackets, strings and heredocs across lines. Comments are dropped and
+// whitespace collapsed outside strings.
+func (p *hclParser) expr() (string, error) {
+	var b strings.Builder
+	depth := 0
+	space := false
+	write := func(s string) {
+		if space && b.Len() > 0 && !strings.ContainsRune("([{", rune(b.String()[b.Len()-1])) {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == '\n' && depth == 0:
+			return strings.TrimSpace(b.String()), nil
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			if c == '\n' {
+				p.line++
+			}
+			space = true
+			p.pos++
+		case c == '#' || strings.HasPrefix(p.s[p.pos:], "//"):
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.s[p.pos:], "/*"):
+			end := strings.Index(p.s[p.pos+2:], "*/")
+			if end < 0 {
+				return "", p.errorf("unterminated comment")
+			}
+			p.pos += end + 4
+			space = true
+		case c == '"':
+			start := p.pos
+			if err := p.skipString(); err != nil {
+				return "", err
+			}
+			write(p.s[start:p.pos])
+		case strings.HasPrefix(p.s[p.pos:], "<<"):
+			text, err := p.heredoc()
+			if err != nil {
+				return "", err
+			}
+			write(text)
+		case c == '(' || c == '[' || c == '{':
+			depth++
+			write(p.s[p.pos : p.pos+1])
+			p.pos++
+		case c == ')' || c == ']' || c == '}':
+			if depth == 0 {
+				// The closing brace of the enclosing block
+				return strings.TrimSpace(b.String()), nil
+			}
+			depth--
+			space = false
+			b.WriteByte(c)
+			p.pos++
+		case c == ',':
+			space = false
+			b.WriteByte(c)
+			space = true
+			p.pos++
+		default:
+			write(p.s[p.pos : p.pos+1])
+			p.pos++
+		}
+	}
+	if depth > 0 {
+		return "", p.errorf("unclosed bracket in expression")
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// heredoc reads a <<EOF or <<-EOF heredoc, returning it with its lines
+// trimmed.
+func (p *hclParser) heredoc() (string, error) {
+	startLine := p.line
+	p.pos += 2
+	if !p.eof() && p.peek() == '-' {
+		p.pos++
+	}
+	marker := p.ident()
+	if marker == "" {
+		return "", p.errorf("expected heredoc marker")
+	}
+	nl := strings.Index(p.s[p.pos:], "\n")
+	if nl < 0 {
+		return "", p.errorf("unterminated heredoc")
+	}
+	p.pos += nl + 1
+	p.line++
+
+	var lines []string
+	for !p.eof() {
+		end := strings.Index(p.s[p.pos:], "\n")
+		if end < 0 {
+			end = len(p.s) - p.pos
+		}
+		line := strings.TrimSpace(p.s[p.pos : p.pos+end])
+		p.pos += end
+		if line == marker {
+			return "<<" + marker + "\n" + strings.Join(lines, "\n") + "\n" + marker, nil
+		}
+		lines = append(lines, line)
+		if !p.eof() {
+			p.pos++
+			p.line++
+		}
+	}
+	return "", fmt.Errorf("line %d: unterminated heredoc", startLine)
+}
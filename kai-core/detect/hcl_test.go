@@ -0,0 +1,154 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHCL_Addresses(t *testing.T) {
+	content := `
+region = "us-east-1"
+
+provider "aws" {
+  region = var.region
+}
+
+provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+
+variable "region" {
+  type    = string
+  default = "us-east-1"
+}
+
+locals {
+  name = "app"
+}
+
+locals {
+  tags = { Owner = "ops" }
+}
+
+data "aws_ami" "ubuntu" {
+  most_recent = true
+}
+
+module "vpc" {
+  source = "./vpc"
+}
+`
+	blocks, err := ParseHCL([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseHCL: %v", err)
+	}
+
+	var addrs []string
+	for _, b := range blocks {
+		addrs = append(addrs, b.Address)
+	}
+	want := []string{"", "provider.aws", "provider.aws.west", "var.region", "locals", "data.aws_ami.ubuntu", "module.vpc"}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Errorf("addresses = %q, want %q", addrs, want)
+	}
+	if got := blocks[4].Attributes["tags"]; got != `{Owner = "ops"}` {
+		t.Errorf("locals.tags = %q", got)
+	}
+}
+
+func TestDiffHCL(t *testing.T) {
+	before := `
+resource "aws_s3_bucket" "logs" {
+  bucket = "app-logs" # bucket name
+
+  versioning {
+    enabled = false
+  }
+
+  ingress {
+    from_port = 80
+  }
+  ingress {
+    from_port = 443
+  }
+}
+
+resource "aws_instance" "old" {
+  ami = "ami-1"
+}
+`
+	after := `
+resource "aws_s3_bucket" "logs" {
+  bucket = "app-logs"
+  acl    = "private"
+
+  versioning {
+    enabled = true
+  }
+
+  ingress {
+    from_port = 80
+  }
+  ingress {
+    from_port = 8443
+  }
+
+  policy = <<EOT
+{"Version": "2012-10-17"}
+EOT
+}
+
+resource "aws_instance" "web" {
+  ami           = "ami-2"
+  instance_type = var.size
+}
+`
+	b, err := ParseHCL([]byte(before))
+	if err != nil {
+		t.Fatalf("ParseHCL(before): %v", err)
+	}
+	a, err := ParseHCL([]byte(after))
+	if err != nil {
+		t.Fatalf("ParseHCL(after): %v", err)
+	}
+
+	got := DiffHCL(b, a)
+	want := []HCLChange{
+		{Category: HCLAttributeAdded, Address: "aws_s3_bucket.logs", Attribute: "acl", After: `"private"`},
+		{Category: HCLAttributeChanged, Address: "aws_s3_bucket.logs", Attribute: "ingress[1].from_port", Before: "443", After: "8443"},
+		{Category: HCLAttributeAdded, Address: "aws_s3_bucket.logs", Attribute: "policy", After: "<<EOT\n{\"Version\": \"2012-10-17\"}\nEOT"},
+		{Category: HCLAttributeChanged, Address: "aws_s3_bucket.logs", Attribute: "versioning.enabled", Before: "false", After: "true"},
+		{Category: HCLBlockAdded, Address: "aws_instance.web"},
+		{Category: HCLBlockRemoved, Address: "aws_instance.old"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffHCL() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDiffHCL_TFVars(t *testing.T) {
+	b, _ := ParseHCL([]byte("size = \"t3.micro\"\ncount = 2\n"))
+	a, _ := ParseHCL([]byte("size = \"t3.large\"\n"))
+
+	got := DiffHCL(b, a)
+	want := []HCLChange{
+		{Category: HCLAttributeRemoved, Attribute: "count", Before: "2"},
+		{Category: HCLAttributeChanged, Attribute: "size", Before: `"t3.micro"`, After: `"t3.large"`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffHCL() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseHCL_Errors(t *testing.T) {
+	for _, content := range []string{
+		`resource "a" "b" {`,
+		`name = "unterminated`,
+		`= 1`,
+	} {
+		if _, err := ParseHCL([]byte(content)); err == nil {
+			t.Errorf("ParseHCL(%q): expected an error", content)
+		}
+	}
+}
@@ -0,0 +1,547 @@
+package detect
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"kai-core/parse"
+)
+
+// langSpec tells the detector which nodes of a language's grammar hold the
+// constructs it compares, and which top-level names make up the public API.
+type langSpec struct {
+	// functions are function and method declarations, named by their "name"
+	// field and qualified by the enclosing containers.
+	functions []string
+
+	// containers are the nodes that qualify the functions declared in them,
+	// mapped to the field holding the container's name.
+	containers map[string]string
+
+	// signature are the fields of a function that make up its signature.
+	signature []string
+
+	conditions []string // comparison and boolean expressions
+	numbers    []string // numeric literals
+	strings    []string // string literals
+
+	// exports returns the exported names of a file, each with the node
+	// declaring it.
+	exports func(root *sitter.Node, content []byte) map[string]*sitter.Node
+
+	// calls are the call nodes, mapped to the field holding the callee. An
+	// empty field means a Ruby-style receiver and method.
+	calls map[string]string
+
+	// errorNodes are error handling constructs, errorGuard matches if
+	// conditions that check for an error, and errorCalls are callee
+	// patterns that raise or recover.
+	errorNodes []string
+	errorGuard *regexp.Regexp
+	errorCalls []string
+
+	// concurrencyNodes are concurrency constructs and concurrencyCalls are
+	// callee patterns that start, synchronize or wait for concurrent work.
+	concurrencyNodes []string
+	concurrencyCalls []string
+}
+
+var (
+	jsSpec = &langSpec{
+		conditions:       []string{"binary_expression", "logical_expression", "relational_expression"},
+		numbers:          []string{"number"},
+		strings:          []string{"string"},
+		exports:          jsExports,
+		calls:            map[string]string{"call_expression": "function", "new_expression": "constructor"},
+		errorNodes:       []string{"catch_clause", "finally_clause", "throw_statement"},
+		errorCalls:       []string{"*.catch", "*.finally"},
+		concurrencyNodes: []string{"await_expression", "async"},
+		concurrencyCalls: []string{"Promise.*", "Atomics.*", "Worker", "setTimeout", "setInterval", "queueMicrotask"},
+	}
+
+	goSpec = &langSpec{
+		functions:        []string{"function_declaration", "method_declaration"},
+		signature:        []string{"parameters", "result"},
+		conditions:       []string{"binary_expression"},
+		numbers:          []string{"int_literal", "float_literal"},
+		strings:          []string{"interpreted_string_literal", "raw_string_literal"},
+		exports:          goExports,
+		calls:            map[string]string{"call_expression": "function"},
+		errorGuard:       regexp.MustCompile(`\berr\w*\s*[!=]=\s*nil\b|\bnil\s*[!=]=\s*err`),
+		errorCalls:       []string{"panic", "recover", "errors.Is", "errors.As", "errors.Join"},
+		concurrencyNodes: []string{"go_statement", "select_statement", "send_statement", "channel_type"},
+		concurrencyCalls: []string{"sync.*", "atomic.*", "errgroup.*", "*.Lock", "*.Unlock", "*.RLock", "*.RUnlock", "*.TryLock", "*.Wait", "close"},
+	}
+
+	pySpec = &langSpec{
+		functions:        []string{"function_definition"},
+		containers:       map[string]string{"class_definition": "name"},
+		signature:        []string{"parameters", "return_type"},
+		conditions:       []string{"comparison_operator", "boolean_operator", "binary_operator"},
+		numbers:          []string{"integer", "float"},
+		strings:          []string{"string"},
+		exports:          pyExports,
+		calls:            map[string]string{"call": "function"},
+		errorNodes:       []string{"except_clause", "finally_clause", "raise_statement"},
+		concurrencyNodes: []string{"await", "async"},
+		concurrencyCalls: []string{"threading.*", "asyncio.*", "multiprocessing.*", "concurrent.*", "Thread", "Lock", "RLock",
+			"ThreadPoolExecutor", "ProcessPoolExecutor", "*.acquire", "*.release"},
+	}
+
+	rbSpec = &langSpec{
+		functions:        []string{"method", "singleton_method"},
+		containers:       map[string]string{"class": "name", "module": "name"},
+		signature:        []string{"parameters"},
+		conditions:       []string{"binary"},
+		numbers:          []string{"integer", "float"},
+		strings:          []string{"string"},
+		exports:          rbExports,
+		calls:            map[string]string{"call": ""},
+		errorNodes:       []string{"rescue", "ensure", "rescue_modifier", "retry"},
+		errorCalls:       []string{"raise", "fail"},
+		concurrencyCalls: []string{"Thread.*", "Mutex.*", "Queue.*", "Ractor.*", "Fiber.*", "Concurrent::*", "*.synchronize"},
+	}
+
+	rsSpec = &langSpec{
+		functions:        []string{"function_item", "function_signature_item"},
+		containers:       map[string]string{"impl_item": "type", "trait_item": "name", "mod_item": "name"},
+		signature:        []string{"parameters", "return_type"},
+		conditions:       []string{"binary_expression"},
+		numbers:          []string{"integer_literal", "float_literal"},
+		strings:          []string{"string_literal"},
+		exports:          rsExports,
+		calls:            map[string]string{"call_expression": "function", "macro_invocation": "macro"},
+		errorNodes:       []string{"try_expression"},
+		errorGuard:       regexp.MustCompile(`\bErr\(|\.is_err\(\)|\.is_ok\(\)`),
+		errorCalls:       []string{"panic", "unreachable", "*.unwrap", "*.expect", "*.map_err", "*.ok_or*"},
+		concurrencyNodes: []string{"await_expression", "async_block", "async"},
+		concurrencyCalls: []string{"*thread::spawn", "*::spawn", "tokio::*", "*Mutex::new", "*RwLock::new", "*Arc::new",
+			"*::channel", "*.lock"},
+	}
+)
+
+// specFor returns the detector table for a language, falling back to
+// JavaScript as the parser does.
+func specFor(lang string) *langSpec {
+	switch lang {
+	case "go", "golang":
+		return goSpec
+	case "py", "python":
+		return pySpec
+	case "rb", "ruby":
+		return rbSpec
+	case "rs", "rust":
+		return rsSpec
+	default:
+		return jsSpec
+	}
+}
+
+// IsCodeLang reports whether a language gets semantic change detection
+// (functions, conditions, constants and API surface).
+func IsCodeLang(lang string) bool {
+	switch lang {
+	case "js", "ts", "jsx", "tsx", "javascript", "typescript",
+		"go", "golang", "py", "python", "rb", "ruby", "rs", "rust":
+		return true
+	default:
+		return false
+	}
+}
+
+// literals returns the literal node types compared for constant updates.
+func (s *langSpec) literals() []string {
+	return append(append([]string{}, s.numbers...), s.strings...)
+}
+
+// functionName returns a function's name qualified by its enclosing
+// containers, e.g. "Cart.total" for a method of class Cart.
+func (s *langSpec) functionName(node *sitter.Node, content []byte) string {
+	nameNode := node.ChildByFieldName("name")
+	if nameNode == nil {
+		return ""
+	}
+	name := parse.GetNodeContent(nameNode, content)
+
+	switch node.Type() {
+	case "method_declaration":
+		return goFunctionName(node, content)
+	case "singleton_method":
+		name = "self." + name
+	}
+
+	for p := node.Parent(); p != nil; p = p.Parent() {
+		field, ok := s.containers[p.Type()]
+		if !ok {
+			continue
+		}
+		if n := p.ChildByFieldName(field); n != nil {
+			name = typeName(parse.GetNodeContent(n, content)) + "." + name
+		}
+	}
+	return name
+}
+
+// callee returns the callee of a call as written, such as "exec.Command" or
+// "Thread.new", or "" when the node isn't a call.
+func (s *langSpec) callee(node *sitter.Node, content []byte) string {
+	field, ok := s.calls[node.Type()]
+	if !ok {
+		return ""
+	}
+	if field == "" {
+		method := node.ChildByFieldName("method")
+		if method == nil {
+			return ""
+		}
+		name := parse.GetNodeContent(method, content)
+		if recv := node.ChildByFieldName("receiver"); recv != nil {
+			name = parse.GetNodeContent(recv, content) + "." + name
+		}
+		return strings.Join(strings.Fields(name), "")
+	}
+	if n := node.ChildByFieldName(field); n != nil {
+		return strings.Join(strings.Fields(parse.GetNodeContent(n, content)), "")
+	}
+	return ""
+}
+
+// functionSignature returns the text of a function's signature fields.
+func (s *langSpec) functionSignature(node *sitter.Node, content []byte) string {
+	if s.signature == nil {
+		return getFunctionParams(node, content)
+	}
+	var parts []string
+	for _, field := range s.signature {
+		if n := node.ChildByFieldName(field); n != nil {
+			parts = append(parts, parse.GetNodeContent(n, content))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// typeName strips generic parameters from a type, so "Stack<T>" qualifies
+// methods as "Stack".
+func typeName(s string) string {
+	if i := strings.IndexAny(s, "<["); i > 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// goFunctionName returns a Go function's name, qualified by the receiver
+// type for methods.
+func goFunctionName(node *sitter.Node, content []byte) string {
+	nameNode := node.ChildByFieldName("name")
+	if nameNode == nil {
+		return ""
+	}
+	name := parse.GetNodeContent(nameNode, content)
+	if recv := node.ChildByFieldName("receiver"); recv != nil {
+		if typ := goReceiverType(recv, content); typ != "" {
+			return typ + "." + name
+		}
+	}
+	return name
+}
+
+// goReceiverType returns the type name of a Go method receiver, without
+// pointer or type parameters.
+func goReceiverType(recv *sitter.Node, content []byte) string {
+	var find func(n *sitter.Node) string
+	find = func(n *sitter.Node) string {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			child := n.Child(i)
+			switch child.Type() {
+			case "type_identifier":
+				return parse.GetNodeContent(child, content)
+			case "parameter_declaration", "pointer_type", "generic_type":
+				if name := find(child); name != "" {
+					return name
+				}
+			}
+		}
+		return ""
+	}
+	return find(recv)
+}
+
+// jsExports returns the identifiers named in export statements.
+func jsExports(root *sitter.Node, content []byte) map[string]*sitter.Node {
+	exports := make(map[string]*sitter.Node)
+	for _, node := range findNodes(root, "export_statement") {
+		for _, id := range getExportedIdentifiers(node, content) {
+			if _, ok := exports[id]; !ok {
+				exports[id] = node
+			}
+		}
+	}
+	return exports
+}
+
+// goExports returns the capitalized top-level functions, methods, types,
+// constants and variables.
+func goExports(root *sitter.Node, content []byte) map[string]*sitter.Node {
+	exports := make(map[string]*sitter.Node)
+	add := func(name string, node *sitter.Node) {
+		r := []rune(name[strings.LastIndex(name, ".")+1:])
+		if len(r) > 0 && unicode.IsUpper(r[0]) {
+			exports[name] = node
+		}
+	}
+
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		node := root.NamedChild(i)
+		switch node.Type() {
+		case "function_declaration", "method_declaration":
+			if name := goFunctionName(node, content); name != "" {
+				add(name, node)
+			}
+		case "type_declaration":
+			for j := 0; j < int(node.NamedChildCount()); j++ {
+				spec := node.NamedChild(j)
+				if n := spec.ChildByFieldName("name"); n != nil {
+					add(parse.GetNodeContent(n, content), spec)
+				}
+			}
+		case "const_declaration", "var_declaration":
+			for _, spec := range findNodes(node, "const_spec", "var_spec") {
+				// A spec's names come before its type and values
+				for j := 0; j < int(spec.ChildCount()); j++ {
+					child := spec.Child(j)
+					if child.Type() == "identifier" {
+						add(parse.GetNodeContent(child, content), spec)
+					} else if child.Type() != "," {
+						break
+					}
+				}
+			}
+		}
+	}
+	return exports
+}
+
+// pyExports returns the names listed in __all__, or without one the
+// top-level functions, classes and assignments not starting with "_". The
+// public methods of exported classes are exported too.
+func pyExports(root *sitter.Node, content []byte) map[string]*sitter.Node {
+	exports := make(map[string]*sitter.Node)
+	methods := make(map[string]map[string]*sitter.Node) // class -> methods
+	var all *sitter.Node
+
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		node := root.NamedChild(i)
+		def := node
+		if node.Type() == "decorated_definition" {
+			def = node.ChildByFieldName("definition")
+		}
+		switch def.Type() {
+		case "function_definition", "class_definition":
+			n := def.ChildByFieldName("name")
+			if n == nil {
+				continue
+			}
+			name := parse.GetNodeContent(n, content)
+			exports[name] = node
+			if def.Type() == "class_definition" {
+				methods[name] = make(map[string]*sitter.Node)
+				body := def.ChildByFieldName("body")
+				for j := 0; body != nil && j < int(body.NamedChildCount()); j++ {
+					fn := body.NamedChild(j)
+					if fn.Type() == "decorated_definition" {
+						fn = fn.ChildByFieldName("definition")
+					}
+					if fn.Type() != "function_definition" {
+						continue
+					}
+					if m := fn.ChildByFieldName("name"); m != nil && !strings.HasPrefix(parse.GetNodeContent(m, content), "_") {
+						methods[name][parse.GetNodeContent(m, content)] = fn
+					}
+				}
+			}
+		case "expression_statement":
+			for j := 0; j < int(def.NamedChildCount()); j++ {
+				assign := def.NamedChild(j)
+				if assign.Type() != "assignment" && assign.Type() != "augmented_assignment" {
+					continue
+				}
+				left := assign.ChildByFieldName("left")
+				if left == nil || left.Type() != "identifier" {
+					continue
+				}
+				name := parse.GetNodeContent(left, content)
+				if name == "__all__" {
+					all = assign
+					continue
+				}
+				exports[name] = node
+			}
+		}
+	}
+
+	public := make(map[string]*sitter.Node)
+	if all != nil {
+		for _, s := range findNodes(all, "string") {
+			public[strings.Trim(parse.GetNodeContent(s, content), `"'`)] = all
+		}
+	} else {
+		for name, node := range exports {
+			if !strings.HasPrefix(name, "_") {
+				public[name] = node
+			}
+		}
+	}
+	for class, ms := range methods {
+		if _, ok := public[class]; !ok {
+			continue
+		}
+		for name, node := range ms {
+			public[class+"."+name] = node
+		}
+	}
+	return public
+}
+
+// rbExports returns the public methods: those not following a bare private
+// or protected, and not named in a private/protected call.
+func rbExports(root *sitter.Node, content []byte) map[string]*sitter.Node {
+	exports := make(map[string]*sitter.Node)
+
+	var walk func(body *sitter.Node, prefix string)
+	walk = func(body *sitter.Node, prefix string) {
+		hidden := false
+		var private []string
+		for i := 0; i < int(body.NamedChildCount()); i++ {
+			node := body.NamedChild(i)
+			switch node.Type() {
+			case "body_statement":
+				walk(node, prefix)
+			case "class", "module":
+				if n := node.ChildByFieldName("name"); n != nil {
+					walk(node, prefix+parse.GetNodeContent(n, content)+".")
+				}
+			case "identifier":
+				switch parse.GetNodeContent(node, content) {
+				case "private", "protected":
+					hidden = true
+				case "public":
+					hidden = false
+				}
+			case "call":
+				method := node.ChildByFieldName("method")
+				if method == nil {
+					continue
+				}
+				switch parse.GetNodeContent(method, content) {
+				case "private", "protected", "private_class_method":
+					for _, sym := range findNodes(node, "simple_symbol") {
+						private = append(private, strings.TrimPrefix(parse.GetNodeContent(sym, content), ":"))
+					}
+				}
+			case "method":
+				if n := node.ChildByFieldName("name"); n != nil && !hidden {
+					exports[prefix+parse.GetNodeContent(n, content)] = node
+				}
+			case "singleton_method":
+				if n := node.ChildByFieldName("name"); n != nil {
+					exports[prefix+"self."+parse.GetNodeContent(n, content)] = node
+				}
+			}
+		}
+		for _, name := range private {
+			delete(exports, prefix+name)
+			delete(exports, prefix+"self."+name)
+		}
+	}
+	walk(root, "")
+	return exports
+}
+
+// rsExports returns the items declared pub, in the file and in its modules
+// and impl blocks.
+func rsExports(root *sitter.Node, content []byte) map[string]*sitter.Node {
+	exports := make(map[string]*sitter.Node)
+
+	var walk func(items *sitter.Node, prefix string)
+	walk = func(items *sitter.Node, prefix string) {
+		for i := 0; i < int(items.NamedChildCount()); i++ {
+			node := items.NamedChild(i)
+			if node.Type() == "impl_item" {
+				if typ := node.ChildByFieldName("type"); typ != nil {
+					if body := node.ChildByFieldName("body"); body != nil {
+						walk(body, prefix+typeName(parse.GetNodeContent(typ, content))+".")
+					}
+				}
+				continue
+			}
+
+			name := node.ChildByFieldName("name")
+			if name == nil || !hasChildOfType(node, "visibility_modifier") {
+				continue
+			}
+			exports[prefix+parse.GetNodeContent(name, content)] = node
+			if node.Type() == "mod_item" {
+				if body := node.ChildByFieldName("body"); body != nil {
+					walk(body, prefix+parse.GetNodeContent(name, content)+".")
+				}
+			}
+		}
+	}
+	walk(root, "")
+	return exports
+}
+
+// exportEvidence picks the node to report an export change at: the first
+// added name's declaration, else the first remaining export, else nil.
+func exportEvidence(beforeSet, afterSet map[string]*sitter.Node) *sitter.Node {
+	var added []string
+	for name := range afterSet {
+		if _, ok := beforeSet[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	if len(added) > 0 {
+		return afterSet[added[0]]
+	}
+
+	var first *sitter.Node
+	for _, node := range afterSet {
+		if first == nil || node.StartByte() < first.StartByte() {
+			first = node
+		}
+	}
+	return first
+}
+
+func hasChildOfType(node *sitter.Node, nodeType string) bool {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if node.Child(i).Type() == nodeType {
+			return true
+		}
+	}
+	return false
+}
+
+// findNodes returns the nodes of the given types under root, in order.
+func findNodes(root *sitter.Node, nodeTypes ...string) []*sitter.Node {
+	var nodes []*sitter.Node
+	iter := sitter.NewIterator(root, sitter.DFSMode)
+	for {
+		n, err := iter.Next()
+		if err != nil || n == nil {
+			break
+		}
+		for _, t := range nodeTypes {
+			if n.Type() == t {
+				nodes = append(nodes, n)
+				break
+			}
+		}
+	}
+	return nodes
+}
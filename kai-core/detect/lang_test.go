@@ -0,0 +1,369 @@
+package detect
+
+import (
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"kai-core/parse"
+)
+
+func findCategory(changes []*ChangeType, category ChangeCategory) []*ChangeType {
+	var found []*ChangeType
+	for _, c := range changes {
+		if c.Category == category {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+func hasSymbol(changes []*ChangeType, symbol string) bool {
+	for _, c := range changes {
+		for _, s := range c.Evidence.Symbols {
+			if s == symbol {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestDetectChanges_Languages(t *testing.T) {
+	tests := []struct {
+		lang          string
+		before, after string
+		added         string // qualified name of an added function
+		condition     bool   // expect CONDITION_CHANGED
+		constant      bool   // expect CONSTANT_UPDATED
+		api           bool   // expect API_SURFACE_CHANGED
+	}{
+		{
+			lang: "go",
+			before: `package cart
+
+func (c *Cart) Total(items int) int {
+	if items > 10 {
+		return 5
+	}
+	return 0
+}
+`,
+			after: `package cart
+
+func (c *Cart) Total(items int) int {
+	if items >= 20 {
+		return 7
+	}
+	return 0
+}
+
+func (c *Cart) Empty() bool {
+	return true
+}
+`,
+			added:     "Cart.Empty",
+			condition: true,
+			constant:  true,
+			api:       true,
+		},
+		{
+			lang: "py",
+			before: `class Cart:
+    def total(self, items):
+        if items > 10 and items < 100:
+            return 5
+        return 0
+`,
+			after: `class Cart:
+    def total(self, items):
+        if items > 10 or items < 100:
+            return 5
+        return 0
+
+    def empty(self):
+        return True
+`,
+			added:     "Cart.empty",
+			condition: true,
+			api:       true,
+		},
+		{
+			lang: "rb",
+			before: `class Cart
+  def total(items)
+    return 5 if items > 10
+    0
+  end
+end
+`,
+			after: `class Cart
+  def total(items)
+    return 5 if items < 10
+    0
+  end
+
+  def empty?
+    true
+  end
+end
+`,
+			added:     "Cart.empty?",
+			condition: true,
+			api:       true,
+		},
+		{
+			lang: "rs",
+			before: `impl Cart {
+    pub fn total(&self, items: u32) -> u32 {
+        if items > 10 { 5 } else { 0 }
+    }
+}
+`,
+			after: `impl Cart {
+    pub fn total(&self, items: u32) -> u32 {
+        if items > 20 { 5 } else { 0 }
+    }
+
+    pub fn empty(&self) -> bool {
+        true
+    }
+}
+`,
+			added:     "Cart.empty",
+			condition: true,
+			constant:  true,
+			api:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			d := NewDetector()
+			changes, err := d.DetectChanges("cart."+tt.lang, []byte(tt.before), []byte(tt.after), "file1", tt.lang)
+			if err != nil {
+				t.Fatalf("DetectChanges failed: %v", err)
+			}
+
+			if !hasSymbol(findCategory(changes, FunctionAdded), "name:"+tt.added) {
+				t.Errorf("expected FUNCTION_ADDED for %s, got %v", tt.added, changes)
+			}
+			if got := len(findCategory(changes, FunctionRemoved)); got != 0 {
+				t.Errorf("expected no FUNCTION_REMOVED, got %d", got)
+			}
+			if got := len(findCategory(changes, ConditionChanged)) > 0; got != tt.condition {
+				t.Errorf("CONDITION_CHANGED found = %v, expected %v", got, tt.condition)
+			}
+			if tt.constant && len(findCategory(changes, ConstantUpdated)) == 0 {
+				t.Error("expected CONSTANT_UPDATED")
+			}
+			if got := len(findCategory(changes, APISurfaceChanged)) > 0; got != tt.api {
+				t.Errorf("API_SURFACE_CHANGED found = %v, expected %v", got, tt.api)
+			}
+		})
+	}
+}
+
+func TestDetectChanges_SignatureChanged(t *testing.T) {
+	tests := []struct {
+		lang          string
+		before, after string
+	}{
+		{"go", "package a\n\nfunc run(n int) error { return nil }\n", "package a\n\nfunc run(n int) (int, error) { return 0, nil }\n"},
+		{"py", "def run(n):\n    pass\n", "def run(n, force=False):\n    pass\n"},
+		{"rb", "def run(n)\nend\n", "def run(n, force)\nend\n"},
+		{"rs", "fn run(n: u32) {}\n", "fn run(n: u32) -> bool { true }\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			d := NewDetector()
+			changes, err := d.DetectChanges("a."+tt.lang, []byte(tt.before), []byte(tt.after), "file1", tt.lang)
+			if err != nil {
+				t.Fatalf("DetectChanges failed: %v", err)
+			}
+			if len(findCategory(changes, APISurfaceChanged)) == 0 {
+				t.Errorf("expected API_SURFACE_CHANGED for the signature change, got %v", changes)
+			}
+		})
+	}
+}
+
+func TestExports(t *testing.T) {
+	tests := []struct {
+		lang     string
+		content  string
+		exported []string
+		hidden   []string
+	}{
+		{
+			lang: "go",
+			content: `package a
+
+import "fmt"
+
+type Cart struct{}
+type item struct{}
+
+const Max, min = 10, 1
+
+var (
+	Default = Cart{}
+	cache   map[string]int
+)
+
+func New() *Cart { return nil }
+func helper() {}
+func (c *Cart) Total() int { return 0 }
+func (c *Cart) recalc() {}
+`,
+			exported: []string{"Cart", "Max", "Default", "New", "Cart.Total"},
+			hidden:   []string{"item", "min", "cache", "helper", "Cart.recalc", "fmt"},
+		},
+		{
+			lang: "py",
+			content: `import os
+
+LIMIT = 10
+_cache = {}
+
+def run():
+    pass
+
+def _helper():
+    pass
+
+@decorator
+class Cart:
+    pass
+`,
+			exported: []string{"LIMIT", "run", "Cart"},
+			hidden:   []string{"_cache", "_helper", "os"},
+		},
+		{
+			lang: "py",
+			content: `__all__ = ["run"]
+
+def run():
+    pass
+
+def other():
+    pass
+`,
+			exported: []string{"run"},
+			hidden:   []string{"other"},
+		},
+		{
+			lang: "rb",
+			content: `class Cart
+  def total
+  end
+
+  def self.build
+  end
+
+  def apply
+  end
+  private :apply
+
+  private
+
+  def recalc
+  end
+end
+`,
+			exported: []string{"Cart.total", "Cart.self.build"},
+			hidden:   []string{"Cart.apply", "Cart.recalc"},
+		},
+		{
+			lang: "rs",
+			content: `pub struct Cart;
+struct Item;
+
+pub fn run() {}
+fn helper() {}
+
+impl Cart {
+    pub fn total(&self) -> u32 { 0 }
+    fn recalc(&self) {}
+}
+
+pub mod api {
+    pub fn get() {}
+    fn internal() {}
+}
+`,
+			exported: []string{"Cart", "run", "Cart.total", "api", "api.get"},
+			hidden:   []string{"Item", "helper", "Cart.recalc", "api.internal"},
+		},
+	}
+
+	parser := parse.NewParser()
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			content := []byte(tt.content)
+			parsed, err := parser.Parse(content, tt.lang)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			exports := specFor(tt.lang).exports(parsed.Tree.RootNode(), content)
+			for _, name := range tt.exported {
+				if _, ok := exports[name]; !ok {
+					t.Errorf("expected %q to be exported, got %v", name, exportNames(exports))
+				}
+			}
+			for _, name := range tt.hidden {
+				if _, ok := exports[name]; ok {
+					t.Errorf("expected %q not to be exported", name)
+				}
+			}
+		})
+	}
+}
+
+func TestGetAllFunctions_Qualified(t *testing.T) {
+	parser := parse.NewParser()
+	content := []byte(`module Shop
+  class Cart
+    def total
+    end
+  end
+end
+`)
+
+	parsed, err := parser.Parse(content, "rb")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	funcs := getAllFunctions(parsed, content, specFor("ruby"))
+	if _, ok := funcs["Shop.Cart.total"]; !ok {
+		t.Errorf("expected Shop.Cart.total, got %v", funcs)
+	}
+}
+
+func TestSpecFor(t *testing.T) {
+	tests := map[string]*langSpec{
+		"go":     goSpec,
+		"golang": goSpec,
+		"python": pySpec,
+		"rb":     rbSpec,
+		"rust":   rsSpec,
+		"ts":     jsSpec,
+		"md":     jsSpec,
+	}
+	for lang, expected := range tests {
+		if got := specFor(lang); got != expected {
+			t.Errorf("specFor(%q) returned the wrong table", lang)
+		}
+	}
+}
+
+func exportNames(exports map[string]*sitter.Node) []string {
+	var names []string
+	for name := range exports {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,679 @@
+// Package detect provides dependency manifest change detection.
+package detect
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dependency manifest changes
+const (
+	DependencyAdded    ChangeCategory = "DEPENDENCY_ADDED"
+	DependencyRemoved  ChangeCategory = "DEPENDENCY_REMOVED"
+	DependencyUpgraded ChangeCategory = "DEPENDENCY_UPGRADED" // any version change, downgrades included
+)
+
+// Dependency is a package a manifest or lockfile depends on.
+type Dependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"` // as written: "^1.2.0", "v0.3.1", ">=2.0,<3"
+	Scope   string `json:"scope,omitempty"`   // dev, peer, optional, build, indirect, or a Python extra
+}
+
+// Manifest is what a manifest or lockfile says about dependencies.
+type Manifest struct {
+	Ecosystem    string // go, npm, cargo or pypi
+	Lockfile     bool
+	Dependencies []Dependency
+	// Rest is the file's other content, normalized. When it differs between
+	// versions the change is more than a dependency update.
+	Rest string
+}
+
+// DependencyChange is a dependency added, removed or moved to another
+// version.
+type DependencyChange struct {
+	Category  ChangeCategory `json:"category"`
+	Name      string         `json:"name"`
+	Scope     string         `json:"scope,omitempty"`
+	From      string         `json:"from,omitempty"`
+	To        string         `json:"to,omitempty"`
+	Delta     string         `json:"delta,omitempty"` // major, minor, patch or prerelease
+	Downgrade bool           `json:"downgrade,omitempty"`
+}
+
+// ManifestEcosystem returns the package ecosystem of a dependency manifest
+// or lockfile, or "" for other files.
+func ManifestEcosystem(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	switch base {
+	case "go.mod", "go.sum":
+		return "go"
+	case "package.json", "package-lock.json", "npm-shrinkwrap.json", "yarn.lock":
+		return "npm"
+	case "cargo.toml", "cargo.lock":
+		return "cargo"
+	case "pyproject.toml", "poetry.lock":
+		return "pypi"
+	}
+	if strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt") {
+		return "pypi"
+	}
+	return ""
+}
+
+// ParseManifest reads the dependencies of a manifest or lockfile.
+func ParseManifest(path string, content []byte) (*Manifest, error) {
+	m := &Manifest{Ecosystem: ManifestEcosystem(path)}
+	var err error
+	switch base := strings.ToLower(filepath.Base(path)); {
+	case base == "go.mod":
+		err = parseGoMod(m, content)
+	case base == "go.sum":
+		m.Lockfile = true
+		parseGoSum(m, content)
+	case base == "package.json":
+		err = parsePackageJSON(m, content)
+	case base == "package-lock.json" || base == "npm-shrinkwrap.json":
+		m.Lockfile = true
+		err = parsePackageLock(m, content)
+	case base == "yarn.lock":
+		m.Lockfile = true
+		parseYarnLock(m, content)
+	case base == "cargo.toml":
+		err = parseCargoToml(m, content)
+	case base == "cargo.lock" || base == "poetry.lock":
+		m.Lockfile = true
+		err = parseTOMLLock(m, content)
+	case base == "pyproject.toml":
+		err = parsePyproject(m, content)
+	case m.Ecosystem == "pypi":
+		parseRequirements(m, content)
+	default:
+		return nil, fmt.Errorf("%s is not a dependency manifest", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	sort.Slice(m.Dependencies, func(i, j int) bool {
+		a, b := m.Dependencies[i], m.Dependencies[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Scope < b.Scope
+	})
+	return m, nil
+}
+
+// DiffManifests compares the dependencies of two versions of a manifest. A
+// dependency that moves between scopes with the same version is unchanged.
+func DiffManifests(before, after *Manifest) []DependencyChange {
+	index := func(m *Manifest) map[string]Dependency {
+		deps := make(map[string]Dependency)
+		if m == nil {
+			return deps
+		}
+		for _, d := range m.Dependencies {
+			if prev, ok := deps[d.Name]; ok && prev.Scope == "" {
+				continue // The main requirement wins over an extra or dev one
+			}
+			deps[d.Name] = d
+		}
+		return deps
+	}
+	beforeDeps, afterDeps := index(before), index(after)
+
+	var changes []DependencyChange
+	for name, b := range beforeDeps {
+		a, ok := afterDeps[name]
+		switch {
+		case !ok:
+			changes = append(changes, DependencyChange{Category: DependencyRemoved, Name: name, Scope: b.Scope, From: b.Version})
+		case a.Version != b.Version:
+			delta, downgrade := SemverDelta(b.Version, a.Version)
+			changes = append(changes, DependencyChange{
+				Category:  DependencyUpgraded,
+				Name:      name,
+				Scope:     a.Scope,
+				From:      b.Version,
+				To:        a.Version,
+				Delta:     delta,
+				Downgrade: downgrade,
+			})
+		}
+	}
+	for name, a := range afterDeps {
+		if _, ok := beforeDeps[name]; !ok {
+			changes = append(changes, DependencyChange{Category: DependencyAdded, Name: name, Scope: a.Scope, To: a.Version})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// DetectManifestChanges compares two versions of a dependency manifest. Each
+// change's symbols are the package name followed by "from:<version>",
+// "to:<version>", "delta:<major|minor|patch|prerelease>" and "downgrade" as
+// they apply.
+func DetectManifestChanges(path string, before, after []byte) ([]*ChangeType, error) {
+	beforeManifest, err := ParseManifest(path, before)
+	if err != nil {
+		return nil, err
+	}
+	afterManifest, err := ParseManifest(path, after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*ChangeType
+	for _, dc := range DiffManifests(beforeManifest, afterManifest) {
+		symbols := []string{dc.Name}
+		if dc.From != "" {
+			symbols = append(symbols, "from:"+dc.From)
+		}
+		if dc.To != "" {
+			symbols = append(symbols, "to:"+dc.To)
+		}
+		if dc.Delta != "" {
+			symbols = append(symbols, "delta:"+dc.Delta)
+		}
+		if dc.Downgrade {
+			symbols = append(symbols, "downgrade")
+		}
+		changes = append(changes, &ChangeType{
+			Category: dc.Category,
+			Evidence: Evidence{
+				FileRanges: []FileRange{{Path: path}},
+				Symbols:    symbols,
+			},
+		})
+	}
+	return changes, nil
+}
+
+// semverPattern finds the first version number in a version or requirement:
+// "^1.2.3", "v0.4.0-rc.1", ">=2.0,<3", "1.26.0rc1".
+var semverPattern = regexp.MustCompile(`(\d+)(?:\.(\d+))?(?:\.(\d+))?((?:[-+]|[a-zA-Z])[0-9A-Za-z.+-]*)?`)
+
+// SemverDelta returns the most significant part of a version that changed
+// between two versions: "major", "minor", "patch" or "prerelease". It is ""
+// when either side has no version number or only the range around it
+// changed. downgrade reports whether the new version is lower.
+func SemverDelta(from, to string) (delta string, downgrade bool) {
+	a, aok := parseSemver(from)
+	b, bok := parseSemver(to)
+	if !aok || !bok {
+		return "", false
+	}
+
+	parts := []string{"major", "minor", "patch"}
+	for i := range parts {
+		if a.nums[i] != b.nums[i] {
+			return parts[i], b.nums[i] < a.nums[i]
+		}
+	}
+	if a.pre != b.pre {
+		// A release is higher than its prereleases
+		switch {
+		case a.pre == "":
+			downgrade = true
+		case b.pre == "":
+			downgrade = false
+		default:
+			downgrade = b.pre < a.pre
+		}
+		return "prerelease", downgrade
+	}
+	return "", false
+}
+
+type semver struct {
+	nums [3]int
+	pre  string
+}
+
+func parseSemver(s string) (semver, bool) {
+	m := semverPattern.FindStringSubmatch(s)
+	if m == nil {
+		return semver{}, false
+	}
+	var v semver
+	for i := 0; i < 3; i++ {
+		v.nums[i], _ = strconv.Atoi(m[i+1])
+	}
+	// Build metadata doesn't order versions
+	v.pre, _, _ = strings.Cut(strings.TrimPrefix(m[4], "-"), "+")
+	return v, true
+}
+
+// parseGoMod reads require directives; // indirect marks indirect
+// dependencies. Other directives make up Rest.
+func parseGoMod(m *Manifest, content []byte) error {
+	var rest []string
+	inRequire := false
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		code, comment, _ := strings.Cut(line, "//")
+		code = strings.TrimSpace(code)
+
+		switch {
+		case inRequire && code == ")":
+			inRequire = false
+			continue
+		case strings.Join(strings.Fields(code), "") == "require(":
+			inRequire = true
+			continue
+		case inRequire:
+		case strings.HasPrefix(code, "require "):
+			code = strings.TrimSpace(strings.TrimPrefix(code, "require "))
+		default:
+			if code != "" {
+				rest = append(rest, strings.Join(strings.Fields(code), " "))
+			}
+			continue
+		}
+
+		fields := strings.Fields(code)
+		if len(fields) < 2 {
+			continue
+		}
+		dep := Dependency{Name: strings.Trim(fields[0], `"`), Version: fields[1]}
+		if strings.TrimSpace(comment) == "indirect" {
+			dep.Scope = "indirect"
+		}
+		m.Dependencies = append(m.Dependencies, dep)
+	}
+	m.Rest = strings.Join(rest, "\n")
+	return scanner.Err()
+}
+
+// parseGoSum reads the checksummed module versions, keeping the highest
+// version of each module.
+func parseGoSum(m *Manifest, content []byte) {
+	versions := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		version := strings.TrimSuffix(fields[1], "/go.mod")
+		if prev, ok := versions[fields[0]]; !ok || semverLess(prev, version) {
+			versions[fields[0]] = version
+		}
+	}
+	for name, version := range versions {
+		m.Dependencies = append(m.Dependencies, Dependency{Name: name, Version: version})
+	}
+}
+
+// semverLess reports whether version a is lower than b.
+func semverLess(a, b string) bool {
+	delta, downgrade := SemverDelta(b, a)
+	return delta != "" && downgrade
+}
+
+// packageJSONScopes maps package.json dependency fields to their scope.
+var packageJSONScopes = []struct{ field, scope string }{
+	{"dependencies", ""},
+	{"devDependencies", "dev"},
+	{"peerDependencies", "peer"},
+	{"optionalDependencies", "optional"},
+}
+
+func parsePackageJSON(m *Manifest, content []byte) error {
+	var data map[string]interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return err
+	}
+	for _, s := range packageJSONScopes {
+		deps, _ := data[s.field].(map[string]interface{})
+		for name, v := range deps {
+			version, _ := v.(string)
+			m.Dependencies = append(m.Dependencies, Dependency{Name: name, Version: version, Scope: s.scope})
+		}
+		delete(data, s.field)
+	}
+	rest, err := json.Marshal(data)
+	m.Rest = string(rest)
+	return err
+}
+
+// parsePackageLock reads the installed top-level packages of a
+// package-lock.json, from "packages" (lockfile v2 and v3) or "dependencies"
+// (v1).
+func parsePackageLock(m *Manifest, content []byte) error {
+	var data struct {
+		Packages map[string]struct {
+			Version string `json:"version"`
+			Dev     bool   `json:"dev"`
+		} `json:"packages"`
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+			Dev     bool   `json:"dev"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return err
+	}
+
+	add := func(name, version string, dev bool) {
+		dep := Dependency{Name: name, Version: version}
+		if dev {
+			dep.Scope = "dev"
+		}
+		m.Dependencies = append(m.Dependencies, dep)
+	}
+	if len(data.Packages) > 0 {
+		for key, p := range data.Packages {
+			name, ok := strings.CutPrefix(key, "node_modules/")
+			if !ok || strings.Contains(name, "/node_modules/") {
+				continue // The root package, or a nested copy
+			}
+			add(name, p.Version, p.Dev)
+		}
+		return nil
+	}
+	for name, p := range data.Dependencies {
+		add(name, p.Version, p.Dev)
+	}
+	return nil
+}
+
+// parseYarnLock reads yarn.lock entries, classic and berry. A package
+// resolved to several versions lists them all.
+func parseYarnLock(m *Manifest, content []byte) {
+	versions := make(map[string]map[string]bool)
+	var names []string
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if line[0] != ' ' {
+			// Entry header: "lodash@^4.17.0, lodash@^4.17.21:"
+			names = names[:0]
+			for _, spec := range strings.Split(strings.TrimSuffix(trimmed, ":"), ",") {
+				spec = strings.Trim(strings.TrimSpace(spec), `"`)
+				if at := strings.LastIndex(spec, "@"); at > 0 {
+					names = append(names, spec[:at])
+				}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimSuffix(trimmed, ":"), " ")
+		if !ok || strings.TrimSuffix(key, ":") != "version" || strings.HasPrefix(line, "    ") {
+			continue
+		}
+		version := strings.Trim(strings.TrimSpace(value), `"`)
+		for _, name := range names {
+			if name == "__metadata" {
+				continue
+			}
+			if versions[name] == nil {
+				versions[name] = make(map[string]bool)
+			}
+			versions[name][version] = true
+		}
+	}
+
+	for name, set := range versions {
+		var list []string
+		for v := range set {
+			list = append(list, v)
+		}
+		sort.Strings(list)
+		m.Dependencies = append(m.Dependencies, Dependency{Name: name, Version: strings.Join(list, ", ")})
+	}
+}
+
+// cargoScopes maps Cargo.toml dependency tables to their scope.
+var cargoScopes = map[string]string{
+	"dependencies":       "",
+	"dev-dependencies":   "dev",
+	"build-dependencies": "build",
+}
+
+// parseCargoToml reads [dependencies], [dev-dependencies] and
+// [build-dependencies], including platform-specific ones under [target] and
+// the [workspace.dependencies] a workspace shares.
+func parseCargoToml(m *Manifest, content []byte) error {
+	data, err := parseTOML(content)
+	if err != nil {
+		return err
+	}
+
+	take := func(table map[string]interface{}) {
+		for key, scope := range cargoScopes {
+			deps, _ := table[key].(map[string]interface{})
+			for name, v := range deps {
+				m.Dependencies = append(m.Dependencies, Dependency{Name: name, Version: cargoVersion(v), Scope: scope})
+			}
+			delete(table, key)
+		}
+	}
+	take(data)
+	if workspace, ok := data["workspace"].(map[string]interface{}); ok {
+		deps, _ := workspace["dependencies"].(map[string]interface{})
+		for name, v := range deps {
+			m.Dependencies = append(m.Dependencies, Dependency{Name: name, Version: cargoVersion(v)})
+		}
+		delete(workspace, "dependencies")
+	}
+	if targets, ok := data["target"].(map[string]interface{}); ok {
+		for _, t := range targets {
+			if table, ok := t.(map[string]interface{}); ok {
+				take(table)
+			}
+		}
+	}
+
+	rest, err := json.Marshal(data)
+	m.Rest = string(rest)
+	return err
+}
+
+// cargoVersion returns the version of a Cargo dependency, written as a
+// string or a table. Path and git dependencies without a version are named
+// by their source.
+func cargoVersion(v interface{}) string {
+	switch dep := v.(type) {
+	case string:
+		return dep
+	case map[string]interface{}:
+		if version, ok := dep["version"].(string); ok {
+			return version
+		}
+		for _, key := range []string{"rev", "tag", "branch"} {
+			if ref, ok := dep[key].(string); ok {
+				return key + ":" + ref
+			}
+		}
+		if p, ok := dep["path"].(string); ok {
+			return "path:" + p
+		}
+		if g, ok := dep["git"].(string); ok {
+			return "git:" + g
+		}
+	}
+	return ""
+}
+
+// parseTOMLLock reads the [[package]] entries of Cargo.lock and poetry.lock.
+// A package locked at several versions lists them all.
+func parseTOMLLock(m *Manifest, content []byte) error {
+	data, err := parseTOML(content)
+	if err != nil {
+		return err
+	}
+	packages, _ := data["package"].([]interface{})
+	versions := make(map[string][]string)
+	for _, p := range packages {
+		pkg, _ := p.(map[string]interface{})
+		name, _ := pkg["name"].(string)
+		version, _ := pkg["version"].(string)
+		if name == "" {
+			continue
+		}
+		if m.Ecosystem == "pypi" {
+			name = normalizePyName(name)
+		}
+		versions[name] = append(versions[name], version)
+	}
+	for name, list := range versions {
+		sort.Strings(list)
+		m.Dependencies = append(m.Dependencies, Dependency{Name: name, Version: strings.Join(list, ", ")})
+	}
+	return nil
+}
+
+// parsePyproject reads PEP 621 [project] dependencies and optional
+// dependencies, and Poetry's dependency tables.
+func parsePyproject(m *Manifest, content []byte) error {
+	data, err := parseTOML(content)
+	if err != nil {
+		return err
+	}
+
+	if project, ok := data["project"].(map[string]interface{}); ok {
+		reqs, _ := project["dependencies"].([]interface{})
+		for _, r := range reqs {
+			if s, ok := r.(string); ok {
+				m.addRequirement(s, "")
+			}
+		}
+		extras, _ := project["optional-dependencies"].(map[string]interface{})
+		for extra, list := range extras {
+			reqs, _ := list.([]interface{})
+			for _, r := range reqs {
+				if s, ok := r.(string); ok {
+					m.addRequirement(s, extra)
+				}
+			}
+		}
+		delete(project, "dependencies")
+		delete(project, "optional-dependencies")
+	}
+
+	tool, _ := data["tool"].(map[string]interface{})
+	if poetry, ok := tool["poetry"].(map[string]interface{}); ok {
+		take := func(table map[string]interface{}, key, scope string) {
+			deps, _ := table[key].(map[string]interface{})
+			for name, v := range deps {
+				if name == "python" {
+					continue // The interpreter, not a package
+				}
+				m.Dependencies = append(m.Dependencies, Dependency{Name: normalizePyName(name), Version: cargoVersion(v), Scope: scope})
+			}
+			delete(table, key)
+		}
+		take(poetry, "dependencies", "")
+		take(poetry, "dev-dependencies", "dev")
+		groups, _ := poetry["group"].(map[string]interface{})
+		for group, g := range groups {
+			if table, ok := g.(map[string]interface{}); ok {
+				take(table, "dependencies", group)
+			}
+		}
+	}
+
+	rest, err := json.Marshal(data)
+	m.Rest = string(rest)
+	return err
+}
+
+// parseRequirements reads a pip requirements file. Options such as -r and
+// --index-url make up Rest.
+func parseRequirements(m *Manifest, content []byte) {
+	var rest []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line, _, _ = strings.Cut(line, " #")
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "-"):
+			rest = append(rest, line)
+		default:
+			m.addRequirement(line, "")
+		}
+	}
+	m.Rest = strings.Join(rest, "\n")
+}
+
+// requirementName matches the distribution name at the start of a PEP 508
+// requirement.
+var requirementName = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?`)
+
+// addRequirement adds a PEP 508 requirement such as
+// "requests[socks]>=2.28,<3; python_version >= '3.8'".
+func (m *Manifest) addRequirement(req, scope string) {
+	match := requirementName.FindStringSubmatch(req)
+	if match == nil {
+		return // A URL or local path
+	}
+	version, _, _ := strings.Cut(req[len(match[0]):], ";")
+	m.Dependencies = append(m.Dependencies, Dependency{
+		Name:    normalizePyName(match[1]),
+		Version: strings.Join(strings.Fields(version), ""),
+		Scope:   scope,
+	})
+}
+
+// normalizePyName normalizes a Python distribution name as PEP 503 does:
+// "Flask_SQLAlchemy" and "flask-sqlalchemy" are the same package.
+func normalizePyName(name string) string {
+	return strings.ToLower(pyNameSeparators.ReplaceAllString(name, "-"))
+}
+
+var pyNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// pyImportNames maps distributions to the module they install when the two
+// names differ beyond separators and case.
+var pyImportNames = map[string]string{
+	"beautifulsoup4":  "bs4",
+	"pillow":          "PIL",
+	"pyyaml":          "yaml",
+	"python-dateutil": "dateutil",
+	"scikit-learn":    "sklearn",
+	"opencv-python":   "cv2",
+	"protobuf":        "google.protobuf",
+	"pyjwt":           "jwt",
+	"attrs":           "attr",
+}
+
+// DependencyImported reports whether a source file imports a package of an
+// ecosystem: a Go package under the module path, an npm package or one of
+// its subpaths, a Rust crate, or a Python distribution's module. Matching
+// is textual, so it also works for files the parser can't read.
+func DependencyImported(ecosystem, name string, content []byte) bool {
+	var pattern string
+	switch ecosystem {
+	case "go":
+		pattern = `"` + regexp.QuoteMeta(name) + `(/[^"]*)?"`
+	case "npm":
+		pattern = `(\bfrom\s*|\brequire\s*\(\s*|\bimport\s*\(\s*|\bimport\s+)['"]` + regexp.QuoteMeta(name) + `(/[^'"]*)?['"]`
+	case "cargo":
+		crate := regexp.QuoteMeta(strings.ReplaceAll(name, "-", "_"))
+		pattern = `\b(use\s+(::)?|extern\s+crate\s+)` + crate + `\b|(^|[^\w:])` + crate + `::`
+	case "pypi":
+		module, ok := pyImportNames[normalizePyName(name)]
+		if !ok {
+			module = strings.ReplaceAll(normalizePyName(name), "-", "_")
+		}
+		module = regexp.QuoteMeta(module)
+		pattern = `(?m)^\s*(from\s+` + module + `(\.[\w.]+)?\s+import\b|import\s+([\w.]+(\s+as\s+\w+)?\s*,\s*)*` + module + `(\.|\s|,|$))`
+	default:
+		return false
+	}
+	return regexp.MustCompile(pattern).Match(content)
+}
@@ -0,0 +1,347 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifestEcosystem(t *testing.T) {
+	tests := map[string]string{
+		"go.mod":                  "go",
+		"svc/go.sum":              "go",
+		"web/package.json":        "npm",
+		"package-lock.json":       "npm",
+		"yarn.lock":               "npm",
+		"Cargo.toml":              "cargo",
+		"crates/x/Cargo.lock":     "cargo",
+		"requirements.txt":        "pypi",
+		"requirements-dev.txt":    "pypi",
+		"pyproject.toml":          "pypi",
+		"poetry.lock":             "pypi",
+		"tsconfig.json":           "",
+		"src/requirements.py":     "",
+		"docs/package.json.md":    "",
+		"config/settings.toml":    "",
+		"deploy/requirements.yml": "",
+	}
+	for path, want := range tests {
+		if got := ManifestEcosystem(path); got != want {
+			t.Errorf("ManifestEcosystem(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		path    string
+		content string
+		want    []Dependency
+	}{
+		{
+			path: "go.mod",
+			content: `module example.com/app
+
+go 1.22
+
+require github.com/spf13/cobra v1.8.0
+
+require (
+	golang.org/x/sync v0.6.0
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace example.com/old => ../old
+`,
+			want: []Dependency{
+				{Name: "github.com/spf13/cobra", Version: "v1.8.0"},
+				{Name: "golang.org/x/sync", Version: "v0.6.0"},
+				{Name: "gopkg.in/yaml.v3", Version: "v3.0.1", Scope: "indirect"},
+			},
+		},
+		{
+			path: "go.sum",
+			content: `golang.org/x/sync v0.5.0 h1:aaa=
+golang.org/x/sync v0.6.0 h1:bbb=
+golang.org/x/sync v0.6.0/go.mod h1:ccc=
+`,
+			want: []Dependency{{Name: "golang.org/x/sync", Version: "v0.6.0"}},
+		},
+		{
+			path: "package.json",
+			content: `{
+  "name": "app",
+  "dependencies": {"lodash": "^4.17.21", "@babel/core": "7.24.0"},
+  "devDependencies": {"jest": "^29.0.0"},
+  "peerDependencies": {"react": ">=18"}
+}`,
+			want: []Dependency{
+				{Name: "@babel/core", Version: "7.24.0"},
+				{Name: "jest", Version: "^29.0.0", Scope: "dev"},
+				{Name: "lodash", Version: "^4.17.21"},
+				{Name: "react", Version: ">=18", Scope: "peer"},
+			},
+		},
+		{
+			path: "package-lock.json",
+			content: `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app"},
+    "node_modules/lodash": {"version": "4.17.21"},
+    "node_modules/jest": {"version": "29.7.0", "dev": true},
+    "node_modules/jest/node_modules/chalk": {"version": "4.1.2"}
+  }
+}`,
+			want: []Dependency{
+				{Name: "jest", Version: "29.7.0", Scope: "dev"},
+				{Name: "lodash", Version: "4.17.21"},
+			},
+		},
+		{
+			path: "yarn.lock",
+			content: `# yarn lockfile v1
+
+"@babel/core@^7.0.0", "@babel/core@^7.24.0":
+  version "7.24.0"
+  resolved "https://registry.yarnpkg.com/@babel/core/-/core-7.24.0.tgz"
+  dependencies:
+    debug "^4.1.0"
+
+lodash@^4.17.21:
+  version "4.17.21"
+`,
+			want: []Dependency{
+				{Name: "@babel/core", Version: "7.24.0"},
+				{Name: "lodash", Version: "4.17.21"},
+			},
+		},
+		{
+			path: "Cargo.toml",
+			content: `[package]
+name = "app"
+version = "0.1.0"
+
+[dependencies]
+serde = { version = "1.0", features = ["derive"] }
+tokio = "1.36"
+local = { path = "../local" }
+
+[dev-dependencies]
+criterion = "0.5"
+
+[target.'cfg(unix)'.build-dependencies]
+cc = "1.0"
+`,
+			want: []Dependency{
+				{Name: "cc", Version: "1.0", Scope: "build"},
+				{Name: "criterion", Version: "0.5", Scope: "dev"},
+				{Name: "local", Version: "path:../local"},
+				{Name: "serde", Version: "1.0"},
+				{Name: "tokio", Version: "1.36"},
+			},
+		},
+		{
+			path: "Cargo.lock",
+			content: `version = 3
+
+[[package]]
+name = "serde"
+version = "1.0.197"
+
+[[package]]
+name = "syn"
+version = "1.0.109"
+
+[[package]]
+name = "syn"
+version = "2.0.52"
+`,
+			want: []Dependency{
+				{Name: "serde", Version: "1.0.197"},
+				{Name: "syn", Version: "1.0.109, 2.0.52"},
+			},
+		},
+		{
+			path: "requirements-dev.txt",
+			content: `# tools
+-r requirements.txt
+Flask_SQLAlchemy==3.1.1
+requests[socks] >= 2.28, < 3 ; python_version >= "3.8"
+pytest  # unpinned
+`,
+			want: []Dependency{
+				{Name: "flask-sqlalchemy", Version: "==3.1.1"},
+				{Name: "pytest"},
+				{Name: "requests", Version: ">=2.28,<3"},
+			},
+		},
+		{
+			path: "pyproject.toml",
+			content: `[project]
+name = "app"
+dependencies = ["httpx>=0.27", "PyYAML==6.0.1"]
+
+[project.optional-dependencies]
+test = ["pytest>=8"]
+
+[tool.poetry.dependencies]
+python = "^3.11"
+rich = "^13.7"
+
+[tool.poetry.group.docs.dependencies]
+mkdocs = { version = "^1.5" }
+`,
+			want: []Dependency{
+				{Name: "httpx", Version: ">=0.27"},
+				{Name: "mkdocs", Version: "^1.5", Scope: "docs"},
+				{Name: "pytest", Version: ">=8", Scope: "test"},
+				{Name: "pyyaml", Version: "==6.0.1"},
+				{Name: "rich", Version: "^13.7"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			m, err := ParseManifest(tt.path, []byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseManifest failed: %v", err)
+			}
+			if !reflect.DeepEqual(m.Dependencies, tt.want) {
+				t.Errorf("dependencies =\n%+v\nwant\n%+v", m.Dependencies, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseManifest_Rest(t *testing.T) {
+	parse := func(path, content string) string {
+		m, err := ParseManifest(path, []byte(content))
+		if err != nil {
+			t.Fatalf("ParseManifest(%s) failed: %v", path, err)
+		}
+		return m.Rest
+	}
+
+	// Dependency edits leave the rest alone
+	if parse("package.json", `{"name": "app", "dependencies": {"a": "1"}}`) !=
+		parse("package.json", `{"dependencies": {"a": "2", "b": "1"}, "name": "app"}`) {
+		t.Error("package.json rest changed with dependencies only")
+	}
+	if parse("go.mod", "module m\n\nrequire a v1.0.0\n") != parse("go.mod", "module m\nrequire (\n\ta v1.1.0\n)\n") {
+		t.Error("go.mod rest changed with requirements only")
+	}
+
+	// Other edits change it
+	if parse("package.json", `{"scripts": {"test": "jest"}}`) == parse("package.json", `{"scripts": {"test": "vitest"}}`) {
+		t.Error("package.json rest unchanged after a script edit")
+	}
+	if parse("go.mod", "module m\ngo 1.21\n") == parse("go.mod", "module m\ngo 1.22\n") {
+		t.Error("go.mod rest unchanged after a go version edit")
+	}
+	if parse("Cargo.toml", "[package]\nedition = \"2018\"\n") == parse("Cargo.toml", "[package]\nedition = \"2021\"\n") {
+		t.Error("Cargo.toml rest unchanged after an edition edit")
+	}
+}
+
+func TestDetectManifestChanges(t *testing.T) {
+	before := []byte(`{
+  "dependencies": {"lodash": "^4.17.20", "express": "^4.18.0", "left-pad": "1.3.0"},
+  "devDependencies": {"typescript": "5.4.0-beta"}
+}`)
+	after := []byte(`{
+  "dependencies": {"lodash": "^4.17.21", "express": "^5.0.0", "zod": "^3.22.0"},
+  "devDependencies": {"typescript": "5.4.0"}
+}`)
+
+	changes, err := DetectManifestChanges("package.json", before, after)
+	if err != nil {
+		t.Fatalf("DetectManifestChanges failed: %v", err)
+	}
+
+	got := make(map[string]*ChangeType)
+	for _, c := range changes {
+		got[c.Evidence.Symbols[0]] = c
+	}
+	want := map[string]struct {
+		category ChangeCategory
+		symbols  []string
+	}{
+		"express":    {DependencyUpgraded, []string{"express", "from:^4.18.0", "to:^5.0.0", "delta:major"}},
+		"left-pad":   {DependencyRemoved, []string{"left-pad", "from:1.3.0"}},
+		"lodash":     {DependencyUpgraded, []string{"lodash", "from:^4.17.20", "to:^4.17.21", "delta:patch"}},
+		"typescript": {DependencyUpgraded, []string{"typescript", "from:5.4.0-beta", "to:5.4.0", "delta:prerelease"}},
+		"zod":        {DependencyAdded, []string{"zod", "to:^3.22.0"}},
+	}
+	if len(changes) != len(want) {
+		t.Errorf("got %d changes, want %d", len(changes), len(want))
+	}
+	for name, w := range want {
+		c, ok := got[name]
+		if !ok {
+			t.Errorf("no change for %s", name)
+			continue
+		}
+		if c.Category != w.category || !reflect.DeepEqual(c.Evidence.Symbols, w.symbols) {
+			t.Errorf("%s: got %s %v, want %s %v", name, c.Category, c.Evidence.Symbols, w.category, w.symbols)
+		}
+	}
+
+	if _, err := DetectManifestChanges("package.json", []byte("{"), after); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestSemverDelta(t *testing.T) {
+	tests := []struct {
+		from, to  string
+		delta     string
+		downgrade bool
+	}{
+		{"1.2.3", "2.0.0", "major", false},
+		{"v1.2.3", "v1.3.0", "minor", false},
+		{"^1.2.3", "^1.2.4", "patch", false},
+		{"1.2.4", "1.2.3", "patch", true},
+		{">=2.0,<3", ">=2.1,<3", "minor", false},
+		{"1.0.0-rc.1", "1.0.0", "prerelease", false},
+		{"1.0.0", "1.0.0-rc.1", "prerelease", true},
+		{"1.26.0rc1", "1.26.0rc2", "prerelease", false},
+		{"v0.0.0-20240101000000-abcdef", "v0.1.0", "minor", false},
+		{"1.2", "1.2.0", "", false},
+		{"^1.2.3", "~1.2.3", "", false},
+		{"path:../a", "1.0.0", "", false},
+	}
+	for _, tt := range tests {
+		delta, downgrade := SemverDelta(tt.from, tt.to)
+		if delta != tt.delta || downgrade != tt.downgrade {
+			t.Errorf("SemverDelta(%q, %q) = %q, %v; want %q, %v", tt.from, tt.to, delta, downgrade, tt.delta, tt.downgrade)
+		}
+	}
+}
+
+func TestDependencyImported(t *testing.T) {
+	tests := []struct {
+		ecosystem, name, content string
+		want                     bool
+	}{
+		{"go", "github.com/spf13/cobra", "import (\n\t\"github.com/spf13/cobra\"\n)", true},
+		{"go", "golang.org/x/sync", "import \"golang.org/x/sync/errgroup\"", true},
+		{"go", "golang.org/x/sync", "import \"golang.org/x/syncer\"", false},
+		{"npm", "lodash", "import debounce from 'lodash/debounce';", true},
+		{"npm", "@babel/core", "const babel = require(\"@babel/core\");", true},
+		{"npm", "react", "const x = await import('react-dom');", false},
+		{"cargo", "serde-json", "use serde_json::Value;", true},
+		{"cargo", "tokio", "#[tokio::main]\nasync fn main() {}", true},
+		{"cargo", "rand", "use crate::random::rand_u32;", false},
+		{"pypi", "requests", "import os, requests\n", true},
+		{"pypi", "PyYAML", "from yaml import safe_load\n", true},
+		{"pypi", "Flask-SQLAlchemy", "from flask_sqlalchemy import SQLAlchemy\n", true},
+		{"pypi", "flask", "from flask_sqlalchemy import SQLAlchemy\n", false},
+		{"unknown", "x", "import x", false},
+	}
+	for _, tt := range tests {
+		if got := DependencyImported(tt.ecosystem, tt.name, []byte(tt.content)); got != tt.want {
+			t.Errorf("DependencyImported(%s, %s, %q) = %v, want %v", tt.ecosystem, tt.name, tt.content, got, tt.want)
+		}
+	}
+}
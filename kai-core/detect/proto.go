@@ -0,0 +1,758 @@
+package detect
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Protobuf change categories. Changes that break existing clients on the
+// wire or in generated code are flagged Breaking on the ProtoChange.
+const (
+	ProtoPackageChanged     ChangeCategory = "PROTO_PACKAGE_CHANGED"
+	ProtoMessageAdded       ChangeCategory = "PROTO_MESSAGE_ADDED"
+	ProtoMessageRemoved     ChangeCategory = "PROTO_MESSAGE_REMOVED"
+	ProtoFieldAdded         ChangeCategory = "PROTO_FIELD_ADDED"
+	ProtoFieldRemoved       ChangeCategory = "PROTO_FIELD_REMOVED"
+	ProtoFieldRenamed       ChangeCategory = "PROTO_FIELD_RENAMED"
+	ProtoFieldNumberChanged ChangeCategory = "PROTO_FIELD_NUMBER_CHANGED"
+	ProtoFieldNumberReused  ChangeCategory = "PROTO_FIELD_NUMBER_REUSED"
+	ProtoFieldTypeChanged   ChangeCategory = "PROTO_FIELD_TYPE_CHANGED"
+	ProtoFieldLabelChanged  ChangeCategory = "PROTO_FIELD_LABEL_CHANGED"
+	ProtoEnumAdded          ChangeCategory = "PROTO_ENUM_ADDED"
+	ProtoEnumRemoved        ChangeCategory = "PROTO_ENUM_REMOVED"
+	ProtoEnumValueAdded     ChangeCategory = "PROTO_ENUM_VALUE_ADDED"
+	ProtoEnumValueRemoved   ChangeCategory = "PROTO_ENUM_VALUE_REMOVED"
+	ProtoEnumValueChanged   ChangeCategory = "PROTO_ENUM_VALUE_NUMBER_CHANGED"
+	ProtoServiceAdded       ChangeCategory = "PROTO_SERVICE_ADDED"
+	ProtoServiceRemoved     ChangeCategory = "PROTO_SERVICE_REMOVED"
+	ProtoRPCAdded           ChangeCategory = "PROTO_RPC_ADDED"
+	ProtoRPCRemoved         ChangeCategory = "PROTO_RPC_REMOVED"
+	ProtoRPCChanged         ChangeCategory = "PROTO_RPC_CHANGED"
+)
+
+// protoMaxField is the largest field number, which "max" stands for in
+// reserved ranges.
+const protoMaxField = 536870911
+
+// ProtoFile is the schema a .proto file declares. Nested messages and enums
+// are keyed by their dotted name within the file, e.g. "User.Address".
+type ProtoFile struct {
+	Package  string
+	Messages map[string]*ProtoMessage
+	Enums    map[string]*ProtoEnum
+	Services map[string]*ProtoService
+}
+
+// ProtoMessage is a message with its fields by name.
+type ProtoMessage struct {
+	Name          string
+	Fields        map[string]*ProtoField
+	Reserved      [][2]int // Reserved number ranges, inclusive
+	ReservedNames []string
+}
+
+// ProtoField is a message field. Map fields have Type "map<K, V>".
+type ProtoField struct {
+	Name    string
+	Type    string
+	Label   string // "", "optional", "repeated" or "required"
+	Number  int
+	Oneof   string
+	Options string // Field options, e.g. `json_name = "n"`
+}
+
+// ProtoEnum is an enum with its values by name.
+type ProtoEnum struct {
+	Name         string
+	Values       map[string]int
+	ValueOptions map[string]string // Options of values that have them
+	Reserved     [][2]int
+}
+
+// ProtoService is a service with its RPCs by name.
+type ProtoService struct {
+	Name string
+	RPCs map[string]*ProtoRPC
+}
+
+// ProtoRPC is a service method.
+type ProtoRPC struct {
+	Name            string
+	Request         string
+	Response        string
+	ClientStreaming bool
+	ServerStreaming bool
+}
+
+// ProtoChange is a schema change between two versions of a .proto file.
+type ProtoChange struct {
+	Category ChangeCategory
+	Name     string // Element changed, e.g. "User.email", "Users.GetUser", "Status.ACTIVE"
+	Before   string // Declaration before, e.g. "string email = 2"
+	After    string
+	Breaking bool
+}
+
+// String returns the field's declaration, e.g. "repeated string tags = 3".
+func (f *ProtoField) String() string {
+	decl := f.Type + " " + f.Name + " = " + strconv.Itoa(f.Number)
+	if f.Label != "" {
+		decl = f.Label + " " + decl
+	}
+	return decl
+}
+
+// String returns the RPC's signature.
+func (r *ProtoRPC) String() string {
+	req, resp := r.Request, r.Response
+	if r.ClientStreaming {
+		req = "stream " + req
+	}
+	if r.ServerStreaming {
+		resp = "stream " + resp
+	}
+	return fmt.Sprintf("rpc %s(%s) returns (%s)", r.Name, req, resp)
+}
+
+func (m *ProtoMessage) reserves(number int) bool {
+	return inProtoRanges(m.Reserved, number)
+}
+
+func inProtoRanges(ranges [][2]int, n int) bool {
+	for _, r := range ranges {
+		if n >= r[0] && n <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseProto reads the messages, enums and services of a .proto file.
+// Field and enum value options are kept; other options, imports and
+// extensions are skipped.
+func ParseProto(content []byte) (*ProtoFile, error) {
+	tokens, err := tokenizeProto(string(content))
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{tokens: tokens}
+	f := &ProtoFile{
+		Messages: make(map[string]*ProtoMessage),
+		Enums:    make(map[string]*ProtoEnum),
+		Services: make(map[string]*ProtoService),
+	}
+
+	for !p.eof() {
+		switch tok := p.next(); tok {
+		case "package":
+			f.Package = p.next()
+			p.skipStatement()
+		case "message":
+			if err := p.message(f, ""); err != nil {
+				return nil, err
+			}
+		case "enum":
+			if err := p.enum(f, ""); err != nil {
+				return nil, err
+			}
+		case "service":
+			if err := p.service(f); err != nil {
+				return nil, err
+			}
+		case ";":
+		default:
+			// syntax, edition, import, option and extend
+			p.skipStatement()
+		}
+	}
+
+	// Types are compared without the file's own package
+	if f.Package != "" {
+		local := func(typ string) string {
+			return strings.TrimPrefix(strings.TrimPrefix(typ, "."), f.Package+".")
+		}
+		for _, m := range f.Messages {
+			for _, field := range m.Fields {
+				if key, value, ok := strings.Cut(field.Type, ", "); ok {
+					field.Type = key + ", " + local(value)
+				} else {
+					field.Type = local(field.Type)
+				}
+			}
+		}
+		for _, s := range f.Services {
+			for _, r := range s.RPCs {
+				r.Request = local(r.Request)
+				r.Response = local(r.Response)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// tokenizeProto splits a .proto file into identifiers, numbers, strings and
+// punctuation, dropping comments.
+func tokenizeProto(s string) ([]string, error) {
+	var tokens []string
+	line := 1
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(s[i:i+2+end], "\n")
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' {
+					j++
+				}
+				if j < len(s) && s[j] == '\n' {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			tokens = append(tokens, s[i:j+1])
+			i = j + 1
+		case isProtoIdentChar(c) || c == '.' || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i + 1
+			for j < len(s) && (isProtoIdentChar(s[j]) || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			tokens = append(tokens, s[i:i+1])
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isProtoIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+type protoParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *protoParser) eof() bool { return p.pos >= len(p.tokens) }
+
+func (p *protoParser) peek() string {
+	if p.eof() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *protoParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		return fmt.Errorf("expected %q, got %q", tok, got)
+	}
+	return nil
+}
+
+// skipStatement skips to the end of a statement or of the block it opens.
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for !p.eof() {
+		switch p.next() {
+		case ";":
+			if depth == 0 {
+				return
+			}
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth <= 0 {
+				return
+			}
+		}
+	}
+}
+
+// options reads a [ ... ] field option list, returning its tokens joined
+// by single spaces, or "" if there is none.
+func (p *protoParser) options() string {
+	if p.peek() != "[" {
+		return ""
+	}
+	p.next()
+	var toks []string
+	for !p.eof() {
+		tok := p.next()
+		if tok == "]" {
+			break
+		}
+		toks = append(toks, tok)
+	}
+	return strings.Join(toks, " ")
+}
+
+func (p *protoParser) message(f *ProtoFile, prefix string) error {
+	m := &ProtoMessage{Name: prefix + p.next(), Fields: make(map[string]*ProtoField)}
+	f.Messages[m.Name] = m
+	if err := p.expect("{"); err != nil {
+		return fmt.Errorf("message %s: %w", m.Name, err)
+	}
+	return p.messageBody(f, m, "")
+}
+
+func (p *protoParser) messageBody(f *ProtoFile, m *ProtoMessage, oneof string) error {
+	for !p.eof() {
+		switch tok := p.peek(); tok {
+		case "}":
+			p.next()
+			return nil
+		case ";":
+			p.next()
+		case "message":
+			p.next()
+			if err := p.message(f, m.Name+"."); err != nil {
+				return err
+			}
+		case "enum":
+			p.next()
+			if err := p.enum(f, m.Name+"."); err != nil {
+				return err
+			}
+		case "oneof":
+			p.next()
+			name := p.next()
+			if err := p.expect("{"); err != nil {
+				return fmt.Errorf("oneof %s.%s: %w", m.Name, name, err)
+			}
+			if err := p.messageBody(f, m, name); err != nil {
+				return err
+			}
+		case "reserved":
+			p.next()
+			ranges, names := p.reserved()
+			m.Reserved = append(m.Reserved, ranges...)
+			m.ReservedNames = append(m.ReservedNames, names...)
+		case "option", "extensions", "extend":
+			p.skipStatement()
+		default:
+			field, err := p.field()
+			if err != nil {
+				return fmt.Errorf("message %s: %w", m.Name, err)
+			}
+			field.Oneof = oneof
+			m.Fields[field.Name] = field
+		}
+	}
+	return fmt.Errorf("message %s: unclosed body", m.Name)
+}
+
+// field reads "[label] type name = number [options];" or a map field.
+func (p *protoParser) field() (*ProtoField, error) {
+	field := &ProtoField{}
+	switch p.peek() {
+	case "optional", "repeated", "required":
+		field.Label = p.next()
+	}
+
+	field.Type = p.next()
+	if field.Type == "map" && p.peek() == "<" {
+		p.next()
+		key := p.next()
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		value := p.next()
+		if err := p.expect(">"); err != nil {
+			return nil, err
+		}
+		field.Type = "map<" + key + ", " + value + ">"
+	}
+
+	field.Name = p.next()
+	if err := p.expect("="); err != nil {
+		return nil, fmt.Errorf("field %s: %w", field.Name, err)
+	}
+	number, err := strconv.Atoi(p.next())
+	if err != nil {
+		return nil, fmt.Errorf("field %s: invalid number", field.Name)
+	}
+	field.Number = number
+	field.Options = p.options()
+	if err := p.expect(";"); err != nil {
+		return nil, fmt.Errorf("field %s: %w", field.Name, err)
+	}
+	return field, nil
+}
+
+// reserved reads the number ranges and names of a reserved statement.
+func (p *protoParser) reserved() ([][2]int, []string) {
+	var ranges [][2]int
+	var names []string
+	for !p.eof() {
+		tok := p.next()
+		switch {
+		case tok == ";":
+			return ranges, names
+		case tok == ",":
+		case strings.HasPrefix(tok, `"`) || strings.HasPrefix(tok, "'"):
+			names = append(names, strings.Trim(tok, `"'`))
+		default:
+			n, err := strconv.Atoi(tok)
+			if err != nil {
+				// An edition-style bare name
+				names = append(names, tok)
+				continue
+			}
+			r := [2]int{n, n}
+			if p.peek() == "to" {
+				p.next()
+				if end := p.next(); end == "max" {
+					r[1] = protoMaxField
+				} else if e, err := strconv.Atoi(end); err == nil {
+					r[1] = e
+				}
+			}
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges, names
+}
+
+func (p *protoParser) enum(f *ProtoFile, prefix string) error {
+	e := &ProtoEnum{Name: prefix + p.next(), Values: make(map[string]int), ValueOptions: make(map[string]string)}
+	f.Enums[e.Name] = e
+	if err := p.expect("{"); err != nil {
+		return fmt.Errorf("enum %s: %w", e.Name, err)
+	}
+	for !p.eof() {
+		switch tok := p.next(); tok {
+		case "}":
+			return nil
+		case ";":
+		case "option":
+			p.skipStatement()
+		case "reserved":
+			ranges, _ := p.reserved()
+			e.Reserved = append(e.Reserved, ranges...)
+		default:
+			if err := p.expect("="); err != nil {
+				return fmt.Errorf("enum %s: %w", e.Name, err)
+			}
+			n, err := strconv.Atoi(p.next())
+			if err != nil {
+				return fmt.Errorf("enum %s: invalid number for %s", e.Name, tok)
+			}
+			e.Values[tok] = n
+			if opts := p.options(); opts != "" {
+				e.ValueOptions[tok] = opts
+			}
+			if err := p.expect(";"); err != nil {
+				return fmt.Errorf("enum %s: %w", e.Name, err)
+			}
+		}
+	}
+	return fmt.Errorf("enum %s: unclosed body", e.Name)
+}
+
+func (p *protoParser) service(f *ProtoFile) error {
+	s := &ProtoService{Name: p.next(), RPCs: make(map[string]*ProtoRPC)}
+	f.Services[s.Name] = s
+	if err := p.expect("{"); err != nil {
+		return fmt.Errorf("service %s: %w", s.Name, err)
+	}
+	for !p.eof() {
+		switch tok := p.next(); tok {
+		case "}":
+			return nil
+		case ";":
+		case "rpc":
+			r := &ProtoRPC{Name: p.next()}
+			var err error
+			if r.Request, r.ClientStreaming, err = p.rpcType(); err != nil {
+				return fmt.Errorf("rpc %s.%s: %w", s.Name, r.Name, err)
+			}
+			if err := p.expect("returns"); err != nil {
+				return fmt.Errorf("rpc %s.%s: %w", s.Name, r.Name, err)
+			}
+			if r.Response, r.ServerStreaming, err = p.rpcType(); err != nil {
+				return fmt.Errorf("rpc %s.%s: %w", s.Name, r.Name, err)
+			}
+			if p.peek() == "{" {
+				p.skipStatement()
+			} else if err := p.expect(";"); err != nil {
+				return fmt.Errorf("rpc %s.%s: %w", s.Name, r.Name, err)
+			}
+			s.RPCs[r.Name] = r
+		default:
+			p.skipStatement()
+		}
+	}
+	return fmt.Errorf("service %s: unclosed body", s.Name)
+}
+
+// rpcType reads "(stream Type)".
+func (p *protoParser) rpcType() (string, bool, error) {
+	if err := p.expect("("); err != nil {
+		return "", false, err
+	}
+	stream := false
+	if p.peek() == "stream" && p.tokens[min(p.pos+1, len(p.tokens)-1)] != ")" {
+		p.next()
+		stream = true
+	}
+	typ := p.next()
+	if err := p.expect(")"); err != nil {
+		return "", false, err
+	}
+	return typ, stream, nil
+}
+
+// DiffProto compares two versions of a .proto file. Fields are matched by
+// name and then by number, so reusing the number of a removed field for a
+// different one is caught.
+func DiffProto(before, after *ProtoFile) []ProtoChange {
+	var changes []ProtoChange
+	add := func(c ProtoChange) { changes = append(changes, c) }
+
+	if before.Package != after.Package {
+		add(ProtoChange{Category: ProtoPackageChanged, Name: after.Package, Before: before.Package, After: after.Package, Breaking: true})
+	}
+
+	for _, name := range sortedProtoKeys(after.Messages) {
+		m := after.Messages[name]
+		old, ok := before.Messages[name]
+		if !ok {
+			add(ProtoChange{Category: ProtoMessageAdded, Name: name})
+			continue
+		}
+		changes = append(changes, diffProtoFields(old, m)...)
+	}
+	for _, name := range sortedProtoKeys(before.Messages) {
+		if _, ok := after.Messages[name]; !ok {
+			add(ProtoChange{Category: ProtoMessageRemoved, Name: name, Breaking: true})
+		}
+	}
+
+	for _, name := range sortedProtoKeys(after.Enums) {
+		e := after.Enums[name]
+		old, ok := before.Enums[name]
+		if !ok {
+			add(ProtoChange{Category: ProtoEnumAdded, Name: name})
+			continue
+		}
+		for _, v := range sortedProtoKeys(e.Values) {
+			decl := v + " = " + strconv.Itoa(e.Values[v])
+			n, existed := old.Values[v]
+			switch {
+			case !existed:
+				add(ProtoChange{Category: ProtoEnumValueAdded, Name: name + "." + v, After: decl, Breaking: inProtoRanges(old.Reserved, e.Values[v])})
+			case n != e.Values[v]:
+				add(ProtoChange{Category: ProtoEnumValueChanged, Name: name + "." + v, Before: v + " = " + strconv.Itoa(n), After: decl, Breaking: true})
+			}
+		}
+		for _, v := range sortedProtoKeys(old.Values) {
+			if _, ok := e.Values[v]; !ok {
+				add(ProtoChange{Category: ProtoEnumValueRemoved, Name: name + "." + v, Before: v + " = " + strconv.Itoa(old.Values[v]), Breaking: true})
+			}
+		}
+	}
+	for _, name := range sortedProtoKeys(before.Enums) {
+		if _, ok := after.Enums[name]; !ok {
+			add(ProtoChange{Category: ProtoEnumRemoved, Name: name, Breaking: true})
+		}
+	}
+
+	for _, name := range sortedProtoKeys(after.Services) {
+		s := after.Services[name]
+		old, ok := before.Services[name]
+		if !ok {
+			add(ProtoChange{Category: ProtoServiceAdded, Name: name})
+			continue
+		}
+		for _, rpc := range sortedProtoKeys(s.RPCs) {
+			r := s.RPCs[rpc]
+			oldRPC, existed := old.RPCs[rpc]
+			switch {
+			case !existed:
+				add(ProtoChange{Category: ProtoRPCAdded, Name: name + "." + rpc, After: r.String()})
+			case *oldRPC != *r:
+				add(ProtoChange{Category: ProtoRPCChanged, Name: name + "." + rpc, Before: oldRPC.String(), After: r.String(), Breaking: true})
+			}
+		}
+		for _, rpc := range sortedProtoKeys(old.RPCs) {
+			if _, ok := s.RPCs[rpc]; !ok {
+				add(ProtoChange{Category: ProtoRPCRemoved, Name: name + "." + rpc, Before: old.RPCs[rpc].String(), Breaking: true})
+			}
+		}
+	}
+	for _, name := range sortedProtoKeys(before.Services) {
+		if _, ok := after.Services[name]; !ok {
+			add(ProtoChange{Category: ProtoServiceRemoved, Name: name, Breaking: true})
+		}
+	}
+
+	return changes
+}
+
+// diffProtoFields compares the fields of a message present in both
+// versions.
+func diffProtoFields(before, after *ProtoMessage) []ProtoChange {
+	var changes []ProtoChange
+	beforeByNumber := make(map[int]*ProtoField)
+	for _, f := range before.Fields {
+		beforeByNumber[f.Number] = f
+	}
+	consumed := make(map[string]bool) // Removed fields reported as renamed or reused
+
+	for _, name := range sortedProtoKeys(after.Fields) {
+		f := after.Fields[name]
+		qualified := after.Name + "." + name
+
+		if old, ok := before.Fields[name]; ok {
+			if old.Number != f.Number {
+				changes = append(changes, ProtoChange{Category: ProtoFieldNumberChanged, Name: qualified, Before: old.String(), After: f.String(), Breaking: true})
+			}
+			if old.Type != f.Type {
+				changes = append(changes, ProtoChange{Category: ProtoFieldTypeChanged, Name: qualified, Before: old.String(), After: f.String(), Breaking: true})
+			}
+			if old.Label != f.Label {
+				// Only moving to or from repeated or required changes the encoding
+				breaking := old.Label == "repeated" || f.Label == "repeated" || old.Label == "required" || f.Label == "required"
+				changes = append(changes, ProtoChange{Category: ProtoFieldLabelChanged, Name: qualified, Before: old.String(), After: f.String(), Breaking: breaking})
+			}
+			continue
+		}
+
+		old, numberUsed := beforeByNumber[f.Number]
+		_, oldKept := after.Fields[old.nameOrEmpty()]
+		switch {
+		case numberUsed && !oldKept && old.Type == f.Type && old.Label == f.Label:
+			consumed[old.Name] = true
+			changes = append(changes, ProtoChange{Category: ProtoFieldRenamed, Name: qualified, Before: old.String(), After: f.String()})
+		case numberUsed:
+			consumed[old.Name] = !oldKept
+			changes = append(changes, ProtoChange{Category: ProtoFieldNumberReused, Name: qualified, Before: old.String(), After: f.String(), Breaking: true})
+		case before.reserves(f.Number):
+			changes = append(changes, ProtoChange{Category: ProtoFieldNumberReused, Name: qualified, Before: "reserved " + strconv.Itoa(f.Number), After: f.String(), Breaking: true})
+		default:
+			changes = append(changes, ProtoChange{Category: ProtoFieldAdded, Name: qualified, After: f.String(), Breaking: f.Label == "required"})
+		}
+	}
+
+	for _, name := range sortedProtoKeys(before.Fields) {
+		if _, ok := after.Fields[name]; ok || consumed[name] {
+			continue
+		}
+		f := before.Fields[name]
+		// Removing a field is safe once its number is reserved against reuse
+		changes = append(changes, ProtoChange{Category: ProtoFieldRemoved, Name: before.Name + "." + name, Before: f.String(), Breaking: !after.reserves(f.Number)})
+	}
+
+	return changes
+}
+
+func (f *ProtoField) nameOrEmpty() string {
+	if f == nil {
+		return ""
+	}
+	return f.Name
+}
+
+// CanonicalProto renders a .proto file's schema with declarations sorted and
+// comments and formatting dropped, so that edits which do not change the
+// schema keep the same digest. Field and enum value options are kept, as
+// json_name, packed and deprecated change how clients see the schema.
+func CanonicalProto(content []byte) ([]byte, error) {
+	f, err := ParseProto(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	if f.Package != "" {
+		fmt.Fprintf(&b, "package %s;\n", f.Package)
+	}
+	for _, name := range sortedProtoKeys(f.Messages) {
+		m := f.Messages[name]
+		fmt.Fprintf(&b, "message %s {\n", name)
+		fields := make([]*ProtoField, 0, len(m.Fields))
+		for _, field := range m.Fields {
+			fields = append(fields, field)
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Number < fields[j].Number })
+		for _, field := range fields {
+			decl := field.String()
+			if field.Options != "" {
+				decl += " [" + field.Options + "]"
+			}
+			if field.Oneof != "" {
+				fmt.Fprintf(&b, "  %s; // oneof %s\n", decl, field.Oneof)
+			} else {
+				fmt.Fprintf(&b, "  %s;\n", decl)
+			}
+		}
+		for _, r := range m.Reserved {
+			fmt.Fprintf(&b, "  reserved %d to %d;\n", r[0], r[1])
+		}
+		for _, n := range m.ReservedNames {
+			fmt.Fprintf(&b, "  reserved %q;\n", n)
+		}
+		b.WriteString("}\n")
+	}
+	for _, name := range sortedProtoKeys(f.Enums) {
+		e := f.Enums[name]
+		fmt.Fprintf(&b, "enum %s {\n", name)
+		for _, v := range sortedProtoKeys(e.Values) {
+			if opts := e.ValueOptions[v]; opts != "" {
+				fmt.Fprintf(&b, "  %s = %d [%s];\n", v, e.Values[v], opts)
+			} else {
+				fmt.Fprintf(&b, "  %s = %d;\n", v, e.Values[v])
+			}
+		}
+		b.WriteString("}\n")
+	}
+	for _, name := range sortedProtoKeys(f.Services) {
+		s := f.Services[name]
+		fmt.Fprintf(&b, "service %s {\n", name)
+		for _, rpc := range sortedProtoKeys(s.RPCs) {
+			fmt.Fprintf(&b, "  %s;\n", s.RPCs[rpc])
+		}
+		b.WriteString("}\n")
+	}
+	return []byte(b.String()), nil
+}
+
+func sortedProtoKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
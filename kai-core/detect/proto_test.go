@@ -0,0 +1,213 @@
+package detect
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const protoBefore = `syntax = "proto3";
+
+package acme.users.v1;
+
+import "google/protobuf/timestamp.proto";
+
+// A user account.
+message User {
+  string id = 1;
+  string email = 2;
+  int32 age = 3;
+  string nickname = 4;
+  repeated string tags = 5;
+  string legacy = 6;
+  string note = 7;
+
+  reserved 11;
+
+  oneof contact {
+    string phone = 8;
+  }
+
+  message Address {
+    string city = 1;
+  }
+}
+
+message Audit {
+  string actor = 1;
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  ACTIVE = 1;
+  BANNED = 2;
+}
+
+service Users {
+  rpc GetUser(GetUserRequest) returns (User);
+  rpc ListUsers(ListUsersRequest) returns (stream User);
+  rpc DeleteUser(DeleteUserRequest) returns (Empty);
+}
+`
+
+const protoAfter = `syntax = "proto3";
+
+package acme.users.v1;
+
+message User {
+  reserved 6;
+  reserved "legacy";
+
+  string id = 1;
+  string email_address = 2;
+  int64 age = 3;
+  int32 score = 4;
+  string tags = 5;
+  optional string note = 7;
+  string handle = 11;
+
+  oneof contact {
+    string phone = 8;
+    string fax = 9;
+  }
+
+  message Address {
+    string city = 1;
+    string zip = 2 [deprecated = true];
+  }
+
+  map<string, acme.users.v1.User.Address> addresses = 10;
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  ACTIVE = 1;
+  SUSPENDED = 3;
+}
+
+service Users {
+  rpc GetUser(GetUserRequest) returns (User) {
+    option deprecated = true;
+  }
+  rpc ListUsers(ListUsersRequest) returns (User);
+  rpc CreateUser(CreateUserRequest) returns (User);
+}
+`
+
+func TestDiffProto(t *testing.T) {
+	before, err := ParseProto([]byte(protoBefore))
+	if err != nil {
+		t.Fatalf("ParseProto(before): %v", err)
+	}
+	after, err := ParseProto([]byte(protoAfter))
+	if err != nil {
+		t.Fatalf("ParseProto(after): %v", err)
+	}
+
+	var got []string
+	for _, c := range DiffProto(before, after) {
+		got = append(got, fmt.Sprintf("%s %s %v", c.Category, c.Name, c.Breaking))
+	}
+	want := []string{
+		"PROTO_FIELD_ADDED User.addresses false",
+		"PROTO_FIELD_TYPE_CHANGED User.age true",
+		"PROTO_FIELD_RENAMED User.email_address false",
+		"PROTO_FIELD_ADDED User.fax false",
+		"PROTO_FIELD_NUMBER_REUSED User.handle true",
+		"PROTO_FIELD_LABEL_CHANGED User.note false",
+		"PROTO_FIELD_NUMBER_REUSED User.score true",
+		"PROTO_FIELD_LABEL_CHANGED User.tags true",
+		"PROTO_FIELD_REMOVED User.legacy false",
+		"PROTO_FIELD_ADDED User.Address.zip false",
+		"PROTO_MESSAGE_REMOVED Audit true",
+		"PROTO_ENUM_VALUE_ADDED Status.SUSPENDED false",
+		"PROTO_ENUM_VALUE_REMOVED Status.BANNED true",
+		"PROTO_RPC_ADDED Users.CreateUser false",
+		"PROTO_RPC_CHANGED Users.ListUsers true",
+		"PROTO_RPC_REMOVED Users.DeleteUser true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffProto() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	addresses := after.Messages["User"].Fields["addresses"]
+	if addresses.Type != "map<string, User.Address>" || addresses.Number != 10 {
+		t.Errorf("addresses = %+v", addresses)
+	}
+}
+
+func TestDiffProto_Package(t *testing.T) {
+	before, _ := ParseProto([]byte("package a.v1;\nmessage M { string x = 1; }"))
+	after, _ := ParseProto([]byte("package a.v2;\nmessage M { string x = 1; }"))
+
+	got := DiffProto(before, after)
+	want := []ProtoChange{{Category: ProtoPackageChanged, Name: "a.v2", Before: "a.v1", After: "a.v2", Breaking: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffProto() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCanonicalProto(t *testing.T) {
+	a := `syntax = "proto3";
+package p;
+// comment
+message B { string y = 2; string x = 1; }
+message A { int32 n = 1 [json_name = "num"]; }
+`
+	b := `syntax = "proto3";
+
+package p;
+
+message A {
+  int32 n = 1 [ json_name="num" ];
+}
+
+/* block
+   comment */
+message B {
+  string x = 1;
+  string y = 2;
+}
+`
+	ca, err := CanonicalProto([]byte(a))
+	if err != nil {
+		t.Fatalf("CanonicalProto(a): %v", err)
+	}
+	cb, err := CanonicalProto([]byte(b))
+	if err != nil {
+		t.Fatalf("CanonicalProto(b): %v", err)
+	}
+	if string(ca) != string(cb) {
+		t.Errorf("canonical forms differ:\n%s\n---\n%s", ca, cb)
+	}
+
+	cc, _ := CanonicalProto([]byte(strings.Replace(b, "string y = 2", "string y = 3", 1)))
+	if string(cc) == string(cb) {
+		t.Error("expected a field number change to change the canonical form")
+	}
+
+	for _, edit := range []string{`json_name="num2"`, `json_name="num", deprecated=true`} {
+		cd, _ := CanonicalProto([]byte(strings.Replace(b, `json_name="num"`, edit, 1)))
+		if string(cd) == string(cb) {
+			t.Errorf("expected option edit %s to change the canonical form", edit)
+		}
+	}
+	ce, _ := CanonicalProto([]byte("enum E { A = 0; B = 1 [deprecated = true]; }"))
+	cf, _ := CanonicalProto([]byte("enum E { A = 0; B = 1; }"))
+	if string(ce) == string(cf) {
+		t.Error("expected an enum value option to change the canonical form")
+	}
+}
+
+func TestParseProto_Errors(t *testing.T) {
+	for _, content := range []string{
+		`message M { string x = 1;`,
+		`message M { string x = ; }`,
+		`service S { rpc Get(Req) returns Res; }`,
+	} {
+		if _, err := ParseProto([]byte(content)); err == nil {
+			t.Errorf("ParseProto(%q): expected an error", content)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package detect
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"kai-core/parse"
+)
+
+// SensitivePattern names calls that touch a security-sensitive API. Pattern
+// is matched against the callee as written, where * matches any text:
+// "exec.Command", "subprocess.*", "*uthoriz*".
+type SensitivePattern struct {
+	Kind    string `json:"kind" yaml:"kind"` // crypto, exec, sql, auth, or any label
+	Pattern string `json:"pattern" yaml:"pattern"`
+}
+
+// DefaultSensitivePatterns are the calls reported as SENSITIVE_API_TOUCHED
+// without configuration. SQL built from strings is found without patterns.
+var DefaultSensitivePatterns = []SensitivePattern{
+	// Hashing, encryption, signing and TLS
+	{"crypto", "crypto.*"}, {"crypto", "hashlib.*"}, {"crypto", "hmac.*"}, {"crypto", "secrets.*"},
+	{"crypto", "bcrypt.*"}, {"crypto", "md5.*"}, {"crypto", "sha1.*"}, {"crypto", "sha256.*"},
+	{"crypto", "sha512.*"}, {"crypto", "aes.*"}, {"crypto", "cipher.*"}, {"crypto", "rsa.*"},
+	{"crypto", "ecdsa.*"}, {"crypto", "ed25519.*"}, {"crypto", "tls.*"}, {"crypto", "x509.*"},
+	{"crypto", "Digest::*"}, {"crypto", "OpenSSL::*"}, {"crypto", "BCrypt::*"}, {"crypto", "ring::*"},
+	{"crypto", "*.encrypt"}, {"crypto", "*.decrypt"}, {"crypto", "encrypt"}, {"crypto", "decrypt"},
+
+	// Running commands and evaluating code
+	{"exec", "exec.Command*"}, {"exec", "syscall.Exec"}, {"exec", "os.system"}, {"exec", "os.popen"},
+	{"exec", "subprocess.*"}, {"exec", "child_process.*"}, {"exec", "execSync"}, {"exec", "execFile*"},
+	{"exec", "spawn"}, {"exec", "spawnSync"}, {"exec", "eval"}, {"exec", "exec"}, {"exec", "system"},
+	{"exec", "Open3.*"}, {"exec", "IO.popen"}, {"exec", "*Command::new"},
+
+	// Authentication and authorization checks
+	{"auth", "*uthoriz*"}, {"auth", "*uthentic*"}, {"auth", "*ermission*"}, {"auth", "*erifyToken*"},
+	{"auth", "*erify_token*"}, {"auth", "*sAdmin*"}, {"auth", "*s_admin*"}, {"auth", "jwt.*"},
+}
+
+// AddSensitivePatterns adds call patterns to report as SENSITIVE_API_TOUCHED,
+// on top of DefaultSensitivePatterns.
+func (d *Detector) AddSensitivePatterns(patterns ...SensitivePattern) {
+	d.sensitive = append(d.sensitive, patterns...)
+}
+
+// sqlStatement matches string literals that hold SQL.
+var sqlStatement = regexp.MustCompile(`(?is)\b(select\b.+\bfrom|insert\s+into|update\b.+\bset|delete\s+from)\b`)
+
+// signal is an error handling, concurrency or sensitive construct found in
+// one version of a file.
+type signal struct {
+	category ChangeCategory
+	kind     string // for SENSITIVE_API_TOUCHED: crypto, exec, sql, auth...
+	key      string // normalized text, compared across versions
+	node     *sitter.Node
+}
+
+// collectSignals finds the constructs of a file the language table marks as
+// error handling or concurrency, and the calls and SQL building that touch
+// sensitive APIs.
+func (d *Detector) collectSignals(parsed *parse.ParsedFile, content []byte, spec *langSpec) []signal {
+	var signals []signal
+	add := func(category ChangeCategory, kind string, node *sitter.Node) {
+		key := strings.Join(strings.Fields(parse.GetNodeContent(node, content)), " ")
+		if !node.IsNamed() && node.Parent() != nil {
+			// Keywords such as async stand for the declaration holding them
+			node = node.Parent()
+			line, _, _ := strings.Cut(parse.GetNodeContent(node, content), "\n")
+			key += " " + strings.TrimSpace(line)
+		}
+		signals = append(signals, signal{category: category, kind: kind, key: key, node: node})
+	}
+
+	// await and async are keyed by where they sit - the enclosing function
+	// and, for await, its position among that function's awaits - so that
+	// editing the awaited expression or the function's parameters is not a
+	// concurrency change.
+	awaits := make(map[string]int)
+	addAsync := func(node *sitter.Node) {
+		switch {
+		case node.Type() == "async" && node.Parent() != nil:
+			decl := node.Parent()
+			signals = append(signals, signal{category: ConcurrencyChanged, key: "async " + decl.Type() + " " + functionName(decl, content), node: decl})
+		case node.IsNamed():
+			fn := "<module>"
+			if decl := enclosingFunction(node); decl != nil {
+				fn = functionName(decl, content)
+			}
+			key := "await in " + fn + " #" + strconv.Itoa(awaits[fn])
+			awaits[fn]++
+			signals = append(signals, signal{category: ConcurrencyChanged, key: key, node: node})
+		}
+		// The await keyword inside Python's await node is counted with it
+	}
+
+	stringTypes := append([]string{"template_string"}, spec.strings...)
+	iter := sitter.NewIterator(parsed.Tree.RootNode(), sitter.DFSMode)
+	for {
+		node, err := iter.Next()
+		if err != nil || node == nil {
+			break
+		}
+		nodeType := node.Type()
+
+		switch {
+		case containsString(spec.errorNodes, nodeType):
+			add(ErrorHandlingChanged, "", node)
+		case containsString(spec.concurrencyNodes, nodeType) && isAwaitOrAsync(nodeType):
+			addAsync(node)
+		case containsString(spec.concurrencyNodes, nodeType):
+			add(ConcurrencyChanged, "", node)
+		case spec.errorGuard != nil && (nodeType == "if_statement" || nodeType == "if_expression"):
+			if cond := node.ChildByFieldName("condition"); cond != nil && spec.errorGuard.MatchString(parse.GetNodeContent(cond, content)) {
+				add(ErrorHandlingChanged, "", node)
+			}
+		case containsString(stringTypes, nodeType) && sqlStatement.Match(content[node.StartByte():node.EndByte()]):
+			if built := sqlBuilding(node, content, spec); built != nil {
+				add(SensitiveAPITouched, "sql", built)
+			}
+		}
+
+		callee := spec.callee(node, content)
+		if callee == "" {
+			continue
+		}
+		if matchesAny(spec.errorCalls, callee) {
+			add(ErrorHandlingChanged, "", node)
+		}
+		if matchesAny(spec.concurrencyCalls, callee) {
+			add(ConcurrencyChanged, "", node)
+		}
+		for _, p := range d.sensitive {
+			if matchPattern(p.Pattern, callee) {
+				add(SensitiveAPITouched, p.Kind, node)
+				break
+			}
+		}
+	}
+	return signals
+}
+
+// isAwaitOrAsync reports whether a node type is an await expression or the
+// async keyword.
+func isAwaitOrAsync(nodeType string) bool {
+	switch nodeType {
+	case "await_expression", "await", "async":
+		return true
+	}
+	return false
+}
+
+// functionNodes are the function declarations and expressions of the
+// languages with async/await.
+var functionNodes = []string{
+	"function_declaration", "function", "function_expression", "arrow_function", "method_definition",
+	"generator_function_declaration", "generator_function", "function_definition",
+}
+
+// enclosingFunction returns the innermost function holding node, or nil at
+// the top level.
+func enclosingFunction(node *sitter.Node) *sitter.Node {
+	for p := node.Parent(); p != nil; p = p.Parent() {
+		if containsString(functionNodes, p.Type()) {
+			return p
+		}
+	}
+	return nil
+}
+
+// functionName names a function by its name field, or by the variable,
+// property or assignment it is bound to.
+func functionName(decl *sitter.Node, content []byte) string {
+	if name := decl.ChildByFieldName("name"); name != nil {
+		return parse.GetNodeContent(name, content)
+	}
+	if parent := decl.Parent(); parent != nil {
+		for _, field := range []string{"name", "key", "left"} {
+			if name := parent.ChildByFieldName(field); name != nil {
+				return parse.GetNodeContent(name, content)
+			}
+		}
+	}
+	return "<anonymous>"
+}
+
+// sqlBuilding returns the expression that builds SQL from a string literal
+// by interpolation, concatenation or a format call, or nil for a constant
+// query.
+func sqlBuilding(str *sitter.Node, content []byte, spec *langSpec) *sitter.Node {
+	for i := 0; i < int(str.NamedChildCount()); i++ {
+		switch str.NamedChild(i).Type() {
+		case "interpolation", "template_substitution":
+			return str
+		}
+	}
+
+	parent := str.Parent()
+	for parent != nil {
+		switch parent.Type() {
+		case "argument_list", "arguments", "token_tree", "attribute", "parenthesized_expression":
+			parent = parent.Parent()
+			continue
+		}
+		break
+	}
+	if parent == nil {
+		return nil
+	}
+	if containsString(spec.conditions, parent.Type()) {
+		return parent
+	}
+	if callee := strings.ToLower(spec.callee(parent, content)); strings.Contains(callee, "printf") || strings.Contains(callee, "format") {
+		return parent
+	}
+	return nil
+}
+
+// detectSignalChanges reports the error handling, concurrency and sensitive
+// constructs added, changed or removed between two versions. Each category
+// (and each kind of sensitive API) is one change, with a range per construct.
+func (d *Detector) detectSignalChanges(path string, before, after *parse.ParsedFile, beforeContent, afterContent []byte, fileID string, spec *langSpec) []*ChangeType {
+	beforeSignals := d.collectSignals(before, beforeContent, spec)
+	afterSignals := d.collectSignals(after, afterContent, spec)
+
+	type group struct {
+		category ChangeCategory
+		kind     string
+	}
+	added := make(map[group][]*sitter.Node)
+	removed := make(map[group][]*sitter.Node)
+
+	// A construct is unchanged when the other version has one with the same
+	// text
+	unmatched := func(from, other []signal, into map[group][]*sitter.Node) {
+		counts := make(map[string]int)
+		for _, s := range other {
+			counts[string(s.category)+"\x00"+s.key]++
+		}
+		for _, s := range from {
+			k := string(s.category) + "\x00" + s.key
+			if counts[k] > 0 {
+				counts[k]--
+				continue
+			}
+			g := group{s.category, s.kind}
+			into[g] = append(into[g], s.node)
+		}
+	}
+	unmatched(afterSignals, beforeSignals, added)
+	unmatched(beforeSignals, afterSignals, removed)
+
+	var groups []group
+	for g := range added {
+		groups = append(groups, g)
+	}
+	for g := range removed {
+		if _, ok := added[g]; !ok {
+			groups = append(groups, g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].category != groups[j].category {
+			return groups[i].category < groups[j].category
+		}
+		return groups[i].kind < groups[j].kind
+	})
+
+	var changes []*ChangeType
+	for _, g := range groups {
+		// Ranges are in the new version, or in the old one when constructs
+		// were only removed
+		nodes := added[g]
+		if len(nodes) == 0 {
+			nodes = removed[g]
+		}
+
+		change := &ChangeType{Category: g.category}
+		if g.kind != "" {
+			change.Evidence.Symbols = append(change.Evidence.Symbols, "sensitive:"+g.kind)
+		}
+		seen := make(map[string]bool)
+		for _, node := range outermostNodes(nodes) {
+			r := parse.GetNodeRange(node)
+			change.Evidence.FileRanges = append(change.Evidence.FileRanges, FileRange{
+				Path:  path,
+				Start: r.Start,
+				End:   r.End,
+			})
+			if len(added[g]) > 0 {
+				for _, id := range d.findOverlappingSymbols(fileID, r) {
+					if !seen[id] {
+						seen[id] = true
+						change.Evidence.Symbols = append(change.Evidence.Symbols, id)
+					}
+				}
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// outermostNodes drops nodes inside another of the nodes and sorts the rest
+// by position.
+func outermostNodes(nodes []*sitter.Node) []*sitter.Node {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].StartByte() != nodes[j].StartByte() {
+			return nodes[i].StartByte() < nodes[j].StartByte()
+		}
+		return nodes[i].EndByte() > nodes[j].EndByte()
+	})
+	var result []*sitter.Node
+	for _, n := range nodes {
+		if len(result) > 0 && n.EndByte() <= result[len(result)-1].EndByte() {
+			continue
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+func matchesAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches a callee against a pattern where * is any text.
+func matchPattern(pattern, s string) bool {
+	ok, err := path.Match(pattern, s)
+	return err == nil && ok
+}
@@ -0,0 +1,220 @@
+package detect
+
+import (
+	"testing"
+)
+
+func TestDetectChanges_Signals(t *testing.T) {
+	tests := []struct {
+		name          string
+		lang          string
+		before, after string
+		expected      []ChangeCategory
+		unexpected    []ChangeCategory
+		kind          string // expected sensitive kind
+	}{
+		{
+			name:     "go error check",
+			lang:     "go",
+			before:   "package a\n\nfunc f() error {\n\tx, _ := g()\n\treturn use(x)\n}\n",
+			after:    "package a\n\nfunc f() error {\n\tx, err := g()\n\tif err != nil {\n\t\treturn err\n\t}\n\treturn use(x)\n}\n",
+			expected: []ChangeCategory{ErrorHandlingChanged},
+		},
+		{
+			name:       "go goroutine and lock",
+			lang:       "go",
+			before:     "package a\n\nfunc f() {\n\trun()\n}\n",
+			after:      "package a\n\nfunc f() {\n\tmu.Lock()\n\tdefer mu.Unlock()\n\tgo run()\n}\n",
+			expected:   []ChangeCategory{ConcurrencyChanged},
+			unexpected: []ChangeCategory{ErrorHandlingChanged, SensitiveAPITouched},
+		},
+		{
+			name:     "go exec",
+			lang:     "go",
+			before:   "package a\n\nfunc f() {\n\texec.Command(\"ls\").Run()\n}\n",
+			after:    "package a\n\nfunc f() {\n\texec.Command(\"sh\", \"-c\", arg).Run()\n}\n",
+			expected: []ChangeCategory{SensitiveAPITouched},
+			kind:     "exec",
+		},
+		{
+			name:     "go sql building",
+			lang:     "go",
+			before:   "package a\n\nfunc f() {\n\tq := \"SELECT * FROM users WHERE id = ?\"\n\tdb.Query(q, id)\n}\n",
+			after:    "package a\n\nfunc f() {\n\tq := fmt.Sprintf(\"SELECT * FROM users WHERE id = %s\", id)\n\tdb.Query(q)\n}\n",
+			expected: []ChangeCategory{SensitiveAPITouched},
+			kind:     "sql",
+		},
+		{
+			name:       "go constant sql",
+			lang:       "go",
+			before:     "package a\n\nfunc f() {\n\tdb.Query(\"SELECT * FROM users WHERE id = ?\", id)\n}\n",
+			after:      "package a\n\nfunc f() {\n\tdb.Query(\"SELECT name FROM users WHERE id = ?\", id)\n}\n",
+			unexpected: []ChangeCategory{SensitiveAPITouched},
+		},
+		{
+			name:     "js try/catch and await",
+			lang:     "js",
+			before:   "function load() {\n  return fetch(url);\n}\n",
+			after:    "async function load() {\n  try {\n    return await fetch(url);\n  } catch (e) {\n    throw new Error('failed');\n  }\n}\n",
+			expected: []ChangeCategory{ErrorHandlingChanged, ConcurrencyChanged},
+		},
+		{
+			name:       "js awaited argument",
+			lang:       "js",
+			before:     "async function load() {\n  const a = await fetch('/a');\n  return a;\n}\n",
+			after:      "async function load(opts) {\n  const a = await fetch('/b', opts);\n  return a;\n}\n",
+			unexpected: []ChangeCategory{ConcurrencyChanged},
+		},
+		{
+			name:     "js await added",
+			lang:     "js",
+			before:   "async function load() {\n  return await fetch('/a');\n}\n",
+			after:    "async function load() {\n  await ready();\n  return await fetch('/a');\n}\n",
+			expected: []ChangeCategory{ConcurrencyChanged},
+		},
+		{
+			name:       "python awaited argument",
+			lang:       "py",
+			before:     "async def load():\n    return await fetch('/a')\n",
+			after:      "async def load():\n    return await fetch('/b')\n",
+			unexpected: []ChangeCategory{ConcurrencyChanged},
+		},
+		{
+			name:     "js template sql",
+			lang:     "js",
+			before:   "function q(id) {\n  return db.query('SELECT * FROM users WHERE id = $1', [id]);\n}\n",
+			after:    "function q(id) {\n  return db.query(`SELECT * FROM users WHERE id = ${id}`);\n}\n",
+			expected: []ChangeCategory{SensitiveAPITouched},
+			kind:     "sql",
+		},
+		{
+			name:     "python except and subprocess",
+			lang:     "py",
+			before:   "def run(cmd):\n    try:\n        go(cmd)\n    except ValueError:\n        pass\n",
+			after:    "def run(cmd):\n    try:\n        subprocess.run(cmd, shell=True)\n    except (ValueError, OSError):\n        raise\n",
+			expected: []ChangeCategory{ErrorHandlingChanged, SensitiveAPITouched},
+			kind:     "exec",
+		},
+		{
+			name:     "python threads",
+			lang:     "py",
+			before:   "def start():\n    work()\n",
+			after:    "def start():\n    t = threading.Thread(target=work)\n    t.start()\n",
+			expected: []ChangeCategory{ConcurrencyChanged},
+		},
+		{
+			name:     "ruby rescue and thread",
+			lang:     "rb",
+			before:   "def sync\n  pull\nend\n",
+			after:    "def sync\n  Thread.new { pull }\nrescue Timeout::Error\n  retry\nend\n",
+			expected: []ChangeCategory{ErrorHandlingChanged, ConcurrencyChanged},
+		},
+		{
+			name:     "ruby auth check",
+			lang:     "rb",
+			before:   "def show\n  render\nend\n",
+			after:    "def show\n  authorize! :read, @post\n  render\nend\n",
+			expected: []ChangeCategory{SensitiveAPITouched},
+			kind:     "auth",
+		},
+		{
+			name:     "rust try and spawn",
+			lang:     "rs",
+			before:   "fn load() -> u32 {\n    read().unwrap()\n}\n",
+			after:    "fn load() -> Result<u32, Error> {\n    let h = std::thread::spawn(|| read());\n    Ok(h.join()?)\n}\n",
+			expected: []ChangeCategory{ErrorHandlingChanged, ConcurrencyChanged},
+		},
+		{
+			name:       "unrelated edit",
+			lang:       "go",
+			before:     "package a\n\nfunc f() error {\n\tif err := g(); err != nil {\n\t\treturn err\n\t}\n\treturn h(1)\n}\n",
+			after:      "package a\n\nfunc f() error {\n\tif err := g(); err != nil {\n\t\treturn err\n\t}\n\treturn h(2)\n}\n",
+			unexpected: []ChangeCategory{ErrorHandlingChanged, ConcurrencyChanged, SensitiveAPITouched},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector()
+			changes, err := d.DetectChanges("f."+tt.lang, []byte(tt.before), []byte(tt.after), "file1", tt.lang)
+			if err != nil {
+				t.Fatalf("DetectChanges failed: %v", err)
+			}
+
+			for _, category := range tt.expected {
+				found := findCategory(changes, category)
+				if len(found) == 0 {
+					t.Errorf("expected %s, got %v", category, categories(changes))
+					continue
+				}
+				if len(found[0].Evidence.FileRanges) == 0 {
+					t.Errorf("expected %s to have evidence ranges", category)
+				}
+			}
+			for _, category := range tt.unexpected {
+				if len(findCategory(changes, category)) > 0 {
+					t.Errorf("unexpected %s", category)
+				}
+			}
+			if tt.kind != "" && !hasSymbol(findCategory(changes, SensitiveAPITouched), "sensitive:"+tt.kind) {
+				t.Errorf("expected sensitive kind %q", tt.kind)
+			}
+		})
+	}
+}
+
+func TestAddSensitivePatterns(t *testing.T) {
+	before := []byte("package a\n\nfunc f() {\n\tstore.Save(x)\n}\n")
+	after := []byte("package a\n\nfunc f() {\n\tstore.Save(x)\n\tbilling.Charge(card, amount)\n}\n")
+
+	d := NewDetector()
+	changes, err := d.DetectChanges("f.go", before, after, "file1", "go")
+	if err != nil {
+		t.Fatalf("DetectChanges failed: %v", err)
+	}
+	if len(findCategory(changes, SensitiveAPITouched)) > 0 {
+		t.Fatal("billing.Charge is not sensitive by default")
+	}
+
+	d.AddSensitivePatterns(SensitivePattern{Kind: "payments", Pattern: "billing.*"})
+	changes, err = d.DetectChanges("f.go", before, after, "file1", "go")
+	if err != nil {
+		t.Fatalf("DetectChanges failed: %v", err)
+	}
+	found := findCategory(changes, SensitiveAPITouched)
+	if !hasSymbol(found, "sensitive:payments") {
+		t.Fatalf("expected SENSITIVE_API_TOUCHED for payments, got %v", categories(changes))
+	}
+	if r := found[0].Evidence.FileRanges[0]; r.Start[0] != 4 {
+		t.Errorf("expected the range to start on line 4, got %v", r.Start)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, callee string
+		expected        bool
+	}{
+		{"exec.Command*", "exec.CommandContext", true},
+		{"subprocess.*", "subprocess.run", true},
+		{"*uthoriz*", "policy.Authorize", true},
+		{"*.lock", "self.state.lock", true},
+		{"*.unwrap", "m.lock().unwrap", true},
+		{"Concurrent::*", "Concurrent::Future.execute", true},
+		{"eval", "evaluate", false},
+		{"crypto.*", "mycrypto.hash", false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.callee); got != tt.expected {
+			t.Errorf("matchPattern(%q, %q) = %v, expected %v", tt.pattern, tt.callee, got, tt.expected)
+		}
+	}
+}
+
+func categories(changes []*ChangeType) []ChangeCategory {
+	var result []ChangeCategory
+	for _, c := range changes {
+		result = append(result, c.Category)
+	}
+	return result
+}
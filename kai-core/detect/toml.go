@@ -0,0 +1,375 @@
+package detect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML reads a TOML document into nested maps. Tables and inline tables
+// are map[string]interface{}, arrays and arrays of tables []interface{};
+// strings, booleans, integers and floats decode to Go values, and dates and
+// times stay as their text. It reads what manifests and config files use,
+// not every corner of the spec.
+func parseTOML(content []byte) (map[string]interface{}, error) {
+	p := &tomlParser{s: string(content), line: 1}
+	root := make(map[string]interface{})
+	current := root
+
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return root, nil
+		}
+
+		if p.peek() == '[' {
+			array := strings.HasPrefix(p.s[p.pos:], "[[")
+			if array {
+				p.pos += 2
+			} else {
+				p.pos++
+			}
+			keys, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			closing := "]"
+			if array {
+				closing = "]]"
+			}
+			p.skipBlank(false)
+			if !strings.HasPrefix(p.s[p.pos:], closing) {
+				return nil, p.errorf("expected %s after table name", closing)
+			}
+			p.pos += len(closing)
+
+			if array {
+				parent, err := tomlTable(root, keys[:len(keys)-1])
+				if err != nil {
+					return nil, p.errorf("%v", err)
+				}
+				last := keys[len(keys)-1]
+				list, _ := parent[last].([]interface{})
+				current = make(map[string]interface{})
+				parent[last] = append(list, current)
+			} else if current, err = tomlTable(root, keys); err != nil {
+				return nil, p.errorf("%v", err)
+			}
+		} else {
+			keys, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipBlank(false)
+			if p.eof() || p.peek() != '=' {
+				return nil, p.errorf("expected = after key %q", strings.Join(keys, "."))
+			}
+			p.pos++
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			table, err := tomlTable(current, keys[:len(keys)-1])
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			table[keys[len(keys)-1]] = value
+		}
+
+		p.skipBlank(false)
+		if !p.eof() && p.peek() != '\n' && p.peek() != '\r' {
+			return nil, p.errorf("unexpected %q", p.peek())
+		}
+	}
+}
+
+// tomlTable returns the table at a key path below root, creating missing
+// tables. A path through an array of tables goes to its last table.
+func tomlTable(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	table := root
+	for _, k := range keys {
+		switch v := table[k].(type) {
+		case nil:
+			next := make(map[string]interface{})
+			table[k] = next
+			table = next
+		case map[string]interface{}:
+			table = v
+		case []interface{}:
+			last, ok := v[len(v)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", k)
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("%s is not a table", k)
+		}
+	}
+	return table, nil
+}
+
+type tomlParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) eof() bool  { return p.pos >= len(p.s) }
+func (p *tomlParser) peek() byte { return p.s[p.pos] }
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skipBlank skips spaces and comments, and line breaks when newlines is set.
+func (p *tomlParser) skipBlank(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		case newlines && (c == '\n' || c == '\r'):
+			if c == '\n' {
+				p.line++
+			}
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// key reads a dotted key of bare and quoted parts.
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipBlank(false)
+		if p.eof() {
+			return nil, p.errorf("expected key")
+		}
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			k, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("unexpected %q in key", c)
+			}
+			keys = append(keys, p.s[start:p.pos])
+		}
+		p.skipBlank(false)
+		if p.eof() || p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// value reads a string, array, inline table or scalar.
+func (p *tomlParser) value() (interface{}, error) {
+	p.skipBlank(false)
+	if p.eof() {
+		return nil, p.errorf("expected value")
+	}
+
+	switch p.peek() {
+	case '"', '\'':
+		return p.str()
+
+	case '[':
+		p.pos++
+		list := []interface{}{}
+		for {
+			p.skipBlank(true)
+			if p.eof() {
+				return nil, p.errorf("unterminated array")
+			}
+			if p.peek() == ']' {
+				p.pos++
+				return list, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			p.skipBlank(true)
+			if !p.eof() && p.peek() == ',' {
+				p.pos++
+			}
+		}
+
+	case '{':
+		p.pos++
+		table := make(map[string]interface{})
+		for {
+			p.skipBlank(false)
+			if p.eof() {
+				return nil, p.errorf("unterminated inline table")
+			}
+			switch p.peek() {
+			case '}':
+				p.pos++
+				return table, nil
+			case ',':
+				p.pos++
+				continue
+			}
+			keys, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			if p.eof() || p.peek() != '=' {
+				return nil, p.errorf("expected = after key %q", strings.Join(keys, "."))
+			}
+			p.pos++
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			inner, err := tomlTable(table, keys[:len(keys)-1])
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			inner[keys[len(keys)-1]] = v
+		}
+	}
+
+	// Scalars run to the end of the value
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(",]}#\r\n", rune(p.peek())) {
+		p.pos++
+	}
+	text := strings.TrimSpace(p.s[start:p.pos])
+	switch text {
+	case "":
+		return nil, p.errorf("expected value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	plain := strings.ReplaceAll(text, "_", "")
+	if i, err := strconv.ParseInt(plain, 0, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(plain, 64); err == nil {
+		return f, nil
+	}
+	return text, nil
+}
+
+// str reads a basic or literal string, single or multi-line.
+func (p *tomlParser) str() (string, error) {
+	quote := p.s[p.pos : p.pos+1]
+	if strings.HasPrefix(p.s[p.pos:], quote+quote+quote) {
+		p.pos += 3
+		// A newline right after the opening quotes is not part of the string
+		if strings.HasPrefix(p.s[p.pos:], "\r\n") {
+			p.pos += 2
+			p.line++
+		} else if strings.HasPrefix(p.s[p.pos:], "\n") {
+			p.pos++
+			p.line++
+		}
+		end := strings.Index(p.s[p.pos:], quote+quote+quote)
+		if end < 0 {
+			return "", p.errorf("unterminated string")
+		}
+		// Up to two quotes may close the content before the delimiter
+		for end+3 < len(p.s[p.pos:]) && p.s[p.pos+end+3] == quote[0] {
+			end++
+		}
+		text := p.s[p.pos : p.pos+end]
+		p.line += strings.Count(text, "\n")
+		p.pos += end + 3
+		if quote == "'" {
+			return text, nil
+		}
+		return unescapeTOML(text, true), nil
+	}
+
+	p.pos++
+	start := p.pos
+	for !p.eof() && p.peek() != quote[0] {
+		if p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		if quote == `"` && p.peek() == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.eof() {
+		return "", p.errorf("unterminated string")
+	}
+	text := p.s[start:p.pos]
+	p.pos++
+	if quote == "'" {
+		return text, nil
+	}
+	return unescapeTOML(text, false), nil
+}
+
+// unescapeTOML resolves the escapes of a basic string. In multi-line strings
+// a backslash at the end of a line joins it to the next non-blank text.
+func unescapeTOML(s string, multiline bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+n < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += n
+					continue
+				}
+			}
+			b.WriteByte(c)
+		case ' ', '\t', '\r', '\n':
+			if !multiline {
+				b.WriteByte(c)
+				continue
+			}
+			for i+1 < len(s) && strings.ContainsRune(" \t\r\n", rune(s[i+1])) {
+				i++
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
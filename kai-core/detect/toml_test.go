@@ -0,0 +1,93 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	content := []byte(`
+# A Cargo manifest
+title = "demo"     # trailing comment
+count = 1_000
+ratio = 0.5
+enabled = true
+released = 1979-05-27T07:32:00Z
+a.b = 'literal \n'
+
+[package]
+name = "app"
+authors = [
+  "Ann",   # first
+  "Bob",
+]
+
+[dependencies]
+serde = { version = "1.0", features = ["derive"] }
+
+[target.'cfg(unix)'.dependencies]
+libc = "0.2"
+
+[[bin]]
+name = "one"
+
+[[bin]]
+name = "two"
+description = """
+multi \
+  line"""
+`)
+
+	got, err := parseTOML(content)
+	if err != nil {
+		t.Fatalf("parseTOML failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"title":    "demo",
+		"count":    int64(1000),
+		"ratio":    0.5,
+		"enabled":  true,
+		"released": "1979-05-27T07:32:00Z",
+		"a":        map[string]interface{}{"b": `literal \n`},
+		"package": map[string]interface{}{
+			"name":    "app",
+			"authors": []interface{}{"Ann", "Bob"},
+		},
+		"dependencies": map[string]interface{}{
+			"serde": map[string]interface{}{"version": "1.0", "features": []interface{}{"derive"}},
+		},
+		"target": map[string]interface{}{
+			"cfg(unix)": map[string]interface{}{
+				"dependencies": map[string]interface{}{"libc": "0.2"},
+			},
+		},
+		"bin": []interface{}{
+			map[string]interface{}{"name": "one"},
+			map[string]interface{}{"name": "two", "description": "multi line"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestParseTOML_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"missing equals", "key value\n"},
+		{"unterminated string", "key = \"value\n"},
+		{"unterminated array", "key = [1, 2\n"},
+		{"unclosed table", "[table\n"},
+		{"trailing text", "key = \"a\" b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseTOML([]byte(tt.content)); err == nil {
+				t.Errorf("parseTOML(%q) succeeded, want error", tt.content)
+			}
+		})
+	}
+}
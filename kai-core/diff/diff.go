@@ -45,6 +45,35 @@ func (d *Differ) DiffFile(path string, before, after []byte) (*FileDiff, error)
 
 	// Compute unit-level diffs based on file type
 	switch {
+	case detect.ManifestEcosystem(path) != "":
+		units, err := d.diffManifest(path, before, after)
+		if err != nil {
+			return fd, nil
+		}
+		fd.Units = units
+
+	case detect.ConfigFormat(path) != "":
+		fd.Lang = detect.ConfigFormat(path)
+		units, err := d.diffConfig(path, before, after)
+		if err != nil {
+			return fd, nil
+		}
+		fd.Units = units
+
+	case lang == "hcl":
+		units, err := d.diffHCL(before, after)
+		if err != nil {
+			return fd, nil
+		}
+		fd.Units = units
+
+	case lang == "proto":
+		units, err := d.diffProto(before, after)
+		if err != nil {
+			return fd, nil
+		}
+		fd.Units = units
+
 	case lang == "json":
 		units, err := d.diffJSON(path, before, after)
 		if err != nil {
@@ -224,6 +253,48 @@ func (d *Differ) diffJSON(path string, before, after []byte) ([]UnitDiff, error)
 	return units, nil
 }
 
+// diffManifest computes unit diffs for dependency manifests and lockfiles:
+// one unit per dependency added, removed or moved to another version.
+func (d *Differ) diffManifest(path string, before, after []byte) ([]UnitDiff, error) {
+	if before == nil || after == nil {
+		return nil, nil
+	}
+
+	beforeManifest, err := detect.ParseManifest(path, before)
+	if err != nil {
+		return nil, err
+	}
+	afterManifest, err := detect.ParseManifest(path, after)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []UnitDiff
+	for _, c := range detect.DiffManifests(beforeManifest, afterManifest) {
+		ud := UnitDiff{
+			Kind:       KindDependency,
+			Name:       c.Name,
+			Path:       c.Scope,
+			Before:     c.From,
+			After:      c.To,
+			ChangeType: string(c.Category),
+		}
+
+		switch c.Category {
+		case detect.DependencyAdded:
+			ud.Action = ActionAdded
+		case detect.DependencyRemoved:
+			ud.Action = ActionRemoved
+		default:
+			ud.Action = ActionModified
+		}
+
+		units = append(units, ud)
+	}
+
+	return units, nil
+}
+
 // diffYAML computes unit diffs for YAML files.
 func (d *Differ) diffYAML(path string, before, after []byte) ([]UnitDiff, error) {
 	if before == nil || after == nil {
@@ -262,98 +333,196 @@ func (d *Differ) diffYAML(path string, before, after []byte) ([]UnitDiff, error)
 	return units, nil
 }
 
-// diffSQL computes unit diffs for SQL schema files.
+// diffSQL computes unit diffs for SQL schema files by replaying each
+// version's DDL into a schema and comparing the results.
 func (d *Differ) diffSQL(path string, before, after []byte) ([]UnitDiff, error) {
-	var beforeTables, afterTables map[string]*sqlTable
+	return ComputeSQLDiff(string(before), string(after)).Changes, nil
+}
 
-	if before != nil {
-		beforeTables = parseSQL(string(before))
-	} else {
-		beforeTables = make(map[string]*sqlTable)
+// diffConfig computes unit diffs for TOML, INI and .env files. Values in
+// .env files are usually secrets, so only their keys are reported.
+func (d *Differ) diffConfig(path string, before, after []byte) ([]UnitDiff, error) {
+	if before == nil || after == nil {
+		return nil, nil
 	}
 
-	if after != nil {
-		afterTables = parseSQL(string(after))
-	} else {
-		afterTables = make(map[string]*sqlTable)
+	beforeConfig, err := detect.ParseConfig(path, before)
+	if err != nil {
+		return nil, err
 	}
+	afterConfig, err := detect.ParseConfig(path, after)
+	if err != nil {
+		return nil, err
+	}
+	redact := detect.ConfigFormat(path) == "env"
 
 	var units []UnitDiff
+	for _, c := range detect.DiffConfigs(beforeConfig, afterConfig) {
+		ud := UnitDiff{
+			Kind:       KindConfigKey,
+			Name:       c.Key,
+			Path:       c.Key,
+			ChangeType: string(c.Category),
+		}
+		if !redact {
+			ud.Before, ud.After = c.Before, c.After
+		}
 
-	// Find added and modified tables
-	for name, afterTable := range afterTables {
-		beforeTable, exists := beforeTables[name]
-		if !exists {
-			// Table added
-			units = append(units, UnitDiff{
-				Kind:   KindSQLTable,
-				Name:   name,
-				Action: ActionAdded,
-				After:  afterTable.Definition,
-			})
-			// Add all columns as added
-			for colName, col := range afterTable.Columns {
-				units = append(units, UnitDiff{
-					Kind:   KindSQLColumn,
-					Name:   colName,
-					Path:   name + "." + colName,
-					Action: ActionAdded,
-					After:  col.Definition,
-				})
-			}
-		} else {
-			// Check for column changes
-			for colName, afterCol := range afterTable.Columns {
-				beforeCol, colExists := beforeTable.Columns[colName]
-				if !colExists {
-					units = append(units, UnitDiff{
-						Kind:   KindSQLColumn,
-						Name:   colName,
-						Path:   name + "." + colName,
-						Action: ActionAdded,
-						After:  afterCol.Definition,
-					})
-				} else if afterCol.Definition != beforeCol.Definition {
-					units = append(units, UnitDiff{
-						Kind:   KindSQLColumn,
-						Name:   colName,
-						Path:   name + "." + colName,
-						Action: ActionModified,
-						Before: beforeCol.Definition,
-						After:  afterCol.Definition,
-					})
-				}
-			}
-			// Check for removed columns
-			for colName, beforeCol := range beforeTable.Columns {
-				if _, exists := afterTable.Columns[colName]; !exists {
-					units = append(units, UnitDiff{
-						Kind:   KindSQLColumn,
-						Name:   colName,
-						Path:   name + "." + colName,
-						Action: ActionRemoved,
-						Before: beforeCol.Definition,
-					})
-				}
+		switch c.Category {
+		case detect.ConfigKeyAdded:
+			ud.Action = ActionAdded
+		case detect.ConfigKeyRemoved:
+			ud.Action = ActionRemoved
+		default:
+			ud.Action = ActionModified
+		}
+
+		units = append(units, ud)
+	}
+
+	return units, nil
+}
+
+// diffHCL computes unit diffs for Terraform and other HCL files: blocks by
+// address, then their attributes.
+func (d *Differ) diffHCL(before, after []byte) ([]UnitDiff, error) {
+	if before == nil || after == nil {
+		return nil, nil
+	}
+
+	beforeBlocks, err := detect.ParseHCL(before)
+	if err != nil {
+		return nil, err
+	}
+	afterBlocks, err := detect.ParseHCL(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []UnitDiff
+	for _, c := range detect.DiffHCL(beforeBlocks, afterBlocks) {
+		ud := UnitDiff{
+			Kind:       KindHCLBlock,
+			Name:       c.Address,
+			Before:     c.Before,
+			After:      c.After,
+			ChangeType: string(c.Category),
+		}
+		if c.Attribute != "" {
+			ud.Kind = KindHCLAttribute
+			ud.Path = c.Attribute
+			if c.Address != "" {
+				ud.Path = c.Address + "." + c.Attribute
 			}
 		}
+
+		switch c.Category {
+		case detect.HCLBlockAdded, detect.HCLAttributeAdded:
+			ud.Action = ActionAdded
+		case detect.HCLBlockRemoved, detect.HCLAttributeRemoved:
+			ud.Action = ActionRemoved
+		default:
+			ud.Action = ActionModified
+		}
+
+		units = append(units, ud)
 	}
 
-	// Find removed tables
-	for name, beforeTable := range beforeTables {
-		if _, exists := afterTables[name]; !exists {
-			units = append(units, UnitDiff{
-				Kind:   KindSQLTable,
-				Name:   name,
-				Action: ActionRemoved,
-				Before: beforeTable.Definition,
-			})
+	return units, nil
+}
+
+// diffProto computes unit diffs for protobuf schemas, flagging changes that
+// break the wire format or generated clients.
+func (d *Differ) diffProto(before, after []byte) ([]UnitDiff, error) {
+	if before == nil || after == nil {
+		return nil, nil
+	}
+
+	beforeFile, err := detect.ParseProto(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFile, err := detect.ParseProto(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []UnitDiff
+	for _, c := range detect.DiffProto(beforeFile, afterFile) {
+		ud := UnitDiff{
+			Kind:       protoUnitKind(c.Category),
+			Name:       c.Name,
+			Before:     c.Before,
+			After:      c.After,
+			ChangeType: string(c.Category),
+			Breaking:   c.Breaking,
 		}
+
+		switch {
+		case strings.HasSuffix(string(c.Category), "_ADDED"):
+			ud.Action = ActionAdded
+		case strings.HasSuffix(string(c.Category), "_REMOVED"):
+			ud.Action = ActionRemoved
+		default:
+			ud.Action = ActionModified
+		}
+
+		units = append(units, ud)
 	}
 
 	return units, nil
 }
 
+func protoUnitKind(category detect.ChangeCategory) UnitKind {
+	switch category {
+	case detect.ProtoPackageChanged:
+		return KindProtoPackage
+	case detect.ProtoMessageAdded, detect.ProtoMessageRemoved:
+		return KindProtoMessage
+	case detect.ProtoEnumAdded, detect.ProtoEnumRemoved:
+		return KindProtoEnum
+	case detect.ProtoEnumValueAdded, detect.ProtoEnumValueRemoved, detect.ProtoEnumValueChanged:
+		return KindProtoValue
+	case detect.ProtoServiceAdded, detect.ProtoServiceRemoved:
+		return KindProtoService
+	case detect.ProtoRPCAdded, detect.ProtoRPCRemoved, detect.ProtoRPCChanged:
+		return KindProtoRPC
+	default:
+		return KindProtoField
+	}
+}
+
+// DiffMigrations diffs a migration directory as the schema it builds. The
+// maps hold file contents by path; files outside dir are ignored.
+func (d *Differ) DiffMigrations(dir string, before, after map[string][]byte) FileDiff {
+	beforeFiles := migrationFiles(dir, before)
+	afterFiles := migrationFiles(dir, after)
+
+	fd := FileDiff{
+		Path:   dir + "/",
+		Action: ActionModified,
+		Lang:   "sql",
+		Units:  ComputeMigrationDiff(beforeFiles, afterFiles).Changes,
+	}
+	switch {
+	case len(beforeFiles) == 0:
+		fd.Action = ActionAdded
+	case len(afterFiles) == 0:
+		fd.Action = ActionRemoved
+	}
+	return fd
+}
+
+func migrationFiles(dir string, contents map[string][]byte) map[string]string {
+	files := make(map[string]string)
+	for path, content := range contents {
+		if MigrationDir(path) == dir {
+			files[path] = string(content)
+		}
+	}
+	return files
+}
+
 // Helper functions
 
 func extToLang(ext string) string {
@@ -372,6 +541,14 @@ func extToLang(ext string) string {
 		return "yaml"
 	case ".sql":
 		return "sql"
+	case ".toml":
+		return "toml"
+	case ".ini", ".cfg":
+		return "ini"
+	case ".tf", ".tfvars", ".hcl":
+		return "hcl"
+	case ".proto":
+		return "proto"
 	default:
 		return ""
 	}
@@ -1,6 +1,7 @@
 package diff
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -114,6 +115,137 @@ func TestDiffFile_JSON(t *testing.T) {
 	}
 }
 
+func TestDiffFile_Manifest(t *testing.T) {
+	before := []byte("module m\n\nrequire (\n\tgithub.com/a/b v1.2.0\n\tgithub.com/c/d v0.3.0\n)\n")
+	after := []byte("module m\n\nrequire (\n\tgithub.com/a/b v2.0.0\n\tgithub.com/e/f v1.0.0 // indirect\n)\n")
+
+	d := NewDiffer()
+	fd, err := d.DiffFile("go.mod", before, after)
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+
+	want := []UnitDiff{
+		{Kind: KindDependency, Name: "github.com/a/b", Action: ActionModified, Before: "v1.2.0", After: "v2.0.0", ChangeType: "DEPENDENCY_UPGRADED"},
+		{Kind: KindDependency, Name: "github.com/c/d", Action: ActionRemoved, Before: "v0.3.0", ChangeType: "DEPENDENCY_REMOVED"},
+		{Kind: KindDependency, Name: "github.com/e/f", Path: "indirect", Action: ActionAdded, After: "v1.0.0", ChangeType: "DEPENDENCY_ADDED"},
+	}
+	if !reflect.DeepEqual(fd.Units, want) {
+		t.Errorf("units =\n%+v\nwant\n%+v", fd.Units, want)
+	}
+
+	sd := &SemanticDiff{Files: []FileDiff{*fd}}
+	output := sd.FormatText()
+	for _, line := range []string{
+		"~ dependency github.com/a/b: v1.2.0 -> v2.0.0 [major]",
+		"- dependency github.com/c/d v0.3.0",
+		"+ dependency github.com/e/f (indirect) v1.0.0",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestDiffFile_Config(t *testing.T) {
+	d := NewDiffer()
+
+	fd, err := d.DiffFile("config/app.toml", []byte("[server]\nport = 8080\n"), []byte("[server]\nport = 9090\nhost = \"0.0.0.0\"\n"))
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	want := []UnitDiff{
+		{Kind: KindConfigKey, Name: "server.host", Path: "server.host", Action: ActionAdded, After: "0.0.0.0", ChangeType: "CONFIG_KEY_ADDED"},
+		{Kind: KindConfigKey, Name: "server.port", Path: "server.port", Action: ActionModified, Before: "8080", After: "9090", ChangeType: "CONFIG_VALUE_CHANGED"},
+	}
+	if fd.Lang != "toml" || !reflect.DeepEqual(fd.Units, want) {
+		t.Errorf("lang %s, units =\n%+v\nwant\n%+v", fd.Lang, fd.Units, want)
+	}
+
+	// .env values are secrets and never reach the diff
+	fd, err = d.DiffFile(".env.production", []byte("API_KEY=old-secret\n"), []byte("API_KEY=new-secret\n"))
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	if fd.Lang != "env" || len(fd.Units) != 1 {
+		t.Fatalf("lang %s, units %+v", fd.Lang, fd.Units)
+	}
+	if u := fd.Units[0]; u.Name != "API_KEY" || u.Action != ActionModified || u.Before != "" || u.After != "" {
+		t.Errorf("unexpected .env unit %+v", u)
+	}
+}
+
+func TestDiffFile_HCL(t *testing.T) {
+	before := []byte(`resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
+`)
+	after := []byte(`resource "aws_s3_bucket" "logs" {
+  bucket = "app-logs"
+}
+
+variable "region" {}
+`)
+
+	fd, err := NewDiffer().DiffFile("infra/main.tf", before, after)
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	want := []UnitDiff{
+		{Kind: KindHCLAttribute, Name: "aws_s3_bucket.logs", Path: "aws_s3_bucket.logs.bucket", Action: ActionModified, Before: `"logs"`, After: `"app-logs"`, ChangeType: "HCL_ATTRIBUTE_CHANGED"},
+		{Kind: KindHCLBlock, Name: "var.region", Action: ActionAdded, ChangeType: "HCL_BLOCK_ADDED"},
+	}
+	if fd.Lang != "hcl" || !reflect.DeepEqual(fd.Units, want) {
+		t.Errorf("lang %s, units =\n%+v\nwant\n%+v", fd.Lang, fd.Units, want)
+	}
+
+	sd := &SemanticDiff{Files: []FileDiff{*fd}}
+	output := sd.FormatText()
+	for _, line := range []string{
+		`~ aws_s3_bucket.logs.bucket: "logs" -> "app-logs"`,
+		"+ block var.region",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestDiffFile_Proto(t *testing.T) {
+	before := []byte(`syntax = "proto3";
+message User {
+  string id = 1;
+  string email = 2;
+}
+`)
+	after := []byte(`syntax = "proto3";
+message User {
+  string id = 1;
+  int64 email = 2;
+  string name = 3;
+}
+`)
+
+	fd, err := NewDiffer().DiffFile("api/user.proto", before, after)
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	if fd.Lang != "proto" || len(fd.Units) != 2 {
+		t.Fatalf("lang %s, units %+v", fd.Lang, fd.Units)
+	}
+
+	sd := &SemanticDiff{Files: []FileDiff{*fd}}
+	output := sd.FormatText()
+	for _, line := range []string{
+		"~ field User.email: string email = 2 -> int64 email = 2 (field type changed, breaking)",
+		"+ field User.name: string name = 3",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
 func TestDiffFile_SQL(t *testing.T) {
 	before := []byte(`CREATE TABLE users (
   id INTEGER PRIMARY KEY,
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+
+	"kai-core/detect"
 )
 
 // FormatText formats a semantic diff as human-readable text.
@@ -88,7 +90,7 @@ func formatUnit(u UnitDiff) string {
 			sb.WriteString(fmt.Sprintf("  %s %s %s\n", actionChar, kindStr, u.Name))
 		}
 
-	case KindJSONKey, KindYAMLKey:
+	case KindJSONKey, KindYAMLKey, KindConfigKey, KindHCLAttribute:
 		path := u.Path
 		if path == "" {
 			path = u.Name
@@ -100,11 +102,63 @@ func formatUnit(u UnitDiff) string {
 		}
 
 	case KindSQLTable:
-		sb.WriteString(fmt.Sprintf("  %s table %s\n", actionChar, u.Name))
+		if u.ChangeType == "TABLE_RENAMED" {
+			sb.WriteString(fmt.Sprintf("  %s table %s -> %s%s\n", actionChar, u.Before, u.After, changeNote(u)))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %s table %s%s\n", actionChar, u.Name, changeNote(u)))
+		}
+
+	case KindSQLIndex, KindSQLConstraint, KindSQLView, KindSQLEnum:
+		name := u.Name
+		switch {
+		case u.Kind == KindSQLIndex && u.Path != "":
+			name += " on " + u.Path
+		case u.Kind == KindSQLConstraint && u.Path != "":
+			name = u.Path
+		case strings.HasPrefix(u.ChangeType, "ENUM_VALUE_"):
+			name += " value '" + u.Before + u.After + "'"
+		}
+		if u.Action == ActionModified && u.Before != "" && u.After != "" {
+			name += ": " + truncateValue(u.Before) + " -> " + truncateValue(u.After)
+		}
+		sb.WriteString(fmt.Sprintf("  %s %s %s%s\n", actionChar, kindStr, name, changeNote(u)))
+
+	case KindProtoPackage, KindProtoMessage, KindProtoField, KindProtoEnum, KindProtoValue, KindProtoService, KindProtoRPC:
+		decl := ""
+		switch {
+		case u.Action == ActionModified:
+			decl = ": " + u.Before + " -> " + u.After
+		case u.After != "":
+			decl = ": " + u.After
+		case u.Before != "":
+			decl = ": " + u.Before
+		}
+		sb.WriteString(fmt.Sprintf("  %s %s %s%s%s\n", actionChar, kindStr, u.Name, decl, changeNote(u)))
+
+	case KindDependency:
+		name := u.Name
+		if u.Path != "" {
+			name += " (" + u.Path + ")"
+		}
+		switch u.Action {
+		case ActionModified:
+			delta, downgrade := detect.SemverDelta(u.Before, u.After)
+			if downgrade {
+				delta += " downgrade"
+			}
+			if delta != "" {
+				delta = " [" + delta + "]"
+			}
+			sb.WriteString(fmt.Sprintf("  %s %s %s: %s -> %s%s\n", actionChar, kindStr, name, u.Before, u.After, delta))
+		case ActionAdded:
+			sb.WriteString(strings.TrimRight(fmt.Sprintf("  %s %s %s %s", actionChar, kindStr, name, u.After), " ") + "\n")
+		default:
+			sb.WriteString(strings.TrimRight(fmt.Sprintf("  %s %s %s %s", actionChar, kindStr, name, u.Before), " ") + "\n")
+		}
 
 	case KindSQLColumn:
 		if u.Action == ActionModified {
-			sb.WriteString(fmt.Sprintf("  %s %s: %s -> %s\n", actionChar, u.Path, truncateValue(u.Before), truncateValue(u.After)))
+			sb.WriteString(fmt.Sprintf("  %s %s: %s -> %s%s\n", actionChar, u.Path, truncateValue(u.Before), truncateValue(u.After), changeNote(u)))
 		} else {
 			defStr := ""
 			if u.After != "" {
@@ -112,7 +166,7 @@ func formatUnit(u UnitDiff) string {
 			} else if u.Before != "" {
 				defStr = ": " + truncateValue(u.Before)
 			}
-			sb.WriteString(fmt.Sprintf("  %s %s%s\n", actionChar, u.Path, defStr))
+			sb.WriteString(fmt.Sprintf("  %s %s%s%s\n", actionChar, u.Path, defStr, changeNote(u)))
 		}
 
 	default:
@@ -155,11 +209,57 @@ func formatKind(kind UnitKind) string {
 		return "table"
 	case KindSQLColumn:
 		return ""
+	case KindSQLIndex:
+		return "index"
+	case KindSQLConstraint:
+		return "constraint"
+	case KindSQLView:
+		return "view"
+	case KindSQLEnum:
+		return "enum"
+	case KindDependency:
+		return "dependency"
+	case KindConfigKey, KindHCLAttribute:
+		return ""
+	case KindHCLBlock:
+		return "block"
+	case KindProtoPackage:
+		return "package"
+	case KindProtoMessage:
+		return "message"
+	case KindProtoField:
+		return "field"
+	case KindProtoEnum:
+		return "enum"
+	case KindProtoValue:
+		return "enum value"
+	case KindProtoService:
+		return "service"
+	case KindProtoRPC:
+		return "rpc"
 	default:
 		return string(kind)
 	}
 }
 
+// changeNote describes a schema change for the text format, e.g.
+// " (column type narrowed, breaking)". Additions and removals only note
+// whether they break.
+func changeNote(u UnitDiff) string {
+	var notes []string
+	if u.Action == ActionModified && u.ChangeType != "" {
+		changeType := strings.TrimPrefix(u.ChangeType, "PROTO_")
+		notes = append(notes, strings.ToLower(strings.ReplaceAll(changeType, "_", " ")))
+	}
+	if u.Breaking {
+		notes = append(notes, "breaking")
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(notes, ", ") + ")"
+}
+
 func truncateValue(s string) string {
 	// Remove newlines and excessive whitespace
 	s = strings.ReplaceAll(s, "\n", " ")
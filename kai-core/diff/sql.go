@@ -1,108 +1,658 @@
 package diff
 
 import (
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// sqlTable represents a parsed SQL table definition.
+// sqlSchema is a database schema built by replaying DDL statements in order,
+// so a script or a directory of migrations reads as the schema it leaves.
+type sqlSchema struct {
+	Tables  map[string]*sqlTable
+	Indexes map[string]*sqlIndex
+	Views   map[string]*sqlView
+	Enums   map[string]*sqlEnum
+}
+
+// sqlTable represents a table in a replayed schema.
 type sqlTable struct {
-	Name       string
-	Definition string
-	Columns    map[string]*sqlColumn
+	Name        string
+	Definition  string
+	Origin      string // statement that created it, kept across renames
+	Columns     map[string]*sqlColumn
+	Constraints map[string]*sqlConstraint
 }
 
-// sqlColumn represents a parsed SQL column definition.
+// sqlColumn represents a column in a replayed schema.
 type sqlColumn struct {
 	Name       string
 	Type       string
 	Definition string
 	Nullable   bool
 	Default    string
+	Extra      string // constraints and options after the type
+	Origin     string
+}
+
+// sqlConstraint is a table-level constraint. Unnamed constraints get the
+// names Postgres gives them, so a later DROP CONSTRAINT finds them.
+type sqlConstraint struct {
+	Name       string
+	Kind       string // "primary key", "unique", "foreign key", "check" or "exclude"
+	Definition string
+}
+
+// sqlIndex represents an index in a replayed schema.
+type sqlIndex struct {
+	Name       string
+	Table      string
+	Columns    []string
+	Unique     bool
+	Definition string
+}
+
+// sqlView represents a view or materialized view.
+type sqlView struct {
+	Name       string
+	Definition string
 }
 
-var createTableStartRe = regexp.MustCompile(`(?i)CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?["'\x60]?(\w+)["'\x60]?\s*\(`)
+// sqlEnum represents an enum type and its values in order.
+type sqlEnum struct {
+	Name   string
+	Values []string
+}
+
+func newSQLSchema() *sqlSchema {
+	return &sqlSchema{
+		Tables:  make(map[string]*sqlTable),
+		Indexes: make(map[string]*sqlIndex),
+		Views:   make(map[string]*sqlView),
+		Enums:   make(map[string]*sqlEnum),
+	}
+}
+
+// sqlIdent matches a possibly qualified and quoted identifier, capturing it.
+const sqlIdentPart = "(?:\"[^\"]+\"|`[^`]+`|\\[[^\\]]+\\]|[\\w$]+)"
+const sqlIdent = `(` + sqlIdentPart + `(?:\s*\.\s*` + sqlIdentPart + `)*)`
+
+var (
+	createTableRe   = regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:(?:GLOBAL|LOCAL)\s+)?(?:(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(IF\s+NOT\s+EXISTS\s+)?` + sqlIdent + `\s*\(`)
+	alterTableRe    = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` + sqlIdent + `\s+(.*)$`)
+	dropTableRe     = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?(.*?)(?:\s+(?:CASCADE|RESTRICT))?$`)
+	renameTableRe   = regexp.MustCompile(`(?is)^RENAME\s+TABLE\s+(.*)$`)
+	renamePairRe    = regexp.MustCompile(`(?is)^` + sqlIdent + `\s+TO\s+` + sqlIdent + `$`)
+	createIndexRe   = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(IF\s+NOT\s+EXISTS\s+)?(?:` + sqlIdent + `\s+)?ON\s+(?:ONLY\s+)?` + sqlIdent + `(.*)$`)
+	dropIndexRe     = regexp.MustCompile(`(?is)^DROP\s+INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+EXISTS\s+)?(.*?)(?:\s+ON\s+` + sqlIdent + `)?(?:\s+(?:CASCADE|RESTRICT))?$`)
+	renameIndexRe   = regexp.MustCompile(`(?is)^ALTER\s+INDEX\s+(?:IF\s+EXISTS\s+)?` + sqlIdent + `\s+RENAME\s+TO\s+` + sqlIdent + `$`)
+	createViewRe    = regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:(?:TEMP|TEMPORARY)\s+)?(?:MATERIALIZED\s+)?VIEW\s+(?:IF\s+NOT\s+EXISTS\s+)?` + sqlIdent + `(?:\s*\([^)]*\))?\s+AS\s+(.*)$`)
+	dropViewRe      = regexp.MustCompile(`(?is)^DROP\s+(?:MATERIALIZED\s+)?VIEW\s+(?:IF\s+EXISTS\s+)?(.*?)(?:\s+(?:CASCADE|RESTRICT))?$`)
+	renameViewRe    = regexp.MustCompile(`(?is)^ALTER\s+(?:MATERIALIZED\s+)?VIEW\s+(?:IF\s+EXISTS\s+)?` + sqlIdent + `\s+RENAME\s+TO\s+` + sqlIdent + `$`)
+	createEnumRe    = regexp.MustCompile(`(?is)^CREATE\s+TYPE\s+` + sqlIdent + `\s+AS\s+ENUM\s*\((.*)\)$`)
+	enumAddValueRe  = regexp.MustCompile(`(?is)^ALTER\s+TYPE\s+` + sqlIdent + `\s+ADD\s+VALUE\s+(?:IF\s+NOT\s+EXISTS\s+)?'((?:[^']|'')*)'(?:\s+(BEFORE|AFTER)\s+'((?:[^']|'')*)')?$`)
+	enumRenameValRe = regexp.MustCompile(`(?is)^ALTER\s+TYPE\s+` + sqlIdent + `\s+RENAME\s+VALUE\s+'((?:[^']|'')*)'\s+TO\s+'((?:[^']|'')*)'$`)
+	renameTypeRe    = regexp.MustCompile(`(?is)^ALTER\s+TYPE\s+` + sqlIdent + `\s+RENAME\s+TO\s+` + sqlIdent + `$`)
+	dropTypeRe      = regexp.MustCompile(`(?is)^DROP\s+TYPE\s+(?:IF\s+EXISTS\s+)?(.*?)(?:\s+(?:CASCADE|RESTRICT))?$`)
+
+	// ALTER TABLE actions, tried in order
+	renameToActRe         = regexp.MustCompile(`(?is)^RENAME\s+TO\s+` + sqlIdent + `$`)
+	renameConstraintActRe = regexp.MustCompile(`(?is)^RENAME\s+CONSTRAINT\s+` + sqlIdent + `\s+TO\s+` + sqlIdent + `$`)
+	renameColumnActRe     = regexp.MustCompile(`(?is)^RENAME\s+(?:COLUMN\s+)?` + sqlIdent + `\s+TO\s+` + sqlIdent + `$`)
+	addConstraintActRe    = regexp.MustCompile(`(?is)^ADD\s+((?:CONSTRAINT|PRIMARY\s+KEY|UNIQUE|FOREIGN\s+KEY|CHECK|EXCLUDE)\b.*)$`)
+	addIndexActRe         = regexp.MustCompile(`(?is)^ADD\s+(?:FULLTEXT\s+|SPATIAL\s+)?(?:INDEX|KEY)\s+(.*)$`)
+	addColumnActRe        = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(IF\s+NOT\s+EXISTS\s+)?(.*)$`)
+	dropPrimaryKeyActRe   = regexp.MustCompile(`(?is)^DROP\s+PRIMARY\s+KEY$`)
+	dropConstraintActRe   = regexp.MustCompile(`(?is)^DROP\s+(?:CONSTRAINT|FOREIGN\s+KEY|CHECK)\s+(?:IF\s+EXISTS\s+)?` + sqlIdent + `(?:\s+(?:CASCADE|RESTRICT))?$`)
+	dropIndexActRe        = regexp.MustCompile(`(?is)^DROP\s+(?:INDEX|KEY)\s+` + sqlIdent + `$`)
+	dropColumnActRe       = regexp.MustCompile(`(?is)^DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?` + sqlIdent + `(?:\s+(?:CASCADE|RESTRICT))?$`)
+	alterColumnActRe      = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?` + sqlIdent + `\s+(.*)$`)
+	modifyColumnActRe     = regexp.MustCompile(`(?is)^MODIFY\s+(?:COLUMN\s+)?(.*)$`)
+	changeColumnActRe     = regexp.MustCompile(`(?is)^CHANGE\s+(?:COLUMN\s+)?` + sqlIdent + `\s+(.*)$`)
+
+	// ALTER COLUMN changes
+	setTypeRe      = regexp.MustCompile(`(?is)^(?:SET\s+DATA\s+)?TYPE\s+(.*?)(?:\s+(?:USING|COLLATE)\s+.*)?$`)
+	setNotNullRe   = regexp.MustCompile(`(?is)^SET\s+NOT\s+NULL$`)
+	dropNotNullRe  = regexp.MustCompile(`(?is)^DROP\s+NOT\s+NULL$`)
+	setDefaultRe   = regexp.MustCompile(`(?is)^SET\s+DEFAULT\s+(.*)$`)
+	dropDefaultRe  = regexp.MustCompile(`(?is)^DROP\s+DEFAULT$`)
+	constraintDef  = regexp.MustCompile(`(?is)^(?:CONSTRAINT|PRIMARY\s+KEY|FOREIGN\s+KEY|UNIQUE|CHECK|EXCLUDE)\b`)
+	constraintName = regexp.MustCompile(`(?is)^CONSTRAINT\s+` + sqlIdent + `\s+`)
+	uniqueKeyName  = regexp.MustCompile(`(?is)^UNIQUE\s+(?:KEY|INDEX)\s+` + sqlIdent + `\s*\(`)
+	inlineIndexRe  = regexp.MustCompile(`(?is)^(?:FULLTEXT\s+|SPATIAL\s+)?(?:INDEX|KEY)\s+(?:` + sqlIdent + `\s*)?(\(.*)$`)
+	sqlStringRe    = regexp.MustCompile(`'((?:[^']|'')*)'`)
+	dollarQuoteRe  = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+	migrationDown  = regexp.MustCompile(`(?im)^\s*--\s*(?:migrate:down|\+goose\s+down|\+migrate\s+down)\b`)
+)
 
 // parseSQL extracts table and column definitions from SQL content.
-// Uses manual parsing to handle nested parentheses in column types like VARCHAR(255).
 func parseSQL(content string) map[string]*sqlTable {
-	tables := make(map[string]*sqlTable)
-
-	// Find all CREATE TABLE starts
-	matches := createTableStartRe.FindAllStringSubmatchIndex(content, -1)
-	for _, match := range matches {
-		// match[0]:match[1] is the full match
-		// match[2]:match[3] is the table name
-		tableName := strings.ToLower(content[match[2]:match[3]])
-		startIdx := match[1] // Position right after the opening (
-
-		// Find the matching closing ) by counting parentheses
-		depth := 1
-		endIdx := startIdx
-		for i := startIdx; i < len(content) && depth > 0; i++ {
-			switch content[i] {
-			case '(':
-				depth++
-			case ')':
-				depth--
-				if depth == 0 {
-					endIdx = i
+	return replaySQL(content).Tables
+}
+
+// replaySQL builds the schema a single script leaves behind.
+func replaySQL(content string) *sqlSchema {
+	s := newSQLSchema()
+	s.apply("", content)
+	return s
+}
+
+// replayMigrations builds the schema a directory of migrations leaves
+// behind, applying the files in natural order (so V2 runs before V10) and
+// skipping down migrations.
+func replayMigrations(files map[string]string) *sqlSchema {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		if !isDownMigration(path) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return naturalLess(paths[i], paths[j]) })
+
+	s := newSQLSchema()
+	for _, path := range paths {
+		s.apply(path, files[path])
+	}
+	return s
+}
+
+// isDownMigration reports whether a file only reverts a migration.
+func isDownMigration(path string) bool {
+	lower := strings.ToLower(filepath.ToSlash(path))
+	return strings.HasSuffix(lower, ".down.sql") || strings.HasSuffix(lower, "/down.sql") || lower == "down.sql"
+}
+
+// MigrationDir returns the migrations directory a SQL file belongs to - the
+// nearest enclosing directory named migrations, migration or migrate - or ""
+// when the file is not a migration.
+func MigrationDir(path string) string {
+	if strings.ToLower(filepath.Ext(path)) != ".sql" {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := len(parts) - 2; i >= 0; i-- {
+		switch strings.ToLower(parts[i]) {
+		case "migrations", "migration", "migrate":
+			return strings.Join(parts[:i+1], "/")
+		}
+	}
+	return ""
+}
+
+// naturalLess orders strings with digit runs compared as numbers.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// apply replays the statements of one script. Statements the schema model
+// does not cover, and changes to objects it has not seen, are ignored.
+func (s *sqlSchema) apply(source, content string) {
+	if loc := migrationDown.FindStringIndex(content); loc != nil {
+		content = content[:loc[0]]
+	}
+	for i, stmt := range splitSQLStatements(content) {
+		s.exec(stmt, source+"#"+strconv.Itoa(i))
+	}
+}
+
+// splitSQLStatements splits a script on semicolons outside quotes, comments
+// and dollar-quoted bodies, dropping the comments.
+func splitSQLStatements(content string) []string {
+	var stmts []string
+	var current strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case strings.HasPrefix(content[i:], "--"):
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end + 3
+			}
+			current.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(content) && content[end] != c {
+				end++
+			}
+			end = min(end, len(content)-1)
+			current.WriteString(content[i : end+1])
+			i = end
+		case c == '$' && dollarQuoteRe.MatchString(content[i:]):
+			tag := dollarQuoteRe.FindString(content[i:])
+			end := len(content)
+			if n := strings.Index(content[i+len(tag):], tag); n >= 0 {
+				end = i + len(tag) + n + len(tag)
+			}
+			current.WriteString(content[i:end])
+			i = end - 1
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	return stmts
+}
+
+// exec applies one statement to the schema.
+func (s *sqlSchema) exec(stmt, origin string) {
+	switch {
+	case createTableRe.MatchString(stmt):
+		s.createTable(stmt, origin)
+
+	case alterTableRe.MatchString(stmt):
+		m := alterTableRe.FindStringSubmatch(stmt)
+		if table, ok := s.Tables[normalizeIdent(m[1])]; ok {
+			for _, action := range splitColumns(m[2]) {
+				s.alterTable(table, strings.TrimSpace(action), origin)
+			}
+		}
+
+	case dropTableRe.MatchString(stmt):
+		for _, name := range splitIdentList(dropTableRe.FindStringSubmatch(stmt)[1]) {
+			delete(s.Tables, name)
+			for key, idx := range s.Indexes {
+				if idx.Table == name {
+					delete(s.Indexes, key)
+				}
+			}
+		}
+
+	case renameTableRe.MatchString(stmt):
+		for _, pair := range strings.Split(renameTableRe.FindStringSubmatch(stmt)[1], ",") {
+			if m := renamePairRe.FindStringSubmatch(strings.TrimSpace(pair)); m != nil {
+				s.renameTable(normalizeIdent(m[1]), normalizeIdent(m[2]))
+			}
+		}
+
+	case createIndexRe.MatchString(stmt):
+		m := createIndexRe.FindStringSubmatch(stmt)
+		table := normalizeIdent(m[4])
+		cols := parenColumns(m[5])
+		name := normalizeIdent(m[3])
+		if name == "" {
+			name = table + "_" + strings.Join(cols, "_") + "_idx"
+		}
+		if _, exists := s.Indexes[name]; exists && m[2] != "" {
+			return
+		}
+		s.Indexes[name] = &sqlIndex{Name: name, Table: table, Columns: cols, Unique: m[1] != "", Definition: collapseSQL(stmt)}
+
+	case dropIndexRe.MatchString(stmt):
+		m := dropIndexRe.FindStringSubmatch(stmt)
+		for _, name := range splitIdentList(m[1]) {
+			s.dropIndex(name)
+		}
+
+	case renameIndexRe.MatchString(stmt):
+		m := renameIndexRe.FindStringSubmatch(stmt)
+		if idx, ok := s.Indexes[normalizeIdent(m[1])]; ok {
+			delete(s.Indexes, idx.Name)
+			idx.Name = normalizeIdent(m[2])
+			s.Indexes[idx.Name] = idx
+		}
+
+	case createViewRe.MatchString(stmt):
+		m := createViewRe.FindStringSubmatch(stmt)
+		name := normalizeIdent(m[1])
+		s.Views[name] = &sqlView{Name: name, Definition: collapseSQL(m[2])}
+
+	case dropViewRe.MatchString(stmt):
+		for _, name := range splitIdentList(dropViewRe.FindStringSubmatch(stmt)[1]) {
+			delete(s.Views, name)
+		}
+
+	case renameViewRe.MatchString(stmt):
+		m := renameViewRe.FindStringSubmatch(stmt)
+		if view, ok := s.Views[normalizeIdent(m[1])]; ok {
+			delete(s.Views, view.Name)
+			view.Name = normalizeIdent(m[2])
+			s.Views[view.Name] = view
+		}
+
+	case createEnumRe.MatchString(stmt):
+		m := createEnumRe.FindStringSubmatch(stmt)
+		name := normalizeIdent(m[1])
+		enum := &sqlEnum{Name: name}
+		for _, v := range sqlStringRe.FindAllStringSubmatch(m[2], -1) {
+			enum.Values = append(enum.Values, unquoteSQLString(v[1]))
+		}
+		s.Enums[name] = enum
+
+	case enumAddValueRe.MatchString(stmt):
+		m := enumAddValueRe.FindStringSubmatch(stmt)
+		enum, ok := s.Enums[normalizeIdent(m[1])]
+		value := unquoteSQLString(m[2])
+		if !ok || containsString(enum.Values, value) {
+			return
+		}
+		at := len(enum.Values)
+		for i, v := range enum.Values {
+			if m[3] != "" && v == unquoteSQLString(m[4]) {
+				at = i
+				if strings.EqualFold(m[3], "AFTER") {
+					at++
 				}
 			}
 		}
+		enum.Values = append(enum.Values[:at], append([]string{value}, enum.Values[at:]...)...)
 
-		if endIdx <= startIdx {
-			continue // Malformed SQL
+	case enumRenameValRe.MatchString(stmt):
+		m := enumRenameValRe.FindStringSubmatch(stmt)
+		if enum, ok := s.Enums[normalizeIdent(m[1])]; ok {
+			for i, v := range enum.Values {
+				if v == unquoteSQLString(m[2]) {
+					enum.Values[i] = unquoteSQLString(m[3])
+				}
+			}
 		}
 
-		columnsDef := content[startIdx:endIdx]
-		tableDef := content[match[0]:endIdx+1]
+	case renameTypeRe.MatchString(stmt):
+		m := renameTypeRe.FindStringSubmatch(stmt)
+		if enum, ok := s.Enums[normalizeIdent(m[1])]; ok {
+			delete(s.Enums, enum.Name)
+			enum.Name = normalizeIdent(m[2])
+			s.Enums[enum.Name] = enum
+		}
 
-		table := &sqlTable{
-			Name:       tableName,
-			Definition: strings.TrimSpace(tableDef),
-			Columns:    make(map[string]*sqlColumn),
+	case dropTypeRe.MatchString(stmt):
+		for _, name := range splitIdentList(dropTypeRe.FindStringSubmatch(stmt)[1]) {
+			delete(s.Enums, name)
+		}
+	}
+}
+
+// createTable adds a table from a CREATE TABLE statement.
+func (s *sqlSchema) createTable(stmt, origin string) {
+	loc := createTableRe.FindStringSubmatchIndex(stmt)
+	name := normalizeIdent(stmt[loc[4]:loc[5]])
+	if _, exists := s.Tables[name]; exists && loc[2] >= 0 {
+		return
+	}
+
+	// Find the matching closing ) by counting parentheses
+	start := loc[1]
+	depth := 1
+	end := len(stmt)
+	for i := start; i < len(stmt) && depth > 0; i++ {
+		switch stmt[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				end = i
+			}
 		}
+	}
 
-		// Parse columns
-		parseTableColumns(columnsDef, table)
-		tables[tableName] = table
+	table := &sqlTable{
+		Name:        name,
+		Definition:  strings.TrimSpace(stmt),
+		Origin:      origin + ":" + name,
+		Columns:     make(map[string]*sqlColumn),
+		Constraints: make(map[string]*sqlConstraint),
 	}
+	s.Tables[name] = table
 
-	return tables
+	for _, part := range splitColumns(stmt[start:end]) {
+		s.addTableElement(table, strings.TrimSpace(part), origin)
+	}
+}
+
+// addTableElement adds a column, constraint or inline index from a CREATE
+// TABLE body or an ALTER TABLE ADD.
+func (s *sqlSchema) addTableElement(table *sqlTable, part, origin string) {
+	if part == "" {
+		return
+	}
+	switch {
+	case constraintDef.MatchString(part):
+		s.addConstraint(table, part)
+	case inlineIndexRe.MatchString(part):
+		m := inlineIndexRe.FindStringSubmatch(part)
+		cols := parenColumns(m[2])
+		name := normalizeIdent(m[1])
+		if name == "" {
+			name = table.Name + "_" + strings.Join(cols, "_") + "_idx"
+		}
+		s.Indexes[name] = &sqlIndex{Name: name, Table: table.Name, Columns: cols, Definition: collapseSQL(part)}
+	case strings.HasPrefix(strings.ToUpper(part), "LIKE "):
+		// Copied definitions are not followed
+	default:
+		if col := parseColumnDef(part); col != nil {
+			col.Origin = origin + ":" + table.Name + "." + col.Name
+			table.Columns[col.Name] = col
+		}
+	}
 }
 
-// parseTableColumns extracts column definitions from CREATE TABLE body.
-func parseTableColumns(columnsDef string, table *sqlTable) {
-	// Split by comma, but be careful with parentheses (for types like VARCHAR(255))
-	parts := splitColumns(columnsDef)
+// addConstraint parses and adds a table-level constraint.
+func (s *sqlSchema) addConstraint(table *sqlTable, def string) {
+	def = collapseSQL(def)
+	name, rest := "", def
+	if m := constraintName.FindStringSubmatch(def); m != nil {
+		name, rest = normalizeIdent(m[1]), def[len(m[0]):]
+	} else if m := uniqueKeyName.FindStringSubmatch(def); m != nil {
+		name = normalizeIdent(m[1])
+	}
 
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
+	upper := strings.ToUpper(rest)
+	kind := ""
+	for _, k := range []string{"PRIMARY KEY", "UNIQUE", "FOREIGN KEY", "CHECK", "EXCLUDE"} {
+		if strings.HasPrefix(upper, k) {
+			kind = strings.ToLower(k)
+			break
 		}
+	}
+	if kind == "" {
+		return
+	}
 
-		// Skip constraints
-		upperPart := strings.ToUpper(part)
-		if strings.HasPrefix(upperPart, "PRIMARY KEY") ||
-			strings.HasPrefix(upperPart, "FOREIGN KEY") ||
-			strings.HasPrefix(upperPart, "UNIQUE") ||
-			strings.HasPrefix(upperPart, "CHECK") ||
-			strings.HasPrefix(upperPart, "CONSTRAINT") ||
-			strings.HasPrefix(upperPart, "INDEX") ||
-			strings.HasPrefix(upperPart, "KEY ") {
-			continue
+	if name == "" {
+		cols := strings.Join(parenColumns(rest), "_")
+		switch kind {
+		case "primary key":
+			name = table.Name + "_pkey"
+		case "unique":
+			name = table.Name + "_" + cols + "_key"
+		case "foreign key":
+			name = table.Name + "_" + cols + "_fkey"
+		case "check":
+			name = table.Name + "_check"
+		default:
+			name = table.Name + "_excl"
+		}
+		for base, n := name, 1; table.Constraints[name] != nil; n++ {
+			name = base + strconv.Itoa(n)
 		}
+	}
+	table.Constraints[name] = &sqlConstraint{Name: name, Kind: kind, Definition: def}
+}
+
+// alterTable applies one ALTER TABLE action.
+func (s *sqlSchema) alterTable(table *sqlTable, action, origin string) {
+	switch {
+	case renameToActRe.MatchString(action):
+		s.renameTable(table.Name, normalizeIdent(renameToActRe.FindStringSubmatch(action)[1]))
 
-		// Parse column definition
-		col := parseColumnDef(part)
-		if col != nil {
+	case renameConstraintActRe.MatchString(action):
+		m := renameConstraintActRe.FindStringSubmatch(action)
+		if c, ok := table.Constraints[normalizeIdent(m[1])]; ok {
+			delete(table.Constraints, c.Name)
+			c.Name = normalizeIdent(m[2])
+			table.Constraints[c.Name] = c
+		}
+
+	case renameColumnActRe.MatchString(action):
+		m := renameColumnActRe.FindStringSubmatch(action)
+		if col, ok := table.Columns[normalizeIdent(m[1])]; ok {
+			delete(table.Columns, col.Name)
+			for _, idx := range s.Indexes {
+				if idx.Table == table.Name {
+					for i, c := range idx.Columns {
+						if c == col.Name {
+							idx.Columns[i] = normalizeIdent(m[2])
+						}
+					}
+				}
+			}
+			col.Name = normalizeIdent(m[2])
+			col.render()
+			table.Columns[col.Name] = col
+		}
+
+	case addConstraintActRe.MatchString(action):
+		s.addConstraint(table, addConstraintActRe.FindStringSubmatch(action)[1])
+
+	case addIndexActRe.MatchString(action):
+		s.addTableElement(table, strings.TrimSpace(action[len("ADD"):]), origin)
+
+	case addColumnActRe.MatchString(action):
+		m := addColumnActRe.FindStringSubmatch(action)
+		if col := parseColumnDef(m[2]); col != nil {
+			if _, exists := table.Columns[col.Name]; exists && m[1] != "" {
+				return
+			}
+			s.addTableElement(table, m[2], origin)
+		}
+
+	case dropPrimaryKeyActRe.MatchString(action):
+		for name, c := range table.Constraints {
+			if c.Kind == "primary key" {
+				delete(table.Constraints, name)
+			}
+		}
+
+	case dropConstraintActRe.MatchString(action):
+		delete(table.Constraints, normalizeIdent(dropConstraintActRe.FindStringSubmatch(action)[1]))
+
+	case dropIndexActRe.MatchString(action):
+		s.dropIndex(normalizeIdent(dropIndexActRe.FindStringSubmatch(action)[1]))
+
+	case dropColumnActRe.MatchString(action):
+		name := normalizeIdent(dropColumnActRe.FindStringSubmatch(action)[1])
+		delete(table.Columns, name)
+		// Indexes on the column go with it
+		for key, idx := range s.Indexes {
+			if idx.Table == table.Name && containsString(idx.Columns, name) {
+				delete(s.Indexes, key)
+			}
+		}
+
+	case alterColumnActRe.MatchString(action):
+		m := alterColumnActRe.FindStringSubmatch(action)
+		col, ok := table.Columns[normalizeIdent(m[1])]
+		if !ok {
+			return
+		}
+		change := strings.TrimSpace(m[2])
+		switch {
+		case setTypeRe.MatchString(change):
+			col.Type = strings.TrimSpace(setTypeRe.FindStringSubmatch(change)[1])
+		case setNotNullRe.MatchString(change):
+			col.Nullable = false
+		case dropNotNullRe.MatchString(change):
+			col.Nullable = true
+		case setDefaultRe.MatchString(change):
+			col.Default = strings.TrimSpace(setDefaultRe.FindStringSubmatch(change)[1])
+		case dropDefaultRe.MatchString(change):
+			col.Default = ""
+		}
+		col.render()
+
+	case modifyColumnActRe.MatchString(action):
+		col := parseColumnDef(modifyColumnActRe.FindStringSubmatch(action)[1])
+		if col == nil {
+			return
+		}
+		if old, ok := table.Columns[col.Name]; ok {
+			col.Origin = old.Origin
+			table.Columns[col.Name] = col
+		}
+
+	case changeColumnActRe.MatchString(action):
+		m := changeColumnActRe.FindStringSubmatch(action)
+		old, ok := table.Columns[normalizeIdent(m[1])]
+		col := parseColumnDef(m[2])
+		if ok && col != nil {
+			delete(table.Columns, old.Name)
+			col.Origin = old.Origin
 			table.Columns[col.Name] = col
 		}
 	}
 }
 
+func (s *sqlSchema) renameTable(from, to string) {
+	table, ok := s.Tables[from]
+	if !ok {
+		return
+	}
+	delete(s.Tables, from)
+	table.Name = to
+	s.Tables[to] = table
+	for _, idx := range s.Indexes {
+		if idx.Table == from {
+			idx.Table = to
+		}
+	}
+}
+
+// dropIndex drops an index, or a unique constraint MySQL treats as one.
+func (s *sqlSchema) dropIndex(name string) {
+	if _, ok := s.Indexes[name]; ok {
+		delete(s.Indexes, name)
+		return
+	}
+	for _, table := range s.Tables {
+		delete(table.Constraints, name)
+	}
+}
+
 // splitColumns splits column definitions by comma, respecting parentheses.
 func splitColumns(s string) []string {
 	var parts []string
@@ -136,6 +686,14 @@ func splitColumns(s string) []string {
 	return parts
 }
 
+// columnClauseKeywords end a column type or default expression.
+var columnClauseKeywords = map[string]bool{
+	"NOT": true, "NULL": true, "DEFAULT": true, "PRIMARY": true, "UNIQUE": true,
+	"REFERENCES": true, "CHECK": true, "CONSTRAINT": true, "COLLATE": true,
+	"GENERATED": true, "AUTO_INCREMENT": true, "AUTOINCREMENT": true,
+	"IDENTITY": true, "COMMENT": true, "ON": true, "CHARSET": true,
+}
+
 // parseColumnDef parses a single column definition.
 func parseColumnDef(def string) *sqlColumn {
 	def = strings.TrimSpace(def)
@@ -149,9 +707,7 @@ func parseColumnDef(def string) *sqlColumn {
 		return nil
 	}
 
-	// First token is column name (remove quotes)
-	name := strings.Trim(tokens[0], "\"`'")
-	name = strings.ToLower(name)
+	name := normalizeIdent(tokens[0])
 
 	// Skip if it's a SQL keyword
 	upperName := strings.ToUpper(name)
@@ -160,38 +716,67 @@ func parseColumnDef(def string) *sqlColumn {
 		return nil
 	}
 
-	// Second token is type (possibly with size)
-	colType := tokens[1]
+	// The type runs until the first column clause, e.g. DOUBLE PRECISION
+	i := 2
+	for i < len(tokens) && !columnClauseKeywords[strings.ToUpper(tokens[i])] &&
+		!(strings.EqualFold(tokens[i], "CHARACTER") && i+1 < len(tokens) && strings.EqualFold(tokens[i+1], "SET")) {
+		i++
+	}
 
 	col := &sqlColumn{
-		Name:       name,
-		Type:       colType,
-		Definition: def,
-		Nullable:   true,
-	}
-
-	// Check for NOT NULL
-	upperDef := strings.ToUpper(def)
-	if strings.Contains(upperDef, "NOT NULL") {
-		col.Nullable = false
-	}
-
-	// Check for DEFAULT
-	if idx := strings.Index(upperDef, "DEFAULT "); idx != -1 {
-		rest := def[idx+8:]
-		// Extract default value (until next keyword or end)
-		endIdx := len(rest)
-		for _, kw := range []string{" NOT ", " NULL", " PRIMARY", " UNIQUE", " REFERENCES", " CHECK", ","} {
-			if kwIdx := strings.Index(strings.ToUpper(rest), kw); kwIdx != -1 && kwIdx < endIdx {
-				endIdx = kwIdx
+		Name:     name,
+		Type:     strings.Join(tokens[1:i], " "),
+		Nullable: true,
+	}
+
+	var extra []string
+	for i < len(tokens) {
+		switch upper := strings.ToUpper(tokens[i]); {
+		case upper == "NOT" && i+1 < len(tokens) && strings.EqualFold(tokens[i+1], "NULL"):
+			col.Nullable = false
+			i += 2
+		case upper == "NULL":
+			col.Nullable = true
+			i++
+		case upper == "DEFAULT" && i+1 < len(tokens):
+			end := i + 2
+			for end < len(tokens) && !columnClauseKeywords[strings.ToUpper(tokens[end])] {
+				end++
+			}
+			col.Default = strings.Join(tokens[i+1:end], " ")
+			if strings.EqualFold(col.Default, "NULL") {
+				col.Default = ""
 			}
+			i = end
+		default:
+			if upper == "PRIMARY" {
+				col.Nullable = false
+			}
+			extra = append(extra, tokens[i])
+			i++
 		}
-		col.Default = strings.TrimSpace(rest[:endIdx])
 	}
+	col.Extra = strings.Join(extra, " ")
+	col.render()
 
 	return col
 }
 
+// render rebuilds the column definition after a change.
+func (c *sqlColumn) render() {
+	parts := []string{c.Name, c.Type}
+	if !c.Nullable && !strings.Contains(strings.ToUpper(c.Extra), "PRIMARY KEY") {
+		parts = append(parts, "NOT NULL")
+	}
+	if c.Default != "" {
+		parts = append(parts, "DEFAULT "+c.Default)
+	}
+	if c.Extra != "" {
+		parts = append(parts, c.Extra)
+	}
+	c.Definition = strings.Join(parts, " ")
+}
+
 // tokenizeColumnDef splits a column definition into tokens.
 func tokenizeColumnDef(def string) []string {
 	var tokens []string
@@ -216,7 +801,7 @@ func tokenizeColumnDef(def string) []string {
 		case ch == ')':
 			depth--
 			current.WriteRune(ch)
-		case (ch == ' ' || ch == '\t' || ch == '\n') && depth == 0 && !inQuote:
+		case (ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r') && depth == 0 && !inQuote:
 			if current.Len() > 0 {
 				tokens = append(tokens, current.String())
 				current.Reset()
@@ -233,6 +818,183 @@ func tokenizeColumnDef(def string) []string {
 	return tokens
 }
 
+// normalizeIdent unquotes and lowercases an identifier, dropping the
+// default public schema so qualified and bare names match.
+func normalizeIdent(s string) string {
+	var parts []string
+	for _, part := range strings.Split(s, ".") {
+		part = strings.TrimSpace(part)
+		part = strings.Trim(part, "\"`[]")
+		if part != "" {
+			parts = append(parts, strings.ToLower(part))
+		}
+	}
+	if len(parts) == 2 && parts[0] == "public" {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, ".")
+}
+
+// splitIdentList splits a comma-separated list of identifiers.
+func splitIdentList(s string) []string {
+	var names []string
+	for _, part := range strings.Split(s, ",") {
+		if name := normalizeIdent(part); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parenColumns returns the column names in the first parenthesized list.
+func parenColumns(s string) []string {
+	start := strings.Index(s, "(")
+	if start < 0 {
+		return nil
+	}
+	end := strings.LastIndex(s, ")")
+	if nested := strings.Index(s[start+1:], ")"); nested >= 0 {
+		end = start + 1 + nested
+	}
+	if end <= start {
+		return nil
+	}
+	var cols []string
+	for _, part := range strings.Split(s[start+1:end], ",") {
+		if fields := strings.Fields(part); len(fields) > 0 {
+			cols = append(cols, normalizeIdent(fields[0]))
+		}
+	}
+	return cols
+}
+
+func collapseSQL(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func unquoteSQLString(s string) string {
+	return strings.ReplaceAll(s, "''", "'")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlType is a column type reduced to what decides whether a change
+// narrows or widens it.
+type sqlType struct {
+	Family string // "int", "float", "numeric", "string" or the type name
+	Rank   int    // size within the family; -1 for unbounded
+	Scale  int    // numeric scale
+	Array  bool
+}
+
+var sqlTypeAliases = map[string]string{
+	"int2": "smallint", "int4": "int", "integer": "int", "int8": "bigint",
+	"serial": "int", "serial4": "int", "bigserial": "bigint", "serial8": "bigint",
+	"smallserial": "smallint", "serial2": "smallint",
+	"float4": "real", "float8": "double", "double precision": "double", "float": "double",
+	"decimal": "numeric", "character varying": "varchar", "character": "char",
+	"nvarchar": "varchar", "nchar": "char", "bool": "boolean",
+	"timestamp without time zone": "timestamp", "timestamp with time zone": "timestamptz",
+}
+
+var sqlIntRanks = map[string]int{"tinyint": 1, "smallint": 2, "mediumint": 3, "int": 4, "bigint": 5}
+var sqlTextRanks = map[string]int{"tinytext": 255, "text": -1, "mediumtext": -1, "longtext": -1, "clob": -1}
+
+var sqlTypeRe = regexp.MustCompile(`^([a-z][a-z0-9_ ]*?)\s*(?:\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\))?\s*((?:\[\s*\])*)\s*(unsigned)?$`)
+
+func parseSQLType(s string) sqlType {
+	lower := strings.ToLower(collapseSQL(s))
+	m := sqlTypeRe.FindStringSubmatch(lower)
+	if m == nil {
+		return sqlType{Family: lower}
+	}
+	base := m[1]
+	if alias, ok := sqlTypeAliases[base]; ok {
+		base = alias
+	}
+	size := -1
+	if m[2] != "" {
+		size, _ = strconv.Atoi(m[2])
+	}
+	scale, _ := strconv.Atoi(m[3])
+	t := sqlType{Family: base, Rank: size, Scale: scale, Array: m[4] != ""}
+
+	switch {
+	case sqlIntRanks[base] > 0 && m[5] == "":
+		t.Family, t.Rank = "int", sqlIntRanks[base]
+	case base == "real":
+		t.Family, t.Rank = "float", 1
+	case base == "double":
+		t.Family, t.Rank = "float", 2
+	case base == "varchar" || base == "char" || base == "string":
+		t.Family = "string"
+	case sqlTextRanks[base] != 0:
+		t.Family, t.Rank = "string", sqlTextRanks[base]
+	case base == "numeric":
+		t.Family = "numeric"
+	}
+	return t
+}
+
+// compareSQLTypes classifies a column type change as COLUMN_TYPE_WIDENED,
+// COLUMN_TYPE_NARROWED or COLUMN_TYPE_CHANGED, or "" when the types only
+// differ in spelling.
+func compareSQLTypes(before, after string) string {
+	b, a := parseSQLType(before), parseSQLType(after)
+	if b == a {
+		return ""
+	}
+	if b.Family != a.Family || b.Array != a.Array {
+		return "COLUMN_TYPE_CHANGED"
+	}
+
+	if b.Family == "numeric" {
+		// Unbounded numeric holds any precision
+		switch {
+		case a.Rank < 0:
+			return "COLUMN_TYPE_WIDENED"
+		case b.Rank < 0:
+			return "COLUMN_TYPE_NARROWED"
+		case a.Scale >= b.Scale && a.Rank-a.Scale >= b.Rank-b.Scale:
+			return "COLUMN_TYPE_WIDENED"
+		case a.Scale <= b.Scale && a.Rank-a.Scale <= b.Rank-b.Scale:
+			return "COLUMN_TYPE_NARROWED"
+		}
+		return "COLUMN_TYPE_CHANGED"
+	}
+
+	switch {
+	case b.Rank == a.Rank:
+		return "COLUMN_TYPE_CHANGED"
+	case a.Rank < 0 || (b.Rank >= 0 && a.Rank > b.Rank):
+		return "COLUMN_TYPE_WIDENED"
+	default:
+		return "COLUMN_TYPE_NARROWED"
+	}
+}
+
+// autoFilled reports whether the database fills a column on insert.
+func (c *sqlColumn) autoFilled() bool {
+	switch strings.ToLower(c.Type) {
+	case "serial", "bigserial", "smallserial", "serial2", "serial4", "serial8":
+		return true
+	}
+	upper := strings.ToUpper(c.Extra)
+	for _, kw := range []string{"IDENTITY", "AUTO_INCREMENT", "AUTOINCREMENT", "GENERATED"} {
+		if strings.Contains(upper, kw) {
+			return true
+		}
+	}
+	return false
+}
+
 // SQLDiff represents changes between two SQL schemas.
 type SQLDiff struct {
 	TablesAdded    []string
@@ -240,6 +1002,8 @@ type SQLDiff struct {
 	ColumnsAdded   map[string][]string // table -> columns
 	ColumnsRemoved map[string][]string
 	ColumnsChanged map[string][]ColumnChange
+	Changes        []UnitDiff // schema-level changes, e.g. COLUMN_TYPE_NARROWED
+	Breaking       bool       // any change breaks existing rows, queries or writers
 }
 
 // ColumnChange represents a change to a column definition.
@@ -249,64 +1013,408 @@ type ColumnChange struct {
 	After  string
 }
 
-// ComputeSQLDiff compares two SQL schemas.
+// ComputeSQLDiff compares the schemas two SQL scripts leave behind.
 func ComputeSQLDiff(before, after string) *SQLDiff {
-	beforeTables := parseSQL(before)
-	afterTables := parseSQL(after)
+	return computeSchemaDiff(replaySQL(before), replaySQL(after))
+}
+
+// ComputeMigrationDiff compares the schemas two versions of a migration
+// directory build, replaying each version's files in order. The maps go
+// from file path to content.
+func ComputeMigrationDiff(before, after map[string]string) *SQLDiff {
+	return computeSchemaDiff(replayMigrations(before), replayMigrations(after))
+}
 
+func computeSchemaDiff(before, after *sqlSchema) *SQLDiff {
 	diff := &SQLDiff{
 		ColumnsAdded:   make(map[string][]string),
 		ColumnsRemoved: make(map[string][]string),
 		ColumnsChanged: make(map[string][]ColumnChange),
+		Changes:        diffSchemas(before, after),
 	}
 
-	// Find added tables
-	for name := range afterTables {
-		if _, exists := beforeTables[name]; !exists {
+	for _, name := range sortedKeys(after.Tables) {
+		if _, exists := before.Tables[name]; !exists {
 			diff.TablesAdded = append(diff.TablesAdded, name)
 		}
 	}
-
-	// Find removed tables
-	for name := range beforeTables {
-		if _, exists := afterTables[name]; !exists {
+	for _, name := range sortedKeys(before.Tables) {
+		afterTable, exists := after.Tables[name]
+		if !exists {
 			diff.TablesRemoved = append(diff.TablesRemoved, name)
+			continue
 		}
+		beforeTable := before.Tables[name]
+		for _, colName := range sortedKeys(afterTable.Columns) {
+			beforeCol, exists := beforeTable.Columns[colName]
+			if !exists {
+				diff.ColumnsAdded[name] = append(diff.ColumnsAdded[name], colName)
+			} else if afterCol := afterTable.Columns[colName]; afterCol.Definition != beforeCol.Definition {
+				diff.ColumnsChanged[name] = append(diff.ColumnsChanged[name], ColumnChange{
+					Column: colName,
+					Before: beforeCol.Definition,
+					After:  afterCol.Definition,
+				})
+			}
+		}
+		for _, colName := range sortedKeys(beforeTable.Columns) {
+			if _, exists := afterTable.Columns[colName]; !exists {
+				diff.ColumnsRemoved[name] = append(diff.ColumnsRemoved[name], colName)
+			}
+		}
+	}
+
+	for _, u := range diff.Changes {
+		diff.Breaking = diff.Breaking || u.Breaking
 	}
 
-	// Find column changes in existing tables
-	for name, afterTable := range afterTables {
-		beforeTable, exists := beforeTables[name]
+	return diff
+}
+
+// diffSchemas reports schema-level changes between two replayed schemas,
+// flagging those that break existing rows, queries or writers.
+func diffSchemas(before, after *sqlSchema) []UnitDiff {
+	var units []UnitDiff
+
+	renamed := matchRenames(before.Tables, after.Tables, func(t *sqlTable) string { return t.Origin })
+	renamedFrom := make(map[string]bool)
+	for _, old := range renamed {
+		renamedFrom[old] = true
+	}
+
+	for _, name := range sortedKeys(after.Tables) {
+		afterTable := after.Tables[name]
+		beforeTable, exists := before.Tables[name]
+		if old, ok := renamed[name]; ok {
+			beforeTable, exists = before.Tables[old], true
+			units = append(units, UnitDiff{
+				Kind:       KindSQLTable,
+				Name:       name,
+				Action:     ActionModified,
+				Before:     old,
+				After:      name,
+				ChangeType: "TABLE_RENAMED",
+				Breaking:   true,
+			})
+		}
 		if !exists {
+			units = append(units, UnitDiff{
+				Kind:       KindSQLTable,
+				Name:       name,
+				Action:     ActionAdded,
+				After:      afterTable.Definition,
+				ChangeType: "TABLE_ADDED",
+			})
+			// Add all columns as added
+			for _, colName := range sortedKeys(afterTable.Columns) {
+				units = append(units, UnitDiff{
+					Kind:       KindSQLColumn,
+					Name:       colName,
+					Path:       name + "." + colName,
+					Action:     ActionAdded,
+					After:      afterTable.Columns[colName].Definition,
+					ChangeType: "COLUMN_ADDED",
+				})
+			}
 			continue
 		}
+		units = append(units, diffTables(beforeTable, afterTable)...)
+	}
 
-		// Added columns
-		for colName := range afterTable.Columns {
-			if _, exists := beforeTable.Columns[colName]; !exists {
-				diff.ColumnsAdded[name] = append(diff.ColumnsAdded[name], colName)
-			}
+	for _, name := range sortedKeys(before.Tables) {
+		if _, exists := after.Tables[name]; !exists && !renamedFrom[name] {
+			units = append(units, UnitDiff{
+				Kind:       KindSQLTable,
+				Name:       name,
+				Action:     ActionRemoved,
+				Before:     before.Tables[name].Definition,
+				ChangeType: "TABLE_DROPPED",
+				Breaking:   true,
+			})
 		}
+	}
 
-		// Removed columns
-		for colName := range beforeTable.Columns {
-			if _, exists := afterTable.Columns[colName]; !exists {
-				diff.ColumnsRemoved[name] = append(diff.ColumnsRemoved[name], colName)
+	units = append(units, diffIndexes(before, after)...)
+	units = append(units, diffViews(before, after)...)
+	units = append(units, diffEnums(before, after)...)
+
+	return units
+}
+
+// matchRenames pairs objects that only exist on one side but share an
+// origin, returning after name -> before name.
+func matchRenames[T any](before, after map[string]T, origin func(T) string) map[string]string {
+	renamed := make(map[string]string)
+	for _, name := range sortedKeys(after) {
+		if _, exists := before[name]; exists {
+			continue
+		}
+		for _, old := range sortedKeys(before) {
+			if _, kept := after[old]; !kept && origin(before[old]) == origin(after[name]) {
+				renamed[name] = old
+				break
 			}
 		}
+	}
+	return renamed
+}
+
+// diffTables compares the columns and constraints of a table.
+func diffTables(before, after *sqlTable) []UnitDiff {
+	var units []UnitDiff
+	table := after.Name
+
+	renamed := matchRenames(before.Columns, after.Columns, func(c *sqlColumn) string { return c.Origin })
+	renamedFrom := make(map[string]bool)
+	for _, old := range renamed {
+		renamedFrom[old] = true
+	}
+
+	for _, colName := range sortedKeys(after.Columns) {
+		afterCol := after.Columns[colName]
+		path := table + "." + colName
+		beforeCol, exists := before.Columns[colName]
+		if old, ok := renamed[colName]; ok {
+			beforeCol, exists = before.Columns[old], true
+			units = append(units, UnitDiff{
+				Kind:       KindSQLColumn,
+				Name:       colName,
+				Path:       path,
+				Action:     ActionModified,
+				Before:     old,
+				After:      colName,
+				ChangeType: "COLUMN_RENAMED",
+				Breaking:   true,
+			})
+		}
+		if !exists {
+			units = append(units, UnitDiff{
+				Kind:       KindSQLColumn,
+				Name:       colName,
+				Path:       path,
+				Action:     ActionAdded,
+				After:      afterCol.Definition,
+				ChangeType: "COLUMN_ADDED",
+				// Existing inserts that omit it now fail
+				Breaking: !afterCol.Nullable && afterCol.Default == "" && !afterCol.autoFilled(),
+			})
+			continue
+		}
+		units = append(units, diffColumns(path, beforeCol, afterCol)...)
+	}
 
-		// Changed columns
-		for colName, afterCol := range afterTable.Columns {
-			beforeCol, exists := beforeTable.Columns[colName]
-			if exists && afterCol.Definition != beforeCol.Definition {
-				diff.ColumnsChanged[name] = append(diff.ColumnsChanged[name], ColumnChange{
-					Column: colName,
-					Before: beforeCol.Definition,
-					After:  afterCol.Definition,
-				})
+	for _, colName := range sortedKeys(before.Columns) {
+		if _, exists := after.Columns[colName]; !exists && !renamedFrom[colName] {
+			units = append(units, UnitDiff{
+				Kind:       KindSQLColumn,
+				Name:       colName,
+				Path:       table + "." + colName,
+				Action:     ActionRemoved,
+				Before:     before.Columns[colName].Definition,
+				ChangeType: "COLUMN_DROPPED",
+				Breaking:   true,
+			})
+		}
+	}
+
+	for _, name := range sortedKeys(after.Constraints) {
+		c := after.Constraints[name]
+		old, exists := before.Constraints[name]
+		switch {
+		case !exists:
+			// Existing rows or writes may violate it
+			units = append(units, UnitDiff{
+				Kind:       KindSQLConstraint,
+				Name:       name,
+				Path:       table + "." + name,
+				Action:     ActionAdded,
+				After:      c.Definition,
+				ChangeType: "CONSTRAINT_ADDED",
+				Breaking:   true,
+			})
+		case old.Definition != c.Definition:
+			units = append(units, UnitDiff{
+				Kind:       KindSQLConstraint,
+				Name:       name,
+				Path:       table + "." + name,
+				Action:     ActionModified,
+				Before:     old.Definition,
+				After:      c.Definition,
+				ChangeType: "CONSTRAINT_CHANGED",
+				Breaking:   true,
+			})
+		}
+	}
+	for _, name := range sortedKeys(before.Constraints) {
+		if _, exists := after.Constraints[name]; !exists {
+			c := before.Constraints[name]
+			units = append(units, UnitDiff{
+				Kind:       KindSQLConstraint,
+				Name:       name,
+				Path:       table + "." + name,
+				Action:     ActionRemoved,
+				Before:     c.Definition,
+				ChangeType: "CONSTRAINT_DROPPED",
+				// ON CONFLICT targets and foreign keys rely on uniqueness
+				Breaking: c.Kind == "unique" || c.Kind == "primary key",
+			})
+		}
+	}
+
+	return units
+}
+
+// diffColumns reports each change to a column's type, nullability and
+// default, or the whole definition when its other clauses changed.
+func diffColumns(path string, before, after *sqlColumn) []UnitDiff {
+	var units []UnitDiff
+	change := func(changeType, b, a string, breaking bool) {
+		units = append(units, UnitDiff{
+			Kind:       KindSQLColumn,
+			Name:       after.Name,
+			Path:       path,
+			Action:     ActionModified,
+			Before:     b,
+			After:      a,
+			ChangeType: changeType,
+			Breaking:   breaking,
+		})
+	}
+
+	if ct := compareSQLTypes(before.Type, after.Type); ct != "" {
+		change(ct, before.Type, after.Type, ct != "COLUMN_TYPE_WIDENED")
+	}
+	if before.Nullable && !after.Nullable {
+		change("NOT_NULL_ADDED", "NULL", "NOT NULL", true)
+	} else if !before.Nullable && after.Nullable {
+		change("NOT_NULL_DROPPED", "NOT NULL", "NULL", false)
+	}
+	if before.Default != after.Default {
+		change("DEFAULT_CHANGED", defaultText(before.Default), defaultText(after.Default), false)
+	}
+	if len(units) == 0 && !strings.EqualFold(before.Extra, after.Extra) {
+		change("COLUMN_CHANGED", before.Definition, after.Definition, false)
+	}
+
+	return units
+}
+
+func defaultText(d string) string {
+	if d == "" {
+		return "no default"
+	}
+	return "DEFAULT " + d
+}
+
+// diffIndexes compares indexes. Unique indexes added to existing tables can
+// reject writes, and dropping one breaks ON CONFLICT clauses that name it.
+func diffIndexes(before, after *sqlSchema) []UnitDiff {
+	var units []UnitDiff
+	for _, name := range sortedKeys(after.Indexes) {
+		idx := after.Indexes[name]
+		old, exists := before.Indexes[name]
+		_, tableExisted := before.Tables[idx.Table]
+		switch {
+		case !exists:
+			units = append(units, UnitDiff{
+				Kind:       KindSQLIndex,
+				Name:       name,
+				Path:       idx.Table,
+				Action:     ActionAdded,
+				After:      idx.Definition,
+				ChangeType: "INDEX_ADDED",
+				Breaking:   idx.Unique && tableExisted,
+			})
+		case old.Definition != idx.Definition:
+			units = append(units, UnitDiff{
+				Kind:       KindSQLIndex,
+				Name:       name,
+				Path:       idx.Table,
+				Action:     ActionModified,
+				Before:     old.Definition,
+				After:      idx.Definition,
+				ChangeType: "INDEX_CHANGED",
+				Breaking:   idx.Unique || old.Unique,
+			})
+		}
+	}
+	for _, name := range sortedKeys(before.Indexes) {
+		idx := before.Indexes[name]
+		if _, exists := after.Indexes[name]; exists {
+			continue
+		}
+		// Indexes go with their table
+		if _, tableKept := after.Tables[idx.Table]; !tableKept {
+			continue
+		}
+		units = append(units, UnitDiff{
+			Kind:       KindSQLIndex,
+			Name:       name,
+			Path:       idx.Table,
+			Action:     ActionRemoved,
+			Before:     idx.Definition,
+			ChangeType: "INDEX_DROPPED",
+			Breaking:   idx.Unique,
+		})
+	}
+	return units
+}
+
+// diffViews compares views by their query.
+func diffViews(before, after *sqlSchema) []UnitDiff {
+	var units []UnitDiff
+	for _, name := range sortedKeys(after.Views) {
+		view := after.Views[name]
+		old, exists := before.Views[name]
+		switch {
+		case !exists:
+			units = append(units, UnitDiff{Kind: KindSQLView, Name: name, Action: ActionAdded, After: view.Definition, ChangeType: "VIEW_ADDED"})
+		case old.Definition != view.Definition:
+			units = append(units, UnitDiff{Kind: KindSQLView, Name: name, Action: ActionModified, Before: old.Definition, After: view.Definition, ChangeType: "VIEW_CHANGED"})
+		}
+	}
+	for _, name := range sortedKeys(before.Views) {
+		if _, exists := after.Views[name]; !exists {
+			units = append(units, UnitDiff{Kind: KindSQLView, Name: name, Action: ActionRemoved, Before: before.Views[name].Definition, ChangeType: "VIEW_DROPPED", Breaking: true})
+		}
+	}
+	return units
+}
+
+// diffEnums compares enum types value by value.
+func diffEnums(before, after *sqlSchema) []UnitDiff {
+	var units []UnitDiff
+	for _, name := range sortedKeys(after.Enums) {
+		enum := after.Enums[name]
+		old, exists := before.Enums[name]
+		if !exists {
+			units = append(units, UnitDiff{Kind: KindSQLEnum, Name: name, Action: ActionAdded, After: strings.Join(enum.Values, ", "), ChangeType: "ENUM_ADDED"})
+			continue
+		}
+		for _, v := range enum.Values {
+			if !containsString(old.Values, v) {
+				units = append(units, UnitDiff{Kind: KindSQLEnum, Name: name, Path: name + "." + v, Action: ActionAdded, After: v, ChangeType: "ENUM_VALUE_ADDED"})
+			}
+		}
+		for _, v := range old.Values {
+			if !containsString(enum.Values, v) {
+				units = append(units, UnitDiff{Kind: KindSQLEnum, Name: name, Path: name + "." + v, Action: ActionRemoved, Before: v, ChangeType: "ENUM_VALUE_REMOVED", Breaking: true})
 			}
 		}
 	}
+	for _, name := range sortedKeys(before.Enums) {
+		if _, exists := after.Enums[name]; !exists {
+			units = append(units, UnitDiff{Kind: KindSQLEnum, Name: name, Action: ActionRemoved, Before: strings.Join(before.Enums[name].Values, ", "), ChangeType: "ENUM_DROPPED", Breaking: true})
+		}
+	}
+	return units
+}
 
-	return diff
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,221 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComputeMigrationDiff(t *testing.T) {
+	before := map[string]string{
+		"db/migrations/1_init.sql": `
+CREATE TYPE mood AS ENUM ('happy', 'sad');
+
+CREATE TABLE users (
+  id SERIAL PRIMARY KEY,
+  email VARCHAR(255),
+  name TEXT NOT NULL,
+  bio TEXT,
+  CONSTRAINT users_email_unique UNIQUE (email)
+);
+
+CREATE UNIQUE INDEX users_name_idx ON users (name);
+CREATE INDEX ON users (bio);
+
+CREATE TABLE legacy (id INT);
+
+CREATE VIEW active_users AS SELECT id, email FROM users;
+`,
+	}
+
+	after := map[string]string{
+		"db/migrations/1_init.sql": before["db/migrations/1_init.sql"],
+		"db/migrations/2_tighten.sql": `
+-- migrate:up
+ALTER TABLE users
+  ALTER COLUMN email TYPE VARCHAR(100),
+  ALTER COLUMN email SET NOT NULL,
+  ADD COLUMN age INT NOT NULL,
+  ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT now(),
+  DROP COLUMN bio;
+ALTER TABLE users RENAME COLUMN name TO full_name;
+ALTER TABLE users ALTER COLUMN id TYPE BIGINT;
+DROP INDEX users_name_idx;
+ALTER TABLE users DROP CONSTRAINT users_email_unique;
+ALTER TABLE users ADD CONSTRAINT users_age_check CHECK (age > 0);
+ALTER TABLE legacy RENAME TO archive;
+DROP VIEW active_users;
+ALTER TYPE mood ADD VALUE 'ok' AFTER 'happy';
+
+-- migrate:down
+DROP TABLE users;
+`,
+		"db/migrations/2_tighten.down.sql": `DROP TABLE users;`,
+		// Runs after 2_tighten, not before it as a string sort would
+		"db/migrations/10_defaults.sql": `ALTER TABLE users ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;`,
+	}
+
+	sd := ComputeMigrationDiff(before, after)
+
+	var got []string
+	for _, u := range sd.Changes {
+		got = append(got, fmt.Sprintf("%s %s %s %v", u.ChangeType, u.Name, u.Path, u.Breaking))
+	}
+	want := []string{
+		"TABLE_RENAMED archive  true",
+		"COLUMN_ADDED age users.age true",
+		"COLUMN_ADDED created_at users.created_at false",
+		"COLUMN_TYPE_NARROWED email users.email true",
+		"NOT_NULL_ADDED email users.email true",
+		"COLUMN_RENAMED full_name users.full_name true",
+		"COLUMN_TYPE_WIDENED id users.id false",
+		"COLUMN_DROPPED bio users.bio true",
+		"CONSTRAINT_ADDED users_age_check users.users_age_check true",
+		"CONSTRAINT_DROPPED users_email_unique users.users_email_unique true",
+		"INDEX_DROPPED users_bio_idx users false",
+		"INDEX_DROPPED users_name_idx users true",
+		"VIEW_DROPPED active_users  true",
+		"ENUM_VALUE_ADDED mood mood.ok false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if !sd.Breaking {
+		t.Error("expected the diff to be breaking")
+	}
+	for _, u := range sd.Changes {
+		if u.Path == "users.created_at" && !strings.Contains(u.After, "DEFAULT CURRENT_TIMESTAMP") {
+			t.Errorf("expected 10_defaults.sql to run last, got %q", u.After)
+		}
+	}
+}
+
+func TestComputeSQLDiff_MySQL(t *testing.T) {
+	before := "CREATE TABLE `orders` (\n" +
+		"  `id` INT NOT NULL AUTO_INCREMENT,\n" +
+		"  `total` DECIMAL(10,2) NOT NULL,\n" +
+		"  `note` VARCHAR(50),\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `uk_note` (`note`)\n" +
+		") ENGINE=InnoDB;"
+	after := before + `
+ALTER TABLE orders MODIFY COLUMN total DECIMAL(12,2) NOT NULL, CHANGE note memo TEXT;
+ALTER TABLE orders DROP INDEX uk_note;
+RENAME TABLE orders TO purchases;`
+
+	sd := ComputeSQLDiff(before, after)
+
+	var got []string
+	for _, u := range sd.Changes {
+		got = append(got, fmt.Sprintf("%s %s %s %v", u.ChangeType, u.Name, u.Path, u.Breaking))
+	}
+	want := []string{
+		"TABLE_RENAMED purchases  true",
+		"COLUMN_RENAMED memo purchases.memo true",
+		"COLUMN_TYPE_WIDENED memo purchases.memo false",
+		"COLUMN_TYPE_WIDENED total purchases.total false",
+		"CONSTRAINT_DROPPED uk_note purchases.uk_note true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !reflect.DeepEqual(sd.TablesAdded, []string{"purchases"}) || !reflect.DeepEqual(sd.TablesRemoved, []string{"orders"}) {
+		t.Errorf("tables added %v, removed %v", sd.TablesAdded, sd.TablesRemoved)
+	}
+}
+
+func TestCompareSQLTypes(t *testing.T) {
+	tests := []struct {
+		before, after string
+		want          string
+	}{
+		{"VARCHAR(255)", "VARCHAR(100)", "COLUMN_TYPE_NARROWED"},
+		{"varchar(100)", "character varying(255)", "COLUMN_TYPE_WIDENED"},
+		{"TEXT", "VARCHAR(64)", "COLUMN_TYPE_NARROWED"},
+		{"VARCHAR(64)", "TEXT", "COLUMN_TYPE_WIDENED"},
+		{"BIGINT", "INT", "COLUMN_TYPE_NARROWED"},
+		{"int4", "int8", "COLUMN_TYPE_WIDENED"},
+		{"NUMERIC(10,2)", "NUMERIC(8,2)", "COLUMN_TYPE_NARROWED"},
+		{"NUMERIC(10,2)", "DECIMAL(12,4)", "COLUMN_TYPE_WIDENED"},
+		{"NUMERIC(10,2)", "NUMERIC(10,4)", "COLUMN_TYPE_CHANGED"},
+		{"DOUBLE PRECISION", "REAL", "COLUMN_TYPE_NARROWED"},
+		{"INT", "TEXT", "COLUMN_TYPE_CHANGED"},
+		{"TEXT", "TEXT[]", "COLUMN_TYPE_CHANGED"},
+		{"integer", "INT", ""},
+	}
+	for _, tt := range tests {
+		if got := compareSQLTypes(tt.before, tt.after); got != tt.want {
+			t.Errorf("compareSQLTypes(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	content := `-- leading comment; not a statement
+CREATE TABLE a (note TEXT DEFAULT 'x;y'); /* block; comment */
+CREATE FUNCTION f() RETURNS trigger AS $body$
+BEGIN
+  RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+DROP TABLE a`
+
+	got := splitSQLStatements(content)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 statements, got %d: %q", len(got), got)
+	}
+	if got[0] != "CREATE TABLE a (note TEXT DEFAULT 'x;y')" {
+		t.Errorf("statement 0 = %q", got[0])
+	}
+	if !strings.HasSuffix(got[1], "$body$ LANGUAGE plpgsql") {
+		t.Errorf("statement 1 = %q", got[1])
+	}
+	if got[2] != "DROP TABLE a" {
+		t.Errorf("statement 2 = %q", got[2])
+	}
+}
+
+func TestMigrationDir(t *testing.T) {
+	tests := map[string]string{
+		"db/migrations/001_init.sql":                   "db/migrations",
+		"db/migrations/001_init/up.sql":                "db/migrations",
+		"src/main/resources/db/migration/V1__init.sql": "src/main/resources/db/migration",
+		"migrate/20240101_users.sql":                   "migrate",
+		"schema.sql":                                   "",
+		"db/migrations/README.md":                      "",
+	}
+	for path, want := range tests {
+		if got := MigrationDir(path); got != want {
+			t.Errorf("MigrationDir(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestDiffMigrations_FormatText(t *testing.T) {
+	before := map[string][]byte{
+		"db/migrations/1_init.sql": []byte("CREATE TABLE users (id INT PRIMARY KEY, email TEXT);"),
+		"src/app.go":               []byte("package app"),
+	}
+	after := map[string][]byte{
+		"db/migrations/1_init.sql":  before["db/migrations/1_init.sql"],
+		"db/migrations/2_email.sql": []byte("ALTER TABLE users ALTER COLUMN email SET NOT NULL;\nCREATE UNIQUE INDEX users_email_idx ON users (email);"),
+	}
+
+	fd := NewDiffer().DiffMigrations("db/migrations", before, after)
+	if fd.Path != "db/migrations/" || fd.Action != ActionModified || fd.Lang != "sql" {
+		t.Errorf("unexpected file diff %s %s %s", fd.Path, fd.Action, fd.Lang)
+	}
+
+	sd := &SemanticDiff{Files: []FileDiff{fd}}
+	output := sd.FormatText()
+	for _, line := range []string{
+		"~ users.email: NULL -> NOT NULL (not null added, breaking)",
+		"+ index users_email_idx on users (breaking)",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
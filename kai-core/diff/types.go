@@ -1,6 +1,8 @@
 // Package diff provides unified semantic diff computation and formatting.
 package diff
 
+import "strings"
+
 // Action represents the type of change to a unit.
 type Action string
 
@@ -14,17 +16,32 @@ const (
 type UnitKind string
 
 const (
-	KindFunction  UnitKind = "function"
-	KindClass     UnitKind = "class"
-	KindMethod    UnitKind = "method"
-	KindConst     UnitKind = "const"
-	KindVariable  UnitKind = "variable"
-	KindJSONKey   UnitKind = "json_key"
-	KindYAMLKey   UnitKind = "yaml_key"
-	KindSQLTable  UnitKind = "sql_table"
-	KindSQLColumn UnitKind = "sql_column"
-	KindImport    UnitKind = "import"
-	KindExport    UnitKind = "export"
+	KindFunction      UnitKind = "function"
+	KindClass         UnitKind = "class"
+	KindMethod        UnitKind = "method"
+	KindConst         UnitKind = "const"
+	KindVariable      UnitKind = "variable"
+	KindJSONKey       UnitKind = "json_key"
+	KindYAMLKey       UnitKind = "yaml_key"
+	KindSQLTable      UnitKind = "sql_table"
+	KindSQLColumn     UnitKind = "sql_column"
+	KindSQLIndex      UnitKind = "sql_index"
+	KindSQLConstraint UnitKind = "sql_constraint"
+	KindSQLView       UnitKind = "sql_view"
+	KindSQLEnum       UnitKind = "sql_enum"
+	KindConfigKey     UnitKind = "config_key"
+	KindHCLBlock      UnitKind = "hcl_block"
+	KindHCLAttribute  UnitKind = "hcl_attribute"
+	KindProtoPackage  UnitKind = "proto_package"
+	KindProtoMessage  UnitKind = "proto_message"
+	KindProtoField    UnitKind = "proto_field"
+	KindProtoEnum     UnitKind = "proto_enum"
+	KindProtoValue    UnitKind = "proto_enum_value"
+	KindProtoService  UnitKind = "proto_service"
+	KindProtoRPC      UnitKind = "proto_rpc"
+	KindImport        UnitKind = "import"
+	KindExport        UnitKind = "export"
+	KindDependency    UnitKind = "dependency"
 )
 
 // Range represents a source location.
@@ -47,6 +64,7 @@ type UnitDiff struct {
 	AfterSig   string   `json:"afterSig,omitempty"`   // signature after
 	Range      *Range   `json:"range,omitempty"`
 	ChangeType string   `json:"changeType,omitempty"` // e.g., "API_SURFACE_CHANGED"
+	Breaking   bool     `json:"breaking,omitempty"`   // schema change that breaks existing data, queries or clients
 }
 
 // FileDiff represents changes to a single file.
@@ -78,16 +96,19 @@ type SemanticDiff struct {
 	Summary DiffSummary `json:"summary"`
 }
 
-// ComputeSummary calculates the summary from files.
+// ComputeSummary calculates the summary from files. Directory entries, such
+// as a migration directory's schema diff, count only their units.
 func (sd *SemanticDiff) ComputeSummary() {
 	sd.Summary = DiffSummary{}
 	for _, f := range sd.Files {
-		switch f.Action {
-		case ActionAdded:
+		switch {
+		case strings.HasSuffix(f.Path, "/"):
+			// Not a file
+		case f.Action == ActionAdded:
 			sd.Summary.FilesAdded++
-		case ActionModified:
+		case f.Action == ActionModified:
 			sd.Summary.FilesModified++
-		case ActionRemoved:
+		case f.Action == ActionRemoved:
 			sd.Summary.FilesRemoved++
 		}
 		for _, u := range f.Units {
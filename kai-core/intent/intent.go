@@ -79,6 +79,10 @@ func determineVerb(changeTypes []*detect.ChangeType) string {
 	hasAPI := false
 	hasCondition := false
 	hasConstant := false
+	hasSensitive := false
+	hasConcurrency := false
+	hasErrorHandling := false
+	hasDependency := false
 	hasJSONField := false
 	hasJSONValue := false
 	hasFileContent := false
@@ -95,6 +99,14 @@ func determineVerb(changeTypes []*detect.ChangeType) string {
 			hasCondition = true
 		case detect.ConstantUpdated:
 			hasConstant = true
+		case detect.SensitiveAPITouched:
+			hasSensitive = true
+		case detect.ConcurrencyChanged:
+			hasConcurrency = true
+		case detect.ErrorHandlingChanged:
+			hasErrorHandling = true
+		case detect.DependencyAdded, detect.DependencyRemoved, detect.DependencyUpgraded:
+			hasDependency = true
 		case detect.JSONFieldAdded, detect.JSONFieldRemoved:
 			hasJSONField = true
 		case detect.JSONValueChanged, detect.JSONArrayChanged:
@@ -119,15 +131,19 @@ func determineVerb(changeTypes []*detect.ChangeType) string {
 		return "Remove"
 	}
 	// Semantic code changes
-	if hasAPI {
+	if hasAPI || hasSensitive {
 		return "Update"
 	}
-	if hasCondition {
+	if hasCondition || hasConcurrency || hasErrorHandling {
 		return "Modify"
 	}
 	if hasConstant {
 		return "Update"
 	}
+	// Dependency manifest changes
+	if hasDependency {
+		return "Update"
+	}
 	// JSON changes
 	if hasJSONField {
 		return "Update"
@@ -128,6 +128,29 @@ func TestGenerateIntent_ConstantUpdated(t *testing.T) {
 	}
 }
 
+func TestGenerateIntent_ReviewCategories(t *testing.T) {
+	tests := []struct {
+		categories []detect.ChangeCategory
+		expected   string
+	}{
+		{[]detect.ChangeCategory{detect.SensitiveAPITouched, detect.ConditionChanged}, "Update Auth login"},
+		{[]detect.ChangeCategory{detect.ErrorHandlingChanged}, "Modify Auth login"},
+		{[]detect.ChangeCategory{detect.ConstantUpdated, detect.ConcurrencyChanged}, "Modify Auth login"},
+		{[]detect.ChangeCategory{detect.DependencyRemoved, detect.JSONValueChanged}, "Update Auth login"},
+	}
+
+	for _, tt := range tests {
+		var changeTypes []*detect.ChangeType
+		for _, c := range tt.categories {
+			changeTypes = append(changeTypes, &detect.ChangeType{Category: c})
+		}
+		result := GenerateIntent(changeTypes, []string{"Auth"}, nil, []string{"login.js"})
+		if result != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.categories, tt.expected, result)
+		}
+	}
+}
+
 func TestGenerateIntent_FileAdded(t *testing.T) {
 	changeTypes := []*detect.ChangeType{
 		{
@@ -3,6 +3,9 @@ package merge
 import (
 	"bytes"
 	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
 
 	sitter "github.com/smacker/go-tree-sitter"
 	"kai-core/parse"
@@ -44,13 +47,36 @@ func (e *Extractor) ExtractUnits(path string, content []byte, lang string) (*Fil
 		e.extractRbUnits(parsed, content, path, fu)
 	case "rs", "rust":
 		e.extractRsUnits(parsed, content, path, fu)
+	case "go", "golang":
+		e.extractGoUnits(parsed, content, path, fu)
 	default:
 		e.extractJSUnits(parsed, content, path, fu) // fallback to JS
 	}
 
+	locateUnits(fu)
 	return fu, nil
 }
 
+// locateUnits records where each unit's content sits in the file, so a merge
+// can splice units into the file without touching the text between them.
+// Content spans the unit's node, possibly widened by comments.
+func locateUnits(fu *FileUnits) {
+	for _, u := range fu.Units {
+		node, ok := u.RawNode.(*sitter.Node)
+		if !ok || len(u.Content) == 0 {
+			continue
+		}
+		lo := int(node.EndByte()) - len(u.Content)
+		if lo < 0 {
+			lo = 0
+		}
+		if idx := bytes.Index(fu.Content[lo:], u.Content); idx >= 0 {
+			u.Start = lo + idx
+			u.End = u.Start + len(u.Content)
+		}
+	}
+}
+
 // extractJSUnits extracts merge units from JavaScript/TypeScript AST.
 func (e *Extractor) extractJSUnits(parsed *parse.ParsedFile, content []byte, path string, fu *FileUnits) {
 	root := parsed.GetRootNode()
@@ -1178,3 +1204,325 @@ func (e *Extractor) extractRsUse(node *sitter.Node, content []byte, path string)
 		RawNode:  node,
 	}
 }
+
+// ==================== Go Extraction ====================
+
+// extractGoUnits extracts merge units from Go AST: the package clause, each
+// import spec (so imports merge as a set), functions, methods keyed by
+// receiver type, and each type, const and var spec. Specs of a parenthesized
+// declaration share a Group so they are written back into one block. Doc
+// comments belong to the declaration they precede, and comments before the
+// package clause (license headers, build constraints) to the package clause.
+func (e *Extractor) extractGoUnits(parsed *parse.ParsedFile, content []byte, path string, fu *FileUnits) {
+	root := parsed.GetRootNode()
+	seen := make(map[string]int) // init functions and blank identifiers repeat
+
+	add := func(unit *MergeUnit) {
+		if unit == nil {
+			return
+		}
+		name := unit.Key.SymbolPath[len(unit.Key.SymbolPath)-1]
+		if name == "init" || name == "_" {
+			seen[name]++
+			if seen[name] > 1 {
+				unit.Key.SymbolPath[len(unit.Key.SymbolPath)-1] = fmt.Sprintf("%s#%d", name, seen[name])
+			}
+		}
+		fu.Units[unit.Key.String()] = unit
+	}
+
+	for i := 0; i < int(root.ChildCount()); i++ {
+		node := root.Child(i)
+		switch node.Type() {
+		case "package_clause":
+			add(e.extractGoPackage(node, content, path))
+
+		case "import_declaration":
+			for _, spec := range goSpecs(node, "import_spec") {
+				add(e.extractGoImport(spec, content, path))
+			}
+
+		case "function_declaration":
+			add(e.extractGoFunction(node, content, path))
+
+		case "method_declaration":
+			add(e.extractGoMethod(node, content, path))
+
+		case "type_declaration", "const_declaration", "var_declaration":
+			keyword := strings.TrimSuffix(node.Type(), "_declaration")
+			specs := goSpecs(node, keyword+"_spec")
+			grouped := goGrouped(node)
+			group := ""
+			for _, spec := range specs {
+				unit := e.extractGoSpec(spec, content, path, keyword)
+				if unit == nil {
+					continue
+				}
+				if grouped {
+					if group == "" {
+						group = keyword + " " + unit.Name
+					}
+					unit.Group = group
+				} else {
+					// A single spec is the whole declaration
+					unit.Content = goUnitContent(node, content)
+					unit.BodyHash = hashContent(unit.Content)
+					unit.RawNode = node
+				}
+				add(unit)
+			}
+		}
+	}
+}
+
+func (e *Extractor) extractGoPackage(node *sitter.Node, content []byte, path string) *MergeUnit {
+	var name string
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if child := node.Child(i); child.Type() == "package_identifier" {
+			name = child.Content(content)
+		}
+	}
+
+	// Everything before the package clause is its header
+	start := node.StartByte()
+	for prev := node.PrevNamedSibling(); prev != nil && prev.Type() == "comment"; prev = prev.PrevNamedSibling() {
+		start = prev.StartByte()
+	}
+	unitContent := content[start:node.EndByte()]
+
+	return &MergeUnit{
+		Key: UnitKey{
+			File:       path,
+			SymbolPath: []string{"package"},
+			Kind:       UnitModule,
+		},
+		Kind:      UnitModule,
+		Name:      name,
+		Signature: "package " + name,
+		BodyHash:  hashContent(unitContent),
+		Range:     parse.GetNodeRange(node),
+		Content:   unitContent,
+		RawNode:   node,
+	}
+}
+
+func (e *Extractor) extractGoImport(spec *sitter.Node, content []byte, path string) *MergeUnit {
+	var importPath string
+	for i := 0; i < int(spec.ChildCount()); i++ {
+		child := spec.Child(i)
+		if child.Type() == "interpreted_string_literal" || child.Type() == "raw_string_literal" {
+			importPath, _ = strconv.Unquote(child.Content(content))
+		}
+	}
+	if importPath == "" {
+		return nil
+	}
+
+	// Keyed by path, so an alias change is an edit of the same import
+	unitContent := goUnitContent(spec, content)
+	return &MergeUnit{
+		Key: UnitKey{
+			File:       path,
+			SymbolPath: []string{"import:" + importPath},
+			Kind:       UnitImport,
+		},
+		Kind:     UnitImport,
+		Name:     importPath,
+		BodyHash: hashContent(unitContent),
+		Range:    parse.GetNodeRange(spec),
+		Content:  unitContent,
+		RawNode:  spec,
+		Group:    "import",
+	}
+}
+
+func (e *Extractor) extractGoFunction(node *sitter.Node, content []byte, path string) *MergeUnit {
+	var name string
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if child := node.Child(i); child.Type() == "identifier" {
+			name = child.Content(content)
+			break
+		}
+	}
+	if name == "" {
+		return nil
+	}
+
+	unitContent := goUnitContent(node, content)
+	return &MergeUnit{
+		Key: UnitKey{
+			File:       path,
+			SymbolPath: []string{name},
+			Kind:       UnitFunction,
+		},
+		Kind:      UnitFunction,
+		Name:      name,
+		Signature: goSignature(node, content),
+		BodyHash:  hashContent(unitContent),
+		Range:     parse.GetNodeRange(node),
+		Content:   unitContent,
+		RawNode:   node,
+	}
+}
+
+func (e *Extractor) extractGoMethod(node *sitter.Node, content []byte, path string) *MergeUnit {
+	var name, receiver string
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "parameter_list":
+			if receiver == "" {
+				receiver = goReceiverType(child, content)
+			}
+		case "field_identifier":
+			name = child.Content(content)
+		}
+	}
+	if name == "" || receiver == "" {
+		return nil
+	}
+
+	unitContent := goUnitContent(node, content)
+	return &MergeUnit{
+		Key: UnitKey{
+			File:       path,
+			SymbolPath: []string{receiver, name},
+			Kind:       UnitMethod,
+		},
+		Kind:      UnitMethod,
+		Name:      name,
+		Signature: goSignature(node, content),
+		BodyHash:  hashContent(unitContent),
+		Range:     parse.GetNodeRange(node),
+		Content:   unitContent,
+		RawNode:   node,
+	}
+}
+
+// extractGoSpec extracts a type, const or var spec. A spec declaring several
+// names (var x, y int) is one unit named by all of them.
+func (e *Extractor) extractGoSpec(spec *sitter.Node, content []byte, path, keyword string) *MergeUnit {
+	var names []string
+	for i := 0; i < int(spec.ChildCount()); i++ {
+		child := spec.Child(i)
+		if child.Type() == "identifier" || (keyword == "type" && child.Type() == "type_identifier") {
+			names = append(names, child.Content(content))
+			if keyword == "type" {
+				break // The rest is the type
+			}
+		} else if child.Type() != "," {
+			break
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	kind := UnitType
+	switch keyword {
+	case "const":
+		kind = UnitConst
+	case "var":
+		kind = UnitVariable
+	}
+
+	name := strings.Join(names, ",")
+	unitContent := goUnitContent(spec, content)
+	return &MergeUnit{
+		Key: UnitKey{
+			File:       path,
+			SymbolPath: []string{name},
+			Kind:       kind,
+		},
+		Kind:      kind,
+		Name:      name,
+		Signature: keyword + " " + name,
+		BodyHash:  hashContent(unitContent),
+		Range:     parse.GetNodeRange(spec),
+		Content:   unitContent,
+		RawNode:   spec,
+	}
+}
+
+// goSpecs returns the specs of a declaration, whether written alone or in a
+// parenthesized list.
+func goSpecs(decl *sitter.Node, specType string) []*sitter.Node {
+	var specs []*sitter.Node
+	for i := 0; i < int(decl.ChildCount()); i++ {
+		child := decl.Child(i)
+		switch child.Type() {
+		case specType:
+			specs = append(specs, child)
+		case "import_spec_list", "var_spec_list":
+			specs = append(specs, goSpecs(child, specType)...)
+		}
+	}
+	return specs
+}
+
+// goGrouped reports whether a declaration is parenthesized.
+func goGrouped(decl *sitter.Node) bool {
+	for i := 0; i < int(decl.ChildCount()); i++ {
+		switch decl.Child(i).Type() {
+		case "(":
+			return true
+		case "import_spec_list", "var_spec_list":
+			return goGrouped(decl.Child(i))
+		}
+	}
+	return false
+}
+
+// goUnitContent returns a node's source with its doc comment (the comment
+// lines directly above it) and a trailing comment on its last line.
+func goUnitContent(node *sitter.Node, content []byte) []byte {
+	start, end := node.StartByte(), node.EndByte()
+	row := node.StartPoint().Row
+	for prev := node.PrevNamedSibling(); prev != nil && prev.Type() == "comment" && prev.EndPoint().Row+1 >= row; prev = prev.PrevNamedSibling() {
+		if before := prev.PrevNamedSibling(); before != nil && before.EndPoint().Row == prev.StartPoint().Row {
+			break // Trailing comment of the previous line
+		}
+		start, row = prev.StartByte(), prev.StartPoint().Row
+	}
+	if next := node.NextNamedSibling(); next != nil && next.Type() == "comment" && next.StartPoint().Row == node.EndPoint().Row {
+		end = next.EndByte()
+	}
+	return content[start:end]
+}
+
+// goSignature returns a function's declaration up to its body.
+func goSignature(node *sitter.Node, content []byte) string {
+	end := node.EndByte()
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if child := node.Child(i); child.Type() == "block" {
+			end = child.StartByte()
+		}
+	}
+	return strings.TrimSpace(string(content[node.StartByte():end]))
+}
+
+// goReceiverType returns the receiver's type name without pointer or type
+// parameters, so func (c *Cart[T]) Total keys as Cart.Total.
+func goReceiverType(params *sitter.Node, content []byte) string {
+	var find func(n *sitter.Node) string
+	find = func(n *sitter.Node) string {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			child := n.Child(i)
+			switch child.Type() {
+			case "type_identifier":
+				return child.Content(content)
+			case "parameter_declaration", "pointer_type", "generic_type":
+				if name := find(child); name != "" {
+					return name
+				}
+			}
+		}
+		return ""
+	}
+	return find(params)
+}
+
+func hashContent(content []byte) []byte {
+	sum := sha256.Sum256(content)
+	return sum[:]
+}
@@ -0,0 +1,161 @@
+package merge
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Conflict markers. A conflicting unit is written between them with the left
+// version above the separator and the right version below. The start marker
+// names the conflict kind and unit; both markers carry the side's change
+// description when there is one:
+//
+//	<<<<<<< left BODY_DIVERGED Cart.Total: <LeftDiff>
+//	...left version...
+//	=======
+//	...right version...
+//	>>>>>>> right: <RightDiff>
+const (
+	MarkerStart     = "<<<<<<<"
+	MarkerSeparator = "======="
+	MarkerEnd       = ">>>>>>>"
+)
+
+// ConflictBlock is a conflicting unit written into a file between markers.
+type ConflictBlock struct {
+	Unit   string       // symbol path of the unit, e.g. "Cart.Total"
+	Kind   ConflictKind // kind named in the start marker
+	Start  int          // byte offset of the start marker's line
+	End    int          // byte offset after the end marker's line
+	Line   int          // 1-based line of the start marker
+	Indent string       // indentation of the start marker
+}
+
+// MarkConflict renders a conflict as a marked block. A side that deleted the
+// unit is empty.
+func MarkConflict(c *Conflict) []byte {
+	return markConflict(c, "", "")
+}
+
+// markConflict renders a conflict with the text sharing the unit's lines
+// (such as the import keyword before a lone Go import spec) on each side, so
+// the markers sit on lines of their own.
+func markConflict(c *Conflict, prefix, suffix string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(MarkerStart + " left " + string(c.Kind) + " " + strings.Join(c.UnitKey.SymbolPath, "."))
+	if c.LeftDiff != "" {
+		buf.WriteString(": " + c.LeftDiff)
+	}
+	buf.WriteString("\n")
+	if c.Left != nil {
+		buf.WriteString(prefix + string(c.Left.Content) + suffix + "\n")
+	}
+	buf.WriteString(MarkerSeparator + "\n")
+	if c.Right != nil {
+		buf.WriteString(prefix + string(c.Right.Content) + suffix + "\n")
+	}
+	buf.WriteString(MarkerEnd + " right")
+	if c.RightDiff != "" {
+		buf.WriteString(": " + c.RightDiff)
+	}
+	return buf.Bytes()
+}
+
+// FindConflicts returns the marked blocks in a file, in order. Markers may
+// be indented, as they are for units nested in a declaration.
+func FindConflicts(content []byte) []ConflictBlock {
+	var blocks []ConflictBlock
+	var open *ConflictBlock
+	offset := 0
+	for i, line := range strings.SplitAfter(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		switch {
+		case strings.HasPrefix(trimmed, MarkerStart+" "):
+			fields := strings.Fields(trimmed)
+			block := ConflictBlock{
+				Start:  offset,
+				Line:   i + 1,
+				Indent: line[:len(line)-len(trimmed)],
+			}
+			if len(fields) >= 4 {
+				block.Kind = ConflictKind(fields[2])
+				block.Unit = strings.TrimSuffix(fields[3], ":")
+			}
+			open = &block
+		case open != nil && strings.HasPrefix(trimmed, MarkerEnd):
+			open.End = offset + len(line)
+			blocks = append(blocks, *open)
+			open = nil
+		}
+		offset += len(line)
+	}
+	return blocks
+}
+
+// MarkerLines returns the 1-based lines that hold a conflict marker,
+// including stray markers left from a partly resolved block.
+func MarkerLines(content []byte) []int {
+	var lines []int
+	for i, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, MarkerStart+" ") || strings.HasPrefix(trimmed, MarkerEnd) || trimmed == MarkerSeparator {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// ResolveConflict replaces a marked block with the chosen result. An empty
+// result removes the block's lines.
+func ResolveConflict(content []byte, block ConflictBlock, result []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(content[:block.Start])
+	if len(result) > 0 {
+		buf.WriteString(block.Indent)
+		buf.Write(result)
+		if bytes.HasSuffix(content[:block.End], []byte("\n")) {
+			buf.WriteString("\n")
+		}
+	}
+	buf.Write(content[block.End:])
+	return buf.Bytes()
+}
+
+// resolutions lists the ways a conflict can be resolved: either side's
+// version, and for imports both. A resolution is marked AutoApply when it
+// loses nothing, such as when both versions differ only in whitespace.
+// Results replace the marked block, so they carry the same prefix and suffix.
+func resolutions(c *Conflict, prefix, suffix string) []Resolution {
+	content := func(u *MergeUnit) []byte {
+		if u == nil {
+			return nil
+		}
+		return []byte(prefix + string(u.Content) + suffix)
+	}
+	describe := func(side string, u *MergeUnit) string {
+		if u == nil {
+			return "Delete the unit, as the " + side + " side did"
+		}
+		return "Use the " + side + " version"
+	}
+
+	sameText := c.Left != nil && c.Right != nil &&
+		strings.Join(strings.Fields(string(c.Left.Content)), " ") == strings.Join(strings.Fields(string(c.Right.Content)), " ")
+
+	result := []Resolution{
+		{Label: "Keep left", Description: describe("left", c.Left), AutoApply: sameText, Result: content(c.Left)},
+		{Label: "Keep right", Description: describe("right", c.Right), Result: content(c.Right)},
+	}
+
+	// Importing a path under both names keeps code on either side compiling
+	if c.Kind == ConflictImportAlias && c.Left != nil && c.Right != nil && !sameText {
+		union := append(append(content(c.Left), '\n'), content(c.Right)...)
+		result = append(result, Resolution{
+			Label:       "Union",
+			Description: "Keep both imports",
+			AutoApply:   true,
+			Result:      union,
+		})
+	}
+	return result
+}
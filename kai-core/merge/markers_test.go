@@ -0,0 +1,110 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkedConflicts(t *testing.T) {
+	base := []byte(`package a
+
+import "strings"
+
+func A() int {
+	return 1
+}
+
+func B() int {
+	return 1
+}
+`)
+
+	left := []byte(`package a
+
+import s "strings"
+
+func A() int {
+	return 2
+}
+
+func B() int {
+	return 1
+}
+`)
+
+	right := []byte(`package a
+
+import str "strings"
+
+func A() int {
+	return 3
+}
+
+func B() int {
+	return 4
+}
+`)
+
+	result, err := Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || len(result.Conflicts) != 2 {
+		t.Fatalf("expected 2 conflicts, got %v", result.Conflicts)
+	}
+
+	marked := result.Files["file"]
+	if !strings.Contains(string(marked), "func B() int {\n\treturn 4\n}") {
+		t.Errorf("non-conflicting change should be merged:\n%s", marked)
+	}
+
+	blocks := FindConflicts(marked)
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 marked blocks, got %d:\n%s", len(blocks), marked)
+	}
+	if blocks[0].Unit != `import:strings` || blocks[0].Kind != ConflictImportAlias {
+		t.Errorf("first block = %+v", blocks[0])
+	}
+	if blocks[1].Unit != "A" || blocks[1].Kind != ConflictStatementDiverged || blocks[1].Line != 9 {
+		t.Errorf("second block = %+v", blocks[1])
+	}
+
+	// Each conflict offers both sides; the import also offers both imports
+	byUnit := make(map[string]Conflict)
+	for _, c := range result.Conflicts {
+		byUnit[strings.Join(c.UnitKey.SymbolPath, ".")] = c
+	}
+	if n := len(byUnit["A"].Resolutions); n != 2 {
+		t.Errorf("expected keep left/right for A, got %d resolutions", n)
+	}
+	imp := byUnit["import:strings"].Resolutions
+	if len(imp) != 3 || imp[2].Label != "Union" || !imp[2].AutoApply {
+		t.Errorf("expected an auto-applicable union for the import, got %+v", imp)
+	}
+
+	// Resolve from the end so earlier offsets stay valid
+	resolved := ResolveConflict(marked, blocks[1], byUnit["A"].Resolutions[1].Result)
+	resolved = ResolveConflict(resolved, blocks[0], imp[2].Result)
+	if len(FindConflicts(resolved)) != 0 || len(MarkerLines(resolved)) != 0 {
+		t.Fatalf("markers remain:\n%s", resolved)
+	}
+	if lines := MarkerLines(marked); len(lines) != 6 || lines[0] != 3 {
+		t.Errorf("marker lines = %v", lines)
+	}
+	want := `package a
+
+import s "strings"
+import str "strings"
+
+func A() int {
+	return 3
+}
+
+func B() int {
+	return 4
+}
+`
+	if string(resolved) != want {
+		t.Errorf("resolved file:\n%s\nwant:\n%s", resolved, want)
+	}
+}
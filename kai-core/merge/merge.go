@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
 )
 
 // Merger performs AST-aware 3-way merges.
@@ -183,14 +186,97 @@ func (m *Merger) mergeUnits(path string, base, left, right []byte, lang string)
 			mergedUnits[key] = merged
 		}
 	}
+	conflicts = append(conflicts, implicitValueConflicts(mergedUnits, baseUnits, leftUnits, rightUnits)...)
 
-	if len(conflicts) > 0 {
-		return nil, conflicts, nil
+	// Conflicting units are written between markers so they can be
+	// resolved in place
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].UnitKey.String() < conflicts[j].UnitKey.String()
+	})
+	for i := range conflicts {
+		c := &conflicts[i]
+		c.Resolutions = resolutions(c, "", "")
+		marked := &MergeUnit{Key: c.UnitKey, Content: MarkConflict(c), conflict: c}
+		for _, u := range []*MergeUnit{c.Left, c.Right, c.Base} {
+			if u != nil {
+				marked.Group = u.Group
+			}
+		}
+		mergedUnits[c.UnitKey.String()] = marked
 	}
 
 	// Reconstruct file from merged units
-	result := m.reconstructFile(mergedUnits, leftUnits, rightUnits, lang)
-	return result, nil, nil
+	result, textConflicts := m.reconstructFile(mergedUnits, baseUnits, leftUnits, rightUnits)
+	conflicts = append(conflicts, textConflicts...)
+	return result, conflicts, nil
+}
+
+// implicitValueConflicts reports a CONST_VALUE_CONFLICT for each Go const
+// block both sides added specs to when its values are implicit, as with
+// iota: merging both sides' specs would shift the values of one side's. The
+// added specs are taken out of the merge and written as one conflict where
+// the skeleton side added its own.
+func implicitValueConflicts(merged map[string]*MergeUnit, base, left, right *FileUnits) []Conflict {
+	added := func(fu *FileUnits) map[string][]*MergeUnit {
+		groups := make(map[string][]*MergeUnit)
+		for _, u := range outermostUnits(fu) {
+			if strings.HasPrefix(u.Group, "const ") && base.Units[u.Key.String()] == nil {
+				groups[u.Group] = append(groups[u.Group], u)
+			}
+		}
+		return groups
+	}
+	leftAdded, rightAdded := added(left), added(right)
+	skeleton, _ := skeletonSide(base, left, right)
+
+	var conflicts []Conflict
+	for _, u := range outermostUnits(base) {
+		group := u.Group
+		l, r := leftAdded[group], rightAdded[group]
+		if !strings.HasPrefix(group, "const ") || len(l) == 0 || len(r) == 0 || u.Name != strings.TrimPrefix(group, "const ") {
+			continue // Check each block once, at its first spec
+		}
+		implicit := false
+		for _, fu := range []*FileUnits{base, left, right} {
+			for _, spec := range fu.Units {
+				if spec.Group == group && (!strings.Contains(string(spec.Content), "=") || strings.Contains(string(spec.Content), "iota")) {
+					implicit = true
+				}
+			}
+		}
+		if !implicit {
+			continue
+		}
+
+		side := func(specs []*MergeUnit) (*MergeUnit, string) {
+			var content [][]byte
+			var names []string
+			for _, spec := range specs {
+				delete(merged, spec.Key.String())
+				content = append(content, spec.Content)
+				names = append(names, spec.Name)
+			}
+			unit := *specs[0]
+			unit.Content = bytes.Join(content, []byte("\n"))
+			return &unit, "added " + strings.Join(names, ", ")
+		}
+		leftUnit, leftDiff := side(l)
+		rightUnit, rightDiff := side(r)
+		key := leftUnit.Key
+		if skeleton == right {
+			key = rightUnit.Key
+		}
+		conflicts = append(conflicts, Conflict{
+			Kind:      ConflictConstValueConflict,
+			UnitKey:   key,
+			Message:   fmt.Sprintf("Both sides added constants to the %s block, whose values are implicit", u.Name),
+			Left:      leftUnit,
+			Right:     rightUnit,
+			LeftDiff:  leftDiff,
+			RightDiff: rightDiff,
+		})
+	}
+	return conflicts
 }
 
 // mergeUnit performs 3-way merge on a single unit.
@@ -325,6 +411,14 @@ func (m *Merger) mergeFunctionUnit(base, left, right *MergeUnit) (*MergeUnit, *C
 		}
 	}
 
+	// Both edited the function: merge statement by statement where the
+	// bodies can be aligned
+	if !bytes.Equal(left.BodyHash, right.BodyHash) {
+		if merged, conflict, ok := m.mergeStatements(base, left, right); ok {
+			return merged, conflict
+		}
+	}
+
 	// If only one signature changed, or both changed the same way
 	if sigLeftChanged && !sigRightChanged {
 		// Left changed signature - if body same as right or base, use left
@@ -397,34 +491,317 @@ func (m *Merger) mergeImportUnit(base, left, right *MergeUnit) (*MergeUnit, *Con
 	return left, nil
 }
 
-// reconstructFile rebuilds the file from merged units.
-func (m *Merger) reconstructFile(merged map[string]*MergeUnit, left, right *FileUnits, lang string) []byte {
-	// Collect all units and sort by original position
+// reconstructFile rebuilds the file by splicing the merged units into one
+// side's source. Everything between units (package clauses, headers,
+// comments, blank lines, statements that aren't units) comes from that
+// side, merged line by line with the other side's edits to the same text,
+// so a clean merge only rewrites what changed. Lines both sides changed
+// differently are returned as TEXT_DIVERGED conflicts.
+//
+// The side whose text between units changed from base is used, so units
+// the other side added are placed around its layout.
+func (m *Merger) reconstructFile(merged map[string]*MergeUnit, base, left, right *FileUnits) ([]byte, []Conflict) {
+	skeleton, other := skeletonSide(base, left, right)
+	skeleton, conflicts := mergeTrivia(base, left, right, skeleton)
+
+	skelUnits := outermostUnits(skeleton)
+	inSkeleton := make(map[string]bool)
+	for _, u := range skelUnits {
+		if merged[u.Key.String()] != nil {
+			inSkeleton[u.Key.String()] = true
+		}
+	}
+
+	// Units only the other side has are inserted next to their neighbours
+	// from that side: a grouped spec beside another spec of its group (an
+	// import spec at its sorted place among them), any other unit after the
+	// unit it followed.
+	before := make(map[string][]*MergeUnit)     // joined to the unit, before it
+	after := make(map[string][]*MergeUnit)      // joined to the unit, after it
+	afterBlank := make(map[string][]*MergeUnit) // after the unit, blank line between
+	var leading []*MergeUnit                    // before the first unit
+	otherUnits := outermostUnits(other)
+	insertedGroups := make(map[string]bool)
+	for i, u := range otherUnits {
+		key := u.Key.String()
+		if inSkeleton[key] || merged[key] == nil {
+			continue
+		}
+		if _, ok := skeleton.Units[key]; ok {
+			continue // Nested on the skeleton side; its parent carries it
+		}
+
+		if u.Group != "" {
+			if u.Kind == UnitImport {
+				if anchor, dir := importNeighbour(skelUnits, u, inSkeleton); dir < 0 {
+					after[anchor] = insertImport(after[anchor], merged[key])
+					continue
+				} else if dir > 0 {
+					before[anchor] = insertImport(before[anchor], merged[key])
+					continue
+				}
+			}
+			if anchor := groupNeighbour(otherUnits, i, -1, inSkeleton); anchor != "" {
+				after[anchor] = append(after[anchor], merged[key])
+				continue
+			}
+			if anchor := groupNeighbour(otherUnits, i, 1, inSkeleton); anchor != "" {
+				before[anchor] = append(before[anchor], merged[key])
+				continue
+			}
+			// The whole group is new: insert its declaration once
+			if insertedGroups[u.Group] {
+				continue
+			}
+			insertedGroups[u.Group] = true
+			if decl := enclosingDeclaration(u, other.Content); decl != nil {
+				u = decl
+			}
+		} else {
+			u = merged[key]
+		}
+
+		anchor := ""
+		for j := i - 1; j >= 0; j-- {
+			if k := otherUnits[j].Key.String(); inSkeleton[k] {
+				anchor = k
+				break
+			}
+		}
+		if anchor == "" {
+			leading = append(leading, u)
+		} else {
+			afterBlank[anchor] = append(afterBlank[anchor], u)
+		}
+	}
+
+	src := skeleton.Content
+	var result bytes.Buffer
+	pos := 0
+	for i, s := range skelUnits {
+		key := s.Key.String()
+		start, end := s.Start, s.End
+		if i == 0 {
+			for _, u := range leading {
+				result.Write(src[pos:start])
+				pos = start
+				result.Write(u.Content)
+				result.WriteString("\n\n")
+			}
+		}
+
+		mu := merged[key]
+		if mu == nil {
+			// Deleted: drop its lines if nothing else is on them
+			lineStart, lineEnd := lineBounds(src, start, end)
+			if (i == 0 || skelUnits[i-1].End <= lineStart) && (i == len(skelUnits)-1 || skelUnits[i+1].Start >= lineEnd) {
+				start, end = lineStart, lineEnd
+			}
+			result.Write(src[pos:start])
+			pos = end
+			continue
+		}
+
+		text := mu.Content
+		if c := mu.conflict; c != nil {
+			// Markers go on lines of their own, so the block takes in the
+			// rest of the unit's lines if nothing else is on them
+			lineStart, lineEnd := lineBounds(src, start, end)
+			if lineEnd > end && src[lineEnd-1] == '\n' {
+				lineEnd-- // Keep the newline
+			}
+			if (i == 0 || skelUnits[i-1].End <= lineStart) && (i == len(skelUnits)-1 || skelUnits[i+1].Start >= lineEnd) {
+				prefix, suffix := string(src[lineStart:start]), string(src[end:lineEnd])
+				if strings.TrimSpace(prefix) != "" || strings.TrimSpace(suffix) != "" {
+					text = markConflict(c, prefix, suffix)
+					c.Resolutions = resolutions(c, prefix, suffix)
+					start, end = lineStart, lineEnd
+				}
+			}
+		}
+		if len(before[key])+len(after[key]) > 0 {
+			parts := append(append(append([]*MergeUnit(nil), before[key]...), &MergeUnit{Kind: mu.Kind, Name: mu.Name, Content: text}), after[key]...)
+			lineStart, _ := lineBounds(src, start, end)
+			prefix := string(src[lineStart:start])
+			indent := prefix[:len(prefix)-len(strings.TrimLeft(prefix, " \t"))]
+			if keyword, _, _ := strings.Cut(s.Group, " "); strings.TrimSpace(prefix) == keyword {
+				// import "fmt" becomes import ( ... )
+				text = append([]byte("(\n\t"), joinSpecs(parts, "\t")...)
+				text = append(text, "\n)"...)
+			} else {
+				text = joinSpecs(parts, indent)
+			}
+		}
+		for _, u := range afterBlank[key] {
+			text = append(append(append([]byte(nil), text...), "\n\n"...), u.Content...)
+		}
+
+		result.Write(src[pos:start])
+		result.Write(text)
+		pos = end
+	}
+	if len(skelUnits) == 0 {
+		for _, u := range leading {
+			result.Write(u.Content)
+			result.WriteString("\n\n")
+		}
+	}
+	result.Write(src[pos:])
+
+	return result.Bytes(), conflicts
+}
+
+// skeletonSide returns the side whose source a merged file is rebuilt from,
+// and the other side: left, unless only right changed the text between units.
+func skeletonSide(base, left, right *FileUnits) (skeleton, other *FileUnits) {
+	if bytes.Equal(trivia(base), trivia(left)) {
+		return right, left
+	}
+	return left, right
+}
+
+// outermostUnits returns a file's located units that aren't inside another
+// unit, in source order. Nested units (class methods) travel with their
+// parent's content.
+func outermostUnits(fu *FileUnits) []*MergeUnit {
 	var units []*MergeUnit
-	for _, u := range merged {
-		if u != nil {
+	for _, u := range fu.Units {
+		if u.End > 0 {
 			units = append(units, u)
 		}
 	}
-
-	// Sort by original position (use left as reference, fall back to right)
 	sort.Slice(units, func(i, j int) bool {
-		ui, uj := units[i], units[j]
-		return ui.Range.Start[0] < uj.Range.Start[0] ||
-			(ui.Range.Start[0] == uj.Range.Start[0] && ui.Range.Start[1] < uj.Range.Start[1])
+		if units[i].Start != units[j].Start {
+			return units[i].Start < units[j].Start
+		}
+		return units[i].End > units[j].End
 	})
 
-	// Rebuild content
-	var result bytes.Buffer
-	for i, u := range units {
+	var result []*MergeUnit
+	end := -1
+	for _, u := range units {
+		if u.Start < end {
+			continue
+		}
+		result = append(result, u)
+		end = u.End
+	}
+	return result
+}
+
+// trivia returns the text between a file's units.
+func trivia(fu *FileUnits) []byte {
+	var buf bytes.Buffer
+	pos := 0
+	for _, u := range outermostUnits(fu) {
+		buf.Write(fu.Content[pos:u.Start])
+		buf.WriteByte(0)
+		pos = u.End
+	}
+	buf.Write(fu.Content[pos:])
+	return buf.Bytes()
+}
+
+// groupNeighbour returns the key of the nearest unit of the same group in
+// direction dir that the skeleton has, or "" if there is none.
+func groupNeighbour(units []*MergeUnit, i, dir int, inSkeleton map[string]bool) string {
+	for j := i + dir; j >= 0 && j < len(units) && units[j].Group == units[i].Group; j += dir {
+		if key := units[j].Key.String(); inSkeleton[key] {
+			return key
+		}
+	}
+	return ""
+}
+
+// importNeighbour finds where a Go import spec goes among the skeleton's
+// specs of its group, sorted by path with the standard library first: before
+// the first spec that sorts after it (dir 1), unless that starts the
+// skeleton's third-party imports while the spec is a standard one, or else
+// after the last spec that sorts before it (dir -1). dir is 0 if the
+// skeleton has no spec of the group.
+func importNeighbour(units []*MergeUnit, u *MergeUnit, inSkeleton map[string]bool) (string, int) {
+	var prev, next *MergeUnit
+	for _, s := range units {
+		if s.Group != u.Group || !inSkeleton[s.Key.String()] {
+			continue
+		}
+		if importLess(s, u) {
+			prev = s
+		} else if next == nil {
+			next = s
+		}
+	}
+	switch {
+	case next != nil && (prev == nil || isStdImport(next.Name) == isStdImport(u.Name)):
+		return next.Key.String(), 1
+	case prev != nil:
+		return prev.Key.String(), -1
+	}
+	return "", 0
+}
+
+// insertImport adds an import spec to a run of specs inserted at the same
+// place, keeping the run sorted.
+func insertImport(specs []*MergeUnit, u *MergeUnit) []*MergeUnit {
+	i := sort.Search(len(specs), func(i int) bool { return importLess(u, specs[i]) })
+	return append(specs[:i], append([]*MergeUnit{u}, specs[i:]...)...)
+}
+
+// importLess orders Go import specs as gofmt and goimports lay them out: by
+// path, with the standard library first.
+func importLess(a, b *MergeUnit) bool {
+	if sa, sb := isStdImport(a.Name), isStdImport(b.Name); sa != sb {
+		return sa
+	}
+	return a.Name < b.Name
+}
+
+// isStdImport reports whether a Go import path is in the standard library,
+// whose first path element has no dot.
+func isStdImport(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// joinSpecs joins the specs of a declaration one per line at indent, with a
+// blank line where standard library imports give way to third-party ones.
+func joinSpecs(specs []*MergeUnit, indent string) []byte {
+	var buf bytes.Buffer
+	for i, u := range specs {
 		if i > 0 {
-			result.WriteString("\n\n")
+			buf.WriteString("\n")
+			if prev := specs[i-1]; prev.Kind == UnitImport && u.Kind == UnitImport && isStdImport(prev.Name) && !isStdImport(u.Name) {
+				buf.WriteString("\n")
+			}
+			buf.WriteString(indent)
 		}
-		result.Write(u.Content)
+		buf.Write(u.Content)
 	}
-	result.WriteString("\n")
+	return buf.Bytes()
+}
+
+// enclosingDeclaration returns the top-level declaration a grouped unit is
+// written in, such as the const ( ... ) block around a Go const spec.
+func enclosingDeclaration(u *MergeUnit, content []byte) *MergeUnit {
+	node, ok := u.RawNode.(*sitter.Node)
+	if !ok {
+		return nil
+	}
+	for node.Parent() != nil && node.Parent().Parent() != nil {
+		node = node.Parent()
+	}
+	return &MergeUnit{Key: u.Key, Content: content[node.StartByte():node.EndByte()]}
+}
 
-	return result.Bytes()
+// lineBounds widens [start, end) to the whole lines it occupies, including
+// the final newline.
+func lineBounds(src []byte, start, end int) (int, int) {
+	lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+	lineEnd := len(src)
+	if idx := bytes.IndexByte(src[end:], '\n'); idx >= 0 {
+		lineEnd = end + idx + 1
+	}
+	return lineStart, lineEnd
 }
 
 // Merge3Way is a convenience function for 3-way merge of single files.
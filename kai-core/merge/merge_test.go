@@ -1,6 +1,7 @@
 package merge
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -415,3 +416,527 @@ class MyClass:
 		t.Error("expected to find class 'MyClass'")
 	}
 }
+
+func TestExtractUnits_Go(t *testing.T) {
+	code := []byte(`// Copyright notice
+
+package cart
+
+import (
+	"fmt"
+	str "strings"
+)
+
+const (
+	A = iota // first
+	B
+)
+
+var x, y int
+
+type Cart[T any] struct{}
+
+// Total sums the cart.
+func (c *Cart[T]) Total() int { return 0 }
+
+func init() {}
+
+func init() {}
+`)
+
+	extractor := NewExtractor()
+	units, err := extractor.ExtractUnits("cart.go", code, "go")
+	if err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+
+	want := map[string]UnitKind{
+		"cart.go::package":        UnitModule,
+		"cart.go::import:fmt":     UnitImport,
+		"cart.go::import:strings": UnitImport,
+		"cart.go::A":              UnitConst,
+		"cart.go::B":              UnitConst,
+		"cart.go::x,y":            UnitVariable,
+		"cart.go::Cart":           UnitType,
+		"cart.go::Cart.Total":     UnitMethod,
+		"cart.go::init":           UnitFunction,
+		"cart.go::init#2":         UnitFunction,
+	}
+	for key, kind := range want {
+		u := units.Units[key]
+		if u == nil {
+			t.Errorf("missing unit %s", key)
+			continue
+		}
+		if u.Kind != kind {
+			t.Errorf("%s kind = %s, want %s", key, u.Kind, kind)
+		}
+	}
+	if len(units.Units) != len(want) {
+		t.Errorf("got %d units, want %d", len(units.Units), len(want))
+	}
+
+	if got := string(units.Units["cart.go::package"].Content); !strings.HasPrefix(got, "// Copyright notice") {
+		t.Errorf("package unit should keep the header, got %q", got)
+	}
+	if got := string(units.Units["cart.go::import:strings"].Content); got != `str "strings"` {
+		t.Errorf("import content = %q", got)
+	}
+	if a := units.Units["cart.go::A"]; a.Group != "const A" || string(a.Content) != "A = iota // first" {
+		t.Errorf("const A = group %q content %q", a.Group, a.Content)
+	}
+	if got := string(units.Units["cart.go::Cart.Total"].Content); !strings.HasPrefix(got, "// Total sums the cart.") {
+		t.Errorf("method should keep its doc comment, got %q", got)
+	}
+}
+
+func TestMerge3Way_Go(t *testing.T) {
+	base := []byte(`package cart
+
+import "fmt"
+
+const (
+	A = iota
+	B
+)
+
+func (c *Cart) Total() int {
+	return 0
+}
+
+func (o *Order) Total() int {
+	return 0
+}
+
+func Print() {
+	fmt.Println("cart")
+}
+`)
+
+	left := []byte(`package cart
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	A = iota
+	B
+	C
+)
+
+func (c *Cart) Total() int {
+	return 1
+}
+
+func (o *Order) Total() int {
+	return 0
+}
+
+func Print() {
+	fmt.Fprintln(os.Stderr, "cart")
+}
+`)
+
+	right := []byte(`package cart
+
+import (
+	"example.com/money"
+	"fmt"
+)
+
+const (
+	A = iota
+	B
+)
+
+func (c *Cart) Total() int {
+	return 0
+}
+
+func (o *Order) Total() int {
+	return 2
+}
+
+func Print() {
+	fmt.Println("cart")
+}
+
+func Sum() money.Amount {
+	return 0
+}
+`)
+
+	result, err := Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("expected success, got conflicts: %v", result.Conflicts)
+	}
+
+	merged := string(result.Files["file"])
+	for _, want := range []string{
+		"import (\n\t\"fmt\"\n\t\"os\"\n\n\t\"example.com/money\"\n)",
+		"const (\n\tA = iota\n\tB\n\tC\n)",
+		"func (c *Cart) Total() int {\n\treturn 1\n}",
+		"func (o *Order) Total() int {\n\treturn 2\n}",
+		"fmt.Fprintln(os.Stderr, \"cart\")",
+		"func Sum() money.Amount",
+	} {
+		if !strings.Contains(merged, want) {
+			t.Errorf("merged file missing %q:\n%s", want, merged)
+		}
+	}
+	if !strings.HasPrefix(merged, "package cart\n\nimport (") {
+		t.Errorf("expected package clause then imports:\n%s", merged)
+	}
+}
+
+func TestMerge3Way_Go_ImportAliasConflict(t *testing.T) {
+	base := []byte("package a\n\nimport \"strings\"\n")
+	left := []byte("package a\n\nimport s \"strings\"\n")
+	right := []byte("package a\n\nimport str \"strings\"\n")
+
+	result, err := Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || len(result.Conflicts) != 1 || result.Conflicts[0].Kind != ConflictImportAlias {
+		t.Errorf("expected one import alias conflict, got %v", result.Conflicts)
+	}
+}
+
+func TestMerge3Way_PreservesTrivia(t *testing.T) {
+	base := []byte(`// Package cart holds carts.
+package cart
+
+import "fmt"
+
+// Limit caps items.
+const Limit = 10
+
+// Add adds an item.
+func Add() {
+	fmt.Println("add")
+}
+
+func Remove() {}
+
+// trailing note
+`)
+
+	left := []byte(`// Package cart holds carts.
+package cart
+
+import "fmt"
+
+// Limit caps items.
+const Limit = 20
+
+// Add adds an item.
+func Add() {
+	fmt.Println("add")
+}
+
+// trailing note
+`)
+
+	right := []byte(`// Package cart holds carts.
+package cart
+
+import "fmt"
+
+// Limit caps items.
+const Limit = 10
+
+// Add adds an item.
+func Add() {
+	fmt.Println("add", 1)
+}
+
+func Remove() {}
+
+// trailing note
+`)
+
+	want := `// Package cart holds carts.
+package cart
+
+import "fmt"
+
+// Limit caps items.
+const Limit = 20
+
+// Add adds an item.
+func Add() {
+	fmt.Println("add", 1)
+}
+
+// trailing note
+`
+
+	result, err := Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("expected success, got conflicts: %v", result.Conflicts)
+	}
+	if got := string(result.Files["file"]); got != want {
+		t.Errorf("merged file:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMerge3Way_PreservesTrivia_Inserts(t *testing.T) {
+	base := []byte(`package a
+
+import "fmt"
+
+const (
+	A = 1
+)
+
+func F() { fmt.Println() }
+`)
+
+	// Left reformats the text between units; right adds units
+	left := []byte(`package a
+
+import "fmt"
+
+const (
+	A = 1 // first
+)
+
+// ---- functions ----
+
+func F() { fmt.Println() }
+`)
+
+	right := []byte(`package a
+
+import "fmt"
+import "os"
+
+const (
+	A = 1
+	B = 2
+)
+
+func F() { fmt.Println() }
+
+func G() { os.Exit(1) }
+`)
+
+	want := `package a
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	A = 1 // first
+	B = 2
+)
+
+// ---- functions ----
+
+func F() { fmt.Println() }
+
+func G() { os.Exit(1) }
+`
+
+	result, err := Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("expected success, got conflicts: %v", result.Conflicts)
+	}
+	if got := string(result.Files["file"]); got != want {
+		t.Errorf("merged file:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMerge3Way_PreservesTrivia_JS(t *testing.T) {
+	base := []byte(`'use strict';
+
+function foo() {
+  return 1;
+}
+
+function bar() {
+  return 2;
+}
+
+module.exports = { foo, bar };
+`)
+
+	left := []byte(`'use strict';
+
+function foo() {
+  return 10;
+}
+
+function bar() {
+  return 2;
+}
+
+module.exports = { foo, bar };
+`)
+
+	right := []byte(`'use strict';
+
+function foo() {
+  return 1;
+}
+
+function bar() {
+  return 20;
+}
+
+module.exports = { foo, bar };
+`)
+
+	result, err := Merge3Way(base, left, right, "js")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := strings.Replace(string(left), "return 2;", "return 20;", 1)
+	if got := string(result.Files["file"]); got != want {
+		t.Errorf("merged file:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMerge3Way_MergesTextBetweenUnits(t *testing.T) {
+	base := []byte("def f():\n    return 1\n\nprint(f())\n")
+	left := []byte("# header\ndef f():\n    return 1\n\nprint(f())\n")
+	right := []byte("def f():\n    return 2\n\nprint(f())\nprint('extra')\n")
+
+	result, err := Merge3Way(base, left, right, "py")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("expected success, got conflicts: %v", result.Conflicts)
+	}
+	want := "# header\ndef f():\n    return 2\n\nprint(f())\nprint('extra')\n"
+	if got := string(result.Files["file"]); got != want {
+		t.Errorf("merged file:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMerge3Way_TextConflict(t *testing.T) {
+	base := []byte("def f():\n    return 1\n\nprint('a')\n")
+	left := []byte("def f():\n    return 1\n\nprint('b')\n")
+	right := []byte("def f():\n    return 2\n\nprint('c')\n")
+
+	result, err := Merge3Way(base, left, right, "py")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || len(result.Conflicts) != 1 {
+		t.Fatalf("expected one conflict, got %v", result.Conflicts)
+	}
+	c := result.Conflicts[0]
+	if c.Kind != ConflictTextDiverged || c.UnitKey.String() != "file::line4" {
+		t.Errorf("conflict = %s %s, want TEXT_DIVERGED file::line4", c.Kind, c.UnitKey)
+	}
+
+	want := "def f():\n    return 2\n\n" +
+		"<<<<<<< left TEXT_DIVERGED line4: print('a') -> print('b')\n" +
+		"print('b')\n" +
+		"=======\n" +
+		"print('c')\n" +
+		">>>>>>> right: print('a') -> print('c')\n"
+	merged := result.Files["file"]
+	if string(merged) != want {
+		t.Errorf("merged file:\n%s\nwant:\n%s", merged, want)
+	}
+
+	blocks := FindConflicts(merged)
+	if len(blocks) != 1 || blocks[0].Unit != "line4" || blocks[0].Kind != ConflictTextDiverged {
+		t.Fatalf("blocks = %+v", blocks)
+	}
+	resolved := ResolveConflict(merged, blocks[0], c.Resolutions[1].Result)
+	if got := string(resolved); got != string(right) {
+		t.Errorf("resolved file:\n%s\nwant:\n%s", got, right)
+	}
+}
+
+func TestMerge3Way_Go_ImportOrder(t *testing.T) {
+	base := []byte("package a\n\nimport (\n\t\"fmt\"\n)\n\nfunc F() { fmt.Println() }\n")
+	left := []byte("package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc F() { fmt.Println(os.Args) }\n")
+	right := []byte("package a\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\nfunc F() { fmt.Println() }\n\nfunc G() string { return strings.TrimSpace(\"\") }\n")
+
+	result, err := Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("expected success, got conflicts: %v", result.Conflicts)
+	}
+	if merged := string(result.Files["file"]); !strings.Contains(merged, "import (\n\t\"fmt\"\n\t\"os\"\n\t\"strings\"\n)") {
+		t.Errorf("imports not sorted:\n%s", merged)
+	}
+}
+
+func TestMerge3Way_Go_ImportBecomesBlock(t *testing.T) {
+	base := []byte("package cart\n\nimport \"fmt\"\n\nfunc Print() {\n\tfmt.Println()\n}\n")
+	left := []byte("package cart\n\nimport \"fmt\"\n\nfunc Print() {\n\tfmt.Println()\n}\n\nfunc A() {}\n")
+	right := []byte("package cart\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc Print() {\n\tfmt.Println()\n}\n")
+	want := "package cart\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc Print() {\n\tfmt.Println()\n}\n\nfunc A() {}\n"
+
+	for _, sides := range [][2][]byte{{left, right}, {right, left}} {
+		result, err := Merge3Way(base, sides[0], sides[1], "go")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Success {
+			t.Fatalf("expected success, got conflicts: %v", result.Conflicts)
+		}
+		if got := string(result.Files["file"]); got != want {
+			t.Errorf("merged file:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
+
+func TestMerge3Way_Go_ConstAppends(t *testing.T) {
+	base := []byte("package a\n\nconst (\n\tA = iota\n\tB\n)\n")
+	left := []byte("package a\n\nconst (\n\tA = iota\n\tB\n\tC\n)\n")
+	right := []byte("package a\n\nconst (\n\tA = iota\n\tB\n\tD\n)\n")
+
+	// Merging both would give C the value 3 instead of 2
+	result, err := Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || len(result.Conflicts) != 1 || result.Conflicts[0].Kind != ConflictConstValueConflict {
+		t.Fatalf("expected one const value conflict, got %v", result.Conflicts)
+	}
+	c := result.Conflicts[0]
+	if c.LeftDiff != "added C" || c.RightDiff != "added D" {
+		t.Errorf("diffs = %q, %q", c.LeftDiff, c.RightDiff)
+	}
+	if blocks := FindConflicts(result.Files["file"]); len(blocks) != 1 || blocks[0].Unit != "C" {
+		t.Errorf("blocks = %+v\n%s", blocks, result.Files["file"])
+	}
+
+	// Explicit values don't shift, so both sides' specs merge
+	base = []byte("package a\n\nconst (\n\tA = 1\n\tB = 2\n)\n")
+	left = []byte("package a\n\nconst (\n\tA = 1\n\tB = 2\n\tC = 3\n)\n")
+	right = []byte("package a\n\nconst (\n\tA = 1\n\tB = 2\n\tD = 4\n)\n")
+	result, err = Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("expected success, got conflicts: %v", result.Conflicts)
+	}
+	if merged := string(result.Files["file"]); !strings.Contains(merged, "\tC = 3\n") || !strings.Contains(merged, "\tD = 4\n") {
+		t.Errorf("expected both constants:\n%s", merged)
+	}
+}
@@ -0,0 +1,291 @@
+package merge
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// bodyNodeTypes are the function body nodes whose statements can be merged
+// one by one: Go, Python and Rust blocks, JS statement blocks and Ruby
+// method bodies.
+var bodyNodeTypes = map[string]bool{
+	"block":           true,
+	"statement_block": true,
+	"body_statement":  true,
+}
+
+// functionBody is a function unit split into the text around its body and
+// the body's top-level statements. Offsets are relative to the unit content.
+type functionBody struct {
+	content    []byte
+	start, end int      // the body node
+	stmts      [][2]int // each statement's span
+	texts      []string // each statement's text
+}
+
+// splitBody finds a function unit's body and its statements. It returns nil
+// for units without a located body.
+func splitBody(u *MergeUnit) *functionBody {
+	node, ok := u.RawNode.(*sitter.Node)
+	if !ok || u.End == 0 {
+		return nil
+	}
+
+	// The body is a child of the function, or of the function expression
+	// a variable declarator holds
+	var body *sitter.Node
+	for i := 0; i < int(node.ChildCount()) && body == nil; i++ {
+		child := node.Child(i)
+		if bodyNodeTypes[child.Type()] {
+			body = child
+			break
+		}
+		for j := 0; j < int(child.ChildCount()); j++ {
+			if bodyNodeTypes[child.Child(j).Type()] {
+				body = child.Child(j)
+				break
+			}
+		}
+	}
+	if body == nil {
+		return nil
+	}
+
+	fb := &functionBody{
+		content: u.Content,
+		start:   int(body.StartByte()) - u.Start,
+		end:     int(body.EndByte()) - u.Start,
+	}
+	if fb.start < 0 || fb.end > len(u.Content) {
+		return nil
+	}
+	for i := 0; i < int(body.NamedChildCount()); i++ {
+		stmt := body.NamedChild(i)
+		span := [2]int{int(stmt.StartByte()) - u.Start, int(stmt.EndByte()) - u.Start}
+		fb.stmts = append(fb.stmts, span)
+		fb.texts = append(fb.texts, string(u.Content[span[0]:span[1]]))
+	}
+	return fb
+}
+
+// outside returns the function's text around the body: signature, doc
+// comments, decorators.
+func (fb *functionBody) outside() string {
+	return string(fb.content[:fb.start]) + "\x00" + string(fb.content[fb.end:])
+}
+
+// gap returns the text before statement i: the body's opening for the first
+// statement, otherwise the text since the previous statement.
+func (fb *functionBody) gap(i int) string {
+	if i == 0 {
+		return string(fb.content[fb.start:fb.stmts[0][0]])
+	}
+	return string(fb.content[fb.stmts[i-1][1]:fb.stmts[i][0]])
+}
+
+// stmtRef is a statement of the merged body, taken from one side.
+type stmtRef struct {
+	body *functionBody
+	idx  int
+}
+
+// mergeStatements merges a function both sides changed by aligning the
+// statements of its body. Edits to different statements merge; the same
+// statements changed differently on both sides are a STATEMENT_DIVERGED
+// conflict. ok is false when the bodies can't be split, so the caller falls
+// back to comparing whole bodies.
+func (m *Merger) mergeStatements(base, left, right *MergeUnit) (merged *MergeUnit, conflict *Conflict, ok bool) {
+	b, l, r := splitBody(base), splitBody(left), splitBody(right)
+	if b == nil || l == nil || r == nil || len(l.stmts) == 0 || len(r.stmts) == 0 {
+		return nil, nil, false
+	}
+
+	// The text around the body merges as a whole
+	outer, outerUnit := l, left
+	switch {
+	case l.outside() == b.outside():
+		outer, outerUnit = r, right
+	case r.outside() == b.outside() || r.outside() == l.outside():
+	default:
+		return nil, nil, false
+	}
+
+	matchL := matchStatements(b.texts, l.texts)
+	matchR := matchStatements(b.texts, r.texts)
+
+	var result []stmtRef
+	take := func(fb *functionBody, from, to int) {
+		for i := from; i < to; i++ {
+			result = append(result, stmtRef{fb, i})
+		}
+	}
+
+	// diff3: walk the base statements both sides kept, and merge the chunks
+	// between them
+	i, li, ri := 0, 0, 0
+	for {
+		k := i
+		for k < len(b.texts) && (matchL[k] < 0 || matchR[k] < 0) {
+			k++
+		}
+		lEnd, rEnd := len(l.texts), len(r.texts)
+		if k < len(b.texts) {
+			lEnd, rEnd = matchL[k], matchR[k]
+		}
+
+		baseChunk, leftChunk, rightChunk := b.texts[i:k], l.texts[li:lEnd], r.texts[ri:rEnd]
+		switch {
+		case equalStrings(leftChunk, baseChunk):
+			take(r, ri, rEnd)
+		case equalStrings(rightChunk, baseChunk), equalStrings(leftChunk, rightChunk):
+			take(l, li, lEnd)
+		case len(leftChunk) == len(baseChunk) && len(rightChunk) == len(baseChunk) && replacedApart(baseChunk, leftChunk, rightChunk):
+			// Both sides rewrote statements in place, different ones
+			for n := range baseChunk {
+				if leftChunk[n] == baseChunk[n] {
+					take(r, ri+n, ri+n+1)
+				} else {
+					take(l, li+n, li+n+1)
+				}
+			}
+		default:
+			return nil, &Conflict{
+				Kind:      ConflictStatementDiverged,
+				UnitKey:   base.Key,
+				Message:   fmt.Sprintf("Function %s: the same statements changed differently on both sides", base.Name),
+				Base:      base,
+				Left:      left,
+				Right:     right,
+				LeftDiff:  describeChunk(baseChunk, leftChunk),
+				RightDiff: describeChunk(baseChunk, rightChunk),
+			}, true
+		}
+
+		if k == len(b.texts) {
+			break
+		}
+		take(l, lEnd, lEnd+1) // The kept statement
+		i, li, ri = k+1, lEnd+1, rEnd+1
+	}
+
+	// Reassemble with each statement's own leading text, so indentation and
+	// blank lines come from the side the statement came from
+	var body strings.Builder
+	for j, s := range result {
+		switch {
+		case j == 0:
+			body.WriteString(outer.gap(0))
+		case s.idx > 0:
+			body.WriteString(s.body.gap(s.idx))
+		default:
+			// First statement on its side: use the separator that followed
+			// the previous statement
+			prev := result[j-1]
+			if prev.idx+1 < len(prev.body.stmts) {
+				body.WriteString(prev.body.gap(prev.idx + 1))
+			} else {
+				body.WriteString("\n")
+			}
+		}
+		body.WriteString(s.body.texts[s.idx])
+	}
+	if len(result) == 0 {
+		body.WriteString(outer.gap(0))
+	}
+	body.WriteString(string(outer.content[outer.stmts[len(outer.stmts)-1][1]:outer.end]))
+
+	content := string(outer.content[:outer.start]) + body.String() + string(outer.content[outer.end:])
+	hash := sha256.Sum256([]byte(content))
+
+	unit := *outerUnit
+	unit.Content = []byte(content)
+	unit.BodyHash = hash[:]
+	return &unit, nil, true
+}
+
+// matchStatements aligns two statement lists by their longest common
+// subsequence, returning for each statement of a its index in b, or -1.
+func matchStatements(a, b []string) []int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	match := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			match[i] = j
+			i++
+			j++
+		case j < len(b) && lcs[i][j+1] >= lcs[i+1][j]:
+			j++
+		default:
+			match[i] = -1
+			i++
+		}
+	}
+	return match
+}
+
+// replacedApart reports whether no statement of equal-length chunks was
+// changed differently by both sides.
+func replacedApart(base, left, right []string) bool {
+	for n := range base {
+		if left[n] != base[n] && right[n] != base[n] && left[n] != right[n] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// describeChunk summarizes one side's edit of a run of statements by its
+// first line. Statements the side kept at either end are skipped, so an
+// insertion after an unchanged statement reads as an insertion.
+func describeChunk(base, side []string) string {
+	for len(base) > 0 && len(side) > 0 && base[0] == side[0] {
+		base, side = base[1:], side[1:]
+	}
+	for len(base) > 0 && len(side) > 0 && base[len(base)-1] == side[len(side)-1] {
+		base, side = base[:len(base)-1], side[:len(side)-1]
+	}
+
+	first := func(s string) string {
+		line, _, _ := strings.Cut(s, "\n")
+		return strings.TrimSpace(line)
+	}
+	switch {
+	case len(side) == 0 && len(base) == 0:
+		return "unchanged"
+	case len(side) == 0:
+		return "removed " + first(base[0])
+	case len(base) == 0:
+		return "added " + first(side[0])
+	}
+	return first(base[0]) + " -> " + first(side[0])
+}
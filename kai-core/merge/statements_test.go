@@ -0,0 +1,125 @@
+package merge
+
+import (
+	"testing"
+)
+
+func TestMerge3Way_Statements(t *testing.T) {
+	tests := []struct {
+		name              string
+		lang              string
+		base, left, right string
+		want              string
+	}{
+		{
+			name:  "go different statements",
+			lang:  "go",
+			base:  "package a\n\nfunc F() int {\n\tx := 1\n\ty := 2\n\n\treturn x + y\n}\n",
+			left:  "package a\n\nfunc F() int {\n\tx := 10\n\ty := 2\n\n\treturn x + y\n}\n",
+			right: "package a\n\nfunc F() int {\n\tx := 1\n\ty := 2\n\tlog(y)\n\n\treturn x + y\n}\n",
+			want:  "package a\n\nfunc F() int {\n\tx := 10\n\ty := 2\n\tlog(y)\n\n\treturn x + y\n}\n",
+		},
+		{
+			name:  "go doc comment and body",
+			lang:  "go",
+			base:  "package a\n\nfunc F() int {\n\tx := 1\n\treturn x\n}\n",
+			left:  "package a\n\n// F returns one.\nfunc F() int {\n\tx := 1\n\treturn x\n}\n",
+			right: "package a\n\nfunc F() int {\n\tx := 2\n\treturn x\n}\n",
+			want:  "package a\n\n// F returns one.\nfunc F() int {\n\tx := 2\n\treturn x\n}\n",
+		},
+		{
+			name:  "js insert and delete",
+			lang:  "js",
+			base:  "function f() {\n  a();\n  b();\n  c();\n}\n",
+			left:  "function f() {\n  start();\n  a();\n  b();\n  c();\n}\n",
+			right: "function f() {\n  a();\n  c();\n}\n",
+			want:  "function f() {\n  start();\n  a();\n  c();\n}\n",
+		},
+		{
+			name:  "python",
+			lang:  "py",
+			base:  "def f():\n    a = 1\n    b = 2\n    return a + b\n",
+			left:  "def f():\n    a = 3\n    b = 2\n    return a + b\n",
+			right: "def f():\n    a = 1\n    b = 4\n    return a + b\n",
+			want:  "def f():\n    a = 3\n    b = 4\n    return a + b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Merge3Way([]byte(tt.base), []byte(tt.left), []byte(tt.right), tt.lang)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Success {
+				t.Fatalf("expected success, got conflicts: %v", result.Conflicts)
+			}
+			if got := string(result.Files["file"]); got != tt.want {
+				t.Errorf("merged file:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge3Way_StatementConflict(t *testing.T) {
+	base := []byte("package a\n\nfunc F() int {\n\tx := 1\n\treturn x\n}\n")
+	left := []byte("package a\n\nfunc F() int {\n\tx := 2\n\treturn x\n}\n")
+	right := []byte("package a\n\nfunc F() int {\n\tx := 3\n\treturn x\n}\n")
+
+	result, err := Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || len(result.Conflicts) != 1 {
+		t.Fatalf("expected one conflict, got %v", result.Conflicts)
+	}
+	c := result.Conflicts[0]
+	if c.Kind != ConflictStatementDiverged || c.LeftDiff != "x := 1 -> x := 2" || c.RightDiff != "x := 1 -> x := 3" {
+		t.Errorf("conflict = %s %q %q", c.Kind, c.LeftDiff, c.RightDiff)
+	}
+}
+
+func TestMerge3Way_StatementConflictInsertion(t *testing.T) {
+	base := []byte("package a\n\nfunc F() int {\n\tx := 1\n\treturn x\n}\n")
+	left := []byte("package a\n\nfunc F() int {\n\tx := 1\n\tx++\n\treturn x\n}\n")
+	right := []byte("package a\n\nfunc F() int {\n\tx := 3\n\treturn x\n}\n")
+
+	result, err := Merge3Way(base, left, right, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || len(result.Conflicts) != 1 {
+		t.Fatalf("expected one conflict, got %v", result.Conflicts)
+	}
+	c := result.Conflicts[0]
+	if c.LeftDiff != "added x++" || c.RightDiff != "x := 1 -> x := 3" {
+		t.Errorf("conflict diffs = %q %q", c.LeftDiff, c.RightDiff)
+	}
+}
+
+func TestDescribeChunk(t *testing.T) {
+	tests := []struct {
+		base, side []string
+		want       string
+	}{
+		{[]string{"a"}, []string{"a", "b"}, "added b"},
+		{[]string{"a", "b", "c"}, []string{"a", "c"}, "removed b"},
+		{[]string{"a", "b"}, []string{"a", "x"}, "b -> x"},
+		{[]string{"a"}, []string{"a"}, "unchanged"},
+	}
+	for _, tt := range tests {
+		if got := describeChunk(tt.base, tt.side); got != tt.want {
+			t.Errorf("describeChunk(%q, %q) = %q, want %q", tt.base, tt.side, got, tt.want)
+		}
+	}
+}
+
+func TestMatchStatements(t *testing.T) {
+	got := matchStatements([]string{"a", "b", "c", "d"}, []string{"a", "x", "c", "d", "e"})
+	want := []int{0, -1, 2, 3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("matchStatements = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package merge
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// mergeTrivia merges the text between the skeleton's units with the same
+// stretch of text on the other side, and returns the skeleton with the
+// merged text in place. A stretch is merged where it lies between the same
+// two units on all three sides; where units were added or removed around
+// it, the skeleton's text is kept and the units are merged on their own.
+//
+// The text around a grouped declaration whose specs the other side added or
+// removed holds delimiters, such as the parentheses of import ( ... ), that
+// only match that side's specs. Its specs are merged and written back on
+// their own, so the skeleton's delimiters are kept; any other edit the
+// other side made there is a TEXT_DIVERGED conflict.
+//
+// Nested units keep their offsets in the original skeleton; only outermost
+// units are located in the returned content.
+func mergeTrivia(base, left, right, skeleton *FileUnits) (*FileUnits, []Conflict) {
+	other := right
+	if skeleton == right {
+		other = left
+	}
+	baseStretches, otherStretches := stretches(base), stretches(other)
+	baseGroups, otherGroups := groupMembers(base), groupMembers(other)
+	regrouped := func(key string) bool {
+		u := base.Units[key]
+		return u != nil && u.Group != "" && baseGroups[u.Group] != otherGroups[u.Group]
+	}
+
+	result := &FileUnits{
+		Path:  skeleton.Path,
+		Lang:  skeleton.Lang,
+		Units: make(map[string]*MergeUnit, len(skeleton.Units)),
+	}
+	for k, u := range skeleton.Units {
+		result.Units[k] = u
+	}
+
+	src := skeleton.Content
+	units := outermostUnits(skeleton)
+	var buf bytes.Buffer
+	var conflicts []Conflict
+	pos, prev := 0, ""
+	for i := 0; i <= len(units); i++ {
+		end, next := len(src), ""
+		if i < len(units) {
+			end, next = units[i].Start, units[i].Key.String()
+		}
+
+		text := string(src[pos:end])
+		b, inBase := baseStretches[prev+"\x00"+next]
+		o, inOther := otherStretches[prev+"\x00"+next]
+		if inBase && inOther {
+			sides := [3]string{string(base.Content[b[0]:b[1]]), text, string(other.Content[o[0]:o[1]])}
+			if skeleton == right {
+				sides[1], sides[2] = sides[2], sides[1]
+			}
+			line := bytes.Count(base.Content[:b[0]], []byte("\n")) + 1
+			var cs []Conflict
+			switch {
+			case !regrouped(prev) && !regrouped(next):
+				text, cs = mergeText(base.Path, sides[0], sides[1], sides[2], line)
+			case !sameBesidesDelimiters(base.Content[b[0]:b[1]], other.Content[o[0]:o[1]]):
+				var out strings.Builder
+				c := textConflict(base.Path, strings.SplitAfter(sides[0], "\n"), strings.SplitAfter(sides[1], "\n"), strings.SplitAfter(sides[2], "\n"), line)
+				writeTextConflict(&out, &c)
+				text, cs = out.String(), []Conflict{c}
+			}
+			if strings.HasPrefix(text, MarkerStart) && buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+				buf.WriteString("\n") // Markers start a line of their own
+			}
+			conflicts = append(conflicts, cs...)
+		}
+		buf.WriteString(text)
+
+		if i == len(units) {
+			break
+		}
+		u := *units[i]
+		u.Start = buf.Len()
+		buf.Write(src[units[i].Start:units[i].End])
+		u.End = buf.Len()
+		result.Units[next] = &u
+		pos, prev = units[i].End, next
+	}
+	result.Content = buf.Bytes()
+	return result, conflicts
+}
+
+// stretches returns the byte range of each stretch of text between a file's
+// outermost units, keyed by the units before and after it ("" at either end
+// of the file).
+func stretches(fu *FileUnits) map[string][2]int {
+	result := make(map[string][2]int)
+	pos, prev := 0, ""
+	for _, u := range outermostUnits(fu) {
+		key := u.Key.String()
+		result[prev+"\x00"+key] = [2]int{pos, u.Start}
+		pos, prev = u.End, key
+	}
+	result[prev+"\x00"] = [2]int{pos, len(fu.Content)}
+	return result
+}
+
+// mergeText 3-way merges a stretch of text line by line, the way
+// mergeStatements merges statements. Lines both sides changed differently
+// are written between conflict markers, each run as a TEXT_DIVERGED
+// conflict named by its line in base. line is the 1-based line the stretch
+// starts on in base.
+func mergeText(path, base, left, right string, line int) (string, []Conflict) {
+	switch {
+	case left == base:
+		return right, nil
+	case right == base, right == left:
+		return left, nil
+	}
+
+	b, l, r := strings.SplitAfter(base, "\n"), strings.SplitAfter(left, "\n"), strings.SplitAfter(right, "\n")
+	matchL := matchStatements(b, l)
+	matchR := matchStatements(b, r)
+
+	var out strings.Builder
+	var conflicts []Conflict
+	i, li, ri := 0, 0, 0
+	for {
+		k := i
+		for k < len(b) && (matchL[k] < 0 || matchR[k] < 0) {
+			k++
+		}
+		lEnd, rEnd := len(l), len(r)
+		if k < len(b) {
+			lEnd, rEnd = matchL[k], matchR[k]
+		}
+
+		baseChunk, leftChunk, rightChunk := b[i:k], l[li:lEnd], r[ri:rEnd]
+		switch {
+		case equalStrings(leftChunk, baseChunk):
+			out.WriteString(strings.Join(rightChunk, ""))
+		case equalStrings(rightChunk, baseChunk), equalStrings(leftChunk, rightChunk):
+			out.WriteString(strings.Join(leftChunk, ""))
+		default:
+			c := textConflict(path, baseChunk, leftChunk, rightChunk, line+i)
+			writeTextConflict(&out, &c)
+			conflicts = append(conflicts, c)
+		}
+
+		if k == len(b) {
+			break
+		}
+		out.WriteString(b[k]) // The kept line
+		i, li, ri = k+1, lEnd+1, rEnd+1
+	}
+	return out.String(), conflicts
+}
+
+// textConflict returns the TEXT_DIVERGED conflict for runs of lines both
+// sides changed differently, starting on the given line of base.
+func textConflict(path string, base, left, right []string, line int) Conflict {
+	c := Conflict{
+		Kind:      ConflictTextDiverged,
+		UnitKey:   UnitKey{File: path, SymbolPath: []string{fmt.Sprintf("line%d", line)}},
+		Message:   fmt.Sprintf("Text at line %d changed differently on both sides", line),
+		Base:      textUnit(base),
+		Left:      textUnit(left),
+		Right:     textUnit(right),
+		LeftDiff:  describeChunk(base, left),
+		RightDiff: describeChunk(base, right),
+	}
+	c.Resolutions = resolutions(&c, "", "")
+	return c
+}
+
+// writeTextConflict writes a text conflict between markers on lines of
+// their own.
+func writeTextConflict(out *strings.Builder, c *Conflict) {
+	if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+		out.WriteString("\n")
+	}
+	out.Write(markConflict(c, "", ""))
+	out.WriteString("\n")
+}
+
+// groupMembers returns the keys of each group's units, in source order.
+func groupMembers(fu *FileUnits) map[string]string {
+	members := make(map[string]string)
+	for _, u := range outermostUnits(fu) {
+		if u.Group != "" {
+			members[u.Group] += u.Key.String() + "\x00"
+		}
+	}
+	return members
+}
+
+// sameBesidesDelimiters reports whether two stretches of text differ only
+// in whitespace and parentheses.
+func sameBesidesDelimiters(a, b []byte) bool {
+	strip := func(r rune) rune {
+		if unicode.IsSpace(r) || r == '(' || r == ')' {
+			return -1
+		}
+		return r
+	}
+	return bytes.Equal(bytes.Map(strip, a), bytes.Map(strip, b))
+}
+
+// textUnit wraps a run of lines as the content of one side of a
+// TEXT_DIVERGED conflict; nil if the side removed them.
+func textUnit(lines []string) *MergeUnit {
+	if len(lines) == 0 {
+		return nil
+	}
+	return &MergeUnit{Content: []byte(strings.TrimSuffix(strings.Join(lines, ""), "\n"))}
+}
@@ -51,6 +51,11 @@ type MergeUnit struct {
 	Children  []*MergeUnit // for classes: methods; for blocks: statements
 	RawNode   interface{}  // underlying AST node (language-specific)
 	Content   []byte       // source content for this unit
+	Group     string       // Declaration the unit is written back inside, e.g. "const A" for the specs of a Go const block
+	Start     int          // byte offset of Content in the file
+	End       int          // byte offset after Content; 0 if the unit could not be located
+
+	conflict *Conflict // set on the stand-in for a conflicting unit
 }
 
 // ConflictKind classifies the type of merge conflict.
@@ -75,7 +80,11 @@ const (
 	ConflictImportAlias      ConflictKind = "IMPORT_ALIAS_CONFLICT"
 
 	// Body conflicts
-	ConflictBodyDiverged ConflictKind = "BODY_DIVERGED"
+	ConflictBodyDiverged      ConflictKind = "BODY_DIVERGED"
+	ConflictStatementDiverged ConflictKind = "STATEMENT_DIVERGED" // same statements of a function body changed on both sides
+
+	// Text conflicts
+	ConflictTextDiverged ConflictKind = "TEXT_DIVERGED" // same lines between units changed on both sides
 )
 
 // Conflict represents a semantic merge conflict.
@@ -103,7 +112,7 @@ type Resolution struct {
 // MergeResult contains the outcome of a 3-way merge.
 type MergeResult struct {
 	Success   bool
-	Files     map[string][]byte // merged file contents
+	Files     map[string][]byte // merged file contents; conflicting units are written between markers
 	Conflicts []Conflict
 	Stats     MergeStats
 }
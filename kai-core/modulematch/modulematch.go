@@ -15,14 +15,28 @@ type ModuleRule struct {
 	Paths []string `yaml:"paths"`
 }
 
+// LayerRule forbids a module from importing the listed modules.
+type LayerRule struct {
+	From string   `yaml:"from"`
+	Deny []string `yaml:"deny"`
+}
+
+// LayersConfig holds architecture rules evaluated against the import graph.
+type LayersConfig struct {
+	NoCycles bool        `yaml:"noCycles,omitempty"` // Forbid import cycles between modules
+	Rules    []LayerRule `yaml:"rules,omitempty"`
+}
+
 // ModulesConfig holds the modules configuration.
 type ModulesConfig struct {
-	Modules []ModuleRule `yaml:"modules"`
+	Modules []ModuleRule  `yaml:"modules"`
+	Layers  *LayersConfig `yaml:"layers,omitempty"`
 }
 
 // Matcher matches file paths to modules.
 type Matcher struct {
 	modules []ModuleRule
+	layers  *LayersConfig
 }
 
 // LoadRules loads module rules from a YAML file.
@@ -37,7 +51,7 @@ func LoadRules(path string) (*Matcher, error) {
 		return nil, fmt.Errorf("parsing modules file: %w", err)
 	}
 
-	return &Matcher{modules: config.Modules}, nil
+	return &Matcher{modules: config.Modules, layers: config.Layers}, nil
 }
 
 // NewMatcher creates a matcher from a list of module rules.
@@ -84,6 +98,16 @@ func (m *Matcher) GetAllModules() []ModuleRule {
 	return m.modules
 }
 
+// GetLayers returns the layering rules, or nil if none are configured.
+func (m *Matcher) GetLayers() *LayersConfig {
+	return m.layers
+}
+
+// SetLayers replaces the layering rules.
+func (m *Matcher) SetLayers(layers *LayersConfig) {
+	m.layers = layers
+}
+
 // GetModulePayload returns the payload for a module node.
 func (m *Matcher) GetModulePayload(name string) map[string]interface{} {
 	for _, mod := range m.modules {
@@ -135,7 +159,7 @@ func (m *Matcher) RemoveModule(name string) bool {
 
 // SaveRules saves module rules to a YAML file.
 func (m *Matcher) SaveRules(path string) error {
-	config := ModulesConfig{Modules: m.modules}
+	config := ModulesConfig{Modules: m.modules, Layers: m.layers}
 	data, err := yaml.Marshal(&config)
 	if err != nil {
 		return fmt.Errorf("marshaling modules: %w", err)
@@ -170,5 +194,5 @@ func LoadRulesOrEmpty(path string) (*Matcher, error) {
 		return nil, fmt.Errorf("parsing modules file: %w", err)
 	}
 
-	return &Matcher{modules: config.Modules}, nil
+	return &Matcher{modules: config.Modules, layers: config.Layers}, nil
 }
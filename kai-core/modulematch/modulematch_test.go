@@ -186,6 +186,54 @@ func TestSaveAndLoadRules(t *testing.T) {
 	}
 }
 
+func TestSaveRulesPreservesLayers(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "modulematch-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	rulesPath := filepath.Join(tmpDir, "rules", "modules.yaml")
+	content := `modules:
+  - name: UI
+    paths: ["src/ui/**"]
+  - name: DB
+    paths: ["src/db/**"]
+layers:
+  noCycles: true
+  rules:
+    - from: UI
+      deny: [DB]
+`
+	if err := os.MkdirAll(filepath.Dir(rulesPath), 0755); err != nil {
+		t.Fatalf("Failed to create rules dir: %v", err)
+	}
+	if err := os.WriteFile(rulesPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+
+	matcher, err := LoadRules(rulesPath)
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+	matcher.AddModule("API", []string{"src/api/**"})
+	if err := matcher.SaveRules(rulesPath); err != nil {
+		t.Fatalf("SaveRules failed: %v", err)
+	}
+
+	loaded, err := LoadRules(rulesPath)
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+	layers := loaded.GetLayers()
+	if layers == nil || !layers.NoCycles {
+		t.Fatalf("Expected layers with noCycles to survive a save, got %+v", layers)
+	}
+	if len(layers.Rules) != 1 || layers.Rules[0].From != "UI" || layers.Rules[0].Deny[0] != "DB" {
+		t.Errorf("Unexpected layer rules: %+v", layers.Rules)
+	}
+}
+
 func TestDoublestarPatterns(t *testing.T) {
 	modules := []ModuleRule{
 		{Name: "AllJS", Paths: []string{"**/*.js"}},
@@ -0,0 +1,517 @@
+package parse
+
+import (
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ExtractAPI returns the symbols of a file that code outside its package can
+// use: exported Go identifiers, JS/TS exports, public Python names and Rust
+// pub items. Methods are included when their type is, along with the members
+// a caller or implementer depends on - exported struct fields ("field"),
+// interface and required trait methods ("interface_method") and Rust enum
+// variants ("variant").
+func (p *Parser) ExtractAPI(content []byte, lang string) ([]*Symbol, error) {
+	parsed, err := p.Parse(content, lang)
+	if err != nil {
+		return nil, err
+	}
+	root := parsed.Tree.RootNode()
+
+	switch lang {
+	case "go", "golang":
+		return extractGoAPI(root, content), nil
+	case "py", "python":
+		return extractPythonAPI(root, content), nil
+	case "rs", "rust":
+		return extractRustAPI(root, content, ""), nil
+	case "js", "ts", "javascript", "typescript":
+		return extractJSAPI(parsed.Symbols, extractExports(root, content)), nil
+	default:
+		return nil, nil
+	}
+}
+
+// normalizeDecl collapses the whitespace of a declaration so that
+// reformatting does not read as a signature change.
+func normalizeDecl(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// ==================== Go ====================
+
+func extractGoAPI(root *sitter.Node, content []byte) []*Symbol {
+	var symbols []*Symbol
+
+	for i := 0; i < int(root.ChildCount()); i++ {
+		n := root.Child(i)
+		switch n.Type() {
+		case "function_declaration":
+			if sym := extractGoFunction(n, content); sym != nil && isGoExported(sym.Name) {
+				symbols = append(symbols, sym)
+			}
+
+		case "method_declaration":
+			sym := extractGoMethod(n, content)
+			receiver := goReceiverName(n, content)
+			if sym == nil || !isGoExported(receiver) {
+				continue
+			}
+			method := sym.Name[strings.LastIndex(sym.Name, ".")+1:]
+			if !isGoExported(method) {
+				continue
+			}
+			// Pointer and value receivers share a name; the signature tells them apart
+			sym.Name = receiver + "." + method
+			sym.Kind = "method"
+			symbols = append(symbols, sym)
+
+		case "type_declaration":
+			for j := 0; j < int(n.ChildCount()); j++ {
+				spec := n.Child(j)
+				if spec.Type() != "type_spec" && spec.Type() != "type_alias" {
+					continue
+				}
+				sym := extractGoTypeSpec(spec, content)
+				if sym == nil || !isGoExported(sym.Name) {
+					continue
+				}
+				symbols = append(symbols, sym)
+				symbols = append(symbols, extractGoTypeMembers(spec, content, sym.Name)...)
+			}
+
+		case "var_declaration", "const_declaration":
+			for _, sym := range extractGoVarConst(n, content) {
+				if isGoExported(sym.Name) {
+					symbols = append(symbols, sym)
+				}
+			}
+		}
+	}
+
+	return symbols
+}
+
+// goReceiverName returns the type name of a method's receiver without
+// pointer or type parameters: "Buffer" for (b *Buffer) or (l List[T]).
+func goReceiverName(node *sitter.Node, content []byte) string {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		if child.Type() != "parameter_list" {
+			continue
+		}
+		fields := strings.Fields(strings.Trim(child.Content(content), "()"))
+		if len(fields) == 0 {
+			return ""
+		}
+		name := strings.TrimLeft(fields[len(fields)-1], "*")
+		if idx := strings.Index(name, "["); idx >= 0 {
+			name = name[:idx]
+		}
+		return name
+	}
+	return ""
+}
+
+// extractGoTypeMembers returns the exported fields of a struct and the
+// methods of an interface, named "Type.Member".
+func extractGoTypeMembers(spec *sitter.Node, content []byte, typeName string) []*Symbol {
+	var members []*Symbol
+
+	for i := 0; i < int(spec.ChildCount()); i++ {
+		child := spec.Child(i)
+		switch child.Type() {
+		case "struct_type":
+			for j := 0; j < int(child.ChildCount()); j++ {
+				list := child.Child(j)
+				if list.Type() != "field_declaration_list" {
+					continue
+				}
+				for k := 0; k < int(list.ChildCount()); k++ {
+					field := list.Child(k)
+					if field.Type() != "field_declaration" {
+						continue
+					}
+					for _, name := range goFieldNames(field, content) {
+						if isGoExported(name) {
+							members = append(members, &Symbol{
+								Name:      typeName + "." + name,
+								Kind:      "field",
+								Range:     nodeRange(field),
+								Signature: normalizeDecl(field.Content(content)),
+							})
+						}
+					}
+				}
+			}
+
+		case "interface_type":
+			for j := 0; j < int(child.ChildCount()); j++ {
+				elem := child.Child(j)
+				var name string
+				switch elem.Type() {
+				case "method_elem", "method_spec":
+					for k := 0; k < int(elem.ChildCount()); k++ {
+						if elem.Child(k).Type() == "field_identifier" {
+							name = elem.Child(k).Content(content)
+							break
+						}
+					}
+				case "type_elem", "constraint_elem":
+					// Embedded interfaces and type constraints
+					name = normalizeDecl(elem.Content(content))
+				}
+				if name != "" {
+					members = append(members, &Symbol{
+						Name:      typeName + "." + name,
+						Kind:      "interface_method",
+						Range:     nodeRange(elem),
+						Signature: normalizeDecl(elem.Content(content)),
+					})
+				}
+			}
+		}
+	}
+
+	return members
+}
+
+// goFieldNames returns the names a struct field declares. An embedded
+// field is named by its type: "Reader" for io.Reader or *Reader.
+func goFieldNames(field *sitter.Node, content []byte) []string {
+	var names []string
+	var typeName string
+
+	for i := 0; i < int(field.ChildCount()); i++ {
+		child := field.Child(i)
+		switch child.Type() {
+		case "field_identifier":
+			names = append(names, child.Content(content))
+		case "type_identifier", "qualified_type", "generic_type":
+			if typeName == "" {
+				typeName = child.Content(content)
+			}
+		}
+	}
+
+	if len(names) == 0 && typeName != "" {
+		if idx := strings.Index(typeName, "["); idx >= 0 {
+			typeName = typeName[:idx]
+		}
+		names = append(names, typeName[strings.LastIndex(typeName, ".")+1:])
+	}
+	return names
+}
+
+// ==================== Python ====================
+
+var pythonAllRe = regexp.MustCompile(`(?m)^__all__\s*(?::[^=\n]*)?=\s*[\[(]([^\])]*)[\])]`)
+var pythonStringRe = regexp.MustCompile(`["']([^"']+)["']`)
+
+// extractPythonAPI returns the module-level functions, classes and
+// variables of a Python file whose names are public, with the public methods
+// of public classes. When the module declares __all__, only the names it
+// lists are public.
+func extractPythonAPI(root *sitter.Node, content []byte) []*Symbol {
+	var all map[string]bool
+	if m := pythonAllRe.FindSubmatch(content); m != nil {
+		all = make(map[string]bool)
+		for _, s := range pythonStringRe.FindAllSubmatch(m[1], -1) {
+			all[string(s[1])] = true
+		}
+	}
+	public := func(name string) bool {
+		if all != nil {
+			return all[name]
+		}
+		return isPythonPublic(name)
+	}
+
+	var symbols []*Symbol
+	for i := 0; i < int(root.ChildCount()); i++ {
+		n := pythonDefinition(root.Child(i))
+		switch n.Type() {
+		case "function_definition":
+			if sym := extractPythonFunction(n, content, ""); sym != nil && public(sym.Name) {
+				symbols = append(symbols, sym)
+			}
+
+		case "class_definition":
+			sym := extractPythonClass(n, content)
+			if sym == nil || !public(sym.Name) {
+				continue
+			}
+			symbols = append(symbols, sym)
+			for j := 0; j < int(n.ChildCount()); j++ {
+				body := n.Child(j)
+				if body.Type() != "block" {
+					continue
+				}
+				for k := 0; k < int(body.ChildCount()); k++ {
+					def := pythonDefinition(body.Child(k))
+					if def.Type() != "function_definition" {
+						continue
+					}
+					method := extractPythonFunction(def, content, sym.Name)
+					if method != nil && isPythonPublic(method.Name[len(sym.Name)+1:]) {
+						symbols = append(symbols, method)
+					}
+				}
+			}
+
+		case "expression_statement":
+			for j := 0; j < int(n.ChildCount()); j++ {
+				if n.Child(j).Type() != "assignment" {
+					continue
+				}
+				for _, sym := range extractPythonAssignment(n.Child(j), content) {
+					if sym.Name != "__all__" && public(sym.Name) {
+						symbols = append(symbols, sym)
+					}
+				}
+			}
+		}
+	}
+
+	return symbols
+}
+
+// pythonDefinition unwraps a decorated definition.
+func pythonDefinition(n *sitter.Node) *sitter.Node {
+	if n.Type() == "decorated_definition" {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			switch n.Child(i).Type() {
+			case "function_definition", "class_definition":
+				return n.Child(i)
+			}
+		}
+	}
+	return n
+}
+
+// isPythonPublic reports whether a name is public by convention: no leading
+// underscore, or a dunder such as __init__.
+func isPythonPublic(name string) bool {
+	if !strings.HasPrefix(name, "_") {
+		return true
+	}
+	return len(name) > 4 && strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__")
+}
+
+// ==================== Rust ====================
+
+// extractRustAPI returns the pub items of a Rust module body, descending
+// into pub inline modules. Items are named by their path from the file,
+// e.g. "config::Builder::new".
+func extractRustAPI(node *sitter.Node, content []byte, prefix string) []*Symbol {
+	var symbols []*Symbol
+	add := func(sym *Symbol) {
+		if sym != nil {
+			sym.Name = prefix + sym.Name
+			symbols = append(symbols, sym)
+		}
+	}
+
+	// Methods of private types are not reachable, so collect the public ones first
+	publicTypes := make(map[string]bool)
+	for i := 0; i < int(node.ChildCount()); i++ {
+		n := node.Child(i)
+		switch n.Type() {
+		case "struct_item", "enum_item", "type_item":
+			if isRustPublic(n, content) {
+				publicTypes[extractRustItemName(n, content, "type_identifier")] = true
+			}
+		}
+	}
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		n := node.Child(i)
+		if n.Type() == "impl_item" {
+			typeName := rustInherentImplType(n, content)
+			if !publicTypes[typeName] {
+				continue
+			}
+			for _, item := range rustDeclarations(n) {
+				if item.Type() == "function_item" && isRustPublic(item, content) {
+					sym := extractRustFunction(item, content, typeName)
+					if sym != nil {
+						sym.Kind = "method"
+					}
+					add(sym)
+				}
+			}
+			continue
+		}
+		if !isRustPublic(n, content) {
+			continue
+		}
+
+		switch n.Type() {
+		case "function_item":
+			add(extractRustFunction(n, content, ""))
+
+		case "struct_item":
+			sym := extractRustStruct(n, content)
+			add(sym)
+			if sym == nil {
+				continue
+			}
+			for j := 0; j < int(n.ChildCount()); j++ {
+				list := n.Child(j)
+				if list.Type() != "field_declaration_list" {
+					continue
+				}
+				for k := 0; k < int(list.ChildCount()); k++ {
+					field := list.Child(k)
+					if field.Type() == "field_declaration" && isRustPublic(field, content) {
+						symbols = append(symbols, &Symbol{
+							Name:      sym.Name + "." + extractRustItemName(field, content, "field_identifier"),
+							Kind:      "field",
+							Range:     nodeRange(field),
+							Signature: normalizeDecl(field.Content(content)),
+						})
+					}
+				}
+			}
+
+		case "enum_item":
+			sym := extractRustEnum(n, content)
+			add(sym)
+			if sym == nil {
+				continue
+			}
+			for j := 0; j < int(n.ChildCount()); j++ {
+				list := n.Child(j)
+				if list.Type() != "enum_variant_list" {
+					continue
+				}
+				for k := 0; k < int(list.ChildCount()); k++ {
+					variant := list.Child(k)
+					if variant.Type() == "enum_variant" {
+						symbols = append(symbols, &Symbol{
+							Name:      sym.Name + "::" + extractRustItemName(variant, content, "identifier"),
+							Kind:      "variant",
+							Range:     nodeRange(variant),
+							Signature: normalizeDecl(variant.Content(content)),
+						})
+					}
+				}
+			}
+
+		case "trait_item":
+			sym := extractRustTrait(n, content)
+			add(sym)
+			if sym == nil {
+				continue
+			}
+			for _, item := range rustDeclarations(n) {
+				var method *Symbol
+				switch item.Type() {
+				case "function_signature_item":
+					// Implementers must provide methods without a default body
+					if method = extractRustFunction(item, content, sym.Name); method != nil {
+						method.Kind = "interface_method"
+					}
+				case "function_item":
+					if method = extractRustFunction(item, content, sym.Name); method != nil {
+						method.Kind = "method"
+					}
+				}
+				if method != nil {
+					symbols = append(symbols, method)
+				}
+			}
+
+		case "type_item":
+			add(extractRustTypeAlias(n, content))
+		case "const_item":
+			add(extractRustConst(n, content))
+		case "static_item":
+			add(extractRustStatic(n, content))
+
+		case "mod_item":
+			name := extractRustItemName(n, content, "identifier")
+			for j := 0; j < int(n.ChildCount()); j++ {
+				if n.Child(j).Type() == "declaration_list" {
+					symbols = append(symbols, extractRustAPI(n.Child(j), content, prefix+name+"::")...)
+				}
+			}
+		}
+	}
+
+	return symbols
+}
+
+// isRustPublic reports whether an item is visible outside its crate: plain
+// pub, not pub(crate) or pub(super).
+func isRustPublic(node *sitter.Node, content []byte) bool {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		if child.Type() == "visibility_modifier" {
+			return child.Content(content) == "pub"
+		}
+	}
+	return false
+}
+
+// rustInherentImplType returns the type of an inherent impl block, or ""
+// for a trait impl, whose methods belong to the trait.
+func rustInherentImplType(node *sitter.Node, content []byte) string {
+	var typeName string
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "for":
+			return ""
+		case "type_identifier":
+			if typeName == "" {
+				typeName = child.Content(content)
+			}
+		case "generic_type":
+			if typeName == "" {
+				typeName = extractRustItemName(child, content, "type_identifier")
+			}
+		}
+	}
+	return typeName
+}
+
+// rustDeclarations returns the items of an impl or trait body.
+func rustDeclarations(node *sitter.Node) []*sitter.Node {
+	var items []*sitter.Node
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		if child.Type() != "declaration_list" {
+			continue
+		}
+		for j := 0; j < int(child.ChildCount()); j++ {
+			items = append(items, child.Child(j))
+		}
+	}
+	return items
+}
+
+// ==================== JavaScript/TypeScript ====================
+
+// extractJSAPI returns the symbols a module exports, with the methods of
+// exported classes.
+func extractJSAPI(symbols []*Symbol, exports []string) []*Symbol {
+	exported := make(map[string]bool, len(exports))
+	for _, name := range exports {
+		exported[name] = true
+	}
+
+	var api []*Symbol
+	for _, sym := range symbols {
+		owner := sym.Name
+		if idx := strings.Index(owner, "."); idx >= 0 {
+			owner = owner[:idx]
+		}
+		if exported[owner] {
+			api = append(api, sym)
+		}
+	}
+	return api
+}
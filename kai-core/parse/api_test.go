@@ -0,0 +1,157 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func apiSignatures(t *testing.T, src, lang string) map[string]string {
+	t.Helper()
+	symbols, err := NewParser().ExtractAPI([]byte(src), lang)
+	if err != nil {
+		t.Fatalf("ExtractAPI: %v", err)
+	}
+	got := make(map[string]string)
+	for _, sym := range symbols {
+		got[sym.Name] = sym.Kind + ": " + sym.Signature
+	}
+	return got
+}
+
+func TestExtractAPI_Go(t *testing.T) {
+	src := `package store
+
+type Store struct {
+	Path string ` + "`json:\"path\"`" + `
+	mu   sync.Mutex
+	*Cache
+}
+
+type Reader interface {
+	Get(key string) ([]byte, error)
+	io.Closer
+}
+
+type entry struct{ Key string }
+
+const Version = "1"
+var errClosed = errors.New("closed")
+
+func Open(path string) (*Store, error) { return nil, nil }
+func open() {}
+func (s *Store) Get(key string) ([]byte, error) { return nil, nil }
+func (s *Store) flush() {}
+func (e entry) Size() int { return 0 }
+func (l List[T]) Len() int { return 0 }
+type List[T any] []T
+`
+	got := apiSignatures(t, src, "go")
+	want := map[string]string{
+		"Store":            "class: type Store struct",
+		"Store.Path":       "field: Path string `json:\"path\"`",
+		"Store.Cache":      "field: *Cache",
+		"Reader":           "interface: type Reader interface",
+		"Reader.Get":       "interface_method: Get(key string) ([]byte, error)",
+		"Reader.io.Closer": "interface_method: io.Closer",
+		"Version":          "variable: const Version",
+		"Open":             "function: func Open(path string) (*Store, error)",
+		"Store.Get":        "method: func (*Store) Get(key string) ([]byte, error)",
+		"List":             "type: type List",
+		"List.Len":         "method: func (List) Len() int",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractAPI(go) =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestExtractAPI_Python(t *testing.T) {
+	src := `__all__ = ["Client", "connect"]
+
+def connect(url, timeout=10):
+    pass
+
+def helper():
+    pass
+
+class Client:
+    def __init__(self, url):
+        pass
+
+    @property
+    def url(self):
+        pass
+
+    def _retry(self):
+        pass
+
+class Internal:
+    pass
+`
+	got := apiSignatures(t, src, "py")
+	want := map[string]string{
+		"connect":         "function: def connect(url, timeout=10)",
+		"Client":          "class: class Client",
+		"Client.__init__": "function: def __init__(self, url)",
+		"Client.url":      "function: def url(self)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractAPI(py) =\n%v\nwant\n%v", got, want)
+	}
+
+	// Without __all__, names without a leading underscore are public
+	got = apiSignatures(t, "def run():\n    pass\n\ndef _setup():\n    pass\n\nLIMIT = 5\n", "py")
+	if _, ok := got["run"]; !ok || len(got) != 2 {
+		t.Errorf("ExtractAPI(py) without __all__ = %v", got)
+	}
+}
+
+func TestExtractAPI_Rust(t *testing.T) {
+	src := `pub struct Config { pub name: String, secret: String }
+struct Hidden;
+pub enum Mode { Fast, Slow(u32) }
+pub trait Store { fn get(&self) -> u32; fn describe(&self) -> String { String::new() } }
+impl Config { pub fn new() -> Config { todo!() } fn check(&self) {} }
+impl Hidden { pub fn leak() {} }
+impl Default for Config { fn default() -> Self { todo!() } }
+pub(crate) fn internal() {}
+pub fn run() {}
+pub mod io { pub fn read() {} fn private() {} }
+`
+	got := apiSignatures(t, src, "rust")
+	want := map[string]string{
+		"Config":          "class: struct Config",
+		"Config.name":     "field: pub name: String",
+		"Mode":            "class: enum Mode",
+		"Mode::Fast":      "variant: Fast",
+		"Mode::Slow":      "variant: Slow(u32)",
+		"Store":           "type: trait Store",
+		"Store::get":      "interface_method: fn get(&self) -> u32",
+		"Store::describe": "method: fn describe(&self) -> String",
+		"Config::new":     "method: fn new() -> Config",
+		"run":             "function: fn run()",
+		"io::read":        "function: fn read()",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractAPI(rust) =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestExtractAPI_JS(t *testing.T) {
+	src := `export function parse(input, options) {}
+function helper() {}
+export class Lexer {
+  next() {}
+}
+const VERSION = "1.0";
+export { VERSION };
+`
+	got := apiSignatures(t, src, "js")
+	for _, name := range []string{"parse", "Lexer", "Lexer.next", "VERSION"} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("expected %s in the API, got %v", name, got)
+		}
+	}
+	if _, ok := got["helper"]; ok {
+		t.Errorf("unexported helper in the API: %v", got)
+	}
+}
@@ -61,8 +61,12 @@ func (p *Parser) ExtractCalls(content []byte, lang string) (*ParsedCalls, error)
 		result.Imports = extractRustImports(root, content)
 		result.Calls = extractRustCallSites(root, content)
 		result.Exports = extractRustExports(root, content)
+	case "py", "python":
+		result.Imports = extractImports(root, content)
+		result.Calls = extractPythonCallSites(root, content)
+		result.Exports = extractExports(root, content)
 	default:
-		// JavaScript/TypeScript/Python
+		// JavaScript/TypeScript
 		result.Imports = extractImports(root, content)
 		result.Calls = extractCallSites(root, content)
 		result.Exports = extractExports(root, content)
@@ -966,6 +970,46 @@ func parseGoSelectorExpression(node *sitter.Node, content []byte, call *CallSite
 	}
 }
 
+// extractPythonCallSites finds all calls in a Python AST.
+func extractPythonCallSites(node *sitter.Node, content []byte) []*CallSite {
+	var calls []*CallSite
+
+	iter := sitter.NewIterator(node, sitter.DFSMode)
+	for {
+		n, err := iter.Next()
+		if err != nil || n == nil {
+			break
+		}
+		if n.Type() != "call" {
+			continue
+		}
+
+		callee := n.ChildByFieldName("function")
+		if callee == nil {
+			continue
+		}
+		call := &CallSite{Range: nodeRange(n)}
+		switch callee.Type() {
+		case "identifier":
+			call.CalleeName = callee.Content(content)
+		case "attribute":
+			// obj.method() or module.func()
+			call.IsMethodCall = true
+			if obj := callee.ChildByFieldName("object"); obj != nil {
+				call.CalleeObject = obj.Content(content)
+			}
+			if attr := callee.ChildByFieldName("attribute"); attr != nil {
+				call.CalleeName = attr.Content(content)
+			}
+		}
+		if call.CalleeName != "" {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
 // extractGoExports finds exported symbols in Go source.
 // In Go, exported symbols are those starting with uppercase letter.
 func extractGoExports(node *sitter.Node, content []byte) []string {
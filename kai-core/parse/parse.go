@@ -655,7 +655,12 @@ func extractGoFunction(node *sitter.Node, content []byte) *Symbol {
 				name = child.Content(content)
 			}
 		case "parameter_list":
-			params = child.Content(content)
+			if params == "" {
+				params = child.Content(content)
+			} else {
+				// Second parameter_list is the results
+				result = child.Content(content)
+			}
 		case "type_identifier", "pointer_type", "slice_type", "map_type", "channel_type", "qualified_type":
 			result = child.Content(content)
 		}
@@ -683,15 +688,17 @@ func extractGoMethod(node *sitter.Node, content []byte) *Symbol {
 	var receiver string
 	var params string
 	var result string
+	lists := 0
 
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
 		switch child.Type() {
 		case "parameter_list":
-			if receiver == "" {
+			lists++
+			if lists == 1 {
 				// First parameter_list is the receiver
 				receiver = extractGoReceiverType(child, content)
-			} else if params == "" {
+			} else if lists == 2 {
 				// Second parameter_list is the params
 				params = child.Content(content)
 			} else {
@@ -743,6 +750,9 @@ func extractGoReceiverType(paramList *sitter.Node, content []byte) string {
 				switch typeChild.Type() {
 				case "type_identifier":
 					return typeChild.Content(content)
+				case "generic_type":
+					// List[T]
+					return typeChild.Child(0).Content(content)
 				case "pointer_type":
 					// Extract the base type from pointer
 					for k := 0; k < int(typeChild.ChildCount()); k++ {
@@ -1291,7 +1301,8 @@ func extractRustFunction(node *sitter.Node, content []byte, implType string) *Sy
 		case "parameters":
 			params = child.Content(content)
 		case "type_identifier", "generic_type", "reference_type", "pointer_type",
-			"array_type", "tuple_type", "unit_type", "scoped_type_identifier":
+			"array_type", "tuple_type", "unit_type", "scoped_type_identifier",
+			"primitive_type", "abstract_type", "dynamic_type":
 			returnType = child.Content(content)
 		}
 	}
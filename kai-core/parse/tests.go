@@ -0,0 +1,202 @@
+package parse
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Test frameworks recognised by ExtractTestCases.
+const (
+	FrameworkGo     = "go"
+	FrameworkPytest = "pytest"
+	FrameworkJest   = "jest"
+)
+
+// TestCase is a single runnable test inside a test file.
+type TestCase struct {
+	// Name is the name the test runner knows the case by: "TestAdd" for Go,
+	// "TestCart::test_total" for pytest, "cart adds items" (describe and it
+	// titles joined by spaces) for jest.
+	Name      string `json:"name"`
+	Framework string `json:"framework"`
+	Range     Range  `json:"range"`
+}
+
+// ExtractTestCases finds Go TestXxx functions, pytest test_* functions and
+// methods of Test* classes, and jest/mocha it(...)/test(...) blocks.
+func (p *Parser) ExtractTestCases(content []byte, lang string) ([]*TestCase, error) {
+	parsed, err := p.Parse(content, lang)
+	if err != nil {
+		return nil, err
+	}
+	root := parsed.Tree.RootNode()
+
+	switch lang {
+	case "go", "golang":
+		return extractGoTestCases(root, content), nil
+	case "py", "python":
+		return extractPythonTestCases(root, content), nil
+	case "js", "ts", "javascript", "typescript":
+		return extractJSTestCases(root, content), nil
+	default:
+		return nil, nil
+	}
+}
+
+// extractGoTestCases returns top-level TestXxx functions. TestMain is the
+// package's test entry point, not a test, and is skipped.
+func extractGoTestCases(root *sitter.Node, content []byte) []*TestCase {
+	var cases []*TestCase
+	for i := 0; i < int(root.ChildCount()); i++ {
+		n := root.Child(i)
+		if n.Type() != "function_declaration" {
+			continue
+		}
+		name := ""
+		if id := n.ChildByFieldName("name"); id != nil {
+			name = id.Content(content)
+		}
+		if isGoTestName(name) {
+			cases = append(cases, &TestCase{Name: name, Framework: FrameworkGo, Range: nodeRange(n)})
+		}
+	}
+	return cases
+}
+
+// isGoTestName reports whether name follows go test's TestXxx convention:
+// "Test" followed by nothing or a character that is not a lowercase letter.
+func isGoTestName(name string) bool {
+	if !strings.HasPrefix(name, "Test") || name == "TestMain" {
+		return false
+	}
+	if len(name) == 4 {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[4:])
+	return !unicode.IsLower(r)
+}
+
+// extractPythonTestCases returns module-level test_* functions and test_*
+// methods of Test* classes, named as pytest node IDs relative to the file.
+func extractPythonTestCases(root *sitter.Node, content []byte) []*TestCase {
+	var cases []*TestCase
+	for i := 0; i < int(root.ChildCount()); i++ {
+		n := unwrapDecorated(root.Child(i))
+		switch n.Type() {
+		case "function_definition":
+			if name := pythonDefName(n, content); strings.HasPrefix(name, "test") {
+				cases = append(cases, &TestCase{Name: name, Framework: FrameworkPytest, Range: nodeRange(n)})
+			}
+		case "class_definition":
+			className := pythonDefName(n, content)
+			body := n.ChildByFieldName("body")
+			if !strings.HasPrefix(className, "Test") || body == nil {
+				continue
+			}
+			for j := 0; j < int(body.ChildCount()); j++ {
+				m := unwrapDecorated(body.Child(j))
+				if m.Type() != "function_definition" {
+					continue
+				}
+				if name := pythonDefName(m, content); strings.HasPrefix(name, "test") {
+					cases = append(cases, &TestCase{Name: className + "::" + name, Framework: FrameworkPytest, Range: nodeRange(m)})
+				}
+			}
+		}
+	}
+	return cases
+}
+
+func unwrapDecorated(n *sitter.Node) *sitter.Node {
+	if n.Type() == "decorated_definition" {
+		if def := n.ChildByFieldName("definition"); def != nil {
+			return def
+		}
+	}
+	return n
+}
+
+func pythonDefName(n *sitter.Node, content []byte) string {
+	if id := n.ChildByFieldName("name"); id != nil {
+		return id.Content(content)
+	}
+	return ""
+}
+
+// extractJSTestCases returns it(...) and test(...) blocks (including .only),
+// prefixed with the titles of their enclosing describe(...) blocks.
+func extractJSTestCases(root *sitter.Node, content []byte) []*TestCase {
+	var cases []*TestCase
+
+	iter := sitter.NewIterator(root, sitter.DFSMode)
+	for {
+		n, err := iter.Next()
+		if err != nil || n == nil {
+			break
+		}
+		if n.Type() != "call_expression" {
+			continue
+		}
+		fn, title := jsTestCall(n, content)
+		if fn != "it" && fn != "test" {
+			continue
+		}
+
+		names := []string{title}
+		for p := n.Parent(); p != nil; p = p.Parent() {
+			if p.Type() != "call_expression" {
+				continue
+			}
+			if pfn, ptitle := jsTestCall(p, content); pfn == "describe" {
+				names = append([]string{ptitle}, names...)
+			}
+		}
+		cases = append(cases, &TestCase{
+			Name:      strings.Join(names, " "),
+			Framework: FrameworkJest,
+			Range:     nodeRange(n),
+		})
+	}
+	return cases
+}
+
+// jsTestCall returns the test function ("it", "test" or "describe") and title
+// of a call like it("title", fn) or describe.only("title", fn). Skipped and
+// todo blocks, and calls without a literal title, return "".
+func jsTestCall(n *sitter.Node, content []byte) (string, string) {
+	callee := n.ChildByFieldName("function")
+	args := n.ChildByFieldName("arguments")
+	if callee == nil || args == nil || args.NamedChildCount() == 0 {
+		return "", ""
+	}
+
+	fn := ""
+	switch callee.Type() {
+	case "identifier":
+		fn = callee.Content(content)
+	case "member_expression":
+		obj := callee.ChildByFieldName("object")
+		prop := callee.ChildByFieldName("property")
+		if obj == nil || prop == nil || obj.Type() != "identifier" || prop.Content(content) != "only" {
+			return "", ""
+		}
+		fn = obj.Content(content)
+	default:
+		return "", ""
+	}
+	if fn != "it" && fn != "test" && fn != "describe" {
+		return "", ""
+	}
+
+	arg := args.NamedChild(0)
+	switch arg.Type() {
+	case "string":
+		return fn, strings.Trim(arg.Content(content), `"'`)
+	case "template_string":
+		return fn, strings.Trim(arg.Content(content), "`")
+	}
+	return "", ""
+}
@@ -0,0 +1,129 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+func caseNames(cases []*TestCase) string {
+	var names []string
+	for _, c := range cases {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestExtractTestCases_Go(t *testing.T) {
+	code := []byte(`package cart
+
+import "testing"
+
+func TestMain(m *testing.M) {}
+
+func TestAdd(t *testing.T) {
+	if Add(1, 2) != 3 {
+		t.Fatal("bad")
+	}
+}
+
+func TestTotal_Empty(t *testing.T) {}
+
+func Testify() {}
+
+func helper(t *testing.T) {}
+`)
+
+	cases, err := NewParser().ExtractTestCases(code, "go")
+	if err != nil {
+		t.Fatalf("ExtractTestCases failed: %v", err)
+	}
+	if got := caseNames(cases); got != "TestAdd,TestTotal_Empty" {
+		t.Errorf("cases = %s", got)
+	}
+	if cases[0].Framework != FrameworkGo || cases[0].Range.Start[0] != 6 || cases[0].Range.End[0] != 10 {
+		t.Errorf("unexpected case: %+v", cases[0])
+	}
+}
+
+func TestExtractTestCases_Pytest(t *testing.T) {
+	code := []byte(`import pytest
+from app.cart import total
+
+def test_total():
+    assert total([]) == 0
+
+@pytest.mark.slow
+def test_big():
+    pass
+
+def helper():
+    pass
+
+class TestCart:
+    def test_add(self):
+        pass
+
+    def setup_method(self):
+        pass
+
+class Helper:
+    def test_not_collected(self):
+        pass
+`)
+
+	cases, err := NewParser().ExtractTestCases(code, "py")
+	if err != nil {
+		t.Fatalf("ExtractTestCases failed: %v", err)
+	}
+	if got := caseNames(cases); got != "test_total,test_big,TestCart::test_add" {
+		t.Errorf("cases = %s", got)
+	}
+}
+
+func TestExtractTestCases_Jest(t *testing.T) {
+	code := []byte(`const { add } = require('../src/math');
+
+describe('math', () => {
+  describe("add", () => {
+    it('sums numbers', () => {
+      expect(add(1, 2)).toBe(3);
+    });
+    it.skip('skipped', () => {});
+  });
+
+  test.only(` + "`multiplies`" + `, () => {});
+});
+
+test('top level', () => {});
+`)
+
+	cases, err := NewParser().ExtractTestCases(code, "js")
+	if err != nil {
+		t.Fatalf("ExtractTestCases failed: %v", err)
+	}
+	if got := caseNames(cases); got != "math add sums numbers,math multiplies,top level" {
+		t.Errorf("cases = %s", got)
+	}
+	if cases[0].Framework != FrameworkJest {
+		t.Errorf("framework = %s", cases[0].Framework)
+	}
+}
+
+func TestExtractCalls_Python(t *testing.T) {
+	code := []byte(`def test_total():
+    cart = Cart()
+    assert cart.total() == compute(1)
+`)
+
+	result, err := NewParser().ExtractCalls(code, "py")
+	if err != nil {
+		t.Fatalf("ExtractCalls failed: %v", err)
+	}
+	var names []string
+	for _, c := range result.Calls {
+		names = append(names, c.CalleeName)
+	}
+	if got := strings.Join(names, ","); got != "Cart,total,compute" {
+		t.Errorf("calls = %s", got)
+	}
+}
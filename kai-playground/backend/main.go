@@ -338,11 +338,28 @@ func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Run kai dump to get graph data
-	// For now, just return snapshots and refs
-	output, _ := s.sm.RunCommand(session, "kai list snapshots --json")
+	// Export the latest snapshot's subgraph (?format=jsonl|dot|graphml)
+	format := r.URL.Query().Get("format")
+	contentType := "application/x-ndjson"
+	switch format {
+	case "", "jsonl":
+		format = "jsonl"
+	case "dot":
+		contentType = "text/vnd.graphviz"
+	case "graphml":
+		contentType = "application/xml"
+	default:
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
+	output, err := s.sm.RunCommand(session, "kai graph export --snapshot @snap:last --format "+format)
+	if err != nil {
+		http.Error(w, output, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
 	w.Write([]byte(output))
 }
 